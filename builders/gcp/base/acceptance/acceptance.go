@@ -49,4 +49,5 @@ const (
 	rubyRuntime     = "google.ruby.runtime"
 	rubyBundle      = "google.ruby.bundle"
 	rubyRails       = "google.ruby.rails"
+	utilsAdditional = "google.utils.additional-buildpacks"
 )
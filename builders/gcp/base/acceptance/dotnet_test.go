@@ -76,6 +76,8 @@ func TestAcceptanceDotNet(t *testing.T) {
 			MustUse:             []string{dotnetSDK, dotnetRuntime, dotnetPublish},
 			FilesMustExist:      []string{sdk, "/workspace/Startup.cs"},
 			MustRebuildOnChange: "/workspace/Startup.cs",
+			// In dev mode the SDK is used at launch time.
+			MustHaveEnv: map[string]string{"DOTNET_ROOT": sdk, "DOTNET_RUNNING_IN_CONTAINER": "true"},
 		},
 		{
 			// This is a separate test case from Dev mode above because it has a fixed runtime version.
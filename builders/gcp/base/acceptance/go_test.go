@@ -43,6 +43,39 @@ func TestAcceptanceGo(t *testing.T) {
 			MustNotUse: []string{goPath},
 			MustOutput: []string{"Using latest runtime version:"},
 		},
+		{
+			Name:     "Go.mod with the google.min.22 run image",
+			App:      "simple_gomod",
+			RunImage: "gcr.io/gae-runtimes/buildpacks/stacks/google-min-22/run",
+			MustUse:  []string{goRuntime, goBuild, goMod},
+		},
+		{
+			Name:    "Go with a Node.js frontend",
+			App:     "with_frontend",
+			Env:     []string{"GOOGLE_ADDITIONAL_BUILDPACKS=nodejs"},
+			MustUse: []string{utilsAdditional, nodeRuntime, nodeNPM, goRuntime, goMod, goBuild},
+			// The frontend built by npm and the binary built by go coexist, and the Go binary is the entrypoint.
+			FilesMustExist: []string{"/workspace/public/index.txt", "/layers/google.go.build/bin/main", "/layers/google.nodejs.runtime/node/bin/node"},
+			FileContains:   map[string][]string{"/workspace/public/index.txt": {"^PASS$"}},
+		},
+		{
+			Name: "Go with a Node.js frontend built with Yarn",
+			App:  "with_frontend_yarn",
+			// GOOGLE_RUNTIME_VERSION selects the Go version and is not used for Node.js.
+			Env:            []string{"GOOGLE_ADDITIONAL_BUILDPACKS=nodejs", "GOOGLE_RUNTIME_VERSION=1.16.4"},
+			MustUse:        []string{utilsAdditional, nodeRuntime, nodeYarn, goRuntime, goMod, goBuild},
+			MustNotUse:     []string{nodeNPM},
+			FilesMustExist: []string{"/workspace/public/index.txt", "/layers/google.go.build/bin/main", "/layers/google.nodejs.runtime/node/bin/node"},
+			FileContains:   map[string][]string{"/workspace/public/index.txt": {"^PASS$"}},
+		},
+		{
+			Name:       "Go.mod without additional buildpacks ignores package.json",
+			App:        "with_frontend",
+			MustUse:    []string{goRuntime, goMod, goBuild},
+			MustNotUse: []string{utilsAdditional, nodeRuntime, nodeNPM},
+			// The frontend is not built, so the Go application fails to read it.
+			MustMatch: "no such file or directory",
+		},
 		{
 			Name:       "Go.mod package",
 			App:        "gomod_package",
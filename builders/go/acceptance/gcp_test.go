@@ -126,6 +126,31 @@ func TestAcceptance(t *testing.T) {
 	}
 }
 
+func TestAcceptanceARM64(t *testing.T) {
+	builderImage, runImage, cleanup := acceptance.ProvisionImagesForArch(t, acceptance.ArchARM64)
+	t.Cleanup(cleanup)
+
+	testCases := []acceptance.Test{
+		{
+			Name: "simple Go application on arm64",
+			// This test only runs against a single version of Go as it is unlikely to break across versions.
+			VersionInclusionConstraint: "1.16",
+			App:                        "simple",
+			Arch:                       acceptance.ArchARM64,
+			MustUse:                    []string{goRuntime, goBuild, goPath},
+			FilesMustExist:             []string{"/layers/google.go.build/bin/main"},
+		},
+	}
+
+	for _, tc := range acceptance.FilterTests(t, testCases) {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			acceptance.TestApp(t, builderImage, runImage, tc)
+		})
+	}
+}
+
 func TestFailures(t *testing.T) {
 	builderImage, runImage, cleanup := acceptance.ProvisionImages(t)
 	t.Cleanup(cleanup)
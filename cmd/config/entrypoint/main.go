@@ -19,19 +19,14 @@ package main
 import (
 	"fmt"
 	"os"
-	"regexp"
 	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/appengine"
-	"github.com/GoogleCloudPlatform/buildpacks/pkg/appyaml"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/entrypoint"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 )
 
-var (
-	processRe = regexp.MustCompile(`(?m)^(\w+):\s*(.+)$`)
-)
-
 func main() {
 	gcp.Main(detectFn, buildFn)
 }
@@ -43,18 +38,23 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 	}
 
 	// Detection for GCP builds follows
-	if os.Getenv(env.Entrypoint) != "" {
-		return gcp.OptInEnvSet(env.Entrypoint), nil
+	if env.IsJob() {
+		return gcp.OptInEnvSet(env.AppType), nil
 	}
-	procExists, err := ctx.FileExists("Procfile")
+	ep, err := entrypoint.ResolveFromSources(ctx)
 	if err != nil {
+		// An app.yaml without a valid entrypoint leaves the entrypoint to the language buildpacks.
+		if ep != nil && ep.Source == entrypoint.SourceAppYAML {
+			return gcp.OptOut(fmt.Sprintf("no valid entrypoint in app.yaml: %v", err)), nil
+		}
 		return nil, err
 	}
-	if procExists {
-		return gcp.OptInFileFound("Procfile"), nil
-	}
-	if entrypoint, _ := appyaml.EntrypointIfExists(ctx.ApplicationRoot()); entrypoint != "" {
-		ctx.Logf("Using entrypoint from app.yaml.")
+	switch ep.Source {
+	case entrypoint.SourceEnv:
+		return gcp.OptInEnvSet(env.Entrypoint), nil
+	case entrypoint.SourceProcfile:
+		return gcp.OptInFileFound(entrypoint.Procfile), nil
+	case entrypoint.SourceAppYAML:
 		return gcp.OptIn("Found the app.yaml file specified by GAE_APPLICATION_YAML_PATH."), nil
 	}
 	return gcp.OptOut(fmt.Sprintf(
@@ -66,6 +66,12 @@ func buildFn(ctx *gcp.Context) error {
 		// Function Frameworks with the function target will automatically build correctly without entrypoint modification.
 		return nil
 	}
+	if ep := os.Getenv(env.Entrypoint); ep != "" {
+		if err := entrypoint.ValidateEntrypoint(ep); err != nil {
+			return err
+		}
+		entrypoint.WarnIfNotOnPath(ctx, ep)
+	}
 	if env.IsGAE() {
 		runtime, ok := os.LookupEnv(env.Runtime)
 		if !ok {
@@ -74,42 +80,45 @@ func buildFn(ctx *gcp.Context) error {
 		return appengine.Build(ctx, runtime, nil)
 	}
 
-	if entrypoint := os.Getenv(env.Entrypoint); entrypoint != "" {
-		ctx.AddProcess(gcp.WebProcess, []string{entrypoint}, gcp.AsDefaultProcess())
-		ctx.Logf("Using entrypoint from environment variable %s: %s", env.Entrypoint, entrypoint)
-		return nil
+	appType, err := env.GetAppType()
+	if err != nil {
+		return gcp.UserErrorf("%v", err)
+	}
+	if appType == env.AppTypeJob {
+		return addJobProcess(ctx)
 	}
 
-	procExists, err := ctx.FileExists("Procfile")
+	ep, err := entrypoint.ResolveFromSources(ctx)
 	if err != nil {
 		return err
 	}
-	if procExists {
-		b, err := ctx.ReadFile("Procfile")
-		if err != nil {
+	switch ep.Source {
+	case entrypoint.SourceProcfile:
+		return addProcfileProcesses(ctx, ep.Procfile)
+	case entrypoint.SourceEnv, entrypoint.SourceAppYAML:
+		if err := entrypoint.ValidateScript(ctx, ep.Command); err != nil {
 			return err
 		}
-		return addProcfileProcesses(ctx, string(b))
-	}
-
-	entrypoint, err := appyaml.EntrypointIfExists(ctx.ApplicationRoot())
-	if err != nil {
-		return gcp.UserErrorf(fmt.Sprintf(
-			"app.yaml env var set but the specified app.yaml file doesn't exist."))
-	}
-	if entrypoint != "" {
-		ctx.AddProcess(gcp.WebProcess, []string{entrypoint}, gcp.AsDefaultProcess())
-		ctx.Logf("Using entrypoint from app.yaml.")
-		return nil
+		return ctx.AddProcess(gcp.WebProcess, []string{ep.Command}, gcp.AsDefaultProcess())
 	}
 
 	return gcp.UserErrorf(fmt.Sprintf(
 		"%s not set, no valid entrypoint config in Procfile or app.yaml.", env.Entrypoint))
 }
 
+// addJobProcess adds the GOOGLE_JOB_COMMAND as the default job process, which is run by a shell.
+func addJobProcess(ctx *gcp.Context) error {
+	command := strings.TrimSpace(os.Getenv(env.JobCommand))
+	if command == "" {
+		return gcp.UserErrorf("%s must be set when %s is %s", env.JobCommand, env.AppType, env.AppTypeJob)
+	}
+	ctx.Logf("Using job command from %s: %s", env.JobCommand, command)
+	return ctx.AddProcess(gcp.JobProcess, []string{command}, gcp.AsDefaultProcess())
+}
+
 // addProcfileProcesses adds all processes from the given Procfile contents.
 func addProcfileProcesses(ctx *gcp.Context, content string) error {
-	matches := processRe.FindAllStringSubmatch(content, -1)
+	matches := entrypoint.ProcessRe.FindAllStringSubmatch(content, -1)
 	if len(matches) == 0 {
 		return gcp.UserErrorf("did not find any processes in Procfile")
 	}
@@ -127,12 +136,18 @@ func addProcfileProcesses(ctx *gcp.Context, content string) error {
 			continue
 		}
 		found[name] = true
+		if err := entrypoint.ValidateScript(ctx, command); err != nil {
+			return err
+		}
 
+		var err error
 		if name == gcp.WebProcess {
-			ctx.Logf("Using entrypoint from Procfile: %s", command)
-			ctx.AddProcess(name, []string{command}, gcp.AsDefaultProcess())
+			err = ctx.AddProcess(name, []string{command}, gcp.AsDefaultProcess())
 		} else {
-			ctx.AddProcess(name, []string{command})
+			err = ctx.AddProcess(name, []string{command})
+		}
+		if err != nil {
+			return err
 		}
 	}
 
@@ -69,6 +69,18 @@ func TestDetect(t *testing.T) {
 			name: "without GOOGLE_ENTRYPOINT, Procfile or app.yaml",
 			want: 100,
 		},
+		{
+			name: "with Procfile, but an empty command",
+			files: map[string]string{
+				"Procfile": "web:\nworker: my worker",
+			},
+			want: 1,
+		},
+		{
+			name: "job",
+			env:  []string{"GOOGLE_APP_TYPE=job", "GOOGLE_JOB_COMMAND=python3 task.py"},
+			want: 0,
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -218,3 +230,63 @@ func TestAddProcfileWebProcessesError(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildJob(t *testing.T) {
+	testCases := []struct {
+		name    string
+		appType string
+		command string
+		want    []libcnb.Process
+		wantErr bool
+	}{
+		{
+			name:    "job process",
+			appType: "job",
+			command: "python3 task.py --all",
+			want: []libcnb.Process{
+				{Type: "job", Command: "python3 task.py --all", Default: true},
+			},
+		},
+		{
+			name:    "job without command",
+			appType: "job",
+			wantErr: true,
+		},
+		{
+			name:    "invalid app type",
+			appType: "cron",
+			command: "python3 task.py",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("GOOGLE_APP_TYPE", tc.appType)
+			t.Setenv("GOOGLE_JOB_COMMAND", tc.command)
+			t.Setenv("GOOGLE_ENTRYPOINT", "gunicorn -b :$PORT main:app")
+			t.Setenv("X_GOOGLE_TARGET_PLATFORM", "")
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(t.TempDir()))
+
+			err := buildFn(ctx)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("buildFn() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if got := ctx.Processes(); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("buildFn() processes = %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBuildInvalidEntrypoint(t *testing.T) {
+	t.Setenv("GOOGLE_ENTRYPOINT", `gunicorn -b :$PORT "main:app`)
+	t.Setenv("X_GOOGLE_TARGET_PLATFORM", "")
+	ctx := gcp.NewContext(gcp.WithApplicationRoot(t.TempDir()))
+
+	if err := buildFn(ctx); err == nil {
+		t.Fatal("buildFn() got no error, want error for unbalanced quote")
+	}
+	if got := ctx.Processes(); len(got) != 0 {
+		t.Errorf("buildFn() processes = %#v, want none", got)
+	}
+}
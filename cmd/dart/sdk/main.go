@@ -73,6 +73,7 @@ func buildFn(ctx *gcp.Context) error {
 		Metadata: map[string]interface{}{"version": version},
 		Build:    true,
 	})
+	ctx.RecordVersion(gcp.VersionCategoryRuntime, "dart", version)
 
 	if runtime.IsCached(ctx, drl, version) {
 		ctx.CacheHit(dartLayer)
@@ -21,8 +21,11 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/builddb"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/dotnet"
@@ -36,6 +39,25 @@ const (
 	dependencyHashKey = "dependency_hash"
 	versionKey        = "version"
 	outputDirectory   = "bin"
+	packagesLayerName = "packages"
+	// nugetConfigDir holds the NuGet.config with expanded credentials, it is removed when the
+	// buildpack finishes.
+	nugetConfigDir = "nuget-config"
+
+	// restoreAttempts and restoreBackoff configure the retries of dotnet restore on network failures.
+	restoreAttempts = 3
+	restoreBackoff  = 2 * time.Second
+)
+
+var (
+	// packagesMaxBytes is the size above which the packages layer is cleared before restore. The
+	// packages are not removed individually, as NuGet considers a package complete by its metadata.
+	packagesMaxBytes int64 = 2 << 30
+
+	// restoreNetworkErrors are printed by dotnet restore when a package source cannot be reached.
+	restoreNetworkErrors = []string{"NU1301", "Unable to load the service index", "An error occurred while sending the request"}
+	// restoreAuthErrors are printed by dotnet restore when a package source denies access.
+	restoreAuthErrors = []string{"401 (Unauthorized)", "403 (Forbidden)"}
 )
 
 func main() {
@@ -59,12 +81,49 @@ func buildFn(ctx *gcp.Context) error {
 		return fmt.Errorf("finding project: %w", err)
 	}
 	ctx.Logf("Installing application dependencies.")
-	pkgLayer, err := ctx.Layer("packages", gcp.BuildLayer, gcp.CacheLayer)
+	pkgLayer, err := packagesLayer(ctx)
 	if err != nil {
-		return fmt.Errorf("creating layer: %w", err)
+		return err
+	}
+
+	p, err := dotnet.ReadProjectFile(ctx, proj)
+	if err != nil {
+		return fmt.Errorf("reading project at %q: %w", proj, err)
+	}
+	selfContained, err := dotnet.IsSelfContained(p)
+	if err != nil {
+		return err
+	}
+	singleFile, err := dotnet.IsSingleFile()
+	if err != nil {
+		return err
+	}
+	blazorWasm, err := dotnet.IsBlazorWasm(proj)
+	if err != nil {
+		return err
+	}
+	web, err := dotnet.IsWebApp(ctx, p)
+	if err != nil {
+		return err
+	}
+	// Framework-dependent apps are published portably unless a runtime identifier is requested, or
+	// they are published as a single file, which is specific to a runtime.
+	var ridArgs []string
+	if selfContained || singleFile || dotnet.RequestedRuntimeIdentifier() != "" {
+		rid, err := dotnet.RuntimeIdentifier(ctx)
+		if err != nil {
+			return err
+		}
+		ctx.Logf("Publishing application for runtime %s (self-contained: %t, single file: %t).", rid, selfContained, singleFile)
+		ridArgs = []string{"--runtime", rid}
+	}
+
+	nugetConfig, err := dotnet.FindNuGetConfig(ctx.ApplicationRoot(), proj)
+	if err != nil {
+		return fmt.Errorf("finding NuGet.config: %w", err)
 	}
 
-	cached, err := checkCache(ctx, pkgLayer)
+	cached, err := checkCache(ctx, pkgLayer, nugetConfig)
 	if err != nil {
 		return fmt.Errorf("checking cache: %w", err)
 	}
@@ -75,26 +134,37 @@ func buildFn(ctx *gcp.Context) error {
 		ctx.CacheMiss(cacheTag)
 	}
 
+	if err := dotnet.RestoreTools(ctx); err != nil {
+		return err
+	}
+
+	verbosity, err := dotnet.VerbosityFlag(os.Getenv(dotnet.VerbosityEnv))
+	if err != nil {
+		return err
+	}
+
 	// Run restore regardless of cache status because it generates files expected by publish.
-	cmd := []string{"dotnet", "restore", "--packages", pkgLayer.Path, proj}
-	ctx.Exec(cmd, gcp.WithEnv("DOTNET_CLI_TELEMETRY_OPTOUT=true"), gcp.WithUserAttribution)
+	cmd := append([]string{"dotnet", "restore", "--packages", pkgLayer.Path}, verbosity...)
+	cmd = append(cmd, ridArgs...)
+	configArgs, configRefs, err := nugetConfigArgs(ctx, nugetConfig)
+	if err != nil {
+		return err
+	}
+	cmd = append(cmd, configArgs...)
+	cmd = append(cmd, proj)
+	if result, err := ctx.ExecWithRetry(cmd, restoreAttempts, restoreBackoff, gcp.WithEnv(packagesEnv(pkgLayer)...), gcp.WithRetryIf(isRestoreNetworkError), gcp.WithUserAttribution); err != nil {
+		if result != nil && isRestoreAuthError(result) {
+			err.Message = err.Message + "\n" + nugetAuthHint(nugetConfig, configRefs)
+		}
+		return err
+	}
 
 	binLayer, err := ctx.Layer("bin", gcp.BuildLayer, gcp.LaunchLayer)
 	if err != nil {
 		return fmt.Errorf("creating layer: %w", err)
 	}
 
-	cmd = []string{
-		"dotnet",
-		"publish",
-		"-nologo",
-		"--verbosity", "minimal",
-		"--configuration", "Release",
-		"--output", outputDirectory,
-		"--no-restore",
-		"--packages", pkgLayer.Path,
-		proj,
-	}
+	cmd = publishCommand(proj, pkgLayer.Path, verbosity, ridArgs, selfContained, singleFile)
 
 	if args := os.Getenv(env.BuildArgs); args != "" {
 		// Use bash to excute the command to avoid havnig to parse the build arguments.
@@ -102,21 +172,52 @@ func buildFn(ctx *gcp.Context) error {
 		cmd = []string{"/bin/bash", "-c", strings.Join(append(cmd, args), " ")}
 	}
 
-	ctx.Exec(cmd, gcp.WithEnv("DOTNET_CLI_TELEMETRY_OPTOUT=true"), gcp.WithUserAttribution)
+	// The database is available to MSBuild targets of the project, e.g. to bundle Entity Framework
+	// migrations with dotnet-ef.
+	db, err := builddb.ProvisionFromEnv(ctx)
+	if err != nil {
+		return err
+	}
+	ctx.Exec(cmd, append([]gcp.ExecOption{gcp.WithEnv(packagesEnv(pkgLayer)...), gcp.WithUserAttribution}, db.ExecOptions()...)...)
+	if err := db.Close(ctx); err != nil {
+		return err
+	}
 
 	// Infer the entrypoint in case an explicit override was not provided.
 	entrypoint := os.Getenv(env.Entrypoint)
 	if entrypoint != "" {
 		entrypoint = "exec " + entrypoint
+	} else if blazorWasm {
+		// Blazor WebAssembly apps run in the browser, so their static files are served without the
+		// .NET runtime.
+		root := filepath.Join(ctx.ApplicationRoot(), outputDirectory, dotnet.BlazorWasmRoot)
+		ctx.Logf("Serving the Blazor WebAssembly app from %s.", root)
+		ep, err := dotnet.BlazorWasmEntrypoint(ctx, root)
+		if err != nil {
+			return fmt.Errorf("getting entrypoint: %w", err)
+		}
+		entrypoint = ep
+		binLayer.BuildEnvironment.Default(env.Entrypoint, entrypoint)
 	} else {
-		ep, err := getEntrypoint(ctx, outputDirectory, proj)
+		// Single-file apps are started with their executable, which loads the runtime layer unless they
+		// are also self-contained.
+		ep, err := getEntrypoint(ctx, outputDirectory, proj, selfContained || singleFile)
 		if err != nil {
 			return fmt.Errorf("getting entrypoint: %w", err)
 		}
+		if web {
+			ctx.Logf("Configuring the ASP.NET Core web app to listen on $PORT.")
+			ep = dotnet.WebEntrypoint(ep)
+		}
 		entrypoint = ep
 		binLayer.BuildEnvironment.Default(env.Entrypoint, entrypoint)
 	}
 	binLayer.LaunchEnvironment.Default("DOTNET_RUNNING_IN_CONTAINER", "true")
+	if !blazorWasm {
+		if err := dotnet.ConfigureDataProtection(ctx, binLayer, os.Getenv(dotnet.DataProtectionKeyDirEnv)); err != nil {
+			return err
+		}
+	}
 
 	// Configure the entrypoint for production.
 	if !devmode.Enabled(ctx) {
@@ -130,15 +231,76 @@ func buildFn(ctx *gcp.Context) error {
 	return nil
 }
 
+// packagesLayer returns the cached layer that holds the NuGet packages restored for the
+// application. It is kept across builds independently of the SDK layer, so that a restore after a
+// change to the dependencies only downloads the packages that changed, and is cleared once it
+// exceeds its maximum size.
+func packagesLayer(ctx *gcp.Context) (*libcnb.Layer, error) {
+	l, err := ctx.Layer(packagesLayerName, gcp.BuildLayer, gcp.CacheLayer)
+	if err != nil {
+		return nil, fmt.Errorf("creating layer: %w", err)
+	}
+	l.BuildEnvironment.Default("NUGET_PACKAGES", l.Path)
+	size, err := cache.Size(l.Path)
+	if err != nil {
+		return nil, err
+	}
+	ctx.Debugf("NuGet packages cache holds %d bytes, the maximum is %d bytes.", size, packagesMaxBytes)
+	if size > packagesMaxBytes {
+		ctx.Logf("Clearing the NuGet packages cache, which holds %d bytes, more than the maximum of %d bytes.", size, packagesMaxBytes)
+		if err := ctx.ClearLayer(l); err != nil {
+			return nil, fmt.Errorf("clearing layer %q: %w", l.Name, err)
+		}
+	}
+	return l, nil
+}
+
+// packagesEnv returns the environment of the dotnet commands that restore packages to the packages
+// layer l. A NUGET_PACKAGES set by the user is left unchanged and the restored packages are then only
+// directed to l by --packages.
+func packagesEnv(l *libcnb.Layer) []string {
+	env := []string{"DOTNET_CLI_TELEMETRY_OPTOUT=true"}
+	if _, ok := os.LookupEnv("NUGET_PACKAGES"); !ok {
+		env = append(env, "NUGET_PACKAGES="+l.Path)
+	}
+	return env
+}
+
+// publishCommand returns the dotnet publish command for the project, using the restored packages.
+func publishCommand(proj, packages string, verbosity, ridArgs []string, selfContained, singleFile bool) []string {
+	cmd := []string{
+		"dotnet",
+		"publish",
+		"-nologo",
+	}
+	cmd = append(cmd, verbosity...)
+	cmd = append(cmd,
+		"--configuration", "Release",
+		"--output", outputDirectory,
+		"--no-restore",
+		"--packages", packages,
+	)
+	if len(ridArgs) > 0 {
+		cmd = append(cmd, ridArgs...)
+		cmd = append(cmd, "--self-contained", strconv.FormatBool(selfContained))
+	}
+	if singleFile {
+		cmd = append(cmd, dotnet.SingleFileArgs(selfContained)...)
+	}
+	return append(cmd, proj)
+}
+
 // getEntrypoint retrieves the appropriate entrypoint for this build.
 // * Check the output directory for a binary or a library with the same name as the project file (e.g. app.csproj --> app or app.dll).
 // * If not found, parse the project file for an AssemblyName field and check for the associated binary or library file in the output directory.
 // * If not found, return user error.
-func getEntrypoint(ctx *gcp.Context, bin, proj string) (string, error) {
+// Self-contained and single-file apps are started with their native executable rather than the
+// library, as indicated by executable.
+func getEntrypoint(ctx *gcp.Context, bin, proj string, executable bool) (string, error) {
 	ctx.Logf("Determining entrypoint from output directory %s and project file %s", bin, proj)
 	p := strings.TrimSuffix(filepath.Base(proj), filepath.Ext(proj))
 
-	ep, err := getEntrypointCmd(ctx, filepath.Join(bin, p))
+	ep, err := getEntrypointCmd(ctx, filepath.Join(bin, p), executable)
 	if err != nil {
 		return "", err
 	}
@@ -151,7 +313,7 @@ func getEntrypoint(ctx *gcp.Context, bin, proj string) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("getting assembly name: %w", err)
 	}
-	ep, err = getEntrypointCmd(ctx, filepath.Join(bin, an))
+	ep, err = getEntrypointCmd(ctx, filepath.Join(bin, an), executable)
 	if err != nil {
 		return "", err
 	}
@@ -163,7 +325,17 @@ func getEntrypoint(ctx *gcp.Context, bin, proj string) (string, error) {
 	return "", gcp.UserErrorf("unable to find executable produced from %s, try setting the AssemblyName property", proj)
 }
 
-func getEntrypointCmd(ctx *gcp.Context, ep string) (string, error) {
+func getEntrypointCmd(ctx *gcp.Context, ep string, executable bool) (string, error) {
+	if executable {
+		exeExists, err := ctx.FileExists(ep)
+		if err != nil {
+			return "", err
+		}
+		if exeExists {
+			return fmt.Sprintf("cd %s && exec ./%s", path.Dir(ep), path.Base(ep)), nil
+		}
+		return "", nil
+	}
 	dll := ep + ".dll"
 	dllExists, err := ctx.FileExists(dll)
 	if err != nil {
@@ -175,21 +347,11 @@ func getEntrypointCmd(ctx *gcp.Context, ep string) (string, error) {
 	return "", nil
 }
 
-func checkCache(ctx *gcp.Context, l *libcnb.Layer) (bool, error) {
-	// We cache all *.*proj files, as if we just cache just the main one, we would miss any changes
-	// to other libraries implemented as part of the app. As many apps are structured such that the
-	// main app only depends on the local binaries, that root project file would change very
-	// infrequently while the associated library files would change significantly more often, as
-	// that's where the primary implementation is done.
-	projectFiles := dotnet.ProjectFiles(ctx, ".")
-	globalJSON := filepath.Join(ctx.ApplicationRoot(), "global.json")
-	globalJSONExists, err := ctx.FileExists(globalJSON)
+func checkCache(ctx *gcp.Context, l *libcnb.Layer, nugetConfig string) (bool, error) {
+	projectFiles, err := dependencyFiles(ctx, nugetConfig)
 	if err != nil {
 		return false, err
 	}
-	if globalJSONExists {
-		projectFiles = append(projectFiles, globalJSON)
-	}
 	currentVersion := ctx.Exec([]string{"dotnet", "--version"}).Stdout
 
 	hash, err := cache.Hash(ctx, cache.WithStrings(currentVersion), cache.WithFiles(projectFiles...))
@@ -215,6 +377,97 @@ func checkCache(ctx *gcp.Context, l *libcnb.Layer) (bool, error) {
 	return false, nil
 }
 
+// dependencyFiles returns the files that determine the restored packages.
+func dependencyFiles(ctx *gcp.Context, nugetConfig string) ([]string, error) {
+	// We cache all *.*proj files, as if we just cache just the main one, we would miss any changes
+	// to other libraries implemented as part of the app. As many apps are structured such that the
+	// main app only depends on the local binaries, that root project file would change very
+	// infrequently while the associated library files would change significantly more often, as
+	// that's where the primary implementation is done.
+	projects := dotnet.ProjectFiles(ctx, ctx.ApplicationRoot())
+	files := append([]string{}, projects...)
+	// Lock files pin the resolved package versions, and the MSBuild props files may set the versions
+	// of centrally managed packages. Source files are not part of the key.
+	var optional []string
+	for _, proj := range projects {
+		optional = append(optional, filepath.Join(filepath.Dir(proj), "packages.lock.json"))
+	}
+	for _, f := range []string{"global.json", "Directory.Packages.props", "Directory.Build.props"} {
+		optional = append(optional, filepath.Join(ctx.ApplicationRoot(), f))
+	}
+	for _, f := range optional {
+		exists, err := ctx.FileExists(f)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			files = append(files, f)
+		}
+	}
+	// The package sources are part of the key, but not the expanded credentials, which are only
+	// referenced by the file.
+	if nugetConfig != "" {
+		files = append(files, nugetConfig)
+	}
+	return files, nil
+}
+
+// nugetConfigArgs writes a copy of the NuGet.config file with the environment variables it
+// references expanded to a temporary directory that is removed when the buildpack finishes, and
+// returns the dotnet restore arguments to use it, along with the names of the referenced variables.
+// The expanded copy may hold credentials, so neither its content nor the variable values are logged.
+func nugetConfigArgs(ctx *gcp.Context, nugetConfig string) ([]string, []string, error) {
+	if nugetConfig == "" {
+		return nil, nil, nil
+	}
+	ctx.Logf("Restoring packages with the package sources in %s.", nugetConfig)
+	data, err := ctx.ReadFile(nugetConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	refs := dotnet.NuGetConfigEnvRefs(data)
+	if len(refs) == 0 {
+		return []string{"--configfile", nugetConfig}, nil, nil
+	}
+	expanded, _ := dotnet.ExpandNuGetConfig(data, os.LookupEnv)
+	dir, err := ctx.TempDir(nugetConfigDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	path := filepath.Join(dir, filepath.Base(nugetConfig))
+	if err := ctx.WriteFile(path, expanded, 0600); err != nil {
+		return nil, nil, err
+	}
+	return []string{"--configfile", path}, refs, nil
+}
+
+// isRestoreAuthError returns true if dotnet restore was denied access to a package source.
+func isRestoreAuthError(result *gcp.ExecResult) bool {
+	for _, e := range restoreAuthErrors {
+		if strings.Contains(result.Combined, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// nugetAuthHint returns a hint for a restore that was denied access to a package source.
+func nugetAuthHint(nugetConfig string, refs []string) string {
+	if nugetConfig == "" {
+		return "A package source requires authentication. Add its credentials to a NuGet.config file, referencing environment variables as %NAME%, and set the variables."
+	}
+	var unset []string
+	for _, name := range refs {
+		if _, ok := os.LookupEnv(name); !ok {
+			unset = append(unset, name)
+		}
+	}
+	if len(unset) > 0 {
+		return fmt.Sprintf("A package source in %s requires authentication. Set the environment variables %s that it references to the credentials of the package source.", nugetConfig, strings.Join(unset, ", "))
+	}
+	return fmt.Sprintf("A package source in %s requires authentication. Check the credentials in its packageSourceCredentials section.", nugetConfig)
+}
+
 func getAssemblyName(ctx *gcp.Context, proj string) (string, error) {
 	p, err := dotnet.ReadProjectFile(ctx, proj)
 	if err != nil {
@@ -231,3 +484,17 @@ func getAssemblyName(ctx *gcp.Context, proj string) (string, error) {
 	}
 	return assemblyNames[0], nil
 }
+
+// isRestoreNetworkError returns true if dotnet restore failed to reach a package source, which is
+// often intermittent. A source that denies access is not retried.
+func isRestoreNetworkError(result *gcp.ExecResult) bool {
+	if isRestoreAuthError(result) {
+		return false
+	}
+	for _, e := range restoreNetworkErrors {
+		if strings.Contains(result.Combined, e) {
+			return true
+		}
+	}
+	return false
+}
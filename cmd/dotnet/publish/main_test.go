@@ -17,13 +17,17 @@ package main
 import (
 	"bytes"
 	"io/ioutil"
+	"log"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"text/template"
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
 )
 
 func TestGetAssemblyName(t *testing.T) {
@@ -109,11 +113,12 @@ func TestGetAssemblyName(t *testing.T) {
 
 func TestGetEntrypoint(t *testing.T) {
 	tcs := []struct {
-		name string
-		exe  string
-		proj string
-		data string
-		want string
+		name          string
+		exe           string
+		proj          string
+		data          string
+		selfContained bool
+		want          string
 	}{
 		{
 			name: "dll from project file",
@@ -153,6 +158,28 @@ func TestGetEntrypoint(t *testing.T) {
 	</Project>`,
 			want: "cd {{.Tmp}} && exec dotnet customapp.dll",
 		},
+		{
+			name:          "self-contained exe from project file",
+			exe:           "myapp",
+			proj:          "myapp.proj",
+			selfContained: true,
+			want:          "cd {{.Tmp}} && exec ./myapp",
+		},
+		{
+			name: "self-contained exe from assembly name",
+			exe:  "customapp",
+			proj: "myapp.proj",
+			data: `<Project Sdk="Microsoft.NET.Sdk.Web">
+
+		<PropertyGroup>
+			<AssemblyName>customapp</AssemblyName>
+			<SelfContained>true</SelfContained>
+		</PropertyGroup>
+
+	</Project>`,
+			selfContained: true,
+			want:          "cd {{.Tmp}} && exec ./customapp",
+		},
 	}
 
 	for _, tc := range tcs {
@@ -181,7 +208,7 @@ func TestGetEntrypoint(t *testing.T) {
 				t.Fatalf("writing proj file: %v", err)
 			}
 
-			ep, err := getEntrypoint(ctx, tmpDir, proj)
+			ep, err := getEntrypoint(ctx, tmpDir, proj, tc.selfContained)
 			if err != nil {
 				t.Fatalf("getting entrypoint: %v", err)
 			}
@@ -233,6 +260,15 @@ func TestDetect(t *testing.T) {
 			},
 			want: 0,
 		},
+		{
+			name: "mixed project types",
+			files: map[string]string{
+				"web/web.csproj": "",
+				"lib/lib.fsproj": "",
+				"vb/vb.vbproj":   "",
+			},
+			want: 0,
+		},
 		{
 			name: "with build env",
 			files: map[string]string{
@@ -278,3 +314,323 @@ func TestDetect(t *testing.T) {
 		})
 	}
 }
+
+func TestDetectReason(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files []string
+		want  string
+	}{
+		{
+			name:  "fsproj",
+			files: []string{"app.fsproj", "Program.fs"},
+			want:  "Opting in: found project files: ./app.fsproj",
+		},
+		{
+			name:  "vbproj",
+			files: []string{"app.vbproj", "Program.vb"},
+			want:  "Opting in: found project files: ./app.vbproj",
+		},
+		{
+			name:  "mixed project types",
+			files: []string{"web/web.csproj", "lib/lib.fsproj", "vb/vb.vbproj"},
+			want:  "Opting in: found project files: ./lib/lib.fsproj, ./vb/vb.vbproj, ./web/web.csproj",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, f := range tc.files {
+				path := filepath.Join(dir, f)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("creating dir for %s: %v", f, err)
+				}
+				if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+					t.Fatalf("writing %s: %v", f, err)
+				}
+			}
+			wd, err := os.Getwd()
+			if err != nil {
+				t.Fatalf("getting working directory: %v", err)
+			}
+			if err := os.Chdir(dir); err != nil {
+				t.Fatalf("changing to %s: %v", dir, err)
+			}
+			defer os.Chdir(wd)
+
+			result, err := detectFn(gcp.NewContext(gcp.WithApplicationRoot(dir)))
+			if err != nil {
+				t.Fatalf("detectFn() got error: %v", err)
+			}
+			if !result.Result().Pass {
+				t.Errorf("detectFn() opted out, want opt in")
+			}
+			if got := result.Reason(); got != tc.want {
+				t.Errorf("detectFn() reason = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestIsRestoreNetworkError(t *testing.T) {
+	testCases := []struct {
+		name   string
+		output string
+		want   bool
+	}{
+		{
+			name:   "unreachable source",
+			output: "error NU1301: Unable to load the service index for source https://api.nuget.org/v3/index.json.",
+			want:   true,
+		},
+		{
+			name:   "missing package",
+			output: "error NU1101: Unable to find package Foo. No packages exist with this id in source(s): nuget.org",
+		},
+		{
+			name:   "unauthorized source",
+			output: "error NU1301: Unable to load the service index for source https://pkgs.example.com/v3/index.json.\n Response status code does not indicate success: 401 (Unauthorized).",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isRestoreNetworkError(&gcp.ExecResult{Combined: tc.output}); got != tc.want {
+				t.Errorf("isRestoreNetworkError(%q) = %t, want %t", tc.output, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDependencyFiles(t *testing.T) {
+	testCases := []struct {
+		name        string
+		files       []string
+		nugetConfig string
+		want        []string
+	}{
+		{
+			name:  "project file",
+			files: []string{"app.csproj"},
+			want:  []string{"app.csproj"},
+		},
+		{
+			name:  "project file and global.json",
+			files: []string{"app.csproj", "global.json"},
+			want:  []string{"app.csproj", "global.json"},
+		},
+		{
+			name:        "with NuGet.config",
+			files:       []string{"app.csproj", "NuGet.Config"},
+			nugetConfig: "NuGet.Config",
+			want:        []string{"app.csproj", "NuGet.Config"},
+		},
+		{
+			name:  "lock and props files without source files",
+			files: []string{"app.csproj", "Program.cs", "Startup.cs", "appsettings.json", "packages.lock.json", "Directory.Packages.props", "Directory.Build.props"},
+			want:  []string{"app.csproj", "packages.lock.json", "Directory.Packages.props", "Directory.Build.props"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, f := range tc.files {
+				if err := ioutil.WriteFile(filepath.Join(dir, f), nil, 0644); err != nil {
+					t.Fatalf("writing %s: %v", f, err)
+				}
+			}
+			abs := func(f string) string { return filepath.Join(dir, f) }
+			nugetConfig := ""
+			if tc.nugetConfig != "" {
+				nugetConfig = abs(tc.nugetConfig)
+			}
+			var want []string
+			for _, f := range tc.want {
+				want = append(want, abs(f))
+			}
+
+			got, err := dependencyFiles(gcp.NewContext(gcp.WithApplicationRoot(dir)), nugetConfig)
+			if err != nil {
+				t.Fatalf("dependencyFiles() got error: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("dependencyFiles() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestPackagesLayer(t *testing.T) {
+	testCases := []struct {
+		name      string
+		cached    string
+		maxBytes  int64
+		wantClear bool
+	}{
+		{
+			name:     "empty cache",
+			maxBytes: 10,
+		},
+		{
+			name:     "cache under the maximum",
+			cached:   "12345",
+			maxBytes: 10,
+		},
+		{
+			name:      "cache over the maximum",
+			cached:    "12345678901",
+			maxBytes:  10,
+			wantClear: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			layers := t.TempDir()
+			pkg := filepath.Join(layers, packagesLayerName, "newtonsoft.json", "13.0.1", "newtonsoft.json.13.0.1.nupkg")
+			if tc.cached != "" {
+				if err := os.MkdirAll(filepath.Dir(pkg), 0755); err != nil {
+					t.Fatalf("creating directory: %v", err)
+				}
+				if err := ioutil.WriteFile(pkg, []byte(tc.cached), 0644); err != nil {
+					t.Fatalf("writing %s: %v", pkg, err)
+				}
+			}
+			origMaxBytes := packagesMaxBytes
+			packagesMaxBytes = tc.maxBytes
+			t.Cleanup(func() { packagesMaxBytes = origMaxBytes })
+			ctx := gcp.NewContext(
+				gcp.WithApplicationRoot(t.TempDir()),
+				gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: layers}}))
+
+			l, err := packagesLayer(ctx)
+			if err != nil {
+				t.Fatalf("packagesLayer() got error: %v", err)
+			}
+			if !l.Cache || !l.Build {
+				t.Errorf("packagesLayer() cache: %t, build: %t, want both true", l.Cache, l.Build)
+			}
+			if got := l.BuildEnvironment["NUGET_PACKAGES.default"]; got != l.Path {
+				t.Errorf("NUGET_PACKAGES = %q, want the layer path %q", got, l.Path)
+			}
+			_, err = os.Stat(pkg)
+			if gotCached := err == nil; gotCached != (tc.cached != "" && !tc.wantClear) {
+				t.Errorf("package cached: %t, want %t", gotCached, tc.cached != "" && !tc.wantClear)
+			}
+		})
+	}
+}
+
+func TestPackagesEnv(t *testing.T) {
+	l := &libcnb.Layer{Path: "/layers/packages"}
+	t.Setenv("NUGET_PACKAGES", "")
+	os.Unsetenv("NUGET_PACKAGES")
+	if got, want := packagesEnv(l), []string{"DOTNET_CLI_TELEMETRY_OPTOUT=true", "NUGET_PACKAGES=/layers/packages"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("packagesEnv() = %v, want %v", got, want)
+	}
+
+	t.Setenv("NUGET_PACKAGES", "/user/packages")
+	if got, want := packagesEnv(l), []string{"DOTNET_CLI_TELEMETRY_OPTOUT=true"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("packagesEnv() with NUGET_PACKAGES set = %v, want %v", got, want)
+	}
+}
+
+func TestNuGetConfigArgs(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "nuget.config")
+	content := `<configuration>
+  <packageSourceCredentials>
+    <private>
+      <add key="Username" value="%NUGET_USER%" />
+      <add key="ClearTextPassword" value="%NUGET_TOKEN%" />
+    </private>
+  </packageSourceCredentials>
+</configuration>`
+	if err := ioutil.WriteFile(config, []byte(content), 0644); err != nil {
+		t.Fatalf("writing %s: %v", config, err)
+	}
+	t.Setenv("NUGET_USER", "builder")
+	t.Setenv("NUGET_TOKEN", "s3cr3t")
+	var logs bytes.Buffer
+	ctx := gcp.NewContext(gcp.WithApplicationRoot(dir), gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: t.TempDir()}}), gcp.WithLogger(log.New(&logs, "", 0)))
+
+	args, refs, err := nugetConfigArgs(ctx, config)
+	if err != nil {
+		t.Fatalf("nugetConfigArgs() got error: %v", err)
+	}
+	if len(args) != 2 || args[0] != "--configfile" || args[1] == config {
+		t.Fatalf("nugetConfigArgs() = %v, want --configfile with an expanded copy of %s", args, config)
+	}
+	if want := []string{"NUGET_TOKEN", "NUGET_USER"}; !reflect.DeepEqual(refs, want) {
+		t.Errorf("nugetConfigArgs() refs = %v, want %v", refs, want)
+	}
+	expanded, err := ioutil.ReadFile(args[1])
+	if err != nil {
+		t.Fatalf("reading %s: %v", args[1], err)
+	}
+	if !strings.Contains(string(expanded), `value="s3cr3t"`) || !strings.Contains(string(expanded), `value="builder"`) {
+		t.Errorf("expanded config does not contain the credentials, got:\n%s", expanded)
+	}
+	if strings.Contains(logs.String(), "s3cr3t") {
+		t.Errorf("log contains the credentials, got:\n%s", logs.String())
+	}
+}
+
+func TestNuGetAuthHint(t *testing.T) {
+	t.Setenv("NUGET_USER", "builder")
+	got := nugetAuthHint("NuGet.Config", []string{"NUGET_TOKEN", "NUGET_USER"})
+	if !strings.Contains(got, "NUGET_TOKEN") || strings.Contains(got, "NUGET_USER") {
+		t.Errorf("nugetAuthHint() = %q, want a hint to set NUGET_TOKEN only", got)
+	}
+}
+
+func TestPublishCommand(t *testing.T) {
+	base := []string{"dotnet", "publish", "-nologo", "--verbosity", "minimal", "--configuration", "Release", "--output", "bin", "--no-restore", "--packages", "/layers/packages"}
+	testCases := []struct {
+		name          string
+		verbosity     []string
+		ridArgs       []string
+		selfContained bool
+		singleFile    bool
+		want          []string
+	}{
+		{
+			name: "portable",
+			want: append(append([]string{}, base...), "app.csproj"),
+		},
+		{
+			name:      "detailed verbosity",
+			verbosity: []string{"--verbosity", "detailed"},
+			want:      []string{"dotnet", "publish", "-nologo", "--verbosity", "detailed", "--configuration", "Release", "--output", "bin", "--no-restore", "--packages", "/layers/packages", "app.csproj"},
+		},
+		{
+			name:          "self-contained",
+			ridArgs:       []string{"--runtime", "linux-x64"},
+			selfContained: true,
+			want:          append(append([]string{}, base...), "--runtime", "linux-x64", "--self-contained", "true", "app.csproj"),
+		},
+		{
+			name:       "framework-dependent single file",
+			ridArgs:    []string{"--runtime", "linux-x64"},
+			singleFile: true,
+			want:       append(append([]string{}, base...), "--runtime", "linux-x64", "--self-contained", "false", "-p:PublishSingleFile=true", "app.csproj"),
+		},
+		{
+			name:          "self-contained single file",
+			ridArgs:       []string{"--runtime", "linux-arm64"},
+			selfContained: true,
+			singleFile:    true,
+			want:          append(append([]string{}, base...), "--runtime", "linux-arm64", "--self-contained", "true", "-p:PublishSingleFile=true", "-p:IncludeNativeLibrariesForSelfExtract=true", "app.csproj"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			verbosity := tc.verbosity
+			if verbosity == nil {
+				verbosity = []string{"--verbosity", "minimal"}
+			}
+			got := publishCommand("app.csproj", "/layers/packages", verbosity, tc.ridArgs, tc.selfContained, tc.singleFile)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("publishCommand() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
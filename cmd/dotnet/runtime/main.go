@@ -25,10 +25,12 @@ import (
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtime"
+	"github.com/buildpacks/libcnb"
 )
 
 const (
 	runtimeLayerName         = "runtime"
+	icuLayerName             = "icu"
 	aspnetRuntimeURL         = "https://dotnetcli.azureedge.net/dotnet/aspnetcore/Runtime/%[1]s/aspnetcore-runtime-%[1]s-linux-x64.tar.gz"
 	uncachedAspnetRuntimeURL = "https://dotnetcli.blob.core.windows.net/dotnet/aspnetcore/Runtime/%[1]s/aspnetcore-runtime-%[1]s-linux-x64.tar.gz"
 	versionKey               = "version"
@@ -58,30 +60,124 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 }
 
 func buildFn(ctx *gcp.Context) error {
+	selfContained, err := env.IsPresentAndTrue(dotnet.PublishSelfContainedEnv)
+	if err != nil {
+		return gcp.UserErrorf("%v", err)
+	}
+	singleFile, err := dotnet.IsSingleFile()
+	if err != nil {
+		return err
+	}
+	bundleICU, err := env.IsPresentAndTrue(dotnet.BundleICUEnv)
+	if err != nil {
+		return gcp.UserErrorf("%v", err)
+	}
+	blazorWasm, err := isBlazorWasm(ctx)
+	if err != nil {
+		return err
+	}
+	if reason := runtimeLayerSkipReason(selfContained, singleFile, blazorWasm); reason != "" {
+		ctx.Logf("Skipping the .NET runtime layer because %s.", reason)
+		if bundleICU {
+			l, err := ctx.Layer(icuLayerName, gcp.CacheLayer, gcp.LaunchLayer)
+			if err != nil {
+				return fmt.Errorf("creating %v layer: %w", icuLayerName, err)
+			}
+			return dotnet.BundleICU(ctx, l)
+		}
+		return nil
+	}
 	runtimeVersion, err := dotnet.GetRuntimeVersion(ctx)
 	if err != nil {
 		return err
 	}
+	if runtimeVersion, err = rollForwardRuntimeVersion(ctx, runtimeVersion); err != nil {
+		return err
+	}
 	isDevMode, err := env.IsDevMode()
 	if err != nil {
 		return fmt.Errorf("checking if dev mode is enabled: %w", err)
 	}
 	if !isDevMode {
-		if err := buildRuntimeLayer(ctx, runtimeVersion); err != nil {
+		rtl, err := buildRuntimeLayer(ctx, runtimeVersion)
+		if err != nil {
 			return fmt.Errorf("building the runtime layer: %w", err)
 		}
+		if bundleICU {
+			return dotnet.BundleICU(ctx, rtl)
+		}
 	}
 	return nil
 }
 
-func buildRuntimeLayer(ctx *gcp.Context, rtVersion string) error {
-	rtl, err := ctx.Layer(runtimeLayerName, gcp.CacheLayer, gcp.LaunchLayer)
+// isBlazorWasm returns true if the application is built from a Blazor WebAssembly project. Prebuilt
+// applications without a project file are not.
+func isBlazorWasm(ctx *gcp.Context) (bool, error) {
+	if len(dotnet.ProjectFiles(ctx, ".")) == 0 {
+		return false, nil
+	}
+	proj, err := dotnet.FindProjectFile(ctx)
+	if err != nil {
+		return false, err
+	}
+	return dotnet.IsBlazorWasm(proj)
+}
+
+// runtimeLayerSkipReason returns why the application does not need the runtime layer, or "" if it
+// does. Self-contained apps bundle the runtime in the published output, whether or not they are
+// published as a single file. Framework-dependent single-file apps still load the runtime layer.
+// Blazor WebAssembly apps run in the browser and their static files are served without a runtime.
+func runtimeLayerSkipReason(selfContained, singleFile, blazorWasm bool) string {
+	switch {
+	case blazorWasm:
+		return "the application is a Blazor WebAssembly app served as static files"
+	case selfContained && singleFile:
+		return "the application was published as a self-contained single file"
+	case selfContained:
+		return "the application was published self-contained"
+	default:
+		return ""
+	}
+}
+
+// rollForwardRuntimeVersion returns the runtime version to install for the version in
+// runtimeconfig.json, rolled forward to an available version if GOOGLE_DOTNET_RUNTIME_ROLLFORWARD is set.
+func rollForwardRuntimeVersion(ctx *gcp.Context, requested string) (string, error) {
+	policy := os.Getenv(dotnet.RuntimeRollForwardEnv)
+	if policy == "" {
+		return requested, nil
+	}
+	available, err := runtime.Versions(runtime.AspNetCore)
+	if err != nil {
+		return "", err
+	}
+	version, err := dotnet.RuntimeRollForward(requested, available, policy)
 	if err != nil {
-		return fmt.Errorf("creating %v layer: %w", runtimeLayerName, err)
+		return "", err
+	}
+	ctx.Logf("Rolling the .NET runtime forward from %s to %s with the %s policy.", requested, version, policy)
+	runtime.LogResolution(ctx, requested, version)
+	return version, nil
+}
+
+func buildRuntimeLayer(ctx *gcp.Context, rtVersion string) (*libcnb.Layer, error) {
+	shared, err := env.IsPresentAndTrue(dotnet.SharedRuntimeEnv)
+	if err != nil {
+		return nil, gcp.UserErrorf("%v", err)
+	}
+	var rtl *libcnb.Layer
+	if shared {
+		if rtl, _, err = runtime.VersionedInstall(ctx, runtime.AspNetCore, rtVersion); err != nil {
+			return nil, err
+		}
+	} else {
+		if rtl, err = ctx.Layer(runtimeLayerName, gcp.CacheLayer, gcp.LaunchLayer); err != nil {
+			return nil, fmt.Errorf("creating %v layer: %w", runtimeLayerName, err)
+		}
+		runtime.InstallTarballIfNotCached(ctx, runtime.AspNetCore, rtVersion, rtl)
 	}
-	runtime.InstallTarballIfNotCached(ctx, runtime.AspNetCore, rtVersion, rtl)
 	rtl.LaunchEnvironment.Default("DOTNET_ROOT", rtl.Path)
 	rtl.LaunchEnvironment.Prepend("PATH", string(os.PathListSeparator), rtl.Path)
 	rtl.LaunchEnvironment.Default("DOTNET_RUNNING_IN_CONTAINER", "true")
-	return nil
+	return rtl, nil
 }
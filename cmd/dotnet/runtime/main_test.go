@@ -15,9 +15,15 @@
 package main
 
 import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	goruntime "runtime"
+	"strings"
 	"testing"
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 )
 
 func TestDetect(t *testing.T) {
@@ -92,3 +98,130 @@ func TestDetect(t *testing.T) {
 		})
 	}
 }
+
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name string
+		envs []string
+		want string
+	}{
+		{
+			name: "self-contained skips runtime layer",
+			envs: []string{"GOOGLE_DOTNET_PUBLISH_SELF_CONTAINED=true"},
+			want: "Skipping the .NET runtime layer because the application was published self-contained.",
+		},
+		{
+			name: "self-contained single file skips runtime layer",
+			envs: []string{"GOOGLE_DOTNET_PUBLISH_SELF_CONTAINED=true", "GOOGLE_DOTNET_SINGLE_FILE=true"},
+			want: "Skipping the .NET runtime layer because the application was published as a self-contained single file.",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := buildpacktest.RunBuild(t, buildFn, buildpacktest.WithEnvs(tc.envs...), buildpacktest.WithTestName(tc.name))
+			if err != nil {
+				t.Fatalf("error running build: %v, result: %#v", err, result)
+			}
+			if !strings.Contains(result.Output, tc.want) {
+				t.Errorf("RunBuild().Output = %q, want %q", result.Output, tc.want)
+			}
+		})
+	}
+}
+
+func TestRuntimeLayerSkipReason(t *testing.T) {
+	testCases := []struct {
+		name          string
+		selfContained bool
+		singleFile    bool
+		blazorWasm    bool
+		wantSkip      bool
+	}{
+		{
+			name: "framework-dependent",
+		},
+		{
+			name:       "framework-dependent single file",
+			singleFile: true,
+		},
+		{
+			name:          "self-contained",
+			selfContained: true,
+			wantSkip:      true,
+		},
+		{
+			name:          "self-contained single file",
+			selfContained: true,
+			singleFile:    true,
+			wantSkip:      true,
+		},
+		{
+			name:       "blazor webassembly",
+			blazorWasm: true,
+			wantSkip:   true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reason := runtimeLayerSkipReason(tc.selfContained, tc.singleFile, tc.blazorWasm)
+			if gotSkip := reason != ""; gotSkip != tc.wantSkip {
+				t.Errorf("runtimeLayerSkipReason(%t, %t, %t) = %q, want skip: %t", tc.selfContained, tc.singleFile, tc.blazorWasm, reason, tc.wantSkip)
+			}
+		})
+	}
+}
+
+func TestRollForwardRuntimeVersion(t *testing.T) {
+	testCases := []struct {
+		name      string
+		requested string
+		policy    string
+		want      string
+		wantErr   bool
+	}{
+		{
+			name:      "no policy",
+			requested: "6.0.0",
+			want:      "6.0.0",
+		},
+		{
+			name:      "latest patch",
+			requested: "6.0.0",
+			policy:    "LatestPatch",
+			want:      "6.0.10",
+		},
+		{
+			name:      "minor",
+			requested: "6.0.11",
+			policy:    "Minor",
+			want:      "6.1.2",
+		},
+		{
+			name:      "disable",
+			requested: "6.0.1",
+			policy:    "Disable",
+			wantErr:   true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, v := range []string{"6.0.0", "6.0.10", "6.1.2"} {
+				name := fmt.Sprintf("aspnetcore-%s-%s.tar.gz", v, goruntime.GOARCH)
+				if err := ioutil.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+					t.Fatalf("writing %s: %v", name, err)
+				}
+			}
+			t.Setenv("GOOGLE_RUNTIME_LOCAL_DIR", dir)
+			t.Setenv("GOOGLE_DOTNET_RUNTIME_ROLLFORWARD", tc.policy)
+
+			got, err := rollForwardRuntimeVersion(gcp.NewContext(), tc.requested)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("rollForwardRuntimeVersion(%q) got error: %v, want error: %t", tc.requested, err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("rollForwardRuntimeVersion(%q) = %q, want %q", tc.requested, got, tc.want)
+			}
+		})
+	}
+}
@@ -19,6 +19,8 @@ package main
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	goruntime "runtime"
 	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/dotnet"
@@ -30,8 +32,6 @@ import (
 
 const (
 	sdkLayerName = "sdk"
-	versionKey   = "version"
-	googleMin22  = "google.min.22"
 )
 
 func main() {
@@ -59,53 +59,133 @@ func buildFn(ctx *gcp.Context) error {
 	if err != nil {
 		return fmt.Errorf("checking if dev mode is enabled: %w", err)
 	}
-	if err := buildSDKLayer(ctx, sdkVersion, isDevMode); err != nil {
+	p, err := readProject(ctx)
+	if err != nil {
+		return err
+	}
+	selfContained, err := dotnet.IsSelfContained(p)
+	if err != nil {
+		return fmt.Errorf("checking if the app is self-contained: %w", err)
+	}
+	workloads, err := dotnet.RequiredWorkloads(p)
+	if err != nil {
+		return err
+	}
+	if err := buildSDKLayer(ctx, sdkVersion, isDevMode, selfContained, workloads); err != nil {
 		return fmt.Errorf("building the sdk layer: %w", err)
 	}
 	return nil
 }
 
-func buildSDKLayer(ctx *gcp.Context, version string, isDevMode bool) error {
+// readProject reads the project that is to be published.
+func readProject(ctx *gcp.Context) (dotnet.Project, error) {
+	proj, err := dotnet.FindProjectFile(ctx)
+	if err != nil {
+		return dotnet.Project{}, fmt.Errorf("finding project: %w", err)
+	}
+	p, err := dotnet.ReadProjectFile(ctx, proj)
+	if err != nil {
+		return dotnet.Project{}, fmt.Errorf("reading project at %q: %w", proj, err)
+	}
+	return p, nil
+}
+
+// sdkMetadata is the metadata of the SDK layer, which is reinstalled whenever it changes.
+type sdkMetadata struct {
+	Version       string `json:"version"`
+	DevMode       bool   `json:"devMode"`
+	SelfContained bool   `json:"selfContained"`
+	Arch          string `json:"arch"`
+	// Workloads are the installed SDK workloads, joined with "+".
+	Workloads string `json:"workloads"`
+}
+
+func newSDKMetadata(version string, isDevMode, selfContained bool, workloads []string) sdkMetadata {
+	return sdkMetadata{
+		Version:       version,
+		DevMode:       isDevMode,
+		SelfContained: selfContained,
+		Arch:          goruntime.GOARCH,
+		Workloads:     strings.Join(workloads, "+"),
+	}
+}
+
+func buildSDKLayer(ctx *gcp.Context, version string, isDevMode, selfContained bool, workloads []string) error {
 	// Keep the SDK layer for launch in devmode because we use `dotnet watch`.
 	sdkl, err := ctx.Layer(sdkLayerName, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayerIfDevMode)
 	if err != nil {
 		return fmt.Errorf("creating %v layer: %w", sdkLayerName, err)
 	}
-	sdkMetaVersion := ctx.GetMetadata(sdkl, versionKey)
-	cacheHitValue := fmt.Sprintf("version:%s,devMode:%t", version, isDevMode)
-	if cacheHitValue == sdkMetaVersion {
+	want := newSDKMetadata(version, isDevMode, selfContained, workloads)
+	var got sdkMetadata
+	if err := ctx.GetMetadataStruct(sdkl, &got); err != nil {
+		ctx.Debugf("Ignoring the metadata of the %s layer: %v", sdkLayerName, err)
+	} else if got == want {
 		ctx.CacheHit(sdkLayerName)
 		ctx.Logf(".NET SDK cache hit, skipping installation.")
 		return nil
 	}
 	ctx.CacheMiss(sdkLayerName)
+	ctx.Debugf("The %s layer metadata %+v does not match %+v.", sdkLayerName, got, want)
 	if err := ctx.ClearLayer(sdkl); err != nil {
 		return fmt.Errorf("clearing layer %q: %w", sdkl.Name, err)
 	}
-	if err := dlAndInstallSDK(ctx, sdkl, version, isDevMode); err != nil {
+	// ClearLayer keeps the metadata, reset it so that the SDK tarball is not considered cached.
+	if err := ctx.SetMetadataStruct(sdkl, sdkMetadata{}); err != nil {
 		return err
 	}
-	ctx.SetMetadata(sdkl, versionKey, cacheHitValue)
-	return nil
+	if err := dlAndInstallSDK(ctx, sdkl, version, isDevMode, selfContained); err != nil {
+		return err
+	}
+	if err := installWorkloads(ctx, sdkl, workloads); err != nil {
+		return err
+	}
+	return ctx.SetMetadataStruct(sdkl, want)
 }
 
-func dlAndInstallSDK(ctx *gcp.Context, sdkl *libcnb.Layer, version string, isDevMode bool) error {
+func dlAndInstallSDK(ctx *gcp.Context, sdkl *libcnb.Layer, version string, isDevMode, selfContained bool) error {
 	if _, err := runtime.InstallTarballIfNotCached(ctx, runtime.DotnetSDK, version, sdkl); err != nil {
 		return err
 	}
-	setSDKEnvVars(ctx, sdkl, isDevMode)
+	return setSDKEnvVars(ctx, sdkl, isDevMode, selfContained)
+}
+
+// installWorkloads installs the given SDK workloads into the SDK layer.
+func installWorkloads(ctx *gcp.Context, sdkl *libcnb.Layer, workloads []string) error {
+	if len(workloads) == 0 {
+		return nil
+	}
+	ctx.Logf("Installing .NET SDK workloads: %s", strings.Join(workloads, ", "))
+	cmd := append([]string{filepath.Join(sdkl.Path, "dotnet"), "workload", "install"}, workloads...)
+	env := []string{"DOTNET_ROOT=" + sdkl.Path, "DOTNET_CLI_TELEMETRY_OPTOUT=true"}
+	result, cerr := ctx.ExecWithErr(cmd, gcp.WithEnv(env...), gcp.WithUserAttribution)
+	if cerr != nil {
+		if result != nil && strings.Contains(result.Combined, "not recognized") {
+			return gcp.UserErrorf("installing workloads %s: unknown workload id, run `dotnet workload search` to list the available workloads", strings.Join(workloads, ", "))
+		}
+		return cerr
+	}
 	return nil
 }
 
-func setSDKEnvVars(ctx *gcp.Context, sdkl *libcnb.Layer, isDevMode bool) {
-	if ctx.StackID() == googleMin22 {
+func setSDKEnvVars(ctx *gcp.Context, sdkl *libcnb.Layer, isDevMode, selfContained bool) error {
+	invariant, err := dotnet.GlobalizationInvariant(ctx)
+	if err != nil {
+		return err
+	}
+	if invariant {
 		sdkl.BuildEnvironment.Default("DOTNET_SYSTEM_GLOBALIZATION_INVARIANT", "true")
 	}
+	if selfContained {
+		// The publish and runtime buildpacks read this to publish self-contained and skip the runtime layer.
+		sdkl.BuildEnvironment.Override(dotnet.PublishSelfContainedEnv, "true")
+	}
 	if isDevMode {
 		setSDKEnvVarsDevMode(sdkl)
 	} else {
 		setSDKEnvVarsForBuild(sdkl)
 	}
+	return nil
 }
 
 // setSDKEnvVarsDevMode sets the env vars for dev mode. In dev mode, the full
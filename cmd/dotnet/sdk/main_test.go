@@ -15,9 +15,13 @@
 package main
 
 import (
+	goruntime "runtime"
 	"testing"
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/dotnet"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
 )
 
 func TestDetect(t *testing.T) {
@@ -92,3 +96,102 @@ func TestDetect(t *testing.T) {
 		})
 	}
 }
+
+func TestSetSDKEnvVars(t *testing.T) {
+	testCases := []struct {
+		name          string
+		isDevMode     bool
+		selfContained bool
+	}{
+		{
+			name: "framework-dependent",
+		},
+		{
+			name:          "self-contained",
+			selfContained: true,
+		},
+		{
+			name:          "self-contained dev mode",
+			isDevMode:     true,
+			selfContained: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sdkl := &libcnb.Layer{
+				Path:              "/layers/sdk",
+				BuildEnvironment:  libcnb.Environment{},
+				LaunchEnvironment: libcnb.Environment{},
+				SharedEnvironment: libcnb.Environment{},
+			}
+			if err := setSDKEnvVars(gcp.NewContext(), sdkl, tc.isDevMode, tc.selfContained); err != nil {
+				t.Fatalf("setSDKEnvVars() got error: %v", err)
+			}
+
+			// libcnb appends an ".override" suffix to each env var
+			val, ok := sdkl.BuildEnvironment[dotnet.PublishSelfContainedEnv+".override"]
+			if ok != tc.selfContained {
+				t.Errorf("setSDKEnvVars() set %s = %t, want %t", dotnet.PublishSelfContainedEnv, ok, tc.selfContained)
+			}
+			if ok && val != "true" {
+				t.Errorf("setSDKEnvVars() %s = %q, want %q", dotnet.PublishSelfContainedEnv, val, "true")
+			}
+		})
+	}
+}
+
+func TestSDKMetadata(t *testing.T) {
+	testCases := []struct {
+		name          string
+		version       string
+		isDevMode     bool
+		selfContained bool
+		workloads     []string
+		want          sdkMetadata
+	}{
+		{
+			name:    "no workloads",
+			version: "6.0.100",
+			want:    sdkMetadata{Version: "6.0.100", Arch: goruntime.GOARCH},
+		},
+		{
+			name:          "dev mode self-contained",
+			version:       "6.0.100",
+			isDevMode:     true,
+			selfContained: true,
+			want:          sdkMetadata{Version: "6.0.100", DevMode: true, SelfContained: true, Arch: goruntime.GOARCH},
+		},
+		{
+			name:      "several workloads",
+			version:   "7.0.100",
+			workloads: []string{"android", "wasm-tools"},
+			want:      sdkMetadata{Version: "7.0.100", Arch: goruntime.GOARCH, Workloads: "android+wasm-tools"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := newSDKMetadata(tc.version, tc.isDevMode, tc.selfContained, tc.workloads); got != tc.want {
+				t.Errorf("newSDKMetadata() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSDKMetadataChangesWithWorkloads(t *testing.T) {
+	ctx := gcp.NewContext()
+	l := &libcnb.Layer{Name: sdkLayerName, Metadata: map[string]interface{}{}}
+	if err := ctx.SetMetadataStruct(l, newSDKMetadata("7.0.100", false, false, nil)); err != nil {
+		t.Fatalf("SetMetadataStruct() got error: %v", err)
+	}
+
+	var got sdkMetadata
+	if err := ctx.GetMetadataStruct(l, &got); err != nil {
+		t.Fatalf("GetMetadataStruct() got error: %v", err)
+	}
+	if want := newSDKMetadata("7.0.100", false, false, nil); got != want {
+		t.Errorf("GetMetadataStruct() = %+v, want %+v", got, want)
+	}
+	if withWorkloads := newSDKMetadata("7.0.100", false, false, []string{"wasm-tools"}); got == withWorkloads {
+		t.Errorf("SDK metadata %+v is the same with and without workloads, want a cache miss", got)
+	}
+}
@@ -22,15 +22,23 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/builddb"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/golang"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/source"
+	"github.com/buildpacks/libcnb"
 )
 
 const (
 	noGoFileError         = "no Go files in"
 	cannotFindModuleError = "cannot find module"
+	// buildFlagsKey is the metadata key of the GOCACHE layer that holds the hash of the build flags.
+	buildFlagsKey = "build_flags"
+	// linkmodeKey is the metadata key of the GOCACHE layer that holds the linker mode, if any.
+	linkmodeKey = "linkmode"
 )
 
 func main() {
@@ -49,14 +57,27 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 }
 
 func buildFn(ctx *gcp.Context) error {
-	// Keep GOCACHE in Devmode for faster rebuilds.
-	cl, err := ctx.Layer("gocache", gcp.BuildLayer, gcp.LaunchLayerIfDevMode)
+	testGate, err := golang.TestGateEnabled()
+	if err != nil {
+		return err
+	}
+	// Keep GOCACHE in Devmode for faster rebuilds, and across builds when the test gate runs so that
+	// the test results are cached.
+	var cl *libcnb.Layer
+	if testGate {
+		cl, err = ctx.Layer("gocache", gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayerIfDevMode)
+	} else {
+		cl, err = ctx.Layer("gocache", gcp.BuildLayer, gcp.LaunchLayerIfDevMode)
+	}
 	if err != nil {
 		return fmt.Errorf("creating layer: %w", err)
 	}
 	if devmode.Enabled(ctx) {
 		cl.LaunchEnvironment.Override("GOCACHE", cl.Path)
 	}
+	if err := ctx.Setenv("GOCACHE", cl.Path); err != nil {
+		return err
+	}
 
 	// Create a layer for the compiled binary.  Add it to PATH in case
 	// users wish to invoke the binary manually.
@@ -67,14 +88,54 @@ func buildFn(ctx *gcp.Context) error {
 	bl.LaunchEnvironment.Prepend("PATH", string(os.PathListSeparator), bl.Path)
 	outBin := filepath.Join(bl.Path, golang.OutBin)
 
+	if err := setVendorMode(ctx); err != nil {
+		return err
+	}
+
+	if err := golang.RunGenerate(ctx); err != nil {
+		return err
+	}
+
+	if err := ctx.LintGate(os.Getenv(env.LintCommand)); err != nil {
+		return err
+	}
+	if err := golang.TestGate(ctx); err != nil {
+		return err
+	}
+	tool, target, err := gcp.BuildTargetFromEnv()
+	if err != nil {
+		return err
+	}
+	if target != "" {
+		return buildWithTarget(ctx, tool, target, outBin)
+	}
+
 	buildable, err := goBuildable(ctx)
 	if err != nil {
 		return fmt.Errorf("unable to find a valid buildable: %w", err)
 	}
 
+	flags, err := goBuildFlags()
+	if err != nil {
+		return err
+	}
+	if cl.Cache {
+		// Cached build output is only valid for the flags it was built with.
+		if err := clearGoCacheIfFlagsChanged(ctx, cl, flags); err != nil {
+			return err
+		}
+	}
+	mode := golang.Linkmode()
+	if mode != "" {
+		ctx.Logf("Linking with linker mode %q.", mode)
+	}
+	if err := clearGoCacheIfLinkmodeChanged(ctx, cl, mode); err != nil {
+		return err
+	}
+
 	// Build the application.
 	bld := []string{"go", "build"}
-	bld = append(bld, goBuildFlags()...)
+	bld = append(bld, flags...)
 	bld = append(bld, "-o", outBin)
 	bld = append(bld, buildable)
 	// BuildDirEnv should only be set by App Engine buildpacks.
@@ -82,7 +143,19 @@ func buildFn(ctx *gcp.Context) error {
 	if workdir == "" {
 		workdir = ctx.ApplicationRoot()
 	}
-	ctx.Exec(bld, gcp.WithEnv("GOCACHE="+cl.Path), gcp.WithWorkDir(workdir), gcp.WithMessageProducer(printTipsAndKeepStderrTail(ctx)), gcp.WithUserAttribution)
+	opts, err := golang.ModuleDownloadOptions(ctx)
+	if err != nil {
+		return err
+	}
+	opts = append(opts, gcp.WithEnv("GOCACHE="+cl.Path), gcp.WithWorkDir(workdir), gcp.WithMessageProducer(printTipsAndKeepStderrTail(ctx)), gcp.WithUserAttribution)
+	ctx.Exec(bld, opts...)
+
+	if err := ctx.PruneTestFiles("go"); err != nil {
+		return err
+	}
+	if err := source.RemoveExportIgnored(ctx); err != nil {
+		return err
+	}
 
 	// Configure the entrypoint for production. Use the full path to save `skaffold debug`
 	// from fetching the remote container image (tens to hundreds of megabytes), which is slow.
@@ -105,6 +178,56 @@ func buildFn(ctx *gcp.Context) error {
 	return nil
 }
 
+// buildWithTarget builds the application with the build tool target instead of `go build`. The
+// binary that the target produces is installed to outBin and used as the entrypoint if
+// GOOGLE_BUILDABLE is set to its path, otherwise GOOGLE_ENTRYPOINT must be set. Dev mode is not
+// supported, as its file watcher rebuilds the application with `go build`.
+func buildWithTarget(ctx *gcp.Context, tool, target, outBin string) error {
+	if devmode.Enabled(ctx) {
+		return gcp.UserErrorf("the %s target %q cannot be used in dev mode, unset %s or %s", tool, target, env.MakeTarget, env.Task)
+	}
+	buildable := os.Getenv(env.Buildable)
+	if buildable == "" && os.Getenv(env.Entrypoint) == "" {
+		return gcp.UserErrorf("set %s to the path of the binary that the %s target %q produces, or set %s", env.Buildable, tool, target, env.Entrypoint)
+	}
+	db, err := builddb.ProvisionFromEnv(ctx)
+	if err != nil {
+		return err
+	}
+	if err := ctx.RunBuildTarget(tool, target, db.ExecOptions()...); err != nil {
+		return err
+	}
+	if err := db.Close(ctx); err != nil {
+		return err
+	}
+	if buildable != "" {
+		bin := filepath.Join(ctx.ApplicationRoot(), buildable)
+		if fi, err := os.Stat(bin); err != nil || !fi.Mode().IsRegular() {
+			return gcp.UserErrorf("%s=%q is not a file produced by the %s target %q", env.Buildable, buildable, tool, target)
+		}
+		data, err := ctx.ReadFile(bin)
+		if err != nil {
+			return err
+		}
+		if err := ctx.WriteFile(outBin, data, 0755); err != nil {
+			return err
+		}
+	}
+
+	if err := ctx.PruneTestFiles("go"); err != nil {
+		return err
+	}
+	if err := source.RemoveExportIgnored(ctx); err != nil {
+		return err
+	}
+	if buildable == "" {
+		ctx.Logf("Using %s as the entrypoint of the application built by the %s target %q.", env.Entrypoint, tool, target)
+		return nil
+	}
+	ctx.AddWebProcess([]string{outBin})
+	return nil
+}
+
 func goBuildable(ctx *gcp.Context) (string, error) {
 	// The user tells us what to build.
 	if buildable, ok := os.LookupEnv(env.Buildable); ok {
@@ -146,15 +269,83 @@ func searchBuildables(ctx *gcp.Context) ([]string, error) {
 	return buildables, nil
 }
 
-func goBuildFlags() []string {
+// setVendorMode adds the -mod flag selected by golang.VendorMode to GOFLAGS, so that every go
+// command run by the buildpack builds with the vendor directory or ignores a stale one.
+func setVendorMode(ctx *gcp.Context) error {
+	mode, err := golang.VendorMode(ctx)
+	if err != nil {
+		return err
+	}
+	if mode == "" {
+		return nil
+	}
+	if mode == golang.ModVendor {
+		ctx.Logf("Building with the vendored dependencies.")
+	}
+	return ctx.Setenv("GOFLAGS", strings.TrimSpace(os.Getenv("GOFLAGS")+" -mod="+mode))
+}
+
+func goBuildFlags() ([]string, error) {
 	var flags []string
 	if v := os.Getenv(env.GoGCFlags); v != "" {
 		flags = append(flags, "-gcflags", v)
 	}
+	tags, err := golang.BuildTags()
+	if err != nil {
+		return nil, err
+	}
+	if tags != "" {
+		flags = append(flags, "-tags", tags)
+	}
+	ldflags, err := golang.BuildLDFlags()
+	if err != nil {
+		return nil, err
+	}
+	// go build only honors the last -ldflags, so GOOGLE_GOLDFLAGS and GOOGLE_GOBUILD_LDFLAGS are combined.
 	if v := os.Getenv(env.GoLDFlags); v != "" {
-		flags = append(flags, "-ldflags", v)
+		ldflags = strings.TrimSpace(v + " " + ldflags)
+	}
+	linkmode, err := golang.LinkmodeFlag(golang.Linkmode())
+	if err != nil {
+		return nil, err
+	}
+	if linkmode != "" {
+		ldflags = strings.TrimSpace(ldflags + " " + linkmode)
+	}
+	if ldflags != "" {
+		flags = append(flags, "-ldflags", ldflags)
+	}
+	return flags, nil
+}
+
+// clearGoCacheIfFlagsChanged clears the GOCACHE layer if the build flags differ from the flags the
+// layer was built with, so that changing the flags invalidates cached output.
+func clearGoCacheIfFlagsChanged(ctx *gcp.Context, l *libcnb.Layer, flags []string) error {
+	key, err := cache.Hash(ctx, cache.WithStrings(flags...))
+	if err != nil {
+		return fmt.Errorf("computing build flags hash: %w", err)
+	}
+	if meta := ctx.GetMetadata(l, buildFlagsKey); meta != "" && meta != key {
+		ctx.Debugf("Build flags have changed, clearing the GOCACHE layer.")
+		if err := ctx.ClearLayer(l); err != nil {
+			return fmt.Errorf("clearing layer %q: %w", l.Name, err)
+		}
 	}
-	return flags
+	ctx.SetMetadata(l, buildFlagsKey, key)
+	return nil
+}
+
+// clearGoCacheIfLinkmodeChanged clears the GOCACHE layer if it was built with a different linker
+// mode than mode, so that cgo output linked for one mode is not reused for another.
+func clearGoCacheIfLinkmodeChanged(ctx *gcp.Context, l *libcnb.Layer, mode string) error {
+	if prev := ctx.GetMetadata(l, linkmodeKey); prev != mode {
+		ctx.Debugf("Linker mode has changed from %q to %q, clearing the GOCACHE layer.", prev, mode)
+		if err := ctx.ClearLayer(l); err != nil {
+			return fmt.Errorf("clearing layer %q: %w", l.Name, err)
+		}
+	}
+	ctx.SetMetadata(l, linkmodeKey, mode)
+	return nil
 }
 
 func printTipsAndKeepStderrTail(ctx *gcp.Context) gcp.MessageProducer {
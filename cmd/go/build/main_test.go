@@ -15,12 +15,19 @@
 package main
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
 	"reflect"
+	"regexp"
 	"strings"
 	"testing"
 
 	"github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
 )
 
 func TestDetect(t *testing.T) {
@@ -58,6 +65,7 @@ func TestGoBuildFlags(t *testing.T) {
 		name     string
 		env      []string
 		expected []string
+		wantErr  bool
 	}{
 		{
 			name:     "no GOOGLE_GOGCFLAGS or GOOGLE_GOLDFLAGS",
@@ -78,11 +86,54 @@ func TestGoBuildFlags(t *testing.T) {
 			env:      []string{"GOOGLE_GOGCFLAGS=gcflags1 gcflags2", "GOOGLE_GOLDFLAGS=ldflags1 ldflags2"},
 			expected: []string{"-gcflags", "gcflags1 gcflags2", "-ldflags", "ldflags1 ldflags2"},
 		},
+		{
+			name:     "with GOOGLE_GOBUILD_TAGS",
+			env:      []string{"GOOGLE_GOBUILD_TAGS=netgo osusergo"},
+			expected: []string{"-tags", "netgo,osusergo"},
+		},
+		{
+			name:     "with GOOGLE_GOBUILD_LDFLAGS",
+			env:      []string{"GOOGLE_GOBUILD_LDFLAGS=-X 'main.version=1.2.3'  -s"},
+			expected: []string{"-ldflags", "-X main.version=1.2.3 -s"},
+		},
+		{
+			name:     "with GOOGLE_GOLDFLAGS and GOOGLE_GOBUILD_LDFLAGS",
+			env:      []string{"GOOGLE_GOLDFLAGS=-w", "GOOGLE_GOBUILD_LDFLAGS=-X \"main.message=hello world\""},
+			expected: []string{"-ldflags", "-w -X 'main.message=hello world'"},
+		},
+		{
+			name:     "with GOOGLE_GO_LINKMODE",
+			env:      []string{"GOOGLE_GO_LINKMODE=external"},
+			expected: []string{"-ldflags", "-linkmode=external"},
+		},
+		{
+			name:     "with GOOGLE_GOLDFLAGS and GOOGLE_GO_LINKMODE",
+			env:      []string{"GOOGLE_GOLDFLAGS=-s -w", "GOOGLE_GO_LINKMODE=internal"},
+			expected: []string{"-ldflags", "-s -w -linkmode=internal"},
+		},
+		{
+			name:    "with invalid GOOGLE_GO_LINKMODE",
+			env:     []string{"GOOGLE_GO_LINKMODE=static"},
+			wantErr: true,
+		},
+		{
+			name:    "with invalid GOOGLE_GOBUILD_TAGS",
+			env:     []string{"GOOGLE_GOBUILD_TAGS=netgo,$(id)"},
+			wantErr: true,
+		},
+		{
+			name:    "with unterminated quote in GOOGLE_GOBUILD_LDFLAGS",
+			env:     []string{"GOOGLE_GOBUILD_LDFLAGS=-X 'main.version"},
+			wantErr: true,
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			clearAndSetEnv(tc.env)
-			result := goBuildFlags()
+			result, err := goBuildFlags()
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("goBuildFlags() got err=%t, want err=%t. err: %v", gotErr, tc.wantErr, err)
+			}
 			if !reflect.DeepEqual(tc.expected, result) {
 				t.Errorf("goBuildFlags() = %v, want %v", result, tc.expected)
 			}
@@ -90,6 +141,336 @@ func TestGoBuildFlags(t *testing.T) {
 	}
 }
 
+func TestBuildGoGenerate(t *testing.T) {
+	testCases := []struct {
+		name         string
+		envs         []string
+		mocks        []*mockprocess.Mock
+		wantGenerate bool
+		wantExitCode int
+	}{
+		{
+			name: "generate skipped by default",
+		},
+		{
+			name:         "generate runs before build",
+			envs:         []string{"GOOGLE_GO_GENERATE=true"},
+			wantGenerate: true,
+		},
+		{
+			name:         "generate disabled",
+			envs:         []string{"GOOGLE_GO_GENERATE=false"},
+			wantGenerate: false,
+		},
+		{
+			name: "generator error fails the build",
+			envs: []string{"GOOGLE_GO_GENERATE=1"},
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^go generate`, mockprocess.WithStderr("generator failed"), mockprocess.WithExitCode(1)),
+			},
+			wantGenerate: true,
+			wantExitCode: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mocks := append(tc.mocks, mockprocess.New(`^go build`))
+			opts := []buildpacktest.Option{
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithEnvs(append(tc.envs, "GOOGLE_BUILDABLE=.")...),
+				buildpacktest.WithExecMocks(mocks...),
+			}
+			result, err := buildpacktest.RunBuild(t, buildFn, opts...)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, result: %#v", err, result)
+			}
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d", result.ExitCode, tc.wantExitCode)
+			}
+
+			generate := strings.Index(result.Output, `Running "go generate ./..."`)
+			if got := generate >= 0; got != tc.wantGenerate {
+				t.Errorf("go generate executed=%t, want %t", got, tc.wantGenerate)
+			}
+			build := strings.Index(result.Output, `Running "go build`)
+			if tc.wantExitCode != 0 {
+				if build >= 0 {
+					t.Errorf("go build executed after a failing go generate")
+				}
+				return
+			}
+			if build < 0 {
+				t.Fatalf("go build was not executed")
+			}
+			if tc.wantGenerate && generate > build {
+				t.Errorf("go generate executed after go build")
+			}
+		})
+	}
+}
+
+func TestBuildDevMode(t *testing.T) {
+	testCases := []struct {
+		name        string
+		envs        []string
+		wantDevMode bool
+	}{
+		{
+			name: "production by default",
+		},
+		{
+			name: "dev mode disabled",
+			envs: []string{"GOOGLE_DEVMODE=false"},
+		},
+		{
+			name:        "dev mode",
+			envs:        []string{"GOOGLE_DEVMODE=true"},
+			wantDevMode: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []buildpacktest.Option{
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithEnvs(append(tc.envs, "GOOGLE_BUILDABLE=.")...),
+				buildpacktest.WithExecMocks(
+					mockprocess.New(`^go build`),
+					// Installs the file watcher in dev mode.
+					mockprocess.New(`^bash -c curl`),
+				),
+			}
+			result, err := buildpacktest.RunBuild(t, buildFn, opts...)
+			if err != nil {
+				t.Fatalf("error running build: %v, result: %#v", err, result)
+			}
+
+			if got := strings.Contains(result.Output, "Installing watchexec"); got != tc.wantDevMode {
+				t.Errorf("file watcher installed=%t, want %t", got, tc.wantDevMode)
+			}
+			// The build cache is kept in the launch image for fast rebuilds in dev mode.
+			re := regexp.MustCompile(fmt.Sprintf(`Using layer "gocache" at \S+ \(build=true, cache=false, launch=%t\)`, tc.wantDevMode))
+			if !re.MatchString(result.Output) {
+				t.Errorf("gocache layer not created with launch=%t, want output matching %q", tc.wantDevMode, re)
+			}
+		})
+	}
+}
+
+func TestBuildWithTarget(t *testing.T) {
+	testCases := []struct {
+		name         string
+		envs         []string
+		files        map[string]string
+		mocks        []*mockprocess.Mock
+		wantTarget   string
+		wantExitCode int
+		wantOutput   string
+	}{
+		{
+			name:       "make target with buildable",
+			envs:       []string{"GOOGLE_MAKE_TARGET=build", "GOOGLE_BUILDABLE=bin/server"},
+			files:      map[string]string{"Makefile": "build:\n\tgo build -o bin/server .\n", "bin/server": "binary"},
+			wantTarget: "make build",
+		},
+		{
+			name:       "task with entrypoint",
+			envs:       []string{"GOOGLE_TASK=build", "GOOGLE_ENTRYPOINT=./bin/server"},
+			files:      map[string]string{"Taskfile.yml": "version: '3'\n"},
+			wantTarget: "task build",
+			wantOutput: "Using GOOGLE_ENTRYPOINT as the entrypoint",
+		},
+		{
+			name:  "failing target aborts the build",
+			envs:  []string{"GOOGLE_MAKE_TARGET=build", "GOOGLE_BUILDABLE=bin/server"},
+			files: map[string]string{"Makefile": "build:\n\tfalse\n"},
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^make build`, mockprocess.WithStderr("make: *** [build] Error 1"), mockprocess.WithExitCode(2)),
+			},
+			wantTarget:   "make build",
+			wantExitCode: 1,
+			wantOutput:   `make target "build" failed`,
+		},
+		{
+			name:         "missing binary",
+			envs:         []string{"GOOGLE_MAKE_TARGET=build", "GOOGLE_BUILDABLE=bin/server"},
+			files:        map[string]string{"Makefile": "build:\n"},
+			wantTarget:   "make build",
+			wantExitCode: 1,
+			wantOutput:   "is not a file produced by the make target",
+		},
+		{
+			name:         "no entrypoint",
+			envs:         []string{"GOOGLE_MAKE_TARGET=build"},
+			files:        map[string]string{"Makefile": "build:\n"},
+			wantExitCode: 1,
+			wantOutput:   "set GOOGLE_BUILDABLE to the path of the binary",
+		},
+		{
+			name:         "dev mode",
+			envs:         []string{"GOOGLE_MAKE_TARGET=build", "GOOGLE_BUILDABLE=bin/server", "GOOGLE_DEVMODE=true"},
+			files:        map[string]string{"Makefile": "build:\n", "bin/server": "binary"},
+			wantExitCode: 1,
+			wantOutput:   `make target "build" cannot be used in dev mode`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mocks := append([]*mockprocess.Mock{mockprocess.New(`^make build`), mockprocess.New(`^task build`), mockprocess.New(`^go build`)}, tc.mocks...)
+			opts := []buildpacktest.Option{
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithEnvs(tc.envs...),
+				buildpacktest.WithFiles(tc.files),
+				buildpacktest.WithExecMocks(mocks...),
+			}
+			result, err := buildpacktest.RunBuild(t, buildFn, opts...)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, result: %#v", err, result)
+			}
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d", result.ExitCode, tc.wantExitCode)
+			}
+			if got := strings.Contains(result.Output, fmt.Sprintf("Running %q", tc.wantTarget)); tc.wantTarget != "" && !got {
+				t.Errorf("%s was not executed, output:\n%s", tc.wantTarget, result.Output)
+			}
+			if strings.Contains(result.Output, `Running "go build`) {
+				t.Errorf("go build executed, want only the build target")
+			}
+			if !strings.Contains(result.Output, tc.wantOutput) {
+				t.Errorf("build output does not contain %q, output:\n%s", tc.wantOutput, result.Output)
+			}
+		})
+	}
+}
+
+func TestClearGoCacheIfFlagsChanged(t *testing.T) {
+	testCases := []struct {
+		name      string
+		oldFlags  []string
+		newFlags  []string
+		wantClear bool
+	}{
+		{
+			name:     "first build",
+			newFlags: []string{"-tags", "netgo"},
+		},
+		{
+			name:     "same flags",
+			oldFlags: []string{"-tags", "netgo"},
+			newFlags: []string{"-tags", "netgo"},
+		},
+		{
+			name:      "tags changed",
+			oldFlags:  []string{"-tags", "netgo"},
+			newFlags:  []string{"-tags", "netgo,osusergo"},
+			wantClear: true,
+		},
+		{
+			name:      "ldflags added",
+			oldFlags:  []string{"-tags", "netgo"},
+			newFlags:  []string{"-tags", "netgo", "-ldflags", "-X main.version=1.2.3"},
+			wantClear: true,
+		},
+		{
+			name:      "linkmode changed",
+			oldFlags:  []string{"-ldflags", "-linkmode=internal"},
+			newFlags:  []string{"-ldflags", "-linkmode=external"},
+			wantClear: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := gcp.NewContext()
+			l := &libcnb.Layer{Name: "gocache", Path: t.TempDir(), Metadata: map[string]interface{}{}}
+			if tc.oldFlags != nil {
+				key, err := cache.Hash(ctx, cache.WithStrings(tc.oldFlags...))
+				if err != nil {
+					t.Fatalf("cache.Hash() got error: %v", err)
+				}
+				ctx.SetMetadata(l, buildFlagsKey, key)
+			}
+			cached := filepath.Join(l.Path, "cached")
+			if err := os.WriteFile(cached, []byte("output"), 0644); err != nil {
+				t.Fatalf("writing %s: %v", cached, err)
+			}
+
+			if err := clearGoCacheIfFlagsChanged(ctx, l, tc.newFlags); err != nil {
+				t.Fatalf("clearGoCacheIfFlagsChanged() got error: %v", err)
+			}
+
+			_, err := os.Stat(cached)
+			if gotClear := os.IsNotExist(err); gotClear != tc.wantClear {
+				t.Errorf("clearGoCacheIfFlagsChanged() cleared=%t, want %t", gotClear, tc.wantClear)
+			}
+			wantKey, err := cache.Hash(ctx, cache.WithStrings(tc.newFlags...))
+			if err != nil {
+				t.Fatalf("cache.Hash() got error: %v", err)
+			}
+			if got := ctx.GetMetadata(l, buildFlagsKey); got != wantKey {
+				t.Errorf("metadata %s=%q, want %q", buildFlagsKey, got, wantKey)
+			}
+		})
+	}
+}
+
+func TestClearGoCacheIfLinkmodeChanged(t *testing.T) {
+	testCases := []struct {
+		name      string
+		oldMode   string
+		newMode   string
+		wantClear bool
+	}{
+		{
+			name: "no linker mode",
+		},
+		{
+			name:    "same linker mode",
+			oldMode: "external",
+			newMode: "external",
+		},
+		{
+			name:      "linker mode changed",
+			oldMode:   "internal",
+			newMode:   "external",
+			wantClear: true,
+		},
+		{
+			name:      "linker mode set",
+			newMode:   "external",
+			wantClear: true,
+		},
+		{
+			name:      "linker mode unset",
+			oldMode:   "external",
+			wantClear: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := gcp.NewContext()
+			l := &libcnb.Layer{Name: "gocache", Path: t.TempDir(), Metadata: map[string]interface{}{}}
+			if tc.oldMode != "" {
+				ctx.SetMetadata(l, linkmodeKey, tc.oldMode)
+			}
+			cached := filepath.Join(l.Path, "cached")
+			if err := os.WriteFile(cached, []byte("output"), 0644); err != nil {
+				t.Fatalf("writing %s: %v", cached, err)
+			}
+
+			if err := clearGoCacheIfLinkmodeChanged(ctx, l, tc.newMode); err != nil {
+				t.Fatalf("clearGoCacheIfLinkmodeChanged() got error: %v", err)
+			}
+
+			_, err := os.Stat(cached)
+			if gotClear := os.IsNotExist(err); gotClear != tc.wantClear {
+				t.Errorf("clearGoCacheIfLinkmodeChanged() cleared=%t, want %t", gotClear, tc.wantClear)
+			}
+			if got := ctx.GetMetadata(l, linkmodeKey); got != tc.newMode {
+				t.Errorf("metadata %s=%q, want %q", linkmodeKey, got, tc.newMode)
+			}
+		})
+	}
+}
+
 func clearAndSetEnv(env []string) {
 	os.Clearenv()
 	for _, p := range env {
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/golang"
 )
@@ -44,14 +45,30 @@ func buildFn(ctx *gcp.Context) error {
 	if err != nil {
 		return fmt.Errorf("creating GOPATH layer: %w", err)
 	}
+	ml, err := golang.NewGoModCacheLayer(ctx)
+	if err != nil {
+		return fmt.Errorf("creating GOMODCACHE layer: %w", err)
+	}
+
+	mode, err := golang.VendorMode(ctx)
+	if err != nil {
+		return err
+	}
+	if mode == "" {
+		mode = golang.GoFlagsMod()
+	}
+	if mode == golang.ModVendor {
+		ctx.Logf("Not downloading modules because the build uses the `vendor` directory")
+		return nil
+	}
 
 	vendorExists, err := ctx.FileExists("vendor")
 	if err != nil {
 		return err
 	}
 	// When there's a vendor folder and go is 1.14+, we shouldn't download the modules
-	// and let go build use the vendored dependencies.
-	if vendorExists {
+	// and let go build use the vendored dependencies, unless -mod selects another mode.
+	if vendorExists && mode == "" {
 		avSupport, err := golang.SupportsAutoVendor(ctx)
 		if err != nil {
 			return fmt.Errorf("checking for auto vendor support: %w", err)
@@ -64,6 +81,15 @@ func buildFn(ctx *gcp.Context) error {
 		ctx.Warnf(`Ignoring "vendor" directory: To use vendor directory, the Go runtime must be 1.14+ and go.mod must contain a "go 1.14"+ entry. See https://cloud.google.com/appengine/docs/standard/go/specifying-dependencies#vendoring_dependencies.`)
 	}
 
+	disabled, err := golang.ModuleDownloadsDisabled()
+	if err != nil {
+		return err
+	}
+	if disabled {
+		ctx.Logf("Not downloading modules because %s=%s, the build uses the module cache", env.GoProxy, golang.ProxyOff)
+		return nil
+	}
+
 	goModIsWriteable, err := ctx.IsWritable("go.mod")
 	if err != nil {
 		return err
@@ -73,7 +99,7 @@ func buildFn(ctx *gcp.Context) error {
 		//     go: updates to go.sum needed, disabled by -mod=readonly
 		return gcp.UserErrorf("go.mod exists but is not writable")
 	}
-	env := []string{"GOPATH=" + l.Path, "GO111MODULE=on"}
+	env := []string{"GOPATH=" + l.Path, "GOMODCACHE=" + ml.Path, "GO111MODULE=on"}
 
 	// BuildDirEnv should only be set by App Engine buildpacks.
 	workdir := os.Getenv(golang.BuildDirEnv)
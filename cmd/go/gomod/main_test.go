@@ -15,9 +15,11 @@
 package main
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
 )
 
 func TestDetect(t *testing.T) {
@@ -45,3 +47,50 @@ func TestDetect(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildGoProxy(t *testing.T) {
+	testCases := []struct {
+		name         string
+		envs         []string
+		wantOutput   string
+		wantExitCode int
+	}{
+		{
+			name:       "off disables downloads",
+			envs:       []string{"GOOGLE_GOPROXY=off"},
+			wantOutput: "Not downloading modules because GOOGLE_GOPROXY=off",
+		},
+		{
+			name:         "invalid proxy",
+			envs:         []string{"GOOGLE_GOPROXY=proxy.example.com"},
+			wantOutput:   `invalid GOOGLE_GOPROXY="proxy.example.com"`,
+			wantExitCode: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []buildpacktest.Option{
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithEnvs(tc.envs...),
+				buildpacktest.WithExecMocks(
+					mockprocess.New(`^go version`, mockprocess.WithStdout("go version go1.20.1 linux/amd64")),
+					mockprocess.New(`^go clean`),
+					mockprocess.New(`^go mod`),
+				),
+			}
+			result, err := buildpacktest.RunBuild(t, buildFn, opts...)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, result: %#v", err, result)
+			}
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d", result.ExitCode, tc.wantExitCode)
+			}
+			if !strings.Contains(result.Output, tc.wantOutput) {
+				t.Errorf("build output does not contain %q, got:\n%s", tc.wantOutput, result.Output)
+			}
+			if strings.Contains(result.Output, `Running "go mod`) {
+				t.Errorf("build ran go mod, want no module downloads:\n%s", result.Output)
+			}
+		})
+	}
+}
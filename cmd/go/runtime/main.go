@@ -98,6 +98,7 @@ func buildFn(ctx *gcp.Context) error {
 		Launch:   true,
 		Build:    true,
 	})
+	ctx.RecordVersion(gcp.VersionCategoryRuntime, "go", version)
 
 	return nil
 }
@@ -23,6 +23,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/builddb"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
@@ -55,6 +56,13 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 	if buildGradleKTSExists {
 		return gcp.OptInFileFound("build.gradle.kts"), nil
 	}
+	reason, buildFile, err := java.DetectKotlin(ctx.ApplicationRoot())
+	if err != nil {
+		return nil, err
+	}
+	if reason != "" && buildFile != "pom.xml" {
+		return gcp.OptIn(reason), nil
+	}
 	return gcp.OptOut("neither build.gradle nor build.gradle.kts found"), nil
 }
 
@@ -97,6 +105,16 @@ func buildFn(ctx *gcp.Context) error {
 
 	command := []string{gradle, "clean", "assemble", "-x", "test", "--build-cache"}
 
+	if err := java.CheckKotlinPlugin(ctx); err != nil {
+		return err
+	}
+
+	workers, err := java.BuildThreadFlags(java.ToolGradle, os.Getenv(java.GradleWorkersEnv))
+	if err != nil {
+		return err
+	}
+	command = append(command, workers...)
+
 	if buildArgs := os.Getenv(env.BuildArgs); buildArgs != "" {
 		if strings.Contains(buildArgs, "project-cache-dir") {
 			ctx.Warnf("Detected project-cache-dir property set in GOOGLE_BUILD_ARGS. Dependency caching may not work properly.")
@@ -108,7 +126,14 @@ func buildFn(ctx *gcp.Context) error {
 		command = append(command, "--quiet")
 	}
 
-	ctx.Exec(command, gcp.WithUserAttribution)
+	db, err := builddb.ProvisionFromEnv(ctx)
+	if err != nil {
+		return err
+	}
+	ctx.Exec(command, append([]gcp.ExecOption{gcp.WithUserAttribution}, db.ExecOptions()...)...)
+	if err := db.Close(ctx); err != nil {
+		return err
+	}
 
 	// Store the build steps in a script to be run on each file change.
 	if devmode.Enabled(ctx) {
@@ -136,6 +161,7 @@ func installGradle(ctx *gcp.Context) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("getting latest gradle version: %w", err)
 	}
+	ctx.RecordVersion(gcp.VersionCategoryBuildTool, "gradle", gradleVersion)
 	if gradleVersion == metaVersion {
 		ctx.CacheHit(gradleLayer)
 		ctx.Logf("Gradle cache hit, skipping installation.")
@@ -40,6 +40,23 @@ func TestDetect(t *testing.T) {
 			},
 			want: 0,
 		},
+		{
+			name: "kotlin multi-project settings.gradle.kts",
+			files: map[string]string{
+				"settings.gradle.kts":                    `include("app")`,
+				"app/build.gradle.kts":                   `plugins { kotlin("jvm") }`,
+				"app/src/main/kotlin/com/example/App.kt": "fun main() {}",
+			},
+			want: 0,
+		},
+		{
+			name: "kotlin maven project",
+			files: map[string]string{
+				"pom.xml":                            "",
+				"src/main/kotlin/com/example/App.kt": "fun main() {}",
+			},
+			want: 100,
+		},
 		{
 			name:  "no files",
 			files: map[string]string{},
@@ -23,12 +23,15 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/builddb"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/java"
+	"github.com/buildpacks/libcnb"
 )
 
 const (
@@ -37,7 +40,18 @@ const (
 	mavenURL     = "https://downloads.apache.org/maven/maven-3/%[1]s/binaries/apache-maven-%[1]s-bin.tar.gz"
 	mavenLayer   = "maven"
 	m2Layer      = "m2"
-	versionKey   = "version"
+	// repoLayer is the local Maven repository, cached until a pom.xml changes.
+	repoLayer = "maven-repository"
+	// moduleLayer passes the selected module of a multi-module project to later buildpacks.
+	moduleLayer = "maven-module"
+	versionKey  = "version"
+	urlKey      = "url"
+	pomHashKey  = "pom_hash"
+)
+
+var (
+	// distributionVersionRegexp matches the version in the file name of a Maven distribution.
+	distributionVersionRegexp = regexp.MustCompile(`apache-maven-([^/]+)-bin\.(?:tar\.gz|zip)$`)
 )
 
 func main() {
@@ -67,6 +81,10 @@ func buildFn(ctx *gcp.Context) error {
 	if err != nil {
 		return fmt.Errorf("creating %v layer: %w", m2Layer, err)
 	}
+	// Nothing else invalidates the Maven Wrapper distributions in ~/.m2, so clear them when the buildpack changes.
+	if _, err := ctx.CheckBuildpackVersion(m2CachedRepo); err != nil {
+		return err
+	}
 	if err := java.CheckCacheExpiration(ctx, m2CachedRepo); err != nil {
 		return fmt.Errorf("validating the cache: %w", err)
 	}
@@ -83,32 +101,44 @@ func buildFn(ctx *gcp.Context) error {
 		return err
 	}
 
+	repo, err := repositoryLayer(ctx)
+	if err != nil {
+		return err
+	}
+
 	if err := addJvmConfig(ctx); err != nil {
 		return err
 	}
 
-	var mvn string
-	mvnwExists, err := ctx.FileExists("mvnw")
+	mvn, err := mavenCommand(ctx)
 	if err != nil {
 		return err
 	}
-	if mvnwExists {
-		// With CRLF endings, the "\r" gets seen as part of the shebang target, which doesn't exist.
-		if err := ensureUnixLineEndings(ctx, "mvnw"); err != nil {
-			return fmt.Errorf("ensuring unix newline characters: %w", err)
-		}
-		mvn = "./mvnw"
-	} else if mvnInstalled(ctx) {
-		mvn = "mvn"
-	} else {
-		var err error
-		mvn, err = installMaven(ctx)
-		if err != nil {
-			return fmt.Errorf("installing Maven: %w", err)
-		}
+
+	if err := java.CheckKotlinPlugin(ctx); err != nil {
+		return err
 	}
 
-	command := []string{mvn, "clean", "package", "--batch-mode", "-DskipTests", "-Dhttp.keepAlive=false"}
+	modules, err := java.MavenModules(ctx.ApplicationRoot())
+	if err != nil {
+		return err
+	}
+	module, err := java.RequestedMavenModule(modules)
+	if err != nil {
+		return err
+	}
+
+	command := append([]string{mvn}, java.MavenGoals()...)
+	command = append(command, "--batch-mode", "-Dhttp.keepAlive=false", "-Dmaven.repo.local="+repo.Path)
+	if module != "" {
+		// Build the module and the modules it depends on.
+		command = append(command, "-pl", module, "-am")
+	}
+	threads, err := java.BuildThreadFlags(java.ToolMaven, os.Getenv(java.MavenThreadsEnv))
+	if err != nil {
+		return err
+	}
+	command = append(command, threads...)
 
 	if buildArgs := os.Getenv(env.BuildArgs); buildArgs != "" {
 		if strings.Contains(buildArgs, "maven.repo.local") {
@@ -121,7 +151,25 @@ func buildFn(ctx *gcp.Context) error {
 		command = append(command, "--quiet")
 	}
 
-	ctx.Exec(command, gcp.WithStdoutTail, gcp.WithUserAttribution)
+	db, err := builddb.ProvisionFromEnv(ctx)
+	if err != nil {
+		return err
+	}
+	ctx.Exec(command, append([]gcp.ExecOption{gcp.WithStdoutTail, gcp.WithUserAttribution}, db.ExecOptions()...)...)
+	if err := db.Close(ctx); err != nil {
+		return err
+	}
+
+	if len(modules) > 0 && module == "" && os.Getenv(env.Entrypoint) == "" {
+		if module, err = java.ExecutableMavenModule(ctx, ctx.ApplicationRoot(), modules); err != nil {
+			return err
+		}
+	}
+	if module != "" {
+		if err := setModule(ctx, module); err != nil {
+			return err
+		}
+	}
 
 	// Store the build steps in a script to be run on each file change.
 	if devmode.Enabled(ctx) {
@@ -160,21 +208,109 @@ func addJvmConfig(ctx *gcp.Context) error {
 	return nil
 }
 
+// setModule makes the module whose executable jar is launched known to the entrypoint buildpack.
+func setModule(ctx *gcp.Context, module string) error {
+	ctx.Logf("Using the executable jar of module %s.", module)
+	l, err := ctx.Layer(moduleLayer, gcp.BuildLayer)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", moduleLayer, err)
+	}
+	l.BuildEnvironment.Override(java.ModuleDirEnv, module)
+	return nil
+}
+
+// repositoryLayer returns the layer of the local Maven repository, cleared if the pom.xml files
+// of the application have changed since it was cached.
+func repositoryLayer(ctx *gcp.Context) (*libcnb.Layer, error) {
+	repo, err := ctx.Layer(repoLayer, gcp.CacheLayer, gcp.LaunchLayerIfDevMode)
+	if err != nil {
+		return nil, fmt.Errorf("creating %v layer: %w", repoLayer, err)
+	}
+	if err := java.CheckCacheExpiration(ctx, repo); err != nil {
+		return nil, fmt.Errorf("validating the cache: %w", err)
+	}
+	hash, err := java.MavenRepositoryCacheKey(ctx, ctx.ApplicationRoot())
+	if err != nil {
+		return nil, err
+	}
+	if ctx.GetMetadata(repo, pomHashKey) == hash {
+		ctx.CacheHit(repoLayer)
+		return repo, nil
+	}
+	ctx.CacheMiss(repoLayer)
+	if ctx.GetMetadata(repo, pomHashKey) != "" {
+		ctx.Logf("pom.xml files changed, clearing the cached Maven repository.")
+		if err := ctx.ClearLayer(repo); err != nil {
+			return nil, fmt.Errorf("clearing layer %q: %w", repo.Name, err)
+		}
+	}
+	ctx.SetMetadata(repo, pomHashKey, hash)
+	return repo, nil
+}
+
+// mavenCommand returns the command that runs Maven: the Maven Wrapper script, if present, else
+// Maven installed from the distribution pinned by the wrapper properties, the Maven on PATH, or
+// Maven installed at the default version, in this order.
+func mavenCommand(ctx *gcp.Context) (string, error) {
+	mvnwExists, err := java.MavenWrapper(ctx.ApplicationRoot())
+	if err != nil {
+		return "", err
+	}
+	if mvnwExists {
+		// With CRLF endings, the "\r" gets seen as part of the shebang target, which doesn't exist.
+		if err := ensureUnixLineEndings(ctx, "mvnw"); err != nil {
+			return "", fmt.Errorf("ensuring unix newline characters: %w", err)
+		}
+		ctx.Logf("Using the Maven Wrapper.")
+		return "./mvnw", nil
+	}
+	distURL, err := java.WrapperDistributionURL(ctx.ApplicationRoot())
+	if err != nil {
+		return "", err
+	}
+	if distURL != "" {
+		version := distURL
+		if m := distributionVersionRegexp.FindStringSubmatch(distURL); m != nil {
+			version = m[1]
+		}
+		// Maven is distributed as both a zip and a tarball at the same location.
+		if strings.HasSuffix(distURL, ".zip") {
+			distURL = strings.TrimSuffix(distURL, ".zip") + ".tar.gz"
+		}
+		ctx.Logf("Using Maven distribution %s pinned by the Maven Wrapper properties.", distURL)
+		mvn, err := installMaven(ctx, version, distURL)
+		if err != nil {
+			return "", fmt.Errorf("installing Maven: %w", err)
+		}
+		return mvn, nil
+	}
+	if mvnInstalled(ctx) {
+		return "mvn", nil
+	}
+	mvn, err := installMaven(ctx, mavenVersion, fmt.Sprintf(mavenURL, mavenVersion))
+	if err != nil {
+		return "", fmt.Errorf("installing Maven: %w", err)
+	}
+	return mvn, nil
+}
+
 func mvnInstalled(ctx *gcp.Context) bool {
 	result := ctx.Exec([]string{"bash", "-c", "command -v mvn || true"})
 	return result.Stdout != ""
 }
 
-// installMaven installs Maven and returns the path of the mvn binary
-func installMaven(ctx *gcp.Context) (string, error) {
+// installMaven installs Maven from the archive at archiveURL and returns the path of the mvn binary
+func installMaven(ctx *gcp.Context, version, archiveURL string) (string, error) {
 	mvnl, err := ctx.Layer(mavenLayer, gcp.CacheLayer, gcp.BuildLayer, gcp.LaunchLayerIfDevMode)
 	if err != nil {
 		return "", fmt.Errorf("creating %v layer: %w", mavenLayer, err)
 	}
 
+	ctx.RecordVersion(gcp.VersionCategoryBuildTool, "maven", version)
 	// Check the metadata in the cache layer to determine if we need to proceed.
 	metaVersion := ctx.GetMetadata(mvnl, versionKey)
-	if mavenVersion == metaVersion {
+	metaURL := ctx.GetMetadata(mvnl, urlKey)
+	if version == metaVersion && (metaURL == "" || metaURL == archiveURL) {
 		ctx.CacheHit(mavenLayer)
 		ctx.Logf("Maven cache hit, skipping installation.")
 		return filepath.Join(mvnl.Path, "bin", "mvn"), nil
@@ -185,19 +321,19 @@ func installMaven(ctx *gcp.Context) (string, error) {
 	}
 
 	// Download and install maven in layer.
-	ctx.Logf("Installing Maven v%s", mavenVersion)
-	archiveURL := fmt.Sprintf(mavenURL, mavenVersion)
+	ctx.Logf("Installing Maven v%s", version)
 	code, err := ctx.HTTPStatus(archiveURL)
 	if err != nil {
 		return "", err
 	}
 	if code != http.StatusOK {
-		return "", gcp.UserErrorf("Maven version %s does not exist at %s (status %d).", mavenVersion, archiveURL, code)
+		return "", gcp.UserErrorf("Maven version %s does not exist at %s (status %d).", version, archiveURL, code)
 	}
 	command := fmt.Sprintf("curl --fail --show-error --silent --location --retry 3 %s | tar xz --directory %s --strip-components=1", archiveURL, mvnl.Path)
 	ctx.Exec([]string{"bash", "-c", command}, gcp.WithUserAttribution)
 
-	ctx.SetMetadata(mvnl, versionKey, mavenVersion)
+	ctx.SetMetadata(mvnl, versionKey, version)
+	ctx.SetMetadata(mvnl, urlKey, archiveURL)
 	return filepath.Join(mvnl.Path, "bin", "mvn"), nil
 }
 
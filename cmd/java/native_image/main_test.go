@@ -132,7 +132,7 @@ func TestGetClasspathAndMainFromSpringBoot(t *testing.T) {
 			name:          "normal spring boot",
 			setupJar:      true,
 			manifest:      "Main-Class: com.example.Main\nStart-Class: com.example.Start",
-			wantClasspath: regexp.MustCompile(".+/exploded-jar:.+/exploded-jar/BOOT-INF/classes:.+/exploded-jar/BOOT-INF/lib/\\*"),
+			wantClasspath: regexp.MustCompile(".+/exploded-jar[0-9]*:.+/exploded-jar[0-9]*/BOOT-INF/classes:.+/exploded-jar[0-9]*/BOOT-INF/lib/\\*"),
 			wantMain:      "com.example.Start",
 		},
 		{
@@ -20,20 +20,19 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"os"
 	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/java"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtime"
 	"github.com/buildpacks/libcnb"
 )
 
 const (
-	javaLayer             = "java"
-	javaVersionURL        = "https://api.adoptopenjdk.net/v3/assets/feature_releases/%s/ga?architecture=x64&heap_size=normal&image_type=jdk&jvm_impl=hotspot&os=linux&page=0&page_size=1&project=jdk&sort_order=DESC&vendor=adoptopenjdk"
-	defaultFeatureVersion = "11"
-	versionKey            = "version"
+	javaLayer      = "java"
+	javaVersionURL = "https://api.adoptopenjdk.net/v3/assets/feature_releases/%s/ga?architecture=x64&heap_size=normal&image_type=jdk&jvm_impl=hotspot&os=linux&page=0&page_size=1&project=jdk&sort_order=DESC&vendor=adoptopenjdk"
+	versionKey     = "version"
 )
 
 func main() {
@@ -44,6 +43,13 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 	if result := runtime.CheckOverride("java"); result != nil {
 		return result, nil
 	}
+	reason, _, err := java.DetectKotlin(ctx.ApplicationRoot())
+	if err != nil {
+		return nil, err
+	}
+	if reason != "" {
+		return gcp.OptIn(reason), nil
+	}
 
 	files := []string{
 		"pom.xml",
@@ -80,12 +86,18 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 }
 
 func buildFn(ctx *gcp.Context) error {
-	featureVersion := defaultFeatureVersion
-	if v := os.Getenv(env.RuntimeVersion); v != "" {
-		featureVersion = v
-		ctx.Logf("Using requested runtime feature version: %s", featureVersion)
+	version, source, err := java.RequestedJavaVersion(ctx.ApplicationRoot())
+	if err != nil {
+		return err
+	}
+	// The AdoptOpenJDK API serves the latest release of a feature version, e.g. 17 for 17.0.2.
+	featureVersion := strings.SplitN(version, ".", 2)[0]
+	if source == "" {
+		ctx.Logf("Using latest Java %s runtime version. You can specify a different version with %s: https://github.com/GoogleCloudPlatform/buildpacks#configuration", featureVersion, env.RuntimeVersion)
+	} else if featureVersion != version {
+		ctx.Logf("Using the latest release of runtime feature version %s for version %s requested by %s.", featureVersion, version, source)
 	} else {
-		ctx.Logf("Using latest Java %s runtime version. You can specify a different version with %s: https://github.com/GoogleCloudPlatform/buildpacks#configuration", defaultFeatureVersion, env.RuntimeVersion)
+		ctx.Logf("Using requested runtime feature version %s from %s.", featureVersion, source)
 	}
 
 	releaseURL := fmt.Sprintf(javaVersionURL, featureVersion)
@@ -114,6 +126,7 @@ func buildFn(ctx *gcp.Context) error {
 		Build:    true,
 		Launch:   true,
 	})
+	ctx.RecordVersion(gcp.VersionCategoryRuntime, "openjdk", version)
 
 	// Check the metadata in the cache layer to determine if we need to proceed.
 	l, err := ctx.Layer(javaLayer, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayer)
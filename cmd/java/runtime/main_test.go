@@ -86,6 +86,14 @@ func TestDetect(t *testing.T) {
 			},
 			want: 0,
 		},
+		{
+			name: "kotlin sources with settings.gradle.kts",
+			files: map[string]string{
+				"settings.gradle.kts":                    `include("app")`,
+				"app/src/main/kotlin/com/example/App.kt": "fun main() {}",
+			},
+			want: 0,
+		},
 		{
 			name:  "no java files",
 			files: map[string]string{},
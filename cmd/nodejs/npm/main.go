@@ -22,11 +22,14 @@ import (
 	"path/filepath"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/ar"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/builddb"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildermetrics"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/source"
 )
 
 const (
@@ -38,12 +41,8 @@ func main() {
 }
 
 func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
-	pkgJSONExists, err := ctx.FileExists("package.json")
-	if err != nil {
-		return nil, err
-	}
-	if !pkgJSONExists {
-		return gcp.OptOutFileNotFound("package.json"), nil
+	if result, optOut, err := nodejs.PackageJSONOptOut(ctx); err != nil || optOut {
+		return result, err
 	}
 	return gcp.OptInFileFound("package.json"), nil
 }
@@ -61,24 +60,79 @@ func buildFn(ctx *gcp.Context) error {
 		return fmt.Errorf("generating Artifact Registry credentials: %w", err)
 	}
 
-	if err := upgradeNPM(ctx); err != nil {
+	sslFlags, err := nodejs.StrictSSLFlag(ctx)
+	if err != nil {
+		return err
+	}
+	if err := upgradeNPM(ctx, sslFlags); err != nil {
 		return err
 	}
+	ctx.RecordVersion(gcp.VersionCategoryPackageManager, "npm", nodejs.NPMVersion(ctx))
 
 	lockfile, err := nodejs.EnsureLockfile(ctx)
 	if err != nil {
 		return err
 	}
+	workspace, err := npmWorkspace(ctx)
+	if err != nil {
+		return err
+	}
+	cacheFiles := []string{"package.json", lockfile}
+	var wsFlags []string
+	if workspace != nil {
+		cacheFiles = workspace.CacheFiles
+		wsFlags = workspace.Flags
+	}
+
+	offline, err := nodejs.ConfiguredOfflineInstall(ctx)
+	if err != nil {
+		return err
+	}
+	// An offline install uses the mirror as its npm cache instead.
+	installOpts := append(offline.ExecOptions(), gcp.WithUserAttribution)
+	var npmCache *nodejs.NPMCache
+	if offline.Mirror == "" {
+		if npmCache, err = nodejs.NewNPMCache(ctx); err != nil {
+			return err
+		}
+		installOpts = append(installOpts, npmCache.ExecOptions()...)
+	}
 
 	nodeEnv := nodejs.NodeEnv()
+	tool, target, err := gcp.BuildTargetFromEnv()
+	if err != nil {
+		return err
+	}
 	gcpBuild, err := nodejs.HasGCPBuild(ctx.ApplicationRoot())
 	if err != nil {
 		return err
 	}
-	if gcpBuild {
+	astroMode, err := nodejs.AstroMode(ctx.ApplicationRoot())
+	if err != nil {
+		return err
+	}
+	var buildScript string
+	if !gcpBuild && astroMode == "" {
+		if buildScript, err = nodejs.BuildScript(ctx); err != nil {
+			return err
+		}
+	}
+	var devFlags []string
+	if target != "" || gcpBuild || astroMode != "" || buildScript != "" {
+		// Install devDependencies, which are usually needed to build the app. They are pruned after
+		// the build.
 		nodeEnv = nodejs.EnvDevelopment
+	} else if devFlags, err = nodejs.NPMDevDependencyFlags(ctx); err != nil {
+		return err
 	}
-	cached, err := nodejs.CheckCache(ctx, ml, cache.WithStrings(nodeEnv), cache.WithFiles("package.json", lockfile))
+	// The cached and the fresh install run with the same flags and environment.
+	installFlags := []string{"--quiet"}
+	installFlags = append(installFlags, wsFlags...)
+	installFlags = append(installFlags, devFlags...)
+	installFlags = append(installFlags, offline.Flags...)
+	installFlags = append(installFlags, sslFlags...)
+	installOpts = append(installOpts, gcp.WithEnv("NODE_ENV="+nodeEnv))
+	cached, err := nodejs.CheckCache(ctx, ml, cache.WithStrings(append([]string{nodeEnv}, devFlags...)...), cache.WithFiles(cacheFiles...))
 	if err != nil {
 		return fmt.Errorf("checking cache: %w", err)
 	}
@@ -89,7 +143,7 @@ func buildFn(ctx *gcp.Context) error {
 
 		// Always run npm install to run preinstall/postinstall scripts.
 		// Otherwise it should be a no-op because the lockfile is unchanged.
-		ctx.Exec([]string{"npm", "install", "--quiet"}, gcp.WithEnv("NODE_ENV="+nodeEnv), gcp.WithUserAttribution)
+		ctx.Exec(append([]string{"npm", "install"}, installFlags...), installOpts...)
 	} else {
 		installCmd, err := nodejs.NPMInstallCommand(ctx)
 		if err != nil {
@@ -101,7 +155,7 @@ func buildFn(ctx *gcp.Context) error {
 			return fmt.Errorf("clearing layer %q: %w", ml.Name, err)
 		}
 
-		ctx.Exec([]string{"npm", installCmd, "--quiet"}, gcp.WithEnv("NODE_ENV="+nodeEnv), gcp.WithUserAttribution)
+		ctx.Exec(append([]string{"npm", installCmd}, installFlags...), installOpts...)
 
 		// Ensure node_modules exists even if no dependencies were installed.
 		if err := ctx.MkdirAll("node_modules", 0755); err != nil {
@@ -109,21 +163,66 @@ func buildFn(ctx *gcp.Context) error {
 		}
 		ctx.Exec([]string{"cp", "--archive", "node_modules", nm}, gcp.WithUserTimingAttribution)
 	}
+	if npmCache != nil {
+		if err := npmCache.Prune(ctx); err != nil {
+			return fmt.Errorf("pruning the npm cache: %w", err)
+		}
+	}
 
-	if gcpBuild {
-		ctx.Exec([]string{"npm", "run", "gcp-build"}, gcp.WithUserAttribution)
+	if err := ctx.LintGate(os.Getenv(env.LintCommand)); err != nil {
+		return err
+	}
+
+	db, err := builddb.ProvisionFromEnv(ctx)
+	if err != nil {
+		return err
+	}
+	buildOpts := append([]gcp.ExecOption{gcp.WithUserAttribution}, db.ExecOptions()...)
+	if target != "" {
+		// The build target replaces the build scripts of package.json.
+		if err := ctx.RunBuildTarget(tool, target, db.ExecOptions()...); err != nil {
+			return err
+		}
+	} else if gcpBuild {
+		ctx.Exec([]string{"npm", "run", "gcp-build"}, buildOpts...)
 		buildermetrics.GlobalBuilderMetrics().GetCounter(buildermetrics.NpmGcpBuildUsageCounterID).Increment(1)
+	} else if astroMode != "" {
+		buildCmd, err := nodejs.AstroBuildCommand(ctx.ApplicationRoot())
+		if err != nil {
+			return err
+		}
+		ctx.Logf("Building the %s Astro app.", astroMode)
+		ctx.Exec(buildCmd, buildOpts...)
+	} else if buildScript != "" {
+		// The build runs in the application directory, so its output is part of the application
+		// image. The cached node_modules layer was already updated above.
+		ctx.Exec([]string{"npm", "run", buildScript}, buildOpts...)
+	}
+	if err := db.Close(ctx); err != nil {
+		return err
+	}
 
+	if target != "" || gcpBuild || astroMode != "" || buildScript != "" {
 		shouldPrune, err := shouldPrune(ctx)
 		if err != nil {
 			return err
 		}
 		if shouldPrune {
 			// npm prune deletes devDependencies from node_modules
-			ctx.Exec([]string{"npm", "prune"}, gcp.WithUserAttribution)
+			ctx.Exec(append([]string{"npm", "prune"}, wsFlags...), gcp.WithUserAttribution)
 		}
 	}
 
+	if err := nodejs.ResolveHoistedAppModules(ctx); err != nil {
+		return err
+	}
+	if err := ctx.PruneTestFiles("nodejs"); err != nil {
+		return err
+	}
+	if err := source.RemoveExportIgnored(ctx); err != nil {
+		return err
+	}
+
 	el, err := ctx.Layer("env", gcp.BuildLayer, gcp.LaunchLayer)
 	if err != nil {
 		return fmt.Errorf("creating layer: %w", err)
@@ -132,7 +231,12 @@ func buildFn(ctx *gcp.Context) error {
 	el.SharedEnvironment.Default("NODE_ENV", nodejs.NodeEnv())
 
 	// Configure the entrypoint for production.
-	cmd := []string{"npm", "start"}
+	cmd := append([]string{"npm", "start"}, wsFlags...)
+	if astroMode != "" {
+		if cmd, err = nodejs.AstroWebProcess(ctx, astroMode); err != nil {
+			return err
+		}
+	}
 
 	if !devmode.Enabled(ctx) {
 		ctx.AddWebProcess(cmd)
@@ -151,23 +255,62 @@ func buildFn(ctx *gcp.Context) error {
 	return nil
 }
 
+// npmWorkspace returns the install of the workspace selected by GOOGLE_NODEJS_WORKSPACE, or nil if
+// no workspace is selected.
+func npmWorkspace(ctx *gcp.Context) (*nodejs.NPMWorkspace, error) {
+	selected := os.Getenv(nodejs.EnvWorkspace)
+	if selected == "" {
+		return nil, nil
+	}
+	member, err := nodejs.FindWorkspace(ctx.ApplicationRoot(), selected)
+	if err != nil {
+		return nil, err
+	}
+	ctx.Logf("Using workspace %s in %s from %s.", member.Name, member.Dir, nodejs.EnvWorkspace)
+	return nodejs.NPMWorkspaceInstall(ctx, member)
+}
+
 func shouldPrune(ctx *gcp.Context) (bool, error) {
 	// if there are no devDependencies, there is no need to prune.
 	if devDeps, err := nodejs.HasDevDependencies(ctx.ApplicationRoot()); err != nil || !devDeps {
 		return false, err
 	}
-	if nodeEnv := nodejs.NodeEnv(); nodeEnv != nodejs.EnvProduction {
-		ctx.Logf("Retaining devDependencies because $NODE_ENV=%q.", nodeEnv)
+	keep, reason, err := nodejs.KeepDevDependencies()
+	if err != nil {
+		return false, err
+	}
+	if keep {
+		ctx.Logf("Retaining devDependencies because %s.", reason)
 		return false, nil
 	}
 	canPrune, err := nodejs.SupportsNPMPrune(ctx)
-	if err == nil && !canPrune {
-		ctx.Warnf("Retaining devDependencies because the version of NPM you are using does not support 'npm prune'.")
+	if err != nil {
+		return false, err
 	}
-	return canPrune, err
+	if !canPrune {
+		if err := ctx.StrictWarnf("Retaining devDependencies because the version of NPM you are using does not support 'npm prune'."); err != nil {
+			return false, err
+		}
+	}
+	return canPrune, nil
 }
 
-func upgradeNPM(ctx *gcp.Context) error {
+func upgradeNPM(ctx *gcp.Context, sslFlags []string) error {
+	pm, err := nodejs.RequestedPackageManager(ctx.ApplicationRoot())
+	if err != nil {
+		return err
+	}
+	if pm != nil && pm.Name == "npm" {
+		// The "packageManager" field pins an exact npm version, which takes precedence over engines.npm.
+		return nodejs.InstallPackageManager(ctx, pm)
+	}
+	if pm != nil && pm.Name == "pnpm" {
+		// Dependencies are still installed with npm, the pinned pnpm is available to the scripts of
+		// package.json.
+		if err := nodejs.InstallPackageManager(ctx, pm); err != nil {
+			return err
+		}
+	}
 	npmVersion, err := nodejs.RequestedNPMVersion(ctx.ApplicationRoot())
 	if err != nil {
 		return err
@@ -188,7 +331,7 @@ func upgradeNPM(ctx *gcp.Context) error {
 	ctx.ClearLayer(npmLayer)
 	prefix := fmt.Sprintf("--prefix=%s", npmLayer.Path)
 	pkg := fmt.Sprintf("npm@%s", npmVersion)
-	ctx.Exec([]string{"npm", "install", "-g", prefix, pkg}, gcp.WithUserAttribution)
+	ctx.Exec(append([]string{"npm", "install", "-g", prefix, pkg}, sslFlags...), gcp.WithUserAttribution)
 	// Set the path here to ensure the version we just installed takes precedence over the npm bundled
 	// with the Node.js engine.
 	if err := ctx.Setenv("PATH", filepath.Join(npmLayer.Path, "bin")+":"+os.Getenv("PATH")); err != nil {
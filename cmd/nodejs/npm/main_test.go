@@ -15,9 +15,11 @@
 package main
 
 import (
+	"strings"
 	"testing"
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
 )
 
 func TestDetect(t *testing.T) {
@@ -41,6 +43,15 @@ func TestDetect(t *testing.T) {
 			},
 			want: 100,
 		},
+		{
+			name: "static site",
+			files: map[string]string{
+				"index.html":    "",
+				"css/style.css": "",
+				"js/app.js":     "",
+			},
+			want: 100,
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -48,3 +59,30 @@ func TestDetect(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildWorkspace(t *testing.T) {
+	files := map[string]string{
+		"package.json":              `{"workspaces": ["packages/*"]}`,
+		"package-lock.json":         "{}",
+		"packages/api/package.json": `{"name": "api", "scripts": {"start": "node index.js"}}`,
+	}
+	mocks := []*mockprocess.Mock{
+		mockprocess.New(`^npm --version`, mockprocess.WithStdout("8.19.2")),
+		mockprocess.New(`^npm [a-z]`),
+	}
+	opts := []buildpacktest.Option{
+		buildpacktest.WithTestName("workspace"),
+		buildpacktest.WithEnvs("GOOGLE_NODEJS_WORKSPACE=api"),
+		buildpacktest.WithFiles(files),
+		buildpacktest.WithExecMocks(mocks...),
+	}
+	result, err := buildpacktest.RunBuild(t, buildFn, opts...)
+	if err != nil {
+		t.Fatalf("error running build: %v, result: %#v", err, result)
+	}
+
+	// The dependencies of the workspace are installed from the workspace root.
+	if want := `Running "npm ci --quiet -w api`; !strings.Contains(result.Output, want) {
+		t.Errorf("build output does not contain %q, output:\n%s", want, result.Output)
+	}
+}
@@ -62,6 +62,8 @@ func buildFn(ctx *gcp.Context) error {
 	if err != nil {
 		return fmt.Errorf("creating %v layer: %w", nodeLayer, err)
 	}
-	_, err = runtime.InstallTarballIfNotCached(ctx, runtime.Nodejs, version, nrl)
-	return err
+	if _, err = runtime.InstallTarballIfNotCached(ctx, runtime.Nodejs, version, nrl); err != nil {
+		return err
+	}
+	return nodejs.AddHeapCalculator(ctx)
 }
@@ -21,10 +21,13 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/builddb"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/source"
 	"github.com/buildpacks/libcnb"
 )
 
@@ -62,18 +65,48 @@ func buildFn(ctx *gcp.Context) error {
 		return fmt.Errorf("installing Yarn: %w", err)
 	}
 
+	// The build script runs as part of the install, so the build database is provisioned first.
+	db, err := builddb.ProvisionFromEnv(ctx)
+	if err != nil {
+		return err
+	}
+	buildOpts := append([]gcp.ExecOption{gcp.WithUserAttribution}, db.ExecOptions()...)
+	script, err := buildScript(ctx)
+	if err != nil {
+		return err
+	}
 	if yarn2, err := nodejs.IsYarn2(ctx.ApplicationRoot()); err != nil {
 		return err
 	} else if yarn2 {
-		if err := yarn2InstallModules(ctx); err != nil {
+		if err := yarn2InstallModules(ctx, script, buildOpts); err != nil {
 			return err
 		}
 	} else {
-		if err := yarn1InstallModules(ctx); err != nil {
+		if err := yarn1InstallModules(ctx, script, buildOpts); err != nil {
 			return err
 		}
 	}
 
+	if err := ctx.LintGate(os.Getenv(env.LintCommand)); err != nil {
+		return err
+	}
+	if err := ctx.RunBuildTargetFromEnv(db.ExecOptions()...); err != nil {
+		return err
+	}
+	if err := db.Close(ctx); err != nil {
+		return err
+	}
+
+	if err := nodejs.ResolveHoistedAppModules(ctx); err != nil {
+		return err
+	}
+	if err := ctx.PruneTestFiles("nodejs"); err != nil {
+		return err
+	}
+	if err := source.RemoveExportIgnored(ctx); err != nil {
+		return err
+	}
+
 	el, err := ctx.Layer("env", gcp.BuildLayer, gcp.LaunchLayer)
 	if err != nil {
 		return fmt.Errorf("creating layer: %w", err)
@@ -82,7 +115,10 @@ func buildFn(ctx *gcp.Context) error {
 	el.SharedEnvironment.Default("NODE_ENV", nodejs.NodeEnv())
 
 	// Configure the entrypoint for production.
-	cmd := []string{"yarn", "run", "start"}
+	cmd, err := startCommand(ctx)
+	if err != nil {
+		return err
+	}
 
 	if !devmode.Enabled(ctx) {
 		ctx.AddWebProcess(cmd)
@@ -101,7 +137,26 @@ func buildFn(ctx *gcp.Context) error {
 	return nil
 }
 
-func yarn1InstallModules(ctx *gcp.Context) error {
+// buildScript returns the package.json script that builds the application, if any: gcp-build, or
+// else the script of nodejs.BuildScript unless a build target replaces it.
+func buildScript(ctx *gcp.Context) (string, error) {
+	gcpBuild, err := nodejs.HasGCPBuild(ctx.ApplicationRoot())
+	if err != nil {
+		return "", err
+	}
+	if gcpBuild {
+		return "gcp-build", nil
+	}
+	_, target, err := gcp.BuildTargetFromEnv()
+	if err != nil || target != "" {
+		return "", err
+	}
+	return nodejs.BuildScript(ctx)
+}
+
+// yarn1InstallModules installs the dependencies with Yarn 1 and runs the build script, if any,
+// with buildOpts.
+func yarn1InstallModules(ctx *gcp.Context, script string, buildOpts []gcp.ExecOption) error {
 	freezeLockfile, err := nodejs.UseFrozenLockfile(ctx)
 	if err != nil {
 		return err
@@ -111,7 +166,11 @@ func yarn1InstallModules(ctx *gcp.Context) error {
 	if err != nil {
 		return fmt.Errorf("creating layer: %w", err)
 	}
-	cached, err := nodejs.CheckCache(ctx, ml, cache.WithFiles("package.json", nodejs.YarnLock))
+	files, err := cacheKeyFiles(ctx)
+	if err != nil {
+		return err
+	}
+	cached, err := nodejs.CheckCache(ctx, ml, cache.WithFiles(files...))
 	if err != nil {
 		return fmt.Errorf("checking cache: %w", err)
 	}
@@ -159,18 +218,20 @@ func yarn1InstallModules(ctx *gcp.Context) error {
 		}
 	}
 
+	offline, err := nodejs.ConfiguredOfflineInstall(ctx)
+	if err != nil {
+		return err
+	}
+
 	// Always run yarn install to execute customer's lifecycle hooks.
 	cmd := []string{"yarn", "install", "--non-interactive", "--prefer-offline", locationFlag}
+	cmd = append(cmd, offline.Flags...)
 
 	// HACK: For backwards compatibility on App Engine Node.js 10 and older, skip using `--frozen-lockfile`.
 	if freezeLockfile {
 		cmd = append(cmd, "--frozen-lockfile")
 	}
-	gcpBuild, err := nodejs.HasGCPBuild(ctx.ApplicationRoot())
-	if err != nil {
-		return err
-	}
-	if gcpBuild {
+	if script != "" {
 		// Setting --production=false causes the devDependencies to be installed regardless of the
 		// NODE_ENV value. The allows the customer's lifecycle hooks to access to them. We purge the
 		// devDependencies from the final app.
@@ -179,12 +240,12 @@ func yarn1InstallModules(ctx *gcp.Context) error {
 
 	// Add the layer's node_modules/.bin to the path so it is available in postinstall scripts.
 	nodeBin := filepath.Join(layerModules, ".bin")
-	ctx.Exec(cmd, gcp.WithUserAttribution, gcp.WithEnv(fmt.Sprintf("PATH=%s:%s", os.Getenv("PATH"), nodeBin)))
+	ctx.Exec(cmd, append(offline.ExecOptions(), gcp.WithUserAttribution, gcp.WithEnv(fmt.Sprintf("PATH=%s:%s", os.Getenv("PATH"), nodeBin)))...)
 
-	if gcpBuild {
-		ctx.Exec([]string{"yarn", "run", "gcp-build"}, gcp.WithUserAttribution)
+	if script != "" {
+		ctx.Exec([]string{"yarn", "run", script}, buildOpts...)
 
-		// If there was a gcp-build script we installed all the devDependencies above. We should try to
+		// If there was a build script we installed all the devDependencies above. We should try to
 		// prune them from the final app image.
 		nodeEnv := nodejs.NodeEnv()
 		if nodejs.NodeEnv() != nodejs.EnvProduction {
@@ -193,18 +254,26 @@ func yarn1InstallModules(ctx *gcp.Context) error {
 			// For Yarn1, setting `--production=true` causes all `devDependencies` to be deleted.
 			ctx.Logf("Pruning devDependencies")
 			cmd := []string{"yarn", "install", "--ignore-scripts", "--prefer-offline", "--production=true", locationFlag}
+			cmd = append(cmd, offline.Flags...)
 			if freezeLockfile {
 				cmd = append(cmd, "--frozen-lockfile")
 			}
-			ctx.Exec(cmd, gcp.WithUserAttribution)
+			ctx.Exec(cmd, append(offline.ExecOptions(), gcp.WithUserAttribution)...)
 		}
 	}
 
 	return nil
 }
 
-func yarn2InstallModules(ctx *gcp.Context) error {
+// yarn2InstallModules installs the dependencies with Yarn 2 or later and runs the build script, if
+// any, with buildOpts.
+func yarn2InstallModules(ctx *gcp.Context, script string, buildOpts []gcp.ExecOption) error {
+	offline, err := nodejs.ConfiguredOfflineInstall(ctx)
+	if err != nil {
+		return err
+	}
 	cmd := []string{"yarn", "install", "--immutable"}
+	cmd = append(cmd, offline.Flags...)
 
 	yarnCacheExists, err := ctx.FileExists(ctx.ApplicationRoot(), ".yarn", "cache")
 	if err != nil {
@@ -216,13 +285,11 @@ func yarn2InstallModules(ctx *gcp.Context) error {
 	if yarnCacheExists {
 		cmd = append(cmd, "--immutable-cache")
 	}
-	ctx.Exec(cmd, gcp.WithUserAttribution)
+	ctx.Exec(cmd, append(offline.ExecOptions(), gcp.WithUserAttribution)...)
 
-	// Run the gcp-build script if it exists.
-	if gcpBuild, err := nodejs.HasGCPBuild(ctx.ApplicationRoot()); err != nil {
-		return err
-	} else if gcpBuild {
-		ctx.Exec([]string{"yarn", "run", "gcp-build"}, gcp.WithUserAttribution)
+	// Run the build script if it exists.
+	if script != "" {
+		ctx.Exec([]string{"yarn", "run", script}, buildOpts...)
 	}
 
 	// If there are no devDependencies, there is nothing to prune. We are done.
@@ -239,13 +306,62 @@ func yarn2InstallModules(ctx *gcp.Context) error {
 	default:
 		// For Yarn2, dependency pruning is via the workspaces plugin.
 		ctx.Logf("Pruning devDependencies")
-		ctx.Exec([]string{"yarn", "workspaces", "focus", "--all", "--production"}, gcp.WithUserAttribution)
+		ctx.Exec([]string{"yarn", "workspaces", "focus", "--all", "--production"}, append(offline.ExecOptions(), gcp.WithUserAttribution)...)
 	}
 
 	return nil
 }
 
+// cacheKeyFiles returns the files that determine the installed dependencies. In a workspaces
+// monorepo the install at the root handles all workspaces, so their package.json files are
+// included.
+func cacheKeyFiles(ctx *gcp.Context) ([]string, error) {
+	files := []string{"package.json", nodejs.YarnLock}
+	wsFiles, err := nodejs.WorkspacePackageJSONs(ctx.ApplicationRoot())
+	if err != nil {
+		return nil, err
+	}
+	if len(wsFiles) > 0 {
+		ctx.Logf("Installing dependencies of %d workspaces from the workspace root.", len(wsFiles))
+	}
+	return append(files, wsFiles...), nil
+}
+
+// startCommand returns the command that starts the application, or the workspace selected by
+// GOOGLE_NODEJS_WORKSPACE.
+func startCommand(ctx *gcp.Context) ([]string, error) {
+	selected := os.Getenv(nodejs.EnvWorkspace)
+	if selected == "" {
+		return []string{"yarn", "run", "start"}, nil
+	}
+	w, err := nodejs.FindWorkspace(ctx.ApplicationRoot(), selected)
+	if err != nil {
+		return nil, err
+	}
+	ctx.Logf("Using workspace %s in %s from %s.", w.Name, w.Dir, nodejs.EnvWorkspace)
+	return []string{"yarn", "workspace", w.Name, "run", "start"}, nil
+}
+
 func installYarn(ctx *gcp.Context) error {
+	pm, err := nodejs.RequestedPackageManager(ctx.ApplicationRoot())
+	if err != nil {
+		return err
+	}
+	if pm != nil && pm.Name == "yarn" {
+		// The "packageManager" field pins an exact Yarn version, which takes precedence over engines.yarn.
+		if err := nodejs.InstallPackageManager(ctx, pm); err != nil {
+			return err
+		}
+		ctx.AddBOMEntry(libcnb.BOMEntry{
+			Name:     yarnLayer,
+			Metadata: map[string]interface{}{"version": pm.Version},
+			Launch:   true,
+			Build:    true,
+		})
+		ctx.RecordVersion(gcp.VersionCategoryPackageManager, "yarn", pm.Version)
+		return nil
+	}
+
 	version, err := nodejs.DetectYarnVersion(ctx.ApplicationRoot())
 	if err != nil {
 		return err
@@ -285,5 +401,6 @@ func installYarn(ctx *gcp.Context) error {
 		Launch:   true,
 		Build:    true,
 	})
+	ctx.RecordVersion(gcp.VersionCategoryPackageManager, "yarn", version)
 	return nil
 }
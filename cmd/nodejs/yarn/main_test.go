@@ -15,9 +15,12 @@
 package main
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 )
 
 func TestDetect(t *testing.T) {
@@ -65,3 +68,64 @@ func TestDetect(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildScript(t *testing.T) {
+	testCases := []struct {
+		name        string
+		packageJSON string
+		buildScript string
+		makeTarget  string
+		want        string
+	}{
+		{
+			name:        "gcp-build",
+			packageJSON: `{"scripts": {"gcp-build": "tsc", "build": "webpack"}}`,
+			want:        "gcp-build",
+		},
+		{
+			name:        "build",
+			packageJSON: `{"scripts": {"build": "webpack"}}`,
+			want:        "build",
+		},
+		{
+			name:        "build script override",
+			packageJSON: `{"scripts": {"build": "webpack", "compile": "tsc"}}`,
+			buildScript: "compile",
+			want:        "compile",
+		},
+		{
+			name:        "build target replaces the build script",
+			packageJSON: `{"scripts": {"build": "webpack"}}`,
+			makeTarget:  "build",
+		},
+		{
+			name:        "gcp-build with a build target",
+			packageJSON: `{"scripts": {"gcp-build": "tsc"}}`,
+			makeTarget:  "build",
+			want:        "gcp-build",
+		},
+		{
+			name:        "no build script",
+			packageJSON: `{"scripts": {"start": "node index.js"}}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "package.json"), []byte(tc.packageJSON), 0644); err != nil {
+				t.Fatalf("writing package.json: %v", err)
+			}
+			t.Setenv("GOOGLE_NODEJS_BUILD_SCRIPT", tc.buildScript)
+			t.Setenv("GOOGLE_MAKE_TARGET", tc.makeTarget)
+			t.Setenv("GOOGLE_TASK", "")
+
+			got, err := buildScript(gcp.NewContext(gcp.WithApplicationRoot(dir)))
+			if err != nil {
+				t.Fatalf("buildScript() got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("buildScript() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
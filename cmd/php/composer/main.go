@@ -18,7 +18,9 @@ package main
 
 import (
 	"fmt"
+	"os"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/php"
 )
@@ -48,5 +50,56 @@ func buildFn(ctx *gcp.Context) error {
 		return fmt.Errorf("composer install: %w", err)
 	}
 
+	isSymfony, err := php.IsSymfony(ctx.ApplicationRoot())
+	if err != nil {
+		return err
+	}
+	if isSymfony {
+		return buildSymfony(ctx)
+	}
+	return nil
+}
+
+// buildSymfony sets the document root to the Symfony front controller directory and warms up the
+// Symfony cache if enabled.
+func buildSymfony(ctx *gcp.Context) error {
+	ctx.Logf("Detected a Symfony application.")
+	l, err := ctx.Layer("symfony", gcp.LaunchLayer)
+	if err != nil {
+		return fmt.Errorf("creating layer: %w", err)
+	}
+	php.SetSymfonyDocroot(l, ctx.ApplicationRoot())
+
+	warmup, err := shouldWarmupSymfonyCache(ctx)
+	if err != nil {
+		return err
+	}
+	if warmup {
+		php.SymfonyCacheWarmup(ctx)
+	}
 	return nil
 }
+
+// shouldWarmupSymfonyCache returns true unless the cache warmup has been disabled through
+// GOOGLE_PHP_SYMFONY_CACHE_WARMUP or the application has no Symfony console.
+func shouldWarmupSymfonyCache(ctx *gcp.Context) (bool, error) {
+	if _, ok := os.LookupEnv(php.SymfonyCacheWarmupEnv); ok {
+		enabled, err := env.IsPresentAndTrue(php.SymfonyCacheWarmupEnv)
+		if err != nil {
+			return false, gcp.UserErrorf("%v", err)
+		}
+		if !enabled {
+			ctx.Logf("Skipping the Symfony cache warmup because %s is false.", php.SymfonyCacheWarmupEnv)
+			return false, nil
+		}
+	}
+	consoleExists, err := ctx.FileExists(ctx.ApplicationRoot(), php.SymfonyConsole)
+	if err != nil {
+		return false, err
+	}
+	if !consoleExists {
+		ctx.Warnf("Skipping the Symfony cache warmup because %s was not found.", php.SymfonyConsole)
+		return false, nil
+	}
+	return true, nil
+}
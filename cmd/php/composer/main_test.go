@@ -15,9 +15,13 @@
 package main
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/php"
 )
 
 func TestDetect(t *testing.T) {
@@ -48,3 +52,65 @@ func TestDetect(t *testing.T) {
 		})
 	}
 }
+
+func TestShouldWarmupSymfonyCache(t *testing.T) {
+	testCases := []struct {
+		name    string
+		files   map[string]string
+		env     string
+		want    bool
+		wantErr bool
+	}{
+		{
+			name:  "console present",
+			files: map[string]string{"bin/console": ""},
+			want:  true,
+		},
+		{
+			name:  "console present and warmup enabled",
+			files: map[string]string{"bin/console": ""},
+			env:   "true",
+			want:  true,
+		},
+		{
+			name:  "warmup disabled",
+			files: map[string]string{"bin/console": ""},
+			env:   "false",
+		},
+		{
+			name: "console missing",
+		},
+		{
+			name:    "invalid env",
+			files:   map[string]string{"bin/console": ""},
+			env:     "sometimes",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for f, c := range tc.files {
+				path := filepath.Join(dir, f)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("creating dir for %s: %v", f, err)
+				}
+				if err := os.WriteFile(path, []byte(c), 0644); err != nil {
+					t.Fatalf("writing %s: %v", f, err)
+				}
+			}
+			if tc.env != "" {
+				t.Setenv(php.SymfonyCacheWarmupEnv, tc.env)
+			}
+
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+			got, err := shouldWarmupSymfonyCache(ctx)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("shouldWarmupSymfonyCache() got err=%t, want err=%t. err: %v", gotErr, tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Errorf("shouldWarmupSymfonyCache()=%t, want=%t", got, tc.want)
+			}
+		})
+	}
+}
@@ -19,6 +19,7 @@ package main
 import (
 	"fmt"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/builddb"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/php"
 )
@@ -57,7 +58,14 @@ func buildFn(ctx *gcp.Context) error {
 		return fmt.Errorf("composer install: %w", err)
 	}
 
-	ctx.Exec([]string{"composer", "run-script", "--timeout=600", "--no-dev", "gcp-build"}, gcp.WithUserAttribution)
+	db, err := builddb.ProvisionFromEnv(ctx)
+	if err != nil {
+		return err
+	}
+	ctx.Exec([]string{"composer", "run-script", "--timeout=600", "--no-dev", "gcp-build"}, append([]gcp.ExecOption{gcp.WithUserAttribution}, db.ExecOptions()...)...)
+	if err := db.Close(ctx); err != nil {
+		return err
+	}
 	if err := ctx.RemoveAll(php.Vendor); err != nil {
 		return err
 	}
@@ -69,6 +69,7 @@ func buildFn(ctx *gcp.Context) error {
 		Metadata: map[string]interface{}{"version": composerVer},
 		Build:    true,
 	})
+	ctx.RecordVersion(gcp.VersionCategoryPackageManager, "composer", composerVer)
 
 	// Check the metadata in the cache layer to determine if we need to proceed.
 	metaVersion := ctx.GetMetadata(l, versionKey)
@@ -30,6 +30,9 @@ import (
 
 const (
 	phpIniName = "php.ini"
+	// phpIniFragmentName is the name of the php.ini fragment with the production configuration, in
+	// the additional ini directory.
+	phpIniFragmentName = "google.ini"
 )
 
 func main() {
@@ -103,5 +106,25 @@ func addPHPIni(ctx *gcp.Context, phpl *libcnb.Layer) error {
 
 	// PHP uses PHPRC env var to find php.ini
 	phpl.LaunchEnvironment.Default("PHPRC", destDir)
+	return addPHPIniFragment(ctx, phpl)
+}
+
+// addPHPIniFragment writes the php.ini fragment with the memory limit and opcache configuration
+// into the conf.d directory of the layer, which PHP scans after the default ini directory.
+func addPHPIniFragment(ctx *gcp.Context, phpl *libcnb.Layer) error {
+	cfg, err := php.IniConfigFromEnv(os.LookupEnv)
+	if err != nil {
+		return err
+	}
+	confDir := filepath.Join(phpl.Path, "etc", "conf.d")
+	if err := ctx.MkdirAll(confDir, 0755); err != nil {
+		return fmt.Errorf("creating conf.d folder: %w", err)
+	}
+	if err := ctx.WriteFile(filepath.Join(confDir, phpIniFragmentName), []byte(php.GenerateIniFragment(cfg)), 0644); err != nil {
+		return err
+	}
+	ctx.Logf("Configured PHP with memory_limit=%s and opcache enabled=%t.", cfg.MemoryLimit, cfg.Opcache)
+	// A leading separator makes PHP scan the directory in addition to its default ini directory.
+	phpl.LaunchEnvironment.Default("PHP_INI_SCAN_DIR", string(os.PathListSeparator)+confDir)
 	return nil
 }
@@ -94,7 +94,9 @@ func entrypoint(ctx *gcp.Context) (*appstart.Entrypoint, error) {
 	}
 
 	if version.LessThan(minVersion) {
-		ctx.Warnf("Installed gunicorn version %q is less than supported version %q.", version, minVersion)
+		if err := ctx.StrictWarnf("Installed gunicorn version %q is less than supported version %q.", version, minVersion); err != nil {
+			return nil, err
+		}
 	}
 
 	return &appstart.Entrypoint{
@@ -22,6 +22,8 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/builddb"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/python"
 	"github.com/buildpacks/libcnb"
@@ -29,6 +31,8 @@ import (
 
 const (
 	layerName = "pip"
+	// condaLayerName holds the conda environment of apps with an environment.yml.
+	condaLayerName = "conda"
 )
 
 // metadata represents metadata stored for a dependencies layer.
@@ -51,7 +55,17 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 	if err != nil {
 		return nil, err
 	}
-	if requirementsExists {
+	// A setuptools package without requirements.txt declares its dependencies in setup.py or
+	// setup.cfg, which the buildpack installs as well.
+	pkg, err := python.IsPackage(ctx.ApplicationRoot())
+	if err != nil {
+		return nil, err
+	}
+	conda, err := ctx.FileExists(python.EnvironmentYML)
+	if err != nil {
+		return nil, err
+	}
+	if requirementsExists || pkg || conda {
 		plan.Provides = python.RequirementsProvides
 	}
 	return gcp.OptInAlways(gcp.WithBuildPlans(plan)), nil
@@ -67,6 +81,16 @@ func buildFn(ctx *gcp.Context) error {
 	if err != nil {
 		return err
 	}
+	condaEnv, err := python.ReadCondaEnvironment(ctx.ApplicationRoot())
+	if err != nil {
+		return err
+	}
+	if condaEnv != nil {
+		if requirementsExists {
+			ctx.Logf("Found both %s and requirements.txt, installing the dependencies with conda. List the pip dependencies under `pip` in the dependencies of %s.", python.EnvironmentYML, python.EnvironmentYML)
+		}
+		return buildConda(ctx, condaEnv, reqs)
+	}
 	if requirementsExists {
 		reqs = append(reqs, "requirements.txt")
 	}
@@ -76,10 +100,66 @@ func buildFn(ctx *gcp.Context) error {
 		return fmt.Errorf("creating %v layer: %w", layerName, err)
 	}
 
-	if err := python.InstallRequirements(ctx, l, reqs...); err != nil {
+	install := python.InstallRequirements
+	if !requirementsExists {
+		pkg, err := python.IsPackage(ctx.ApplicationRoot())
+		if err != nil {
+			return err
+		}
+		if pkg {
+			ctx.Logf("Found a setuptools package without requirements.txt, installing it with its dependencies.")
+			install = python.InstallPackage
+		}
+	}
+	if err := install(ctx, l, reqs...); err != nil {
 		return fmt.Errorf("installing dependencies: %w", err)
 	}
+	if v := python.PipVersion(ctx); v != "" {
+		ctx.RecordVersion(gcp.VersionCategoryPackageManager, "pip", v)
+	}
+
+	if err := checkDependencies(ctx); err != nil {
+		return err
+	}
+	if err := ctx.LintGate(os.Getenv(env.LintCommand)); err != nil {
+		return err
+	}
+	if err := runBuildTarget(ctx); err != nil {
+		return err
+	}
+	return collectStatic(ctx)
+}
+
+// buildConda creates the conda environment declared by environment.yml, which takes precedence
+// over requirements.txt. The requirements provided by other buildpacks, e.g. the Functions
+// Framework, are installed into the conda environment with pip.
+func buildConda(ctx *gcp.Context, condaEnv *python.CondaEnvironment, reqs []string) error {
+	l, err := ctx.Layer(condaLayerName, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayer)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", condaLayerName, err)
+	}
+	if err := python.InstallCondaEnvironment(ctx, l, condaEnv); err != nil {
+		return fmt.Errorf("installing the conda environment: %w", err)
+	}
+	for _, req := range reqs {
+		result, err := ctx.ExecWithErr([]string{"python3", "-m", "pip", "install", "--requirement", req}, gcp.WithUserAttribution)
+		if err != nil {
+			return fmt.Errorf("installing %s: %w", req, err)
+		}
+		if err := python.CheckPipVersion(ctx, result.Combined); err != nil {
+			return err
+		}
+	}
+	if err := ctx.LintGate(os.Getenv(env.LintCommand)); err != nil {
+		return err
+	}
+	if err := runBuildTarget(ctx); err != nil {
+		return err
+	}
+	return collectStatic(ctx)
+}
 
+func checkDependencies(ctx *gcp.Context) error {
 	ctx.Logf("Checking for incompatible dependencies.")
 	result, err := ctx.ExecWithErr([]string{"python3", "-m", "pip", "check"}, gcp.WithUserAttribution)
 	if result == nil {
@@ -94,5 +174,37 @@ func buildFn(ctx *gcp.Context) error {
 		return nil
 	}
 	return gcp.UserErrorf("found incompatible dependencies: %q", result.Stdout)
+}
+
+// runBuildTarget runs the build target selected with GOOGLE_MAKE_TARGET or GOOGLE_TASK, if any,
+// with the database requested with GOOGLE_BUILD_DB.
+func runBuildTarget(ctx *gcp.Context) error {
+	db, err := builddb.ProvisionFromEnv(ctx)
+	if err != nil {
+		return err
+	}
+	if err := ctx.RunBuildTargetFromEnv(db.ExecOptions()...); err != nil {
+		return err
+	}
+	return db.Close(ctx)
+}
 
+// collectStatic runs Django's collectstatic if requested with python.DjangoCollectStaticEnv.
+func collectStatic(ctx *gcp.Context) error {
+	requested, err := env.IsPresentAndTrue(python.DjangoCollectStaticEnv)
+	if err != nil {
+		return gcp.UserErrorf("%v", err)
+	}
+	if !requested {
+		return nil
+	}
+	django, err := python.IsDjango(ctx.ApplicationRoot())
+	if err != nil {
+		return err
+	}
+	if !django {
+		ctx.Warnf("Ignoring %s because the application is not a Django project with manage.py and a django dependency.", python.DjangoCollectStaticEnv)
+		return nil
+	}
+	return python.CollectStatic(ctx)
 }
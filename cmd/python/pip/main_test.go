@@ -34,6 +34,30 @@ func TestDetect(t *testing.T) {
 			},
 			want: 0,
 		},
+		{
+			name: "setup.py only",
+			files: map[string]string{
+				"setup.py":   "from setuptools import setup\nsetup(name='app', install_requires=['flask'])\n",
+				"app/api.py": "",
+			},
+			want: 0,
+		},
+		{
+			name: "setup.cfg only",
+			files: map[string]string{
+				"setup.cfg":  "[metadata]\nname = app\n\n[options]\ninstall_requires =\n    flask\n",
+				"app/api.py": "",
+			},
+			want: 0,
+		},
+		{
+			name: "environment.yml only",
+			files: map[string]string{
+				"main.py":         "",
+				"environment.yml": "dependencies:\n  - python=3.11\n",
+			},
+			want: 0,
+		},
 		{
 			// Opt-in with no requirements in case there's a build plan.
 			name: "no requirements",
@@ -20,10 +20,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/python"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtime"
 )
 
@@ -31,10 +31,12 @@ const (
 	pythonLayer = "python"
 	pythonURL   = "https://storage.googleapis.com/gcp-buildpacks/python/python-%s.tar.gz"
 	// TODO(b/148375706): Add mapping for stable/beta versions.
-	versionURL  = "https://storage.googleapis.com/gcp-buildpacks/python/latest.version"
-	versionFile = ".python-version"
-	versionKey  = "version"
-	versionEnv  = "GOOGLE_PYTHON_VERSION"
+	versionURL = "https://storage.googleapis.com/gcp-buildpacks/python/latest.version"
+	versionKey = "version"
+	versionEnv = "GOOGLE_PYTHON_VERSION"
+	// optimizedBuildEnv selects the optimized build of the Python interpreter, e.g. built with
+	// --enable-optimizations, if one is available for the requested version.
+	optimizedBuildEnv = "GOOGLE_PYTHON_OPTIMIZED_BUILD"
 )
 
 func main() {
@@ -57,6 +59,14 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 }
 
 func buildFn(ctx *gcp.Context) error {
+	condaEnv, err := python.ReadCondaEnvironment(ctx.ApplicationRoot())
+	if err != nil {
+		return err
+	}
+	if condaEnv != nil {
+		ctx.Logf("Skipping the Python installation, Python is installed into the conda environment of %s.", python.EnvironmentYML)
+		return nil
+	}
 	layer, err := ctx.Layer(pythonLayer, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayerUnlessSkipRuntimeLaunch)
 	if err != nil {
 		return fmt.Errorf("creating %v layer: %w", pythonLayer, err)
@@ -65,6 +75,9 @@ func buildFn(ctx *gcp.Context) error {
 	if err != nil {
 		return fmt.Errorf("determining runtime version: %w", err)
 	}
+	if ver, err = optimizedVersion(ctx, ver); err != nil {
+		return err
+	}
 	isCached, err := runtime.InstallTarballIfNotCached(ctx, runtime.Python, ver, layer)
 	if err != nil {
 		return err
@@ -80,6 +93,29 @@ func buildFn(ctx *gcp.Context) error {
 	return nil
 }
 
+// optimizedVersion returns the version of the optimized build of the interpreter that satisfies the
+// version constraint if GOOGLE_PYTHON_OPTIMIZED_BUILD is set and one is available. Otherwise the
+// version constraint is returned unchanged, or the resolved version if there is no optimized build.
+func optimizedVersion(ctx *gcp.Context, ver string) (string, error) {
+	optimized, err := env.IsPresentAndTrue(optimizedBuildEnv)
+	if err != nil {
+		return "", gcp.UserErrorf("%v", err)
+	}
+	if !optimized {
+		return ver, nil
+	}
+	v, err := runtime.SelectOptimizedVariant(runtime.Python, ver)
+	if err != nil {
+		return "", err
+	}
+	if runtime.IsOptimizedVariant(v) {
+		ctx.Logf("Using the optimized build of Python: %s", v)
+	} else {
+		ctx.Logf("No optimized build of Python %s is available, using the standard build.", v)
+	}
+	return v, nil
+}
+
 func runtimeVersion(ctx *gcp.Context) (string, error) {
 	if v := os.Getenv(versionEnv); v != "" {
 		ctx.Logf("Using Python version from %s: %s", versionEnv, v)
@@ -89,21 +125,29 @@ func runtimeVersion(ctx *gcp.Context) (string, error) {
 		ctx.Logf("Using Python version from %s: %s", env.RuntimeVersion, v)
 		return v, nil
 	}
-	versionFileExists, err := ctx.FileExists(versionFile)
+	v, err := python.PyenvVersion(ctx, ctx.ApplicationRoot())
+	if err != nil {
+		return "", err
+	}
+	if v != "" {
+		ctx.Logf("Using Python version from %s: %s", python.PythonVersionFile, v)
+		return v, nil
+	}
+	v, err = python.RequestedPythonVersion(ctx.ApplicationRoot())
 	if err != nil {
 		return "", err
 	}
-	if versionFileExists {
-		raw, err := ctx.ReadFile(versionFile)
-		if err != nil {
-			return "", err
-		}
-		v := strings.TrimSpace(string(raw))
-		if v != "" {
-			ctx.Logf("Using Python version from %s: %s", versionFile, v)
-			return v, nil
-		}
-		return "", gcp.UserErrorf("%s exists but does not specify a version", versionFile)
+	if v != "" {
+		ctx.Logf("Using Python version from %s: %s", python.PyProjectTOML, v)
+		return v, nil
+	}
+	v, file, err := python.RequestedSetupPythonVersion(ctx.ApplicationRoot())
+	if err != nil {
+		return "", err
+	}
+	if v != "" {
+		ctx.Logf("Using Python version from %s: %s", file, v)
+		return v, nil
 	}
 	// This will use the highest listed at https://dl.google.com/runtimes/python/version.json.
 	ctx.Logf("Python version not specified, using the test available version.")
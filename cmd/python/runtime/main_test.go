@@ -15,9 +15,14 @@
 package main
 
 import (
+	"os"
+	"path/filepath"
+	goruntime "runtime"
 	"testing"
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 )
 
 func TestDetect(t *testing.T) {
@@ -62,3 +67,141 @@ func TestDetect(t *testing.T) {
 		})
 	}
 }
+
+func TestRuntimeVersion(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		env   map[string]string
+		want  string
+	}{
+		{
+			name: "default",
+			want: "*",
+		},
+		{
+			name:  "pyproject.toml",
+			files: map[string]string{"pyproject.toml": "[project]\nrequires-python = \">=3.10\"\n"},
+			want:  ">=3.10.0",
+		},
+		{
+			name:  "setup.py python_requires",
+			files: map[string]string{"setup.py": "setup(name='app', python_requires='>=3.8')\n"},
+			want:  ">=3.8.0",
+		},
+		{
+			name: "pyproject.toml takes precedence over setup.cfg",
+			files: map[string]string{
+				"pyproject.toml": "[project]\nrequires-python = \">=3.10\"\n",
+				"setup.cfg":      "[options]\npython_requires = >=3.8\n",
+			},
+			want: ">=3.10.0",
+		},
+		{
+			name:  ".python-version",
+			files: map[string]string{".python-version": "3.11.4\n", "pyproject.toml": "[project]\nrequires-python = \">=3.10\"\n"},
+			want:  "3.11.4",
+		},
+		{
+			name:  "multi-line .python-version",
+			files: map[string]string{".python-version": "3.12.1\n3.11.7\n", "pyproject.toml": "[project]\nrequires-python = \">=3.10\"\n"},
+			want:  "3.12.1",
+		},
+		{
+			name:  "partial .python-version",
+			files: map[string]string{".python-version": "  3.11  \n"},
+			want:  "3.11.x",
+		},
+		{
+			name:  "empty .python-version",
+			files: map[string]string{".python-version": "", "pyproject.toml": "[project]\nrequires-python = \">=3.10\"\n"},
+			want:  ">=3.10.0",
+		},
+		{
+			name:  "whitespace-only .python-version",
+			files: map[string]string{".python-version": " \n\t\n"},
+			want:  "*",
+		},
+		{
+			name:  "env var takes precedence over pyproject.toml",
+			files: map[string]string{"pyproject.toml": "[project]\nrequires-python = \">=3.10\"\n"},
+			env:   map[string]string{versionEnv: "3.9.18"},
+			want:  "3.9.18",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tc.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+					t.Fatalf("writing %s: %v", name, err)
+				}
+			}
+			t.Setenv(versionEnv, tc.env[versionEnv])
+			t.Setenv("GOOGLE_RUNTIME_VERSION", "")
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+
+			got, err := runtimeVersion(ctx)
+			if err != nil {
+				t.Fatalf("runtimeVersion() got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("runtimeVersion()=%q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestOptimizedVersion(t *testing.T) {
+	testCases := []struct {
+		name      string
+		optimized string
+		tarballs  []string
+		version   string
+		want      string
+	}{
+		{
+			name:     "not requested",
+			tarballs: []string{"3.11.4", "3.11.4-optimized"},
+			version:  "3.11.x",
+			want:     "3.11.x",
+		},
+		{
+			name:      "optimized build available",
+			optimized: "1",
+			tarballs:  []string{"3.11.4", "3.11.4-optimized"},
+			version:   "3.11.x",
+			want:      "3.11.4-optimized",
+		},
+		{
+			name:      "optimized build unavailable",
+			optimized: "true",
+			tarballs:  []string{"3.11.3-optimized", "3.11.4"},
+			version:   "3.11.x",
+			want:      "3.11.4",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, v := range tc.tarballs {
+				if err := os.WriteFile(filepath.Join(dir, "python-"+v+"-"+goruntime.GOARCH+".tar.gz"), nil, 0644); err != nil {
+					t.Fatalf("writing tarball: %v", err)
+				}
+			}
+			t.Setenv(env.RuntimeLocalDir, dir)
+			t.Setenv(optimizedBuildEnv, tc.optimized)
+			if tc.optimized == "" {
+				os.Unsetenv(optimizedBuildEnv)
+			}
+
+			got, err := optimizedVersion(gcp.NewContext(), tc.version)
+			if err != nil {
+				t.Fatalf("optimizedVersion(%q) got error: %v", tc.version, err)
+			}
+			if got != tc.want {
+				t.Errorf("optimizedVersion(%q)=%q, want %q", tc.version, got, tc.want)
+			}
+		})
+	}
+}
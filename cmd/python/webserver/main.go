@@ -29,6 +29,8 @@ import (
 
 const (
 	layerName = "gunicorn"
+	// asgiRequirements lists the worker class gunicorn needs to serve ASGI applications.
+	asgiRequirements = "requirements-asgi.txt"
 )
 
 var (
@@ -71,6 +73,42 @@ func buildFn(ctx *gcp.Context) error {
 	ctx.Debugf("Adding webserver requirements.txt to the list of requirements files to install.")
 	r := filepath.Join(ctx.BuildpackRoot(), "requirements.txt")
 	l.BuildEnvironment.Append(python.RequirementsFilesEnv, string(os.PathListSeparator), r)
+
+	script, err := python.PoetryEntrypoint(ctx.ApplicationRoot())
+	if err != nil {
+		return fmt.Errorf("determining Poetry script entrypoint: %w", err)
+	}
+	if script != "" {
+		ctx.Logf("Using Poetry script entrypoint %q.", script)
+		ctx.AddWebProcess([]string{"/bin/bash", "-c", script})
+		return nil
+	}
+
+	iface, frameworks, err := python.ServerInterface(ctx.ApplicationRoot())
+	if err != nil {
+		return fmt.Errorf("classifying web server interface: %w", err)
+	}
+	if iface == "" {
+		if len(frameworks) > 1 {
+			ctx.Logf("Found web frameworks %v with different server interfaces; set %s to choose how to serve the application.", frameworks, env.Entrypoint)
+		}
+		return nil
+	}
+	ctx.Logf("Detected %s application (%v).", iface, frameworks)
+	if iface == python.ASGI {
+		r := filepath.Join(ctx.BuildpackRoot(), asgiRequirements)
+		l.BuildEnvironment.Append(python.RequirementsFilesEnv, string(os.PathListSeparator), r)
+	}
+	cmd, err := python.DefaultEntrypoint(ctx.ApplicationRoot(), iface)
+	if err != nil {
+		return fmt.Errorf("determining default entrypoint: %w", err)
+	}
+	if cmd == "" {
+		ctx.Logf("Unable to determine the application module; set %s to choose how to serve the application.", env.Entrypoint)
+		return nil
+	}
+	ctx.Logf("Using default entrypoint %q.", cmd)
+	ctx.AddWebProcess([]string{"/bin/bash", "-c", cmd})
 	return nil
 }
 
@@ -19,6 +19,7 @@ package main
 import (
 	"fmt"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/builddb"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 )
 
@@ -83,9 +84,15 @@ func needsRailsAssetPrecompile(ctx *gcp.Context) (bool, error) {
 func buildFn(ctx *gcp.Context) error {
 	ctx.Logf("Running Rails asset precompilation")
 
+	db, dbErr := builddb.ProvisionFromEnv(ctx)
+	if dbErr != nil {
+		return dbErr
+	}
+	defer db.Close(ctx)
+
 	// It is common practise in Ruby asset precompilation to ignore non-zero exit codes.
-	result, err := ctx.ExecWithErr([]string{"bundle", "exec", "ruby", "bin/rails", "assets:precompile"},
-		gcp.WithEnv("RAILS_ENV=production", "MALLOC_ARENA_MAX=2", "RAILS_LOG_TO_STDOUT=true", "LANG=C.utf8"), gcp.WithUserAttribution)
+	opts := append([]gcp.ExecOption{gcp.WithEnv("RAILS_ENV=production", "MALLOC_ARENA_MAX=2", "RAILS_LOG_TO_STDOUT=true", "LANG=C.utf8"), gcp.WithUserAttribution}, db.ExecOptions()...)
+	result, err := ctx.ExecWithErr([]string{"bundle", "exec", "ruby", "bin/rails", "assets:precompile"}, opts...)
 	if err != nil && result != nil && result.ExitCode != 0 {
 		ctx.Logf("WARNING: Asset precompilation returned non-zero exit code %d. Ignoring.", result.ExitCode)
 		return nil
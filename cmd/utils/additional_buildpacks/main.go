@@ -0,0 +1,65 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements utils/additional-buildpacks buildpack.
+// The additional-buildpacks buildpack opts in if GOOGLE_ADDITIONAL_BUILDPACKS lists supported
+// languages, which enables the builder groups in which the buildpacks of these languages build
+// part of the application before the buildpacks of its main language.
+package main
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// supportedLanguages are the languages that have additional buildpacks in builder groups.
+var supportedLanguages = map[string]bool{
+	"nodejs": true,
+}
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
+	langs := env.GetAdditionalBuildpacks()
+	if len(langs) == 0 {
+		return gcp.OptOutEnvNotSet(env.AdditionalBuildpacks), nil
+	}
+	for _, l := range langs {
+		if !supportedLanguages[l] {
+			return nil, gcp.UserErrorf("unsupported language %q in %s, supported languages are: %s", l, env.AdditionalBuildpacks, strings.Join(supported(), ", "))
+		}
+	}
+	return gcp.OptInEnvSet(env.AdditionalBuildpacks), nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	ctx.Logf("Building with the additional buildpacks for %s from %s=%q.", strings.Join(env.GetAdditionalBuildpacks(), ", "), env.AdditionalBuildpacks, os.Getenv(env.AdditionalBuildpacks))
+	return nil
+}
+
+// supported returns the supported languages in a stable order for messages.
+func supported() []string {
+	var langs []string
+	for l := range supportedLanguages {
+		langs = append(langs, l)
+	}
+	sort.Strings(langs)
+	return langs
+}
@@ -0,0 +1,54 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+)
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name string
+		env  []string
+		want int
+	}{
+		{
+			name: "not set",
+			want: 100,
+		},
+		{
+			name: "nodejs",
+			env:  []string{"GOOGLE_ADDITIONAL_BUILDPACKS=nodejs"},
+			want: 0,
+		},
+		{
+			name: "case-insensitive",
+			env:  []string{"GOOGLE_ADDITIONAL_BUILDPACKS=NodeJS"},
+			want: 0,
+		},
+		{
+			name: "unsupported language",
+			env:  []string{"GOOGLE_ADDITIONAL_BUILDPACKS=nodejs,cobol"},
+			want: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buildpacktest.TestDetect(t, detectFn, tc.name, map[string]string{}, tc.env, tc.want)
+		})
+	}
+}
@@ -14,11 +14,13 @@
 
 // Implements utils/label-image buildpack.
 // The label-image buildpack adds any environment variables with the "GOOGLE_LABEL_" prefix as
-// labels in the final application image.
+// labels in the final application image, and the ports listed in GOOGLE_EXPOSE_PORTS as the
+// google.expose-ports label.
 package main
 
 import (
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
@@ -47,5 +49,26 @@ func buildFn(ctx *gcp.Context) error {
 		}
 		ctx.AddLabel(key, value)
 	}
-	return nil
+
+	ports, err := parsePorts(os.Getenv(env.ExposePorts))
+	if err != nil {
+		return err
+	}
+	return ctx.ExposePorts(ports)
+}
+
+// parsePorts parses a comma-separated list of port numbers.
+func parsePorts(s string) ([]int, error) {
+	var ports []int
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		port, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, gcp.UserErrorf("invalid port %q in %s, expected a comma-separated list of port numbers", p, env.ExposePorts)
+		}
+		ports = append(ports, port)
+	}
+	return ports, nil
 }
@@ -15,6 +15,7 @@
 package main
 
 import (
+	"reflect"
 	"strings"
 	"testing"
 
@@ -45,6 +46,12 @@ func TestBuild(t *testing.T) {
 			app:  "with_framework",
 			envs: []string{"GOOGLE_FOO=bar"},
 		},
+		{
+			name: "exposed ports",
+			app:  "with_framework",
+			envs: []string{"GOOGLE_EXPOSE_PORTS=9090, 8081,9090"},
+			want: labelLog + " google.expose-ports: 8081,9090",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -62,3 +69,36 @@ func TestBuild(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildInvalidPorts(t *testing.T) {
+	for _, ports := range []string{"http", "8080,0", "70000"} {
+		t.Run(ports, func(t *testing.T) {
+			envs := []string{"GOOGLE_EXPOSE_PORTS=" + ports}
+			if result, err := buildpacktest.RunBuild(t, buildFn, buildpacktest.WithEnvs(envs...), buildpacktest.WithTestName(ports)); err == nil {
+				t.Errorf("RunBuild() with %s succeeded, want error, result: %#v", envs[0], result)
+			}
+		})
+	}
+}
+
+func TestParsePorts(t *testing.T) {
+	testCases := []struct {
+		ports   string
+		want    []int
+		wantErr bool
+	}{
+		{ports: ""},
+		{ports: "8080", want: []int{8080}},
+		{ports: " 8080 , 9090,", want: []int{8080, 9090}},
+		{ports: "8080,http", wantErr: true},
+	}
+	for _, tc := range testCases {
+		got, err := parsePorts(tc.ports)
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("parsePorts(%q) got error: %v, want error: %t", tc.ports, err, tc.wantErr)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("parsePorts(%q) = %v, want %v", tc.ports, got, tc.want)
+		}
+	}
+}
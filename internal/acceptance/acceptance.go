@@ -21,6 +21,7 @@ package acceptance
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/json"
 	"flag"
@@ -70,6 +71,7 @@ var (
 	cloudbuild          bool   // Use cloudbuild network; required for Cloud Build.
 	runtimeVersion      string // A runtime version which will be applied to tests that do not explicilty set a version.
 	runtimeName         string // The name of the runtime (aka the language name such as 'go' or 'dotnet'). Used to properly set GOOGLE_RUNTIME.
+	arm64               bool   // Run the tests for arm64 builder and run images.
 	specialChars        = regexp.MustCompile("[^a-zA-Z0-9]+")
 )
 
@@ -107,6 +109,7 @@ func DefineFlags() {
 	flag.BoolVar(&cloudbuild, "cloudbuild", false, "Use cloudbuild network; required for Cloud Build.")
 	flag.StringVar(&runtimeVersion, "runtime-version", "", "A default runtime version which will be applied to the tests that do not explicitly set a version.")
 	flag.StringVar(&runtimeName, "runtime-name", "", "The name of the runtime (aka the language name such as 'go' or 'dotnet'). Used to properly set GOOGLE_RUNTIME.")
+	flag.BoolVar(&arm64, "arm64", false, "Run the tests for arm64 builder and run images; they are skipped otherwise.")
 
 }
 
@@ -160,6 +163,12 @@ type Test struct {
 	FilesMustExist []string
 	// FilesMustNotExist specifies names of files that must not exist in the final image.
 	FilesMustNotExist []string
+	// FileContains maps names of files in the final image to regular expressions that their contents
+	// must match, e.g. to verify what a buildpack wrote to a layer.
+	FileContains map[string][]string
+	// MustHaveEnv specifies environment variables and their values that must be set when a command
+	// runs in the final image, including those set by the buildpacks through layer environments.
+	MustHaveEnv map[string]string
 	// MustOutput specifies strings to be found in the build logs.
 	MustOutput []string
 	// MustNotOutput specifies strings to not be found in the build logs.
@@ -185,6 +194,16 @@ type Test struct {
 	// `-runtime-version` flag. When the inclusion constraint or `runtime-version` flag are empty all
 	// tests are included. See semver documentation to learn what is possible.
 	VersionInclusionConstraint string
+	// Timeout specifies how long building and invoking the application may take, including the
+	// cached build if EnableCacheTest is set, before the test fails. If unset, defaultTimeout is used.
+	Timeout time.Duration
+	// RunImage specifies the run image to use instead of the one passed to TestApp, e.g. to test
+	// the application on another stack. The test is skipped if the image is not available.
+	RunImage string
+	// Arch specifies the architecture the application image must be built for, such as ArchARM64.
+	// The builder and run images must be provisioned for it with ProvisionImagesForArch. Tests for
+	// arm64 are filtered out by FilterTests unless the -arm64 flag is set.
+	Arch string
 }
 
 // SetupContext is passed into the Test.Setup function, it gives the setupFunc implementor access
@@ -228,6 +247,13 @@ func TestApp(t *testing.T, builderName, runName string, cfg Test) {
 	if cfg.Name == "" {
 		cfg.Name = cfg.App
 	}
+	if cfg.RunImage != "" {
+		var cleanUpRun func(t *testing.T)
+		runName, cleanUpRun = provisionRunImage(t, builderName, cfg.RunImage)
+		defer cleanUpRun(t)
+	}
+	ctx, cancel := testContext(&cfg)
+	defer cancel()
 	// Docker image names may not contain underscores or start with a capital letter.
 	image := fmt.Sprintf("%s-%s", strings.ToLower(specialChars.ReplaceAllString(cfg.Name, "-")), builderName)
 
@@ -238,7 +264,7 @@ func TestApp(t *testing.T, builderName, runName string, cfg Test) {
 	}()
 
 	// Create a configuration for container-structure-tests.
-	checks := NewStructureTest(cfg.FilesMustExist, cfg.FilesMustNotExist)
+	checks := NewStructureTest(cfg.FilesMustExist, cfg.FilesMustNotExist, cfg.FileContains)
 
 	// Run Setup function if provided.
 	src := filepath.Join(testData, cfg.App)
@@ -247,27 +273,31 @@ func TestApp(t *testing.T, builderName, runName string, cfg Test) {
 	}
 
 	if cfg.EnableCacheTest {
-		testAppWithCache(t, src, image, builderName, runName, env, checks, cfg)
+		testAppWithCache(ctx, t, src, image, builderName, runName, env, checks, cfg)
 	} else {
-		testApp(t, src, image, builderName, runName, env, false, checks, cfg)
+		testApp(ctx, t, src, image, builderName, runName, env, false, checks, cfg)
 	}
 }
 
-func testAppWithCache(t *testing.T, src, image, builderName, runName string, env map[string]string, checks *StructureTest, cfg Test) {
+func testAppWithCache(ctx context.Context, t *testing.T, src, image, builderName, runName string, env map[string]string, checks *StructureTest, cfg Test) {
 	// Run a no-cache build, followed by a cache build
 	t.Run("cache false", func(t *testing.T) {
-		testApp(t, src, image, builderName, runName, env, false, checks, cfg)
+		testApp(ctx, t, src, image, builderName, runName, env, false, checks, cfg)
 	})
 	t.Run("cache true", func(t *testing.T) {
-		testApp(t, src, image, builderName, runName, env, true, checks, cfg)
+		testApp(ctx, t, src, image, builderName, runName, env, true, checks, cfg)
 	})
 }
 
-func testApp(t *testing.T, src, image, builderName, runName string, env map[string]string, cacheEnabled bool, checks *StructureTest, cfg Test) {
-	buildApp(t, src, image, builderName, runName, env, cacheEnabled, cfg)
+func testApp(ctx context.Context, t *testing.T, src, image, builderName, runName string, env map[string]string, cacheEnabled bool, checks *StructureTest, cfg Test) {
+	buildApp(ctx, t, src, image, builderName, runName, env, cacheEnabled, cfg)
+	if cfg.Arch != "" {
+		verifyArch(t, image, cfg.Arch)
+	}
 	verifyBuildMetadata(t, image, cfg.MustUse, cfg.MustNotUse, cfg.BOM)
 	verifyStructure(t, image, builderName, cacheEnabled, checks)
-	invokeApp(t, cfg, image, cacheEnabled)
+	verifyLaunchEnv(t, image, cfg.RunEnv, cfg.MustHaveEnv)
+	invokeApp(ctx, t, cfg, image, cacheEnabled)
 }
 
 // FailureTest describes a failure test.
@@ -333,7 +363,7 @@ func TestBuildFailure(t *testing.T, builderName, runName string, cfg FailureTest
 }
 
 // invokeApp performs an HTTP GET or sends a Cloud Event payload to the app.
-func invokeApp(t *testing.T, cfg Test, image string, cache bool) {
+func invokeApp(ctx context.Context, t *testing.T, cfg Test, image string, cache bool) {
 	t.Helper()
 
 	containerID, host, port, cleanup := startContainer(t, image, cfg.Entrypoint, cfg.RunEnv, cache)
@@ -347,9 +377,10 @@ func invokeApp(t *testing.T, cfg Test, image string, cache bool) {
 		reqType = cfg.RequestType
 	}
 
-	body, status, statusCode, err := sendRequest(host, port, cfg.Path, reqType)
+	body, status, statusCode, err := sendRequest(ctx, host, port, cfg.Path, reqType)
 
 	if err != nil {
+		failIfTimedOut(ctx, t, cfg.Timeout, "invoking the application")
 		t.Fatalf("Unable to invoke app: %v", err)
 	}
 
@@ -381,9 +412,10 @@ func invokeApp(t *testing.T, cfg Test, image string, cache bool) {
 		for try := tries; try >= 1; try-- {
 			time.Sleep(1 * time.Second)
 
-			body, status, _, err := sendRequestWithTimeout(host, port, cfg.Path, 10*time.Second, reqType)
+			body, status, _, err := sendRequestWithTimeout(ctx, host, port, cfg.Path, 10*time.Second, reqType)
 			// An app that is rebuilding can be unresponsive.
 			if err != nil {
+				failIfTimedOut(ctx, t, cfg.Timeout, "invoking the updated application")
 				if try == 1 {
 					t.Fatalf("Unable to invoke app after updating source with %d attempts: %v", tries, err)
 				}
@@ -405,14 +437,14 @@ func invokeApp(t *testing.T, cfg Test, image string, cache bool) {
 // sendRequest makes an http call to a given host:port/path
 // or send a cloud event payload to host:port if sendCloudEvents is true.
 // Returns the body, status and statusCode of the response.
-func sendRequest(host string, port int, path string, functionType requestType) (string, string, int, error) {
-	return sendRequestWithTimeout(host, port, path, 120*time.Second, functionType)
+func sendRequest(ctx context.Context, host string, port int, path string, functionType requestType) (string, string, int, error) {
+	return sendRequestWithTimeout(ctx, host, port, path, 120*time.Second, functionType)
 }
 
 // sendRequestWithTimeout makes an http call to a given host:port/path with the specified timeout
 // or send a cloud event payload with timeout to host:port if sendCloudEvents is true.
-// Returns the body, status and statusCode of the response.
-func sendRequestWithTimeout(host string, port int, path string, timeout time.Duration, functionType requestType) (string, string, int, error) {
+// Returns the body, status and statusCode of the response. The requests are canceled when ctx is done.
+func sendRequestWithTimeout(ctx context.Context, host string, port int, path string, timeout time.Duration, functionType requestType) (string, string, int, error) {
 	var res *http.Response
 	var loopErr error
 
@@ -456,7 +488,12 @@ func sendRequestWithTimeout(host string, port int, path string, timeout time.Dur
 				}
 			  }`)
 
-			res, loopErr = http.Post(url, "application/json", bytes.NewBuffer(beJSON))
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(beJSON))
+			if err != nil {
+				return "", "", 0, fmt.Errorf("error creating background event HTTP request: %w", err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			res, loopErr = http.DefaultClient.Do(req)
 		case CloudEventType:
 			ceHeaders := map[string]string{
 				"Content-Type": "application/cloudevents+json",
@@ -472,7 +509,7 @@ func sendRequestWithTimeout(host string, port int, path string, timeout time.Dur
 				"data" : "hello"
 			}`)
 
-			req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(ceJSON))
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(ceJSON))
 			if err != nil {
 				return "", "", 0, fmt.Errorf("error creating CloudEvent HTTP request: %w", loopErr)
 			}
@@ -483,9 +520,13 @@ func sendRequestWithTimeout(host string, port int, path string, timeout time.Dur
 			client := &http.Client{}
 			res, loopErr = client.Do(req)
 		default:
-			res, loopErr = http.Get(url)
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+			if err != nil {
+				return "", "", 0, fmt.Errorf("error creating HTTP request: %w", err)
+			}
+			res, loopErr = http.DefaultClient.Do(req)
 		}
-		if loopErr == nil {
+		if loopErr == nil || ctx.Err() != nil {
 			break
 		}
 
@@ -578,6 +619,25 @@ func cleanUpImage(t *testing.T, name string) {
 // pulled are not cleaned up to prevent conflicts with other tests.
 func ProvisionImages(t *testing.T) (builderName string, runName string, cleanup func()) {
 	t.Helper()
+	return provisionImages(t, "")
+}
+
+// ProvisionImagesForArch is like ProvisionImages, but provisions the builder and run images for the
+// given architecture, such as ArchARM64, instead of the architecture of the Docker daemon. The test
+// is skipped, logging the reason, if the architecture is not enabled by the flags or if the images
+// cannot be pulled and are not available locally for the architecture.
+func ProvisionImagesForArch(t *testing.T, arch string) (builderName string, runName string, cleanup func()) {
+	t.Helper()
+	if reason := archEnabled(arch); reason != "" {
+		t.Skipf("Skipping: %s", reason)
+	}
+	return provisionImages(t, arch)
+}
+
+// provisionImages provisions the images for the given architecture, or for the architecture of the
+// Docker daemon if arch is empty.
+func provisionImages(t *testing.T, arch string) (builderName string, runName string, cleanup func()) {
+	t.Helper()
 
 	if err := checktools.Installed(); err != nil {
 		t.Fatalf("Error checking tools: %v", err)
@@ -590,17 +650,19 @@ func ProvisionImages(t *testing.T) (builderName string, runName string, cleanup
 
 	if builderImage != "" {
 		t.Logf("Testing existing builder image: %s", builderImage)
-		if pullImages {
-			if _, err := runOutput("docker", "pull", builderImage); err != nil {
-				t.Fatalf("Error pulling %s: %v", builderImage, err)
-			}
+		image, cleanUpImageForArch, err := provisionImage(builderImage, arch)
+		if err != nil {
+			skipIfArchUnavailable(t, err)
+			t.Fatalf("Error provisioning %s: %v", builderImage, err)
 		}
 		// Pack cache is based on builder name; retag with a unique name.
-		if _, err := runOutput("docker", "tag", builderImage, builderName); err != nil {
-			t.Fatalf("Error tagging %s as %s: %v", builderImage, builderName, err)
+		if _, err := runOutput("docker", "tag", image, builderName); err != nil {
+			t.Fatalf("Error tagging %s as %s: %v", image, builderName, err)
 		}
-		runName, cleanUpRun, err := provisionRunImageFromBuilder(builderName)
+		cleanUpImageForArch(t)
+		runName, cleanUpRun, err := provisionRunImageFromBuilder(builderName, arch)
 		if err != nil {
+			skipIfArchUnavailable(t, err)
 			t.Fatalf("Error provisioning run image for builder %q: %v", builderName, err)
 		}
 		return builderName, runName, func() {
@@ -627,16 +689,24 @@ func ProvisionImages(t *testing.T) (builderName string, runName string, cleanup
 	}
 	// Pull images once in the beginning to prevent them from changing in the middle of testing.
 	// The images are intentionally not cleaned up to prevent conflicts across different test targets.
-	if pullImages {
-		buildName := builderConfig.Stack.BuildImage
-		if _, err := runOutput("docker", "pull", buildName); err != nil {
-			t.Fatalf("Error pulling %s: %v", buildName, err)
-		}
+	buildName, cleanUpBuild, err := provisionImage(builderConfig.Stack.BuildImage, arch)
+	if err != nil {
+		skipIfArchUnavailable(t, err)
+		t.Fatalf("Error provisioning %s: %v", builderConfig.Stack.BuildImage, err)
 	}
-	runName, cleanUpRun, err := provisionRunImageFromTOML(builderConfig)
+	runName, cleanUpRun, err := provisionRunImageFromTOML(builderConfig, arch)
 	if err != nil {
+		skipIfArchUnavailable(t, err)
 		t.Fatalf("Error provisioning run image: %v", err)
 	}
+	if buildName != builderConfig.Stack.BuildImage || runName != builderConfig.Stack.RunImage {
+		// The images were copied for the architecture; the builder must be created from the copies.
+		if c, err := updateStackImages(config, buildName, runName); err != nil {
+			t.Fatalf("Error updating stack images: %v", err)
+		} else {
+			config = c
+		}
+	}
 
 	// Pack command to create the builder.
 	args := strings.Fields(fmt.Sprintf("builder create %s --config %s --pull-policy never --verbose --no-color", builderName, config))
@@ -659,18 +729,22 @@ func ProvisionImages(t *testing.T) (builderName string, runName string, cleanup
 		cleanUpImage(t, builderName)
 		cleanUpBuilder()
 		cleanUpRun(t)
+		cleanUpBuild(t)
 	}
 }
 
-func provisionRunImageFromTOML(builderConfig *builderTOML) (string, func(t *testing.T), error) {
+func provisionRunImageFromTOML(builderConfig *builderTOML, arch string) (string, func(t *testing.T), error) {
 	runName := builderConfig.Stack.RunImage
 	if runImageOverride != "" {
 		runName = runImageOverride
 	}
-	if pullImages {
-		if _, err := runOutput("docker", "pull", runName); err != nil {
-			return "", nil, fmt.Errorf("pulling %q: %w", runName, err)
-		}
+	runName, cleanUpArch, err := provisionImage(runName, arch)
+	if err != nil {
+		return "", nil, err
+	}
+	if arch != "" {
+		// The run image is a copy for the architecture, so the stack id must be verified.
+		return provisionImageForArchWithStackID(runName, builderConfig.Stack.ID, cleanUpArch)
 	}
 	if runName == builderConfig.Stack.RunImage {
 		// when the run image name is the one defined in the builderconfig, do not verify the stack ids
@@ -680,7 +754,7 @@ func provisionRunImageFromTOML(builderConfig *builderTOML) (string, func(t *test
 	return provisionImageWithMatchingStackID(runName, builderConfig.Stack.ID)
 }
 
-func provisionRunImageFromBuilder(builderName string) (string, func(t *testing.T), error) {
+func provisionRunImageFromBuilder(builderName, arch string) (string, func(t *testing.T), error) {
 	builderDefinedRunImage, err := runImageFromMetadata(builderName)
 	if err != nil {
 		return "", nil, fmt.Errorf("Error extracting run image from image %q: %w", builderName, err)
@@ -689,10 +763,16 @@ func provisionRunImageFromBuilder(builderName string) (string, func(t *testing.T
 	if runImageOverride != "" {
 		runName = runImageOverride
 	}
-	if pullImages {
-		if _, err := runOutput("docker", "pull", runName); err != nil {
-			return "", nil, fmt.Errorf("pulling %q: %w", runName, err)
+	runName, cleanUpArch, err := provisionImage(runName, arch)
+	if err != nil {
+		return "", nil, err
+	}
+	if arch != "" {
+		builderStackID, err := getImageStackID(builderName)
+		if err != nil {
+			return "", nil, fmt.Errorf("getting stack id of builder %q: %w", builderName, err)
 		}
+		return provisionImageForArchWithStackID(runName, builderStackID, cleanUpArch)
 	}
 	if builderDefinedRunImage == runName {
 		// when the run image is the one defined for the builder, do not verify the stack ids match
@@ -783,6 +863,29 @@ func extractBuilder(t *testing.T, builderSource string) (string, func()) {
 
 // updateLifecycle rewrites the lifecycle field of the config to the given uri.
 func updateLifecycle(config, uri string) (string, error) {
+	return updateBuilderTOML(config, func(data map[string]interface{}) {
+		data["lifecycle"] = map[string]string{
+			"uri": uri,
+		}
+	})
+}
+
+// updateStackImages rewrites the build and run images of the stack of the config.
+func updateStackImages(config, buildImage, runImage string) (string, error) {
+	return updateBuilderTOML(config, func(data map[string]interface{}) {
+		stack, ok := data["stack"].(map[string]interface{})
+		if !ok {
+			stack = map[string]interface{}{}
+			data["stack"] = stack
+		}
+		stack["build-image"] = buildImage
+		stack["run-image"] = runImage
+	})
+}
+
+// updateBuilderTOML writes a copy of the config updated by the given function next to it and
+// returns the path of the copy.
+func updateBuilderTOML(config string, update func(data map[string]interface{})) (string, error) {
 	p, err := ioutil.ReadFile(config)
 	if err != nil {
 		return "", fmt.Errorf("reading %s: %v", config, err)
@@ -792,9 +895,7 @@ func updateLifecycle(config, uri string) (string, error) {
 		return "", fmt.Errorf("unmarshaling %s: %v", config, err)
 	}
 
-	data["lifecycle"] = map[string]string{
-		"uri": uri,
-	}
+	update(data)
 
 	f, err := ioutil.TempFile("", "builder-*.toml")
 	if err != nil {
@@ -898,7 +999,7 @@ func buildCommand(srcDir, image, builderName, runName string, env map[string]str
 }
 
 // buildApp builds an application image from source.
-func buildApp(t *testing.T, srcDir, image, builderName, runName string, env map[string]string, cache bool, cfg Test) {
+func buildApp(ctx context.Context, t *testing.T, srcDir, image, builderName, runName string, env map[string]string, cache bool, cfg Test) {
 	t.Helper()
 
 	attempts := cfg.FlakyBuildAttempts
@@ -919,12 +1020,13 @@ func buildApp(t *testing.T, srcDir, image, builderName, runName string, env map[
 		defer cleanup()
 
 		bcmd := buildCommand(srcDir, image, builderName, runName, env, cache)
-		cmd := exec.Command(bcmd[0], bcmd[1:]...)
+		cmd := exec.CommandContext(ctx, bcmd[0], bcmd[1:]...)
 		cmd.Stdout = io.MultiWriter(outFile, &outb) // pack emits detect output to stdout.
 		cmd.Stderr = io.MultiWriter(errFile, &errb) // pack emits build output to stderr.
 
 		t.Logf("Building application %s (logs %s)", image, filepath.Dir(outFile.Name()))
 		if err := cmd.Run(); err != nil {
+			failIfTimedOut(ctx, t, cfg.Timeout, fmt.Sprintf("building application %s, logs:\n%s\n%s", image, outb.String(), errb.String()))
 			if attempt < attempts {
 				t.Logf("Error building application %s, attempt %d of %d: %v, logs:\n%s\n%s", image, attempt, attempts, err, outb.String(), errb.String())
 				outb.Reset()
@@ -1280,10 +1382,15 @@ func PullImages() bool {
 }
 
 // FilterTests returns a new slice with only tests that should be run. Tests are filtered out if
-// their VersionInclusionConstraint does not match the `-runtime-version` flag.
+// their VersionInclusionConstraint does not match the `-runtime-version` flag, or if their Arch
+// is not enabled by the flags.
 func FilterTests(t *testing.T, testCases []Test) []Test {
 	results := make([]Test, 0)
 	for _, tc := range testCases {
+		if reason := archEnabled(tc.Arch); reason != "" {
+			t.Logf("Skipping %q: %s", tc.Name, reason)
+			continue
+		}
 		if ShouldTestVersion(t, tc.VersionInclusionConstraint) {
 			results = append(results, tc)
 		}
@@ -0,0 +1,124 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package acceptance
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"testing"
+)
+
+const (
+	// ArchAMD64 is the architecture of the default builder and run images.
+	ArchAMD64 = "amd64"
+	// ArchARM64 is the 64-bit ARM architecture; tests for it only run with the -arm64 flag.
+	ArchARM64 = "arm64"
+)
+
+// errArchUnavailable is returned if an image is not available for the requested architecture.
+var errArchUnavailable = errors.New("image not available for architecture")
+
+// archEnabled returns an empty string if tests for the given architecture, empty for the default, can
+// run, or the reason why they are skipped.
+func archEnabled(arch string) string {
+	if arch == ArchARM64 && !arm64 {
+		return fmt.Sprintf("tests for %s require the -arm64 flag", arch)
+	}
+	return ""
+}
+
+// pullImage pulls the image for the given architecture, or for the architecture of the Docker
+// daemon if arch is empty.
+func pullImage(image, arch string) error {
+	args := []string{"docker", "pull"}
+	if arch != "" {
+		args = append(args, "--platform", "linux/"+arch)
+	}
+	_, err := runOutput(append(args, image)...)
+	return err
+}
+
+// pullImageForArch pulls the image for the given architecture and returns a uniquely named copy
+// of it, so that the images of other architectures pulled under the same name by concurrent tests
+// do not replace it. The pull may fail if the image already is available locally, but the returned
+// error wraps errArchUnavailable if the local image is not for the given architecture.
+func pullImageForArch(image, arch string) (string, func(t *testing.T), error) {
+	if pullImages {
+		if err := pullImage(image, arch); err != nil {
+			log.Printf("Pulling %s for %s failed, falling back to the local image: %v", image, arch, err)
+		}
+	}
+	got, err := runOutput("docker", "image", "inspect", "--format={{.Architecture}}", image)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %s is not available locally: %v", errArchUnavailable, image, err)
+	}
+	if got != arch {
+		return "", nil, fmt.Errorf("%w: %s is for %s, not %s", errArchUnavailable, image, got, arch)
+	}
+	tagged := generateRandomImageName(image)
+	if _, err := runOutput("docker", "tag", image, tagged); err != nil {
+		return "", nil, fmt.Errorf("tagging %s as %s: %w", image, tagged, err)
+	}
+	return tagged, func(t *testing.T) { cleanUpImage(t, tagged) }, nil
+}
+
+// provisionImage makes the image available locally for the given architecture, or for the
+// architecture of the Docker daemon if arch is empty, and returns its name.
+func provisionImage(image, arch string) (string, func(t *testing.T), error) {
+	if arch != "" {
+		return pullImageForArch(image, arch)
+	}
+	if pullImages {
+		if err := pullImage(image, arch); err != nil {
+			return "", nil, fmt.Errorf("pulling %q: %w", image, err)
+		}
+	}
+	return image, func(t *testing.T) {}, nil
+}
+
+// skipIfArchUnavailable skips the test if err is caused by an image that is not available for the
+// requested architecture.
+func skipIfArchUnavailable(t *testing.T, err error) {
+	t.Helper()
+	if errors.Is(err, errArchUnavailable) {
+		t.Skipf("Skipping: %v", err)
+	}
+}
+
+// verifyArch checks that the application image was built for the given architecture.
+func verifyArch(t *testing.T, image, arch string) {
+	t.Helper()
+	got, err := runOutput("docker", "image", "inspect", "--format={{.Architecture}}", image)
+	if err != nil {
+		t.Fatalf("Error inspecting the architecture of %s: %v", image, err)
+	}
+	if got != arch {
+		t.Errorf("Image %s is for architecture %s, want %s", image, got, arch)
+	}
+}
+
+// provisionImageForArchWithStackID is like provisionImageWithMatchingStackID for an image copied for
+// an architecture, which is also cleaned up by the returned function.
+func provisionImageForArchWithStackID(image, stackID string, cleanUpArch func(t *testing.T)) (string, func(t *testing.T), error) {
+	name, cleanUp, err := provisionImageWithMatchingStackID(image, stackID)
+	if err != nil {
+		return "", nil, err
+	}
+	return name, func(t *testing.T) {
+		cleanUp(t)
+		cleanUpArch(t)
+	}, nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package acceptance
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestArchEnabled(t *testing.T) {
+	testCases := []struct {
+		name        string
+		arch        string
+		arm64       bool
+		wantEnabled bool
+	}{
+		{name: "default", wantEnabled: true},
+		{name: "amd64", arch: ArchAMD64, wantEnabled: true},
+		{name: "arm64 without flag", arch: ArchARM64},
+		{name: "arm64 with flag", arch: ArchARM64, arm64: true, wantEnabled: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			old := arm64
+			arm64 = tc.arm64
+			t.Cleanup(func() { arm64 = old })
+
+			reason := archEnabled(tc.arch)
+			if gotEnabled := reason == ""; gotEnabled != tc.wantEnabled {
+				t.Errorf("archEnabled(%q) = %q, want enabled: %t", tc.arch, reason, tc.wantEnabled)
+			}
+		})
+	}
+}
+
+func TestUpdateStackImages(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "builder.toml")
+	content := `
+[[buildpacks]]
+  id = "google.go.runtime"
+  uri = "go/runtime.tgz"
+
+[stack]
+  id = "google"
+  build-image = "gcr.io/buildpacks/gcp/build:v1"
+  run-image = "gcr.io/buildpacks/gcp/run:v1"
+`
+	if err := ioutil.WriteFile(config, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write builder.toml: %v", err)
+	}
+
+	got, err := updateStackImages(config, "build-arm64", "run-arm64")
+	if err != nil {
+		t.Fatalf("updateStackImages() got error: %v", err)
+	}
+	if filepath.Dir(got) != dir {
+		t.Errorf("updateStackImages() = %q, want a file in %q", got, dir)
+	}
+	bc, err := readBuilderTOML(got)
+	if err != nil {
+		t.Fatalf("Failed to read updated builder.toml: %v", err)
+	}
+	if bc.Stack.ID != "google" || bc.Stack.BuildImage != "build-arm64" || bc.Stack.RunImage != "run-arm64" {
+		t.Errorf("updateStackImages() stack = %+v, want id google with the arm64 images", bc.Stack)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package acceptance
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// launcher is the path of the CNB launcher, which sets up the environment of the layers before
+// running a command in the application image.
+const launcher = "/cnb/lifecycle/launcher"
+
+// verifyLaunchEnv runs `env` through the launcher of the image and checks that the environment
+// variables in want have the expected values.
+func verifyLaunchEnv(t *testing.T, image string, runEnv []string, want map[string]string) {
+	t.Helper()
+
+	if len(want) == 0 {
+		return
+	}
+	command := []string{"docker", "run", "--rm", "--entrypoint=" + launcher}
+	for _, e := range runEnv {
+		command = append(command, "--env", e)
+	}
+	command = append(command, image, "env")
+	out, err := runOutput(command...)
+	if err != nil {
+		t.Fatalf("Error reading the environment of %s: %v", image, err)
+	}
+	if diff := launchEnvDiff(want, parseEnv(out)); diff != "" {
+		t.Errorf("Environment of %s mismatch (-want +got):\n%s", image, diff)
+	}
+}
+
+// parseEnv parses the KEY=VALUE lines printed by `env`. Lines without "=", such as the
+// continuation lines of multi-line values, are ignored.
+func parseEnv(out string) map[string]string {
+	vars := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		if i := strings.Index(line, "="); i > 0 {
+			vars[line[:i]] = line[i+1:]
+		}
+	}
+	return vars
+}
+
+// launchEnvDiff returns the difference between the wanted environment variables and the values of
+// the same variables in got, or "" if they match. Other variables in got are ignored.
+func launchEnvDiff(want, got map[string]string) string {
+	if len(want) == 0 {
+		return ""
+	}
+	relevant := map[string]string{}
+	for k := range want {
+		if v, ok := got[k]; ok {
+			relevant[k] = v
+		}
+	}
+	return cmp.Diff(want, relevant)
+}
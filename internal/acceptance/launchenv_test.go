@@ -0,0 +1,76 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package acceptance
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseEnv(t *testing.T) {
+	out := "PATH=/layers/google.dotnet.sdk/sdk:/usr/bin\nDOTNET_ROOT=/layers/google.dotnet.sdk/sdk\nEMPTY=\nMULTI=a\nb\nOPTS=--x=1"
+
+	got := parseEnv(out)
+
+	want := map[string]string{
+		"PATH":        "/layers/google.dotnet.sdk/sdk:/usr/bin",
+		"DOTNET_ROOT": "/layers/google.dotnet.sdk/sdk",
+		"EMPTY":       "",
+		"MULTI":       "a",
+		"OPTS":        "--x=1",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("parseEnv() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestLaunchEnvDiff(t *testing.T) {
+	got := map[string]string{
+		"DOTNET_ROOT":                 "/layers/google.dotnet.sdk/sdk",
+		"DOTNET_RUNNING_IN_CONTAINER": "true",
+		"HOME":                        "/home/cnb",
+	}
+	testCases := []struct {
+		name     string
+		want     map[string]string
+		wantDiff bool
+	}{
+		{
+			name: "match",
+			want: map[string]string{"DOTNET_ROOT": "/layers/google.dotnet.sdk/sdk", "DOTNET_RUNNING_IN_CONTAINER": "true"},
+		},
+		{
+			name: "empty",
+		},
+		{
+			name:     "mismatch",
+			want:     map[string]string{"DOTNET_ROOT": "/layers/google.dotnet.runtime/rt"},
+			wantDiff: true,
+		},
+		{
+			name:     "missing",
+			want:     map[string]string{"ASPNETCORE_URLS": "http://0.0.0.0:8080"},
+			wantDiff: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if diff := launchEnvDiff(tc.want, got); (diff != "") != tc.wantDiff {
+				t.Errorf("launchEnvDiff(%v, %v) = %q, want diff: %t", tc.want, got, diff, tc.wantDiff)
+			}
+		})
+	}
+}
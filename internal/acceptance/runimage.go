@@ -0,0 +1,45 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package acceptance
+
+import (
+	"log"
+	"testing"
+)
+
+// provisionRunImage makes the run image of a test case available locally with the stack ID of the
+// builder, so that pack accepts it, and returns its name. It skips the test if the run image is not
+// available.
+func provisionRunImage(t *testing.T, builderName, runImage string) (string, func(t *testing.T)) {
+	t.Helper()
+
+	if pullImages {
+		if err := pullImage(runImage, ""); err != nil {
+			log.Printf("Pulling %s failed, falling back to the local image: %v", runImage, err)
+		}
+	}
+	if _, err := runOutput("docker", "image", "inspect", runImage); err != nil {
+		t.Skipf("Skipping: run image %s is not available: %v", runImage, err)
+	}
+	stackID, err := getImageStackID(builderName)
+	if err != nil {
+		t.Fatalf("Error getting the stack id of builder %s: %v", builderName, err)
+	}
+	name, cleanUp, err := provisionImageWithMatchingStackID(runImage, stackID)
+	if err != nil {
+		t.Fatalf("Error provisioning run image %s: %v", runImage, err)
+	}
+	return name, cleanUp
+}
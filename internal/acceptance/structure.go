@@ -14,11 +14,16 @@
 
 package acceptance
 
+import (
+	"sort"
+)
+
 // StructureTest describes verifications on a container image.
 type StructureTest struct {
 	SchemaVersion      string              `yaml:"schemaVersion"`
 	MetadataTest       metadataTest        `yaml:"metadataTest"`
 	FileExistenceTests []fileExistenceTest `yaml:"fileExistenceTests"`
+	FileContentTests   []fileContentTest   `yaml:"fileContentTests"`
 }
 
 // metadataTest verifies the image's metadata.
@@ -39,15 +44,23 @@ type fileExistenceTest struct {
 	GID         int    `yaml:"gid"`
 }
 
+// fileContentTest verifies the contents of a file.
+type fileContentTest struct {
+	Name             string   `yaml:"name"`
+	Path             string   `yaml:"path"`
+	ExpectedContents []string `yaml:"expectedContents"`
+}
+
 // envVar tests for the existence of an environment variable.
 type envVar struct {
 	Key   string `yaml:"key"`
 	Value string `yaml:"value"`
 }
 
-// NewStructureTest creates a new StructureTest. It returns nil if there's nothing to check.
-func NewStructureTest(filesMustExist, filesMustNotExist []string) *StructureTest {
-	if len(filesMustExist) == 0 && len(filesMustNotExist) == 0 {
+// NewStructureTest creates a new StructureTest. The fileContains map holds the regular expressions
+// that the contents of each file must match. It returns nil if there's nothing to check.
+func NewStructureTest(filesMustExist, filesMustNotExist []string, fileContains map[string][]string) *StructureTest {
+	if len(filesMustExist) == 0 && len(filesMustNotExist) == 0 && len(fileContains) == 0 {
 		return nil
 	}
 
@@ -71,8 +84,23 @@ func NewStructureTest(filesMustExist, filesMustNotExist []string) *StructureTest
 		})
 	}
 
+	var paths []string
+	for path := range fileContains {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	var fcs []fileContentTest
+	for _, path := range paths {
+		fcs = append(fcs, fileContentTest{
+			Name:             path,
+			Path:             path,
+			ExpectedContents: fileContains[path],
+		})
+	}
+
 	return &StructureTest{
 		SchemaVersion:      "2.0.0",
 		FileExistenceTests: fts,
+		FileContentTests:   fcs,
 	}
 }
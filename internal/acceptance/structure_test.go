@@ -29,7 +29,7 @@ func TestStructureTestJSON(t *testing.T) {
 		{
 			name:   "empty configuration",
 			checks: StructureTest{},
-			want:   `{"SchemaVersion":"","MetadataTest":{"Env":null,"ExposedPorts":null,"Entrypoint":null,"Cmd":null,"Workdir":""},"FileExistenceTests":null}`,
+			want:   `{"SchemaVersion":"","MetadataTest":{"Env":null,"ExposedPorts":null,"Entrypoint":null,"Cmd":null,"Workdir":""},"FileExistenceTests":null,"FileContentTests":null}`,
 		},
 		{
 			name: "check empty cmd",
@@ -38,7 +38,7 @@ func TestStructureTestJSON(t *testing.T) {
 					Cmd: []string{},
 				},
 			},
-			want: `{"SchemaVersion":"","MetadataTest":{"Env":null,"ExposedPorts":null,"Entrypoint":null,"Cmd":[],"Workdir":""},"FileExistenceTests":null}`,
+			want: `{"SchemaVersion":"","MetadataTest":{"Env":null,"ExposedPorts":null,"Entrypoint":null,"Cmd":[],"Workdir":""},"FileExistenceTests":null,"FileContentTests":null}`,
 		},
 	}
 	for _, tc := range testCases {
@@ -60,6 +60,7 @@ func TestNewStructureTest(t *testing.T) {
 		name              string
 		filesMustExist    []string
 		filesMustNotExist []string
+		fileContains      map[string][]string
 		want              *StructureTest
 	}{
 		{
@@ -92,10 +93,32 @@ func TestNewStructureTest(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "check file contents",
+			fileContains: map[string][]string{
+				"/workspace/public/index.txt": {"^PASS$"},
+				"/workspace/package.json":     {`"name"`, `"start"`},
+			},
+			want: &StructureTest{
+				SchemaVersion: "2.0.0",
+				FileContentTests: []fileContentTest{
+					{
+						Name:             "/workspace/package.json",
+						Path:             "/workspace/package.json",
+						ExpectedContents: []string{`"name"`, `"start"`},
+					},
+					{
+						Name:             "/workspace/public/index.txt",
+						Path:             "/workspace/public/index.txt",
+						ExpectedContents: []string{"^PASS$"},
+					},
+				},
+			},
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			st := NewStructureTest(tc.filesMustExist, tc.filesMustNotExist)
+			st := NewStructureTest(tc.filesMustExist, tc.filesMustNotExist, tc.fileContains)
 
 			if !reflect.DeepEqual(st, tc.want) {
 				t.Errorf("NewStructureTest() got=%#v, want=%#v", st, tc.want)
@@ -0,0 +1,42 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package acceptance
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// defaultTimeout is the time allowed to build and invoke an application when Test.Timeout is unset.
+const defaultTimeout = 30 * time.Minute
+
+// testContext returns a context that expires after cfg.Timeout, setting it to defaultTimeout if unset.
+func testContext(cfg *Test) (context.Context, context.CancelFunc) {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	return context.WithTimeout(context.Background(), cfg.Timeout)
+}
+
+// failIfTimedOut fails the current test if ctx expired while performing the given action.
+func failIfTimedOut(ctx context.Context, t *testing.T, timeout time.Duration, action string) {
+	t.Helper()
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		t.Fatalf("Test timed out after %v while %s; increase Test.Timeout if the application needs more time", timeout, action)
+	}
+}
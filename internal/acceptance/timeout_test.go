@@ -0,0 +1,101 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package acceptance
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTestContext(t *testing.T) {
+	testCases := []struct {
+		name    string
+		timeout time.Duration
+		want    time.Duration
+	}{
+		{
+			name: "default",
+			want: defaultTimeout,
+		},
+		{
+			name:    "custom",
+			timeout: 5 * time.Minute,
+			want:    5 * time.Minute,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := Test{Timeout: tc.timeout}
+			start := time.Now()
+
+			ctx, cancel := testContext(&cfg)
+			defer cancel()
+			end := time.Now()
+
+			if cfg.Timeout != tc.want {
+				t.Errorf("testContext() set Timeout=%v, want %v", cfg.Timeout, tc.want)
+			}
+			deadline, ok := ctx.Deadline()
+			if !ok {
+				t.Fatalf("testContext() returned a context without a deadline")
+			}
+			if deadline.Before(start.Add(tc.want)) || deadline.After(end.Add(tc.want)) {
+				t.Errorf("testContext() deadline in %v, want %v", deadline.Sub(start), tc.want)
+			}
+		})
+	}
+}
+
+func TestSlowAppTimesOut(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		case <-time.After(10 * time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	host, p, err := net.SplitHostPort(srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Splitting %q: %v", srv.Listener.Addr().String(), err)
+	}
+	port, err := strconv.Atoi(p)
+	if err != nil {
+		t.Fatalf("Parsing port %q: %v", p, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+
+	if _, _, _, err := sendRequestWithTimeout(ctx, host, port, "/", 10*time.Second, HTTPType); err == nil {
+		t.Fatal("sendRequestWithTimeout() got nil error, want timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("sendRequestWithTimeout() returned after %v, want it to stop at the deadline", elapsed)
+	}
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		t.Errorf("ctx.Err() = %v, want %v", ctx.Err(), context.DeadlineExceeded)
+	}
+}
@@ -120,6 +120,13 @@ func WithApp(appName string) Option {
 	}
 }
 
+// WithFiles specifies files, by path relative to the app and content, to add to the app.
+func WithFiles(files map[string]string) Option {
+	return func(cfg *config) {
+		cfg.files = files
+	}
+}
+
 // WithEnvs specifies env vars to set for the buildpack test.
 func WithEnvs(envs ...string) Option {
 	return func(cfg *config) {
@@ -0,0 +1,111 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package builddb provisions ephemeral databases for build steps that need one, such as
+// generating a client or bundling migrations against a database schema.
+package builddb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// SQLite is the kind of an empty SQLite database file.
+	SQLite = "sqlite"
+
+	// PathEnv is set to the path of the database for the build step.
+	PathEnv = "GOOGLE_BUILD_DB_PATH"
+	// URLEnv is set to the URL of the database for the build step, unless the application already
+	// sets it.
+	URLEnv = "DATABASE_URL"
+
+	layerName  = "builddb"
+	sqliteFile = "build.db"
+)
+
+// Database is an ephemeral database that only exists during the build step.
+type Database struct {
+	// Kind is the kind of the database, e.g. sqlite.
+	Kind string
+	// Path is the path of the database file.
+	Path string
+	// Env holds the environment variables that point the build step at the database.
+	Env []string
+}
+
+// Provision creates an ephemeral database of the given kind in a scratch layer. It returns nil if
+// kind is empty, i.e. GOOGLE_BUILD_DB is not set.
+func Provision(ctx *gcp.Context, kind string) (*Database, error) {
+	switch strings.ToLower(kind) {
+	case "":
+		return nil, nil
+	case SQLite:
+		return provisionSQLite(ctx)
+	default:
+		return nil, gcp.UserErrorf("unsupported build database %q, must be %q", kind, SQLite)
+	}
+}
+
+// ProvisionFromEnv provisions the database requested with GOOGLE_BUILD_DB, if any, see Provision.
+func ProvisionFromEnv(ctx *gcp.Context) (*Database, error) {
+	return Provision(ctx, os.Getenv(env.BuildDB))
+}
+
+func provisionSQLite(ctx *gcp.Context) (*Database, error) {
+	l, err := ctx.Layer(layerName, gcp.ScratchLayer)
+	if err != nil {
+		return nil, fmt.Errorf("creating %v layer: %w", layerName, err)
+	}
+	path := filepath.Join(l.Path, sqliteFile)
+	// SQLite treats an empty file as an empty database.
+	f, err := ctx.CreateFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, gcp.InternalErrorf("closing %s: %v", path, err)
+	}
+	db := &Database{
+		Kind: SQLite,
+		Path: path,
+		Env:  []string{PathEnv + "=" + path},
+	}
+	if os.Getenv(URLEnv) == "" {
+		db.Env = append(db.Env, URLEnv+"=file:"+path)
+	}
+	ctx.Logf("Provisioned an ephemeral SQLite database at %s for the build.", path)
+	return db, nil
+}
+
+// ExecOptions returns the options to run the build step with, which expose the database.
+func (db *Database) ExecOptions() []gcp.ExecOption {
+	if db == nil {
+		return nil
+	}
+	return []gcp.ExecOption{gcp.WithEnv(db.Env...)}
+}
+
+// Close removes the database once the build step is done.
+func (db *Database) Close(ctx *gcp.Context) error {
+	if db == nil {
+		return nil
+	}
+	return ctx.RemoveAll(filepath.Dir(db.Path))
+}
@@ -0,0 +1,146 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builddb
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestProvisionSQLite(t *testing.T) {
+	testCases := []struct {
+		name    string
+		kind    string
+		url     string
+		wantURL string
+	}{
+		{
+			name: "sqlite",
+			kind: "sqlite",
+		},
+		{
+			name: "case insensitive",
+			kind: "SQLite",
+		},
+		{
+			name:    "application database url is kept",
+			kind:    "sqlite",
+			url:     "file:./dev.db",
+			wantURL: "file:./dev.db",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(URLEnv, tc.url)
+			ctx := gcp.NewContext(gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: t.TempDir()}}))
+
+			db, err := Provision(ctx, tc.kind)
+			if err != nil {
+				t.Fatalf("Provision(%q) got error: %v", tc.kind, err)
+			}
+			if db.Kind != SQLite {
+				t.Errorf("Provision(%q) kind = %q, want %q", tc.kind, db.Kind, SQLite)
+			}
+			wantURL := tc.wantURL
+			if wantURL == "" {
+				wantURL = "file:" + db.Path
+			}
+
+			// The build step sees the database through its environment.
+			result, cerr := ctx.ExecWithErr([]string{"sh", "-c", `test -f "$` + PathEnv + `" && echo "$` + PathEnv + ` $` + URLEnv + `"`}, db.ExecOptions()...)
+			if cerr != nil {
+				t.Fatalf("running the build step got error: %v", cerr)
+			}
+			if got, want := strings.TrimSpace(result.Stdout), db.Path+" "+wantURL; got != want {
+				t.Errorf("build step environment = %q, want %q", got, want)
+			}
+
+			if err := db.Close(ctx); err != nil {
+				t.Fatalf("Close() got error: %v", err)
+			}
+			if _, err := os.Stat(filepath.Dir(db.Path)); !os.IsNotExist(err) {
+				t.Errorf("database directory %s still exists after Close(), stat error: %v", filepath.Dir(db.Path), err)
+			}
+		})
+	}
+}
+
+func TestProvisionUnset(t *testing.T) {
+	ctx := gcp.NewContext(gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: t.TempDir()}}))
+
+	db, err := Provision(ctx, "")
+	if err != nil {
+		t.Fatalf("Provision() got error: %v", err)
+	}
+	if db != nil {
+		t.Fatalf("Provision() = %+v, want nil", db)
+	}
+	if opts := db.ExecOptions(); opts != nil {
+		t.Errorf("ExecOptions() = %v, want nil", opts)
+	}
+	if err := db.Close(ctx); err != nil {
+		t.Errorf("Close() got error: %v", err)
+	}
+}
+
+func TestProvisionFromEnv(t *testing.T) {
+	testCases := []struct {
+		name     string
+		buildDB  string
+		wantKind string
+	}{
+		{
+			name: "unset",
+		},
+		{
+			name:     "sqlite",
+			buildDB:  "sqlite",
+			wantKind: SQLite,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(env.BuildDB, tc.buildDB)
+			ctx := gcp.NewContext(gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: t.TempDir()}}))
+
+			db, err := ProvisionFromEnv(ctx)
+			if err != nil {
+				t.Fatalf("ProvisionFromEnv() got error: %v", err)
+			}
+			defer db.Close(ctx)
+			var gotKind string
+			if db != nil {
+				gotKind = db.Kind
+			}
+			if gotKind != tc.wantKind {
+				t.Errorf("ProvisionFromEnv() kind = %q, want %q", gotKind, tc.wantKind)
+			}
+		})
+	}
+}
+
+func TestProvisionUnsupported(t *testing.T) {
+	ctx := gcp.NewContext(gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: t.TempDir()}}))
+
+	if _, err := Provision(ctx, "postgres"); err == nil {
+		t.Error("Provision(postgres) got no error, want error")
+	}
+}
@@ -57,8 +57,15 @@ func (bo BuilderOutput) IsSystemError() bool {
 
 // BuilderStat contains statistics about a build step
 type BuilderStat struct {
-	BuildpackID      string `json:"buildpackId"`
-	BuildpackVersion string `json:"buildpackVersion"`
-	DurationMs       int64  `json:"totalDurationMs"`
-	UserDurationMs   int64  `json:"userDurationMs"`
+	BuildpackID      string          `json:"buildpackId"`
+	BuildpackVersion string          `json:"buildpackVersion"`
+	DurationMs       int64           `json:"totalDurationMs"`
+	UserDurationMs   int64           `json:"userDurationMs"`
+	Timings          []BuilderTiming `json:"timings,omitempty"`
+}
+
+// BuilderTiming contains the duration of an operation of a build step, such as a command
+type BuilderTiming struct {
+	Name       string `json:"name"`
+	DurationMs int64  `json:"durationMs"`
 }
@@ -19,7 +19,9 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"io/ioutil"
+	"os"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 )
 
@@ -50,12 +52,15 @@ func WithFiles(files ...string) Option {
 	}
 }
 
-// Hash creates a sha256 hash from the given cache options.
+// Hash creates a sha256 hash from the given cache options and GOOGLE_CACHE_SEED, if set.
 func Hash(ctx *gcp.Context, opts ...Option) (result string, err error) {
 	h := sha256.New()
 
 	h.Write([]byte(ctx.BuildpackID()))
 	h.Write([]byte(ctx.BuildpackVersion()))
+	if seed := os.Getenv(env.CacheSeed); seed != "" {
+		h.Write([]byte(seed))
+	}
 
 	for _, opt := range opts {
 		strings, err := opt()
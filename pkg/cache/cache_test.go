@@ -21,6 +21,7 @@ import (
 	"sort"
 	"testing"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/buildpacks/libcnb"
 )
@@ -229,3 +230,30 @@ func removeDuplicates(t *testing.T, original []string) []string {
 	}
 	return result
 }
+
+func TestHashWithCacheSeed(t *testing.T) {
+	hash := func(seed string) string {
+		t.Helper()
+		t.Setenv(env.CacheSeed, seed)
+		ctx := gcp.NewContext(gcp.WithBuildpackInfo(libcnb.BuildpackInfo{ID: "id", Version: "version"}))
+		got, err := Hash(ctx, WithStrings("my-string"))
+		if err != nil {
+			t.Fatalf("Hash() got error: %v", err)
+		}
+		return got
+	}
+
+	// An unset seed keeps the keys computed before seeds were supported.
+	if got, want := hash(""), "75e3d0ce18615f1fcca84513474b0040ec223ceac07e0079a0221a7e1704caa6"; got != want {
+		t.Errorf("Hash() without seed = %q, want %q", got, want)
+	}
+	if a, b := hash("seed-1"), hash("seed-1"); a != b {
+		t.Errorf("Hash() with the same seed = %q and %q, want equal keys", a, b)
+	}
+	if a, b := hash("seed-1"), hash("seed-2"); a == b {
+		t.Errorf("Hash() with different seeds = %q, want different keys", a)
+	}
+	if a, b := hash(""), hash("seed-1"); a == b {
+		t.Errorf("Hash() with and without seed = %q, want different keys", a)
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// cachedFile is a regular file in a cache directory.
+type cachedFile struct {
+	path     string
+	size     int64
+	lastUsed time.Time
+}
+
+// PruneBySize removes the least recently used files of the cache directory dir until the total
+// size of its files is at most maxBytes, so that a cache that is kept across builds does not grow
+// without bounds. A file is used when it is written or read, as recorded by its modification and
+// access times.
+func PruneBySize(ctx *gcp.Context, dir string, maxBytes int64) error {
+	files, total, err := listFiles(dir)
+	if err != nil {
+		return err
+	}
+	ctx.Debugf("Cache %s holds %d bytes in %d files, the maximum is %d bytes.", dir, total, len(files), maxBytes)
+	if total <= maxBytes {
+		return nil
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].lastUsed.Before(files[j].lastUsed)
+	})
+	var removed int
+	var freed int64
+	for _, f := range files {
+		if total-freed <= maxBytes {
+			break
+		}
+		if err := os.Remove(f.path); err != nil {
+			return gcp.InternalErrorf("removing %s: %v", f.path, err)
+		}
+		removed++
+		freed += f.size
+	}
+	ctx.Logf("Pruned %d least recently used files (%d bytes) from the cache %s.", removed, freed, dir)
+	return nil
+}
+
+// lastUsed returns the most recent of the modification and access times of a file.
+func lastUsed(info fs.FileInfo) time.Time {
+	t := info.ModTime()
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		if atime := time.Unix(st.Atim.Sec, st.Atim.Nsec); atime.After(t) {
+			t = atime
+		}
+	}
+	return t
+}
+
+// Size returns the total size of the regular files in the cache directory dir, or 0 if it does
+// not exist. Caches whose files cannot be removed individually, because the tool that fills them
+// would see incomplete entries, are cleared as a whole once they exceed a size.
+func Size(dir string) (int64, error) {
+	_, total, err := listFiles(dir)
+	return total, err
+}
+
+// listFiles returns the regular files in the cache directory dir and their total size, or none if
+// it does not exist.
+func listFiles(dir string) ([]cachedFile, int64, error) {
+	var (
+		files []cachedFile
+		total int64
+	)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, cachedFile{path: path, size: info.Size(), lastUsed: lastUsed(info)})
+		total += info.Size()
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, gcp.InternalErrorf("listing the files of %s: %v", dir, err)
+	}
+	return files, total, nil
+}
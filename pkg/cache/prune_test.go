@@ -0,0 +1,119 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+func TestPruneBySize(t *testing.T) {
+	now := time.Now()
+	// The files are 100 bytes each, from the least to the most recently used.
+	files := []string{"http/a/old", "wheels/b/older.whl", "http/c/recent", "wheels/d/newest.whl"}
+	ages := []time.Duration{72 * time.Hour, 48 * time.Hour, time.Hour, time.Minute}
+	testCases := []struct {
+		name     string
+		maxBytes int64
+		want     []string
+	}{
+		{
+			name:     "under the limit",
+			maxBytes: 1000,
+			want:     files,
+		},
+		{
+			name:     "at the limit",
+			maxBytes: 400,
+			want:     files,
+		},
+		{
+			name:     "least recently used removed first",
+			maxBytes: 250,
+			want:     files[2:],
+		},
+		{
+			name:     "everything removed",
+			maxBytes: 0,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for i, f := range files {
+				path := filepath.Join(dir, f)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("creating directory of %s: %v", f, err)
+				}
+				if err := os.WriteFile(path, make([]byte, 100), 0644); err != nil {
+					t.Fatalf("writing %s: %v", f, err)
+				}
+				used := now.Add(-ages[i])
+				if err := os.Chtimes(path, used, used); err != nil {
+					t.Fatalf("setting the times of %s: %v", f, err)
+				}
+			}
+
+			if err := PruneBySize(gcp.NewContext(), dir, tc.maxBytes); err != nil {
+				t.Fatalf("PruneBySize() got error: %v", err)
+			}
+
+			want := map[string]bool{}
+			for _, f := range tc.want {
+				want[f] = true
+			}
+			for _, f := range files {
+				_, err := os.Stat(filepath.Join(dir, f))
+				if exists := err == nil; exists != want[f] {
+					t.Errorf("PruneBySize(%d) left %s: %t, want %t", tc.maxBytes, f, exists, want[f])
+				}
+			}
+		})
+	}
+}
+
+func TestPruneBySizeMissingDir(t *testing.T) {
+	if err := PruneBySize(gcp.NewContext(), filepath.Join(t.TempDir(), "missing"), 0); err != nil {
+		t.Errorf("PruneBySize() got error: %v", err)
+	}
+}
+
+func TestSize(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{"a": "12345", "sub/b": "123"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("creating directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("writing %s: %v", path, err)
+		}
+	}
+
+	got, err := Size(dir)
+	if err != nil {
+		t.Fatalf("Size() got error: %v", err)
+	}
+	if got != 8 {
+		t.Errorf("Size() = %d, want 8", got)
+	}
+	if got, err := Size(filepath.Join(dir, "missing")); err != nil || got != 0 {
+		t.Errorf("Size() of a missing directory = %d, %v, want 0, nil", got, err)
+	}
+}
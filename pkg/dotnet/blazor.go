@@ -0,0 +1,131 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dotnet
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtime"
+)
+
+const (
+	// BlazorWasmRoot is the directory of the published output of a Blazor WebAssembly app that holds
+	// its static files.
+	BlazorWasmRoot = "wwwroot"
+
+	blazorWasmPackage = "Microsoft.AspNetCore.Components.WebAssembly"
+	blazorWasmSdk     = "Microsoft.NET.Sdk.BlazorWebAssembly"
+
+	nginxLayer = "nginx"
+	// nginxVerConstraint is used to control updating to a new major version with any potential breaking change.
+	nginxVerConstraint = "^1.21.6"
+	nginxConfTemplate  = "nginx.conf.template"
+	nginxPortToken     = "@PORT@"
+)
+
+// IsBlazorWasm returns true if the project file is a Blazor WebAssembly app, which references the
+// Microsoft.AspNetCore.Components.WebAssembly package or uses the Blazor WebAssembly SDK.
+func IsBlazorWasm(projectFile string) (bool, error) {
+	data, err := os.ReadFile(projectFile)
+	if err != nil {
+		return false, gcp.InternalErrorf("reading %s: %v", projectFile, err)
+	}
+	p, err := readProjectFile(data, projectFile)
+	if err != nil {
+		return false, err
+	}
+	return isBlazorWasm(p), nil
+}
+
+func isBlazorWasm(p Project) bool {
+	if strings.EqualFold(strings.TrimSpace(p.Sdk), blazorWasmSdk) {
+		return true
+	}
+	for _, ig := range p.ItemGroups {
+		for _, pr := range ig.PackageReferences {
+			if strings.EqualFold(pr.Include, blazorWasmPackage) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// BlazorWasmEntrypoint installs nginx to a launch layer and returns the entrypoint that serves the
+// static files in root on $PORT. Requests for paths that are not files are served index.html, so
+// that the app handles its own routes.
+func BlazorWasmEntrypoint(ctx *gcp.Context, root string) (string, error) {
+	l, err := ctx.Layer(nginxLayer, gcp.CacheLayer, gcp.LaunchLayer)
+	if err != nil {
+		return "", fmt.Errorf("creating %v layer: %w", nginxLayer, err)
+	}
+	if _, err := runtime.InstallTarballIfNotCached(ctx, runtime.Nginx, nginxVerConstraint, l); err != nil {
+		return "", err
+	}
+	conf := filepath.Join(l.Path, nginxConfTemplate)
+	if err := ctx.WriteFile(conf, []byte(nginxConf(root)), 0644); err != nil {
+		return "", err
+	}
+	return nginxEntrypoint(filepath.Join(l.Path, "sbin", "nginx"), conf), nil
+}
+
+// nginxEntrypoint returns the command that starts nginx in the foreground with the configuration
+// template, in which the port is only known at launch.
+func nginxEntrypoint(nginx, conf string) string {
+	return fmt.Sprintf(`sed "s/%s/${PORT:-8080}/" %s > /tmp/nginx.conf && exec %s -p /tmp -c /tmp/nginx.conf`, nginxPortToken, conf, nginx)
+}
+
+// nginxConf returns the nginx configuration template that serves the files in root, with the port
+// left as a token. The types include application/wasm, which browsers require to stream-compile
+// the .NET runtime.
+func nginxConf(root string) string {
+	return fmt.Sprintf(`daemon off;
+pid /tmp/nginx.pid;
+error_log stderr;
+
+events {}
+
+http {
+  access_log off;
+  default_type application/octet-stream;
+  types {
+    application/json json;
+    application/manifest+json webmanifest;
+    application/wasm wasm;
+    font/woff woff;
+    font/woff2 woff2;
+    image/png png;
+    image/svg+xml svg;
+    image/x-icon ico;
+    text/css css;
+    text/html html;
+    text/javascript js mjs;
+  }
+
+  server {
+    listen %s;
+    root %s;
+
+    location / {
+      try_files $uri $uri/ /index.html;
+    }
+  }
+}
+`, nginxPortToken, root)
+}
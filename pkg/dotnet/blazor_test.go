@@ -0,0 +1,113 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dotnet
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsBlazorWasm(t *testing.T) {
+	testCases := []struct {
+		name string
+		data string
+		want bool
+	}{
+		{
+			name: "webassembly package",
+			data: `<Project Sdk="Microsoft.NET.Sdk.Web">
+  <ItemGroup>
+    <PackageReference Include="Microsoft.AspNetCore.Components.WebAssembly" Version="7.0.0" />
+    <PackageReference Include="Microsoft.AspNetCore.Components.WebAssembly.DevServer" Version="7.0.0" PrivateAssets="all" />
+  </ItemGroup>
+</Project>`,
+			want: true,
+		},
+		{
+			name: "blazor webassembly sdk",
+			data: `<Project Sdk="Microsoft.NET.Sdk.BlazorWebAssembly">
+  <PropertyGroup>
+    <TargetFramework>net7.0</TargetFramework>
+  </PropertyGroup>
+</Project>`,
+			want: true,
+		},
+		{
+			name: "hosted server",
+			data: `<Project Sdk="Microsoft.NET.Sdk.Web">
+  <ItemGroup>
+    <PackageReference Include="Microsoft.AspNetCore.Components.WebAssembly.Server" Version="7.0.0" />
+  </ItemGroup>
+</Project>`,
+		},
+		{
+			name: "web app",
+			data: `<Project Sdk="Microsoft.NET.Sdk.Web">
+  <PropertyGroup>
+    <TargetFramework>net7.0</TargetFramework>
+  </PropertyGroup>
+</Project>`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			proj := filepath.Join(t.TempDir(), "app.csproj")
+			if err := os.WriteFile(proj, []byte(tc.data), 0644); err != nil {
+				t.Fatalf("writing project file: %v", err)
+			}
+			got, err := IsBlazorWasm(proj)
+			if err != nil {
+				t.Fatalf("IsBlazorWasm(%q) got error: %v", proj, err)
+			}
+			if got != tc.want {
+				t.Errorf("IsBlazorWasm(%q) = %t, want %t", proj, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestIsBlazorWasmInvalidProject(t *testing.T) {
+	proj := filepath.Join(t.TempDir(), "app.csproj")
+	if err := os.WriteFile(proj, []byte("<Project>"), 0644); err != nil {
+		t.Fatalf("writing project file: %v", err)
+	}
+	if _, err := IsBlazorWasm(proj); err == nil {
+		t.Errorf("IsBlazorWasm(%q) got no error, want error", proj)
+	}
+}
+
+func TestNginxEntrypoint(t *testing.T) {
+	got := nginxEntrypoint("/layers/nginx/sbin/nginx", "/layers/nginx/nginx.conf.template")
+	want := `sed "s/@PORT@/${PORT:-8080}/" /layers/nginx/nginx.conf.template > /tmp/nginx.conf && exec /layers/nginx/sbin/nginx -p /tmp -c /tmp/nginx.conf`
+	if got != want {
+		t.Errorf("nginxEntrypoint() = %q, want %q", got, want)
+	}
+}
+
+func TestNginxConf(t *testing.T) {
+	conf := nginxConf("/workspace/bin/wwwroot")
+	for _, want := range []string{
+		"listen @PORT@;",
+		"root /workspace/bin/wwwroot;",
+		"try_files $uri $uri/ /index.html;",
+		"application/wasm wasm;",
+	} {
+		if !strings.Contains(conf, want) {
+			t.Errorf("nginxConf() = %q, want it to contain %q", conf, want)
+		}
+	}
+}
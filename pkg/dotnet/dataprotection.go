@@ -0,0 +1,73 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dotnet
+
+import (
+	"path/filepath"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	// DataProtectionKeyDirEnv is an environment variable that sets the directory where ASP.NET Core
+	// data protection keeps its keys at launch. It should be persistent storage, e.g. a mounted
+	// volume, as keys kept in the container are regenerated on every start.
+	DataProtectionKeyDirEnv = "GOOGLE_DOTNET_DATA_PROTECTION_KEY_DIR"
+
+	// dataProtectionKeyDirConfig is the ASP.NET Core configuration key of the keys directory, set
+	// through the environment.
+	dataProtectionKeyDirConfig = "DOTNET_DataProtection__KeyDirectory"
+	// defaultDataProtectionKeyDir is the keys directory if none is configured. It is writable by the
+	// run user, but not persistent.
+	defaultDataProtectionKeyDir = "/tmp/aspnet-dataprotection-keys"
+)
+
+// ephemeralDirs are the directories of the container filesystem, which is lost when the container
+// stops, that an application can write to.
+var ephemeralDirs = []string{"/tmp", "/var/tmp", "/dev/shm", "/workspace", "/layers"}
+
+// ConfigureDataProtection sets the launch default of the ASP.NET Core data protection keys
+// directory on the layer l to dir, the value of GOOGLE_DOTNET_DATA_PROTECTION_KEY_DIR, or to a
+// writable directory of the container if it is empty. A configured directory on the container
+// filesystem is allowed but warned about, as keys that are not persistent invalidate cookies and
+// tokens on every restart.
+func ConfigureDataProtection(ctx *gcp.Context, l *libcnb.Layer, dir string) error {
+	if dir == "" {
+		ctx.Logf("Keeping ASP.NET Core data protection keys in %s, which is not persistent. Set %s to a persistent directory to keep them across restarts.", defaultDataProtectionKeyDir, DataProtectionKeyDirEnv)
+		l.LaunchEnvironment.Default(dataProtectionKeyDirConfig, defaultDataProtectionKeyDir)
+		return nil
+	}
+	if !filepath.IsAbs(dir) {
+		return gcp.UserErrorf("%s must be an absolute path, got %q", DataProtectionKeyDirEnv, dir)
+	}
+	dir = filepath.Clean(dir)
+	if isEphemeralDir(dir) {
+		ctx.Warnf("%s=%s is on the container filesystem, which is not persistent: data protection keys will be regenerated on every restart. Use a mounted volume instead.", DataProtectionKeyDirEnv, dir)
+	}
+	l.LaunchEnvironment.Default(dataProtectionKeyDirConfig, dir)
+	return nil
+}
+
+// isEphemeralDir returns true if the absolute, clean path dir is in one of the ephemeralDirs.
+func isEphemeralDir(dir string) bool {
+	for _, e := range ephemeralDirs {
+		if dir == e || strings.HasPrefix(dir, e+"/") {
+			return true
+		}
+	}
+	return false
+}
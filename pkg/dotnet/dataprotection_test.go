@@ -0,0 +1,93 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dotnet
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestConfigureDataProtection(t *testing.T) {
+	testCases := []struct {
+		name        string
+		dir         string
+		want        string
+		wantWarning bool
+		wantErr     bool
+	}{
+		{
+			name: "default",
+			want: "/tmp/aspnet-dataprotection-keys",
+		},
+		{
+			name: "mounted volume",
+			dir:  "/mnt/keys",
+			want: "/mnt/keys",
+		},
+		{
+			name: "unclean path",
+			dir:  "/mnt/keys/../data/keys/",
+			want: "/mnt/data/keys",
+		},
+		{
+			name:        "tmp",
+			dir:         "/tmp/keys",
+			want:        "/tmp/keys",
+			wantWarning: true,
+		},
+		{
+			name:        "application directory",
+			dir:         "/workspace/keys",
+			want:        "/workspace/keys",
+			wantWarning: true,
+		},
+		{
+			name: "prefix of an ephemeral directory",
+			dir:  "/tmpfs/keys",
+			want: "/tmpfs/keys",
+		},
+		{
+			name:    "relative path",
+			dir:     "keys",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			ctx := gcp.NewContext(gcp.WithLogger(log.New(&buf, "", 0)))
+			l := &libcnb.Layer{LaunchEnvironment: libcnb.Environment{}}
+
+			err := ConfigureDataProtection(ctx, l, tc.dir)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("ConfigureDataProtection(%q) got error: %v, want error: %t", tc.dir, err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if got := l.LaunchEnvironment["DOTNET_DataProtection__KeyDirectory.default"]; got != tc.want {
+				t.Errorf("ConfigureDataProtection(%q) set DOTNET_DataProtection__KeyDirectory=%q, want %q", tc.dir, got, tc.want)
+			}
+			if gotWarning := strings.Contains(buf.String(), "WARNING:"); gotWarning != tc.wantWarning {
+				t.Errorf("ConfigureDataProtection(%q) warned: %t, want %t, logs: %s", tc.dir, gotWarning, tc.wantWarning, buf.String())
+			}
+		})
+	}
+}
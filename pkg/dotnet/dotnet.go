@@ -23,6 +23,8 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/dotnet/release/client"
@@ -40,23 +42,81 @@ var (
 		"netcoreapp3.0": "3.1",
 		"netcoreapp3.1": "3.1",
 	}
+
+	// archToRIDSuffix maps GOARCH values to the architecture component of a RID.
+	archToRIDSuffix = map[string]string{
+		"amd64": "x64",
+		"arm64": "arm64",
+	}
+
+	// muslLoaderGlob matches the dynamic loader of musl-based images, e.g. Alpine.
+	muslLoaderGlob = "/lib/ld-musl-*.so.1"
+
+	// muslStackMarkers are parts of the IDs of stacks that are based on musl, e.g. "alpine.3.16".
+	muslStackMarkers = []string{"alpine", "musl"}
 )
 
-const aspDotnetCore = "Microsoft.AspNetCore.App"
+const (
+	aspDotnetCore = "Microsoft.AspNetCore.App"
+
+	// PublishSelfContainedEnv is an env var used to publish the application self-contained,
+	// bundling the .NET runtime with the published output instead of using the runtime layer.
+	// Example: `true`, `True`, `1` will publish a self-contained application.
+	PublishSelfContainedEnv = "GOOGLE_DOTNET_PUBLISH_SELF_CONTAINED"
+
+	// RuntimeIdentifierEnv is an env var used to override the runtime identifier (RID) the
+	// application is restored and published for.
+	// Example: `linux-arm64` will publish the application for 64-bit ARM.
+	RuntimeIdentifierEnv = "GOOGLE_DOTNET_RUNTIME_IDENTIFIER"
+
+	// RIDEnv is a shorter alias of RuntimeIdentifierEnv that takes precedence over it.
+	// Example: `linux-musl-x64` will publish the application for Alpine Linux.
+	RIDEnv = "GOOGLE_DOTNET_RID"
+
+	// SharedRuntimeEnv is an env var used to install the .NET runtime into a layer that is keyed only
+	// on the runtime version. The layer is cached per application, so it is only reused across
+	// applications whose builds restore the same cache, e.g. the same cache image.
+	// Example: `true`, `True`, `1` will install the runtime into a shared layer.
+	SharedRuntimeEnv = "GOOGLE_DOTNET_SHARED_RUNTIME"
+
+	// BundleICUEnv is an env var used to bundle the ICU libraries with the application, so that it
+	// runs with culture-aware globalization even on stacks without ICU, such as google.min.22.
+	// Example: `true`, `True`, `1` will bundle ICU.
+	BundleICUEnv = "GOOGLE_DOTNET_BUNDLE_ICU"
+
+	// GlobalizationInvariantEnv is an env var used to override whether the .NET globalization-invariant
+	// mode is enabled for the build, which is otherwise enabled if the stack lacks the ICU libraries.
+	// Example: `true` enables the invariant mode, `false` disables it even without ICU.
+	GlobalizationInvariantEnv = "GOOGLE_DOTNET_GLOBALIZATION_INVARIANT"
+
+	// WorkloadsEnv is an env var used to list the SDK workloads to install before the application is
+	// built, separated by commas or spaces. It overrides the workloads inferred from the project file.
+	// Example: `wasm-tools` will install the WebAssembly build tools.
+	WorkloadsEnv = "GOOGLE_DOTNET_WORKLOADS"
+
+	// SingleFileEnv is an env var used to publish the application as a single executable file. Unless
+	// the application is also published self-contained, the file still runs on the runtime layer.
+	// Example: `true`, `True`, `1` will publish a single-file application.
+	SingleFileEnv = "GOOGLE_DOTNET_SINGLE_FILE"
+)
 
-// ProjectFiles finds all project files supported by dotnet.
+// ProjectFiles finds all C# (.csproj), F# (.fsproj) and Visual Basic (.vbproj) project files
+// supported by dotnet, sorted by path.
 func ProjectFiles(ctx *gcp.Context, dir string) []string {
 	result := ctx.Exec([]string{"find", dir, "-regex", `.*\.\(cs\|fs\|vb\)proj`}, gcp.WithUserTimingAttribution).Stdout
 	result = strings.TrimSpace(result)
 	if result == "" {
 		return nil
 	}
-	return strings.Split(result, "\n")
+	files := strings.Split(result, "\n")
+	sort.Strings(files)
+	return files
 }
 
 // Project represents a .NET project file.
 type Project struct {
 	XMLName        xml.Name        `xml:"Project"`
+	Sdk            string          `xml:"Sdk,attr"`
 	PropertyGroups []PropertyGroup `xml:"PropertyGroup"`
 	ItemGroups     []ItemGroup     `xml:"ItemGroup"`
 }
@@ -66,6 +126,11 @@ type PropertyGroup struct {
 	AssemblyName     string `xml:"AssemblyName"`
 	TargetFramework  string `xml:"TargetFramework"`
 	TargetFrameworks string `xml:"TargetFrameworks"`
+	SelfContained    string `xml:"SelfContained"`
+	// RunAOTCompilation and WasmBuildNative compile Blazor WebAssembly apps ahead of time and
+	// relink the runtime, both of which need the wasm-tools workload.
+	RunAOTCompilation string `xml:"RunAOTCompilation"`
+	WasmBuildNative   string `xml:"WasmBuildNative"`
 }
 
 // ItemGroup contains information about a project item group.
@@ -97,6 +162,116 @@ func readProjectFile(data []byte, proj string) (Project, error) {
 	return p, nil
 }
 
+// IsSelfContained returns true if the project should be published self-contained. The value of
+// GOOGLE_DOTNET_PUBLISH_SELF_CONTAINED takes precedence over the project's SelfContained property.
+func IsSelfContained(p Project) (bool, error) {
+	if _, ok := os.LookupEnv(PublishSelfContainedEnv); ok {
+		sc, err := env.IsPresentAndTrue(PublishSelfContainedEnv)
+		if err != nil {
+			return false, gcp.UserErrorf("%v", err)
+		}
+		return sc, nil
+	}
+	for _, pg := range p.PropertyGroups {
+		if strings.EqualFold(strings.TrimSpace(pg.SelfContained), "true") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// IsSingleFile returns true if the application is to be published as a single file.
+func IsSingleFile() (bool, error) {
+	sf, err := env.IsPresentAndTrue(SingleFileEnv)
+	if err != nil {
+		return false, gcp.UserErrorf("%v", err)
+	}
+	return sf, nil
+}
+
+// SingleFileArgs returns the dotnet publish arguments that bundle the application into a single
+// executable file. Self-contained applications also bundle their native libraries, so that the
+// file is the only one needed to run them.
+func SingleFileArgs(selfContained bool) []string {
+	args := []string{"-p:PublishSingleFile=true"}
+	if selfContained {
+		args = append(args, "-p:IncludeNativeLibrariesForSelfExtract=true")
+	}
+	return args
+}
+
+// RuntimeIdentifier returns the runtime identifier (RID) to restore and publish the application
+// for. GOOGLE_DOTNET_RID or GOOGLE_DOTNET_RUNTIME_IDENTIFIER take precedence over the RID derived
+// from the architecture of the build and the C library (glibc or musl) of the stack's base image.
+func RuntimeIdentifier(ctx *gcp.Context) (string, error) {
+	loaders, err := ctx.Glob(muslLoaderGlob)
+	if err != nil {
+		return "", err
+	}
+	musl := len(loaders) > 0 || isMuslStack(ctx.StackID())
+	rid, err := runtimeIdentifier(ctx.StackID(), musl, runtime.GOARCH, ridOverride(os.LookupEnv))
+	if err != nil {
+		return "", err
+	}
+	ctx.Debugf("Using runtime identifier %s", rid)
+	return rid, nil
+}
+
+// ResolveRID is RuntimeIdentifier for the given stack and GOARCH architecture, with the env vars
+// looked up with lookupEnv, e.g. linux-arm64, or linux-musl-x64 on a musl stack such as Alpine.
+func ResolveRID(stackID, arch string, lookupEnv func(string) (string, bool)) (string, error) {
+	return runtimeIdentifier(stackID, isMuslStack(stackID), arch, ridOverride(lookupEnv))
+}
+
+// RequestedRuntimeIdentifier returns the runtime identifier set in GOOGLE_DOTNET_RID or
+// GOOGLE_DOTNET_RUNTIME_IDENTIFIER, if any.
+func RequestedRuntimeIdentifier() string {
+	return ridOverride(os.LookupEnv)
+}
+
+// ridOverride returns the RID set in GOOGLE_DOTNET_RID or GOOGLE_DOTNET_RUNTIME_IDENTIFIER, if any.
+func ridOverride(lookupEnv func(string) (string, bool)) string {
+	for _, name := range []string{RIDEnv, RuntimeIdentifierEnv} {
+		if v, ok := lookupEnv(name); ok && v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// isMuslStack returns true if the stack ID names a musl-based stack.
+func isMuslStack(stackID string) bool {
+	for _, marker := range muslStackMarkers {
+		if strings.Contains(strings.ToLower(stackID), marker) {
+			return true
+		}
+	}
+	return false
+}
+
+func runtimeIdentifier(stack string, musl bool, arch, override string) (string, error) {
+	prefix := "linux-"
+	if musl {
+		prefix = "linux-musl-"
+	}
+	if override != "" {
+		var supported []string
+		for _, suffix := range []string{"x64", "arm64"} {
+			if override == prefix+suffix {
+				return override, nil
+			}
+			supported = append(supported, prefix+suffix)
+		}
+		return "", gcp.UserErrorf("runtime identifier %q set in %s is not supported on stack %q, supported runtime identifiers: %s",
+			override, RuntimeIdentifierEnv, stack, strings.Join(supported, ", "))
+	}
+	suffix, ok := archToRIDSuffix[arch]
+	if !ok {
+		return "", gcp.InternalErrorf("no runtime identifier for architecture %q", arch)
+	}
+	return prefix + suffix, nil
+}
+
 // RuntimeConfigJSONFiles returns all runtimeconfig.json files in 'path' (recursive).
 // The runtimeconfig.json file is present for compiled .NET assemblies.
 func RuntimeConfigJSONFiles(path string) ([]string, error) {
@@ -21,12 +21,73 @@ import (
 	"path"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/testdata"
 )
 
+func TestProjectFiles(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files []string
+		want  []string
+	}{
+		{
+			name:  "csproj",
+			files: []string{"app.csproj", "Program.cs"},
+			want:  []string{"app.csproj"},
+		},
+		{
+			name:  "fsproj",
+			files: []string{"app.fsproj", "Program.fs"},
+			want:  []string{"app.fsproj"},
+		},
+		{
+			name:  "vbproj",
+			files: []string{"app.vbproj", "Program.vb"},
+			want:  []string{"app.vbproj"},
+		},
+		{
+			name:  "mixed project types",
+			files: []string{"web/web.csproj", "lib/lib.fsproj", "legacy/legacy.vbproj"},
+			want:  []string{"legacy/legacy.vbproj", "lib/lib.fsproj", "web/web.csproj"},
+		},
+		{
+			name:  "unsupported project types",
+			files: []string{"app.pyproj", "app.mycsproj", "app.csproj.bak"},
+		},
+		{
+			name:  "no project files",
+			files: []string{"Program.cs"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, f := range tc.files {
+				path := filepath.Join(dir, f)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("creating dir for %s: %v", f, err)
+				}
+				if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+					t.Fatalf("writing %s: %v", f, err)
+				}
+			}
+			var want []string
+			for _, f := range tc.want {
+				want = append(want, filepath.Join(dir, f))
+			}
+
+			got := ProjectFiles(gcp.NewContext(), dir)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ProjectFiles() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
 func TestReadProjectFile(t *testing.T) {
 	d, err := ioutil.TempDir("/tmp", "test-read-project-file")
 	if err != nil {
@@ -54,6 +115,7 @@ func TestReadProjectFile(t *testing.T) {
 
 	want := Project{
 		XMLName: xml.Name{Local: "Project"},
+		Sdk:     "Microsoft.NET.Sdk.Web",
 		PropertyGroups: []PropertyGroup{
 			PropertyGroup{
 				AssemblyName:     "Foo",
@@ -278,3 +340,298 @@ func Test_getSDKChannelForTargetFramework(t *testing.T) {
 		})
 	}
 }
+
+func TestIsSelfContained(t *testing.T) {
+	testCases := []struct {
+		name    string
+		env     string
+		project string
+		want    bool
+		wantErr bool
+	}{
+		{
+			name:    "not self-contained",
+			project: `<Project><PropertyGroup><TargetFramework>net6.0</TargetFramework></PropertyGroup></Project>`,
+		},
+		{
+			name:    "project property",
+			project: `<Project><PropertyGroup><SelfContained>true</SelfContained></PropertyGroup></Project>`,
+			want:    true,
+		},
+		{
+			name:    "project property in second property group",
+			project: `<Project><PropertyGroup></PropertyGroup><PropertyGroup><SelfContained>True</SelfContained></PropertyGroup></Project>`,
+			want:    true,
+		},
+		{
+			name:    "project property false",
+			project: `<Project><PropertyGroup><SelfContained>false</SelfContained></PropertyGroup></Project>`,
+		},
+		{
+			name:    "env var",
+			env:     "true",
+			project: `<Project></Project>`,
+			want:    true,
+		},
+		{
+			name:    "env var overrides project property",
+			env:     "false",
+			project: `<Project><PropertyGroup><SelfContained>true</SelfContained></PropertyGroup></Project>`,
+		},
+		{
+			name:    "invalid env var",
+			env:     "not a bool",
+			project: `<Project></Project>`,
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.env != "" {
+				t.Setenv(PublishSelfContainedEnv, tc.env)
+			}
+			p, err := readProjectFile([]byte(tc.project), "test.csproj")
+			if err != nil {
+				t.Fatalf("readProjectFile got error: %v", err)
+			}
+			got, err := IsSelfContained(p)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("IsSelfContained() got error %v, want error %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("IsSelfContained() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRuntimeIdentifier(t *testing.T) {
+	testCases := []struct {
+		name     string
+		stack    string
+		musl     bool
+		arch     string
+		override string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:  "amd64 glibc",
+			stack: "google",
+			arch:  "amd64",
+			want:  "linux-x64",
+		},
+		{
+			name:  "arm64 glibc",
+			stack: "google.gae.22",
+			arch:  "arm64",
+			want:  "linux-arm64",
+		},
+		{
+			name:  "amd64 google.min.22",
+			stack: "google.min.22",
+			arch:  "amd64",
+			want:  "linux-x64",
+		},
+		{
+			name:  "amd64 musl",
+			stack: "alpine",
+			musl:  true,
+			arch:  "amd64",
+			want:  "linux-musl-x64",
+		},
+		{
+			name:  "arm64 musl",
+			stack: "alpine",
+			musl:  true,
+			arch:  "arm64",
+			want:  "linux-musl-arm64",
+		},
+		{
+			name:    "unsupported arch",
+			stack:   "google",
+			arch:    "386",
+			wantErr: true,
+		},
+		{
+			name:     "override",
+			stack:    "google",
+			arch:     "amd64",
+			override: "linux-arm64",
+			want:     "linux-arm64",
+		},
+		{
+			name:     "musl override on musl stack",
+			stack:    "alpine",
+			musl:     true,
+			arch:     "amd64",
+			override: "linux-musl-arm64",
+			want:     "linux-musl-arm64",
+		},
+		{
+			name:     "musl override on glibc stack",
+			stack:    "google.min.22",
+			arch:     "amd64",
+			override: "linux-musl-x64",
+			wantErr:  true,
+		},
+		{
+			name:     "windows override",
+			stack:    "google",
+			arch:     "amd64",
+			override: "win-x64",
+			wantErr:  true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := runtimeIdentifier(tc.stack, tc.musl, tc.arch, tc.override)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("runtimeIdentifier(%q, %t, %q, %q) got error %v, want error %t", tc.stack, tc.musl, tc.arch, tc.override, err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("runtimeIdentifier(%q, %t, %q, %q) = %q, want %q", tc.stack, tc.musl, tc.arch, tc.override, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestResolveRID(t *testing.T) {
+	testCases := []struct {
+		name    string
+		stack   string
+		arch    string
+		env     map[string]string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "google stack amd64",
+			stack: "google",
+			arch:  "amd64",
+			want:  "linux-x64",
+		},
+		{
+			name:  "google stack arm64",
+			stack: "google.22",
+			arch:  "arm64",
+			want:  "linux-arm64",
+		},
+		{
+			name:  "alpine stack amd64",
+			stack: "alpine.3.16",
+			arch:  "amd64",
+			want:  "linux-musl-x64",
+		},
+		{
+			name:  "musl stack arm64",
+			stack: "io.example.musl",
+			arch:  "arm64",
+			want:  "linux-musl-arm64",
+		},
+		{
+			name:  "GOOGLE_DOTNET_RID",
+			stack: "google",
+			arch:  "amd64",
+			env:   map[string]string{"GOOGLE_DOTNET_RID": "linux-arm64"},
+			want:  "linux-arm64",
+		},
+		{
+			name:  "GOOGLE_DOTNET_RUNTIME_IDENTIFIER",
+			stack: "google",
+			arch:  "amd64",
+			env:   map[string]string{"GOOGLE_DOTNET_RUNTIME_IDENTIFIER": "linux-arm64"},
+			want:  "linux-arm64",
+		},
+		{
+			name:  "GOOGLE_DOTNET_RID takes precedence",
+			stack: "alpine",
+			arch:  "amd64",
+			env:   map[string]string{"GOOGLE_DOTNET_RID": "linux-musl-arm64", "GOOGLE_DOTNET_RUNTIME_IDENTIFIER": "linux-musl-x64"},
+			want:  "linux-musl-arm64",
+		},
+		{
+			name:    "glibc override on musl stack",
+			stack:   "alpine",
+			arch:    "amd64",
+			env:     map[string]string{"GOOGLE_DOTNET_RID": "linux-x64"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			lookupEnv := func(name string) (string, bool) {
+				v, ok := tc.env[name]
+				return v, ok
+			}
+			got, err := ResolveRID(tc.stack, tc.arch, lookupEnv)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("ResolveRID(%q, %q) got error %v, want error %t", tc.stack, tc.arch, err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("ResolveRID(%q, %q) = %q, want %q", tc.stack, tc.arch, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRuntimeIdentifierErrorListsSupported(t *testing.T) {
+	_, err := runtimeIdentifier("google.min.22", false, "amd64", "linux-musl-x64")
+	if err == nil {
+		t.Fatal("runtimeIdentifier() got nil error, want error")
+	}
+	if want := "linux-x64, linux-arm64"; !strings.Contains(err.Error(), want) {
+		t.Errorf("runtimeIdentifier() error = %q, want it to contain %q", err, want)
+	}
+}
+
+func TestSingleFileArgs(t *testing.T) {
+	testCases := []struct {
+		name          string
+		selfContained bool
+		want          []string
+	}{
+		{
+			name: "framework-dependent",
+			want: []string{"-p:PublishSingleFile=true"},
+		},
+		{
+			name:          "self-contained",
+			selfContained: true,
+			want:          []string{"-p:PublishSingleFile=true", "-p:IncludeNativeLibrariesForSelfExtract=true"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := SingleFileArgs(tc.selfContained); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("SingleFileArgs(%t) = %q, want %q", tc.selfContained, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestIsSingleFile(t *testing.T) {
+	testCases := []struct {
+		env     string
+		want    bool
+		wantErr bool
+	}{
+		{env: "1", want: true},
+		{env: "True", want: true},
+		{env: "false", want: false},
+		{env: "maybe", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.env, func(t *testing.T) {
+			t.Setenv(SingleFileEnv, tc.env)
+
+			got, err := IsSingleFile()
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("IsSingleFile() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("IsSingleFile() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
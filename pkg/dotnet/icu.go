@@ -0,0 +1,140 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dotnet
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"strconv"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/fetch"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	icuVersion = "72.1"
+	icuDir     = "icu"
+	icuKey     = "icu_version"
+	// invariantEnv disables culture-aware globalization in the .NET runtime if true.
+	invariantEnv = "DOTNET_SYSTEM_GLOBALIZATION_INVARIANT"
+)
+
+var (
+	// icuURLs are the ICU binary releases for Ubuntu 22.04 by architecture, whose libraries are in
+	// icu/usr/local/lib. ICU is only released for x64.
+	icuURLs = map[string]string{
+		"amd64": "https://github.com/unicode-org/icu/releases/download/release-72-1/icu4c-72_1-Ubuntu22.04-x64.tgz",
+	}
+	// stacksWithoutICU are the stacks whose run image lacks the ICU libraries, even if their build
+	// image has them.
+	stacksWithoutICU = map[string]bool{
+		"google.min.22": true,
+	}
+	// icuLibraryGlobs match the ICU common library in the system library directories.
+	icuLibraryGlobs = []string{
+		"/lib/*/libicuuc.so*",
+		"/usr/lib/*/libicuuc.so*",
+		"/usr/lib/libicuuc.so*",
+		"/usr/lib64/libicuuc.so*",
+	}
+)
+
+// GlobalizationInvariant returns true if the .NET globalization-invariant mode must be enabled,
+// because the ICU libraries that culture-aware globalization requires are missing. The value of
+// GOOGLE_DOTNET_GLOBALIZATION_INVARIANT takes precedence, then the stacks known to lack ICU, and
+// otherwise the system library directories are probed for libicu.
+func GlobalizationInvariant(ctx *gcp.Context) (bool, error) {
+	if v := os.Getenv(GlobalizationInvariantEnv); v != "" {
+		invariant, err := strconv.ParseBool(v)
+		if err != nil {
+			return false, gcp.UserErrorf("invalid value %q for %s, must be true or false: %v", v, GlobalizationInvariantEnv, err)
+		}
+		return invariant, nil
+	}
+	if stacksWithoutICU[ctx.StackID()] {
+		ctx.Debugf("Stack %s lacks the ICU libraries.", ctx.StackID())
+		return true, nil
+	}
+	for _, g := range icuLibraryGlobs {
+		matches, err := filepath.Glob(g)
+		if err != nil {
+			return false, gcp.InternalErrorf("finding ICU libraries with %q: %v", g, err)
+		}
+		if len(matches) > 0 {
+			ctx.Debugf("Found the ICU libraries: %s", matches[0])
+			return false, nil
+		}
+	}
+	ctx.Logf("The ICU libraries were not found, enabling the .NET globalization-invariant mode.")
+	return true, nil
+}
+
+// BundleICU installs the ICU libraries into the icu directory of the given launch layer, unless
+// they are already installed, and configures the .NET runtime to load them with culture-aware
+// globalization at launch.
+func BundleICU(ctx *gcp.Context, l *libcnb.Layer) error {
+	dir := filepath.Join(l.Path, icuDir)
+	libDir := filepath.Join(dir, "lib")
+	installed, err := ctx.FileExists(libDir)
+	if err != nil {
+		return err
+	}
+	if installed && ctx.GetMetadata(l, icuKey) == icuVersion {
+		ctx.CacheHit(icuDir)
+		ctx.Logf("ICU v%s cache hit, skipping installation.", icuVersion)
+	} else {
+		ctx.CacheMiss(icuDir)
+		url, err := icuURL(goruntime.GOARCH)
+		if err != nil {
+			return err
+		}
+		ctx.Logf("Installing ICU v%s.", icuVersion)
+		if err := ctx.RemoveAll(dir); err != nil {
+			return err
+		}
+		if err := ctx.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		tmpDir, err := ctx.TempDir("icu")
+		if err != nil {
+			return err
+		}
+		tarball := filepath.Join(tmpDir, fmt.Sprintf("icu-%s.tgz", icuVersion))
+		if err := ctx.HTTPGet(url, tarball); err != nil {
+			return gcp.InternalErrorf("downloading ICU: %v", err)
+		}
+		// Strip icu/usr/local so that the libraries end up in the lib directory.
+		if err := fetch.UntarFile(tarball, dir, 3); err != nil {
+			return err
+		}
+		ctx.SetMetadata(l, icuKey, icuVersion)
+	}
+	ctx.RecordVersion(gcp.VersionCategoryLibrary, "icu", icuVersion)
+	l.LaunchEnvironment.Prepend("LD_LIBRARY_PATH", string(os.PathListSeparator), libDir)
+	l.LaunchEnvironment.Override(invariantEnv, "false")
+	return nil
+}
+
+// icuURL returns the URL of the ICU binary release for the Go architecture arch.
+func icuURL(arch string) (string, error) {
+	url, ok := icuURLs[arch]
+	if !ok {
+		return "", gcp.UserErrorf("bundling the ICU libraries is not supported on the %s architecture, set %s=true to run without them", arch, GlobalizationInvariantEnv)
+	}
+	return url, nil
+}
@@ -0,0 +1,205 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dotnet
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"strings"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestBundleICU(t *testing.T) {
+	var downloads int
+	tarball := icuTarball(t, "icu/", "icu/usr/", "icu/usr/local/", "icu/usr/local/lib/", "icu/usr/local/lib/libicuuc.so.72", "icu/usr/local/lib/libicui18n.so.72")
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads++
+		w.Write(tarball)
+	}))
+	defer svr.Close()
+	origURLs := icuURLs
+	icuURLs = map[string]string{goruntime.GOARCH: svr.URL}
+	defer func() { icuURLs = origURLs }()
+
+	ctx := gcp.NewContext(
+		gcp.WithBuildpackInfo(libcnb.BuildpackInfo{ID: "google.dotnet.runtime", Version: "0.9.0"}),
+		gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: t.TempDir()}}),
+	)
+	l := &libcnb.Layer{
+		Name:              "runtime",
+		Path:              t.TempDir(),
+		Metadata:          map[string]interface{}{},
+		LaunchEnvironment: libcnb.Environment{},
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := BundleICU(ctx, l); err != nil {
+			t.Fatalf("BundleICU() got error: %v", err)
+		}
+	}
+
+	if downloads != 1 {
+		t.Errorf("BundleICU() downloaded ICU %d times, want 1", downloads)
+	}
+	for _, lib := range []string{"libicuuc.so.72", "libicui18n.so.72"} {
+		if _, err := os.Stat(filepath.Join(l.Path, icuDir, "lib", lib)); err != nil {
+			t.Errorf("ICU library %s not installed: %v", lib, err)
+		}
+	}
+	if got := l.LaunchEnvironment[invariantEnv+".override"]; got != "false" {
+		t.Errorf("launch %s = %q, want %q", invariantEnv, got, "false")
+	}
+	if got, want := l.LaunchEnvironment["LD_LIBRARY_PATH.prepend"], filepath.Join(l.Path, icuDir, "lib"); got != want {
+		t.Errorf("launch LD_LIBRARY_PATH prepends %q, want %q", got, want)
+	}
+	if got := ctx.GetMetadata(l, icuKey); got != icuVersion {
+		t.Errorf("layer metadata %s=%q, want %q", icuKey, got, icuVersion)
+	}
+}
+
+func TestICUURL(t *testing.T) {
+	testCases := []struct {
+		arch    string
+		wantErr bool
+	}{
+		{
+			arch: "amd64",
+		},
+		{
+			arch:    "arm64",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.arch, func(t *testing.T) {
+			got, err := icuURL(tc.arch)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("icuURL(%q) got error: %v, want error: %v", tc.arch, err, tc.wantErr)
+			}
+			if !tc.wantErr && !strings.HasSuffix(got, "-x64.tgz") {
+				t.Errorf("icuURL(%q) = %q, want the x64 release", tc.arch, got)
+			}
+		})
+	}
+}
+
+func icuTarball(t *testing.T, files ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for _, f := range files {
+		if strings.HasSuffix(f, "/") {
+			if err := tw.WriteHeader(&tar.Header{Name: f, Mode: 0755, Typeflag: tar.TypeDir}); err != nil {
+				t.Fatalf("writing tar header for %s: %v", f, err)
+			}
+			continue
+		}
+		content := []byte("ELF")
+		if err := tw.WriteHeader(&tar.Header{Name: f, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatalf("writing tar header for %s: %v", f, err)
+		}
+		if _, err := tw.Write(content); err != nil {
+			t.Fatalf("writing %s: %v", f, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("closing tar writer: %v", err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatalf("closing gzip writer: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestGlobalizationInvariant(t *testing.T) {
+	libDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(libDir, "libicuuc.so.70"), []byte("ELF"), 0644); err != nil {
+		t.Fatalf("writing ICU library: %v", err)
+	}
+	withICU := filepath.Join(libDir, "libicuuc.so*")
+	withoutICU := filepath.Join(t.TempDir(), "libicuuc.so*")
+
+	testCases := []struct {
+		name     string
+		override string
+		stack    string
+		glob     string
+		want     bool
+		wantErr  bool
+	}{
+		{
+			name: "ICU found",
+			glob: withICU,
+			want: false,
+		},
+		{
+			name: "ICU not found",
+			glob: withoutICU,
+			want: true,
+		},
+		{
+			name:  "stack without ICU",
+			stack: "google.min.22",
+			glob:  withICU,
+			want:  true,
+		},
+		{
+			name:     "override enables invariant mode",
+			override: "true",
+			glob:     withICU,
+			want:     true,
+		},
+		{
+			name:     "override disables invariant mode on stack without ICU",
+			override: "false",
+			stack:    "google.min.22",
+			glob:     withoutICU,
+			want:     false,
+		},
+		{
+			name:     "invalid override",
+			override: "sometimes",
+			glob:     withICU,
+			wantErr:  true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(GlobalizationInvariantEnv, tc.override)
+			origGlobs := icuLibraryGlobs
+			icuLibraryGlobs = []string{tc.glob}
+			defer func() { icuLibraryGlobs = origGlobs }()
+			ctx := gcp.NewContext(gcp.WithBuildContext(libcnb.BuildContext{StackID: tc.stack}))
+
+			got, err := GlobalizationInvariant(ctx)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("GlobalizationInvariant() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("GlobalizationInvariant() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
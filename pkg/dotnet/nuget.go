@@ -0,0 +1,92 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dotnet
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// nugetEnvRefRegexp matches the %NAME% references to environment variables that NuGet expands in
+// the values of a NuGet.config file, e.g. in the ClearTextPassword of a package source.
+var nugetEnvRefRegexp = regexp.MustCompile(`%([A-Za-z_][A-Za-z0-9_]*)%`)
+
+// FindNuGetConfig returns the path of the NuGet.config file, in any casing, that is closest to the
+// directory of the project file proj, walking up to the application root appRoot. It returns ""
+// if there is no such file.
+func FindNuGetConfig(appRoot, proj string) (string, error) {
+	root, err := filepath.Abs(appRoot)
+	if err != nil {
+		return "", err
+	}
+	dir := proj
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(root, dir)
+	}
+	if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+		dir = filepath.Dir(dir)
+	}
+	for {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return "", fmt.Errorf("reading %s: %w", dir, err)
+		}
+		for _, e := range entries {
+			if !e.IsDir() && strings.EqualFold(e.Name(), "nuget.config") {
+				return filepath.Join(dir, e.Name()), nil
+			}
+		}
+		if dir == root || dir == filepath.Dir(dir) {
+			return "", nil
+		}
+		rel, err := filepath.Rel(root, dir)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return "", nil
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// ExpandNuGetConfig replaces the %NAME% references to environment variables in the content of a
+// NuGet.config file with their values from lookupEnv. References to unset variables are kept and
+// their names are returned, sorted, so that a failed restore can point to them.
+func ExpandNuGetConfig(data []byte, lookupEnv func(string) (string, bool)) ([]byte, []string) {
+	missing := map[string]bool{}
+	expanded := nugetEnvRefRegexp.ReplaceAllFunc(data, func(ref []byte) []byte {
+		name := string(ref[1 : len(ref)-1])
+		if v, ok := lookupEnv(name); ok {
+			return []byte(v)
+		}
+		missing[name] = true
+		return ref
+	})
+	var names []string
+	for name := range missing {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return expanded, names
+}
+
+// NuGetConfigEnvRefs returns the names of the environment variables referenced in the content of a
+// NuGet.config file, sorted.
+func NuGetConfigEnvRefs(data []byte) []string {
+	_, names := ExpandNuGetConfig(data, func(string) (string, bool) { return "", false })
+	return names
+}
@@ -0,0 +1,125 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dotnet
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFindNuGetConfig(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files []string
+		proj  string
+		want  string
+	}{
+		{
+			name:  "no config",
+			files: []string{"app.csproj"},
+			proj:  "app.csproj",
+		},
+		{
+			name:  "next to project",
+			files: []string{"app.csproj", "NuGet.Config"},
+			proj:  "app.csproj",
+			want:  "NuGet.Config",
+		},
+		{
+			name:  "lower case",
+			files: []string{"app.csproj", "nuget.config"},
+			proj:  "app.csproj",
+			want:  "nuget.config",
+		},
+		{
+			name:  "mixed case",
+			files: []string{"app.csproj", "NuGet.config"},
+			proj:  "app.csproj",
+			want:  "NuGet.config",
+		},
+		{
+			name:  "upward walk to root",
+			files: []string{"src/web/web.csproj", "NuGet.Config"},
+			proj:  "src/web/web.csproj",
+			want:  "NuGet.Config",
+		},
+		{
+			name:  "closest config wins",
+			files: []string{"src/web/web.csproj", "src/nuget.config", "NuGet.Config"},
+			proj:  "src/web/web.csproj",
+			want:  "src/nuget.config",
+		},
+		{
+			name:  "project directory",
+			files: []string{"src/web/web.csproj", "src/web/nuget.config"},
+			proj:  "src/web",
+			want:  "src/web/nuget.config",
+		},
+		{
+			name:  "directory named like config",
+			files: []string{"app.csproj", "nuget.config/readme"},
+			proj:  "app.csproj",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, f := range tc.files {
+				path := filepath.Join(dir, f)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("creating dir for %s: %v", f, err)
+				}
+				if err := os.WriteFile(path, nil, 0644); err != nil {
+					t.Fatalf("writing %s: %v", f, err)
+				}
+			}
+			want := ""
+			if tc.want != "" {
+				want = filepath.Join(dir, tc.want)
+			}
+
+			got, err := FindNuGetConfig(dir, tc.proj)
+			if err != nil {
+				t.Fatalf("FindNuGetConfig(%q, %q) got error: %v", dir, tc.proj, err)
+			}
+			if got != want {
+				t.Errorf("FindNuGetConfig(%q, %q) = %q, want %q", dir, tc.proj, got, want)
+			}
+		})
+	}
+}
+
+func TestExpandNuGetConfig(t *testing.T) {
+	config := `<add key="Username" value="%NUGET_USER%" /><add key="ClearTextPassword" value="%NUGET_TOKEN%" /><add key="Other" value="100%" />`
+	env := map[string]string{"NUGET_USER": "builder"}
+	lookupEnv := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	got, missing := ExpandNuGetConfig([]byte(config), lookupEnv)
+	want := `<add key="Username" value="builder" /><add key="ClearTextPassword" value="%NUGET_TOKEN%" /><add key="Other" value="100%" />`
+	if string(got) != want {
+		t.Errorf("ExpandNuGetConfig() = %q, want %q", got, want)
+	}
+	if wantMissing := []string{"NUGET_TOKEN"}; !reflect.DeepEqual(missing, wantMissing) {
+		t.Errorf("ExpandNuGetConfig() missing = %v, want %v", missing, wantMissing)
+	}
+	if refs, wantRefs := NuGetConfigEnvRefs([]byte(config)), []string{"NUGET_TOKEN", "NUGET_USER"}; !reflect.DeepEqual(refs, wantRefs) {
+		t.Errorf("NuGetConfigEnvRefs() = %v, want %v", refs, wantRefs)
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dotnet
+
+import (
+	"sort"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/Masterminds/semver"
+)
+
+const (
+	// RuntimeRollForwardEnv is an env var used to install the newest runtime compatible with the
+	// version in runtimeconfig.json under a .NET roll-forward policy, instead of that exact version.
+	// Example: `LatestPatch` will install the latest patch of the requested major and minor version.
+	RuntimeRollForwardEnv = "GOOGLE_DOTNET_RUNTIME_ROLLFORWARD"
+
+	// RollForwardDisable only allows the requested version.
+	RollForwardDisable = "Disable"
+	// RollForwardLatestPatch allows the latest patch of the requested major and minor version.
+	RollForwardLatestPatch = "LatestPatch"
+	// RollForwardMinor allows the latest patch of the requested minor version, or of the lowest
+	// higher minor version if the requested minor version is not available.
+	RollForwardMinor = "Minor"
+	// RollForwardLatestMinor allows the latest version of the requested major version.
+	RollForwardLatestMinor = "LatestMinor"
+	// RollForwardMajor allows the same versions as RollForwardMinor, or the latest patch of the
+	// lowest minor version of the lowest higher major version if the requested major version is not available.
+	RollForwardMajor = "Major"
+	// RollForwardLatestMajor allows the latest version.
+	RollForwardLatestMajor = "LatestMajor"
+)
+
+var rollForwardPolicies = []string{
+	RollForwardDisable,
+	RollForwardLatestPatch,
+	RollForwardMinor,
+	RollForwardLatestMinor,
+	RollForwardMajor,
+	RollForwardLatestMajor,
+}
+
+// RuntimeRollForward returns the runtime version to install for the requested version among the
+// available versions, following the .NET roll-forward policy, which is case-insensitive.
+// Only versions at least as high as the requested version are considered, and pre-release
+// versions are only considered if the requested version is a pre-release.
+func RuntimeRollForward(requested string, available []string, policy string) (string, error) {
+	canonical := ""
+	for _, p := range rollForwardPolicies {
+		if strings.EqualFold(p, policy) {
+			canonical = p
+		}
+	}
+	if canonical == "" {
+		return "", gcp.UserErrorf("invalid .NET roll-forward policy %q, must be one of %s", policy, strings.Join(rollForwardPolicies, ", "))
+	}
+	req, err := semver.NewVersion(requested)
+	if err != nil {
+		return "", gcp.UserErrorf("invalid .NET runtime version %q: %v", requested, err)
+	}
+
+	var candidates []*semver.Version
+	for _, a := range available {
+		v, err := semver.NewVersion(a)
+		if err != nil || v.LessThan(req) || (v.Prerelease() != "" && req.Prerelease() == "") {
+			continue
+		}
+		candidates = append(candidates, v)
+	}
+	sort.Sort(semver.Collection(candidates))
+
+	var v *semver.Version
+	switch canonical {
+	case RollForwardDisable:
+		v = lowest(candidates, func(c *semver.Version) bool { return c.Equal(req) })
+	case RollForwardLatestPatch:
+		v = highest(candidates, func(c *semver.Version) bool { return c.Major() == req.Major() && c.Minor() == req.Minor() })
+	case RollForwardMinor:
+		v = latestPatchOfLowestMinor(candidates, func(c *semver.Version) bool { return c.Major() == req.Major() })
+	case RollForwardLatestMinor:
+		v = highest(candidates, func(c *semver.Version) bool { return c.Major() == req.Major() })
+	case RollForwardMajor:
+		v = latestPatchOfLowestMinor(candidates, func(c *semver.Version) bool { return c.Major() == req.Major() })
+		if v == nil {
+			v = latestPatchOfLowestMinor(candidates, func(c *semver.Version) bool { return true })
+		}
+	case RollForwardLatestMajor:
+		v = highest(candidates, func(c *semver.Version) bool { return true })
+	}
+	if v == nil {
+		return "", gcp.UserErrorf("no .NET runtime version compatible with %s under roll-forward policy %s, available versions: %s", requested, canonical, strings.Join(available, ", "))
+	}
+	return v.Original(), nil
+}
+
+// lowest returns the lowest of the sorted versions that match, or nil.
+func lowest(sorted []*semver.Version, match func(*semver.Version) bool) *semver.Version {
+	for _, v := range sorted {
+		if match(v) {
+			return v
+		}
+	}
+	return nil
+}
+
+// highest returns the highest of the sorted versions that match, or nil.
+func highest(sorted []*semver.Version, match func(*semver.Version) bool) *semver.Version {
+	for i := len(sorted) - 1; i >= 0; i-- {
+		if match(sorted[i]) {
+			return sorted[i]
+		}
+	}
+	return nil
+}
+
+// latestPatchOfLowestMinor returns the latest patch of the lowest major and minor version among the
+// sorted versions that match, or nil.
+func latestPatchOfLowestMinor(sorted []*semver.Version, match func(*semver.Version) bool) *semver.Version {
+	low := lowest(sorted, match)
+	if low == nil {
+		return nil
+	}
+	return highest(sorted, func(v *semver.Version) bool {
+		return match(v) && v.Major() == low.Major() && v.Minor() == low.Minor()
+	})
+}
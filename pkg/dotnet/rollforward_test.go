@@ -0,0 +1,65 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dotnet
+
+import "testing"
+
+func TestRuntimeRollForward(t *testing.T) {
+	available := []string{"3.1.32", "6.0.0", "6.0.9", "6.0.10", "6.1.2", "6.1.5", "6.2.0", "7.0.0-rc.1", "7.0.3", "7.1.0", "8.0.1"}
+	testCases := []struct {
+		name      string
+		requested string
+		available []string
+		policy    string
+		want      string
+		wantErr   bool
+	}{
+		{name: "disable exact", requested: "6.0.9", policy: "Disable", want: "6.0.9"},
+		{name: "disable missing", requested: "6.0.1", policy: "Disable", wantErr: true},
+		{name: "latest patch", requested: "6.0.0", policy: "LatestPatch", want: "6.0.10"},
+		{name: "latest patch ignores lower patches", requested: "6.1.3", policy: "LatestPatch", want: "6.1.5"},
+		{name: "latest patch missing minor", requested: "6.3.0", policy: "LatestPatch", wantErr: true},
+		{name: "minor available", requested: "6.0.0", policy: "Minor", want: "6.0.10"},
+		{name: "minor rolls to lowest higher minor", requested: "6.0.11", policy: "Minor", want: "6.1.5"},
+		{name: "minor missing major", requested: "5.0.0", policy: "Minor", wantErr: true},
+		{name: "latest minor", requested: "6.0.0", policy: "LatestMinor", want: "6.2.0"},
+		{name: "major available", requested: "6.0.0", policy: "Major", want: "6.0.10"},
+		{name: "major rolls to lowest higher major", requested: "5.0.0", policy: "Major", want: "6.0.10"},
+		{name: "major skips prerelease", requested: "6.2.1", policy: "Major", want: "7.0.3"},
+		{name: "latest major", requested: "3.1.0", policy: "LatestMajor", want: "8.0.1"},
+		{name: "prerelease requested", requested: "7.0.0-rc.1", policy: "LatestPatch", want: "7.0.3"},
+		{name: "case-insensitive policy", requested: "6.0.0", policy: "latestpatch", want: "6.0.10"},
+		{name: "nothing higher", requested: "9.0.0", policy: "LatestMajor", wantErr: true},
+		{name: "invalid policy", requested: "6.0.0", policy: "Newest", wantErr: true},
+		{name: "invalid requested version", requested: "six", policy: "Minor", wantErr: true},
+		{name: "skips invalid available versions", requested: "6.0.0", available: []string{"6.0.1", "latest"}, policy: "LatestPatch", want: "6.0.1"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			versions := available
+			if tc.available != nil {
+				versions = tc.available
+			}
+
+			got, err := RuntimeRollForward(tc.requested, versions, tc.policy)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("RuntimeRollForward(%q, %v, %q) got error: %v, want error: %t", tc.requested, versions, tc.policy, err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("RuntimeRollForward(%q, %v, %q) = %q, want %q", tc.requested, versions, tc.policy, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dotnet
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	toolsLayer    = "tools"
+	toolsHashKey  = "manifest_hash"
+	toolShimShell = "#!/bin/sh\nNUGET_PACKAGES='%s' exec dotnet tool run %s -- \"$@\"\n"
+)
+
+// toolManifestPaths are the locations of the local tool manifest relative to the application
+// root, in the order the dotnet CLI looks them up.
+var toolManifestPaths = []string{filepath.Join(".config", "dotnet-tools.json"), "dotnet-tools.json"}
+
+// Tool is a local .NET tool listed in a dotnet-tools.json manifest.
+type Tool struct {
+	Name     string
+	Version  string   `json:"version"`
+	Commands []string `json:"commands"`
+}
+
+// FindToolManifest returns the path of the local tool manifest of the application, or "" if there
+// is none.
+func FindToolManifest(appRoot string) (string, error) {
+	for _, p := range toolManifestPaths {
+		path := filepath.Join(appRoot, p)
+		fi, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", gcp.InternalErrorf("stating %s: %v", path, err)
+		}
+		if fi.Mode().IsRegular() {
+			return path, nil
+		}
+	}
+	return "", nil
+}
+
+// ReadToolManifest returns the tools listed in the local tool manifest at path, sorted by name.
+func ReadToolManifest(path string) ([]Tool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, gcp.InternalErrorf("reading %s: %v", path, err)
+	}
+	var manifest struct {
+		Tools map[string]Tool `json:"tools"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, gcp.UserErrorf("parsing %s: %v", path, err)
+	}
+	var tools []Tool
+	for name, t := range manifest.Tools {
+		t.Name = name
+		tools = append(tools, t)
+	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Name < tools[j].Name })
+	return tools, nil
+}
+
+// RestoreTools runs `dotnet tool restore` for the local tool manifest of the application, if any,
+// with the tool packages in a cached layer that is reused while the manifest is unchanged. The
+// commands of the tools are put on PATH for the subsequent build steps, as shims that find the
+// tool packages in the layer, so that NUGET_PACKAGES is left unchanged for the other steps.
+func RestoreTools(ctx *gcp.Context) error {
+	manifest, err := FindToolManifest(ctx.ApplicationRoot())
+	if err != nil || manifest == "" {
+		return err
+	}
+	tools, err := ReadToolManifest(manifest)
+	if err != nil || len(tools) == 0 {
+		return err
+	}
+	l, err := ctx.Layer(toolsLayer, gcp.BuildLayer, gcp.CacheLayer)
+	if err != nil {
+		return fmt.Errorf("creating layer: %w", err)
+	}
+	return restoreTools(ctx, l, manifest, tools)
+}
+
+func restoreTools(ctx *gcp.Context, l *libcnb.Layer, manifest string, tools []Tool) error {
+	packages := filepath.Join(l.Path, "packages")
+	bin := filepath.Join(l.Path, "bin")
+	l.BuildEnvironment.Prepend("PATH", string(os.PathListSeparator), bin)
+	if err := ctx.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH")); err != nil {
+		return err
+	}
+
+	hash, err := cache.Hash(ctx, cache.WithFiles(manifest))
+	if err != nil {
+		return fmt.Errorf("computing tool manifest hash: %w", err)
+	}
+	if ctx.GetMetadata(l, toolsHashKey) == hash {
+		ctx.CacheHit(toolsLayer)
+		ctx.Logf("Local tools cache hit, skipping restore.")
+		return nil
+	}
+	ctx.CacheMiss(toolsLayer)
+	if err := ctx.ClearLayer(l); err != nil {
+		return fmt.Errorf("clearing layer %q: %w", l.Name, err)
+	}
+
+	ctx.Logf("Restoring local tools from %s.", manifest)
+	cmd := []string{"dotnet", "tool", "restore", "--tool-manifest", manifest}
+	if _, err := ctx.ExecWithErr(cmd, gcp.WithEnv("DOTNET_CLI_TELEMETRY_OPTOUT=true", "NUGET_PACKAGES="+packages), gcp.WithUserAttribution); err != nil {
+		return err
+	}
+
+	if err := ctx.MkdirAll(bin, 0755); err != nil {
+		return err
+	}
+	for _, t := range tools {
+		for _, c := range t.Commands {
+			if err := ctx.WriteFile(filepath.Join(bin, c), []byte(fmt.Sprintf(toolShimShell, packages, c)), 0755); err != nil {
+				return err
+			}
+		}
+	}
+	ctx.SetMetadata(l, toolsHashKey, hash)
+	return nil
+}
@@ -0,0 +1,180 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dotnet
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+const toolManifest = `{
+  "version": 1,
+  "isRoot": true,
+  "tools": {
+    "dotnet-ef": {"version": "7.0.5", "commands": ["dotnet-ef"]},
+    "csharpier": {"version": "0.24.2", "commands": ["dotnet-csharpier"]}
+  }
+}`
+
+func TestFindToolManifest(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files []string
+		want  string
+	}{
+		{
+			name:  "no manifest",
+			files: []string{"app.csproj"},
+		},
+		{
+			name:  "config directory",
+			files: []string{"app.csproj", ".config/dotnet-tools.json"},
+			want:  ".config/dotnet-tools.json",
+		},
+		{
+			name:  "application root",
+			files: []string{"app.csproj", "dotnet-tools.json"},
+			want:  "dotnet-tools.json",
+		},
+		{
+			name:  "config directory takes precedence",
+			files: []string{".config/dotnet-tools.json", "dotnet-tools.json"},
+			want:  ".config/dotnet-tools.json",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, f := range tc.files {
+				writeAppFile(t, dir, f, "")
+			}
+			want := ""
+			if tc.want != "" {
+				want = filepath.Join(dir, tc.want)
+			}
+
+			got, err := FindToolManifest(dir)
+			if err != nil {
+				t.Fatalf("FindToolManifest(%q) got error: %v", dir, err)
+			}
+			if got != want {
+				t.Errorf("FindToolManifest(%q) = %q, want %q", dir, got, want)
+			}
+		})
+	}
+}
+
+func TestReadToolManifest(t *testing.T) {
+	dir := t.TempDir()
+	path := writeAppFile(t, dir, ".config/dotnet-tools.json", toolManifest)
+
+	got, err := ReadToolManifest(path)
+	if err != nil {
+		t.Fatalf("ReadToolManifest(%q) got error: %v", path, err)
+	}
+	want := []Tool{
+		{Name: "csharpier", Version: "0.24.2", Commands: []string{"dotnet-csharpier"}},
+		{Name: "dotnet-ef", Version: "7.0.5", Commands: []string{"dotnet-ef"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadToolManifest(%q) = %v, want %v", path, got, want)
+	}
+
+	invalid := writeAppFile(t, dir, "dotnet-tools.json", "not json")
+	if _, err := ReadToolManifest(invalid); err == nil {
+		t.Errorf("ReadToolManifest(%q) got no error, want error", invalid)
+	}
+}
+
+func TestRestoreToolsCache(t *testing.T) {
+	dir := t.TempDir()
+	manifest := writeAppFile(t, dir, ".config/dotnet-tools.json", toolManifest)
+	tools, err := ReadToolManifest(manifest)
+	if err != nil {
+		t.Fatalf("ReadToolManifest(%q) got error: %v", manifest, err)
+	}
+	var restores int
+	execCmd := func(name string, args ...string) *exec.Cmd {
+		if name == "dotnet" && strings.Join(args, " ") == "tool restore --tool-manifest "+manifest {
+			restores++
+		}
+		return exec.Command("true")
+	}
+	t.Setenv("PATH", os.Getenv("PATH"))
+	t.Setenv("NUGET_PACKAGES", "")
+	ctx := gcp.NewContext(gcp.WithApplicationRoot(dir), gcp.WithExecCmd(execCmd))
+	// The same layer is reused, as when the lifecycle restores it from the cache.
+	l := &libcnb.Layer{
+		Name:             toolsLayer,
+		Path:             t.TempDir(),
+		Metadata:         map[string]interface{}{},
+		BuildEnvironment: libcnb.Environment{},
+	}
+
+	for i, tc := range []struct {
+		manifest     string
+		wantRestores int
+	}{
+		{manifest: toolManifest, wantRestores: 1},
+		{manifest: toolManifest, wantRestores: 1},
+		{manifest: strings.Replace(toolManifest, "7.0.5", "7.0.10", 1), wantRestores: 2},
+	} {
+		writeAppFile(t, dir, ".config/dotnet-tools.json", tc.manifest)
+		if err := restoreTools(ctx, l, manifest, tools); err != nil {
+			t.Fatalf("restoreTools() #%d got error: %v", i, err)
+		}
+		if restores != tc.wantRestores {
+			t.Errorf("restoreTools() #%d restored %d times in total, want %d", i, restores, tc.wantRestores)
+		}
+	}
+
+	for _, c := range []string{"dotnet-ef", "dotnet-csharpier"} {
+		if _, err := os.Stat(filepath.Join(l.Path, "bin", c)); err != nil {
+			t.Errorf("missing shim for %s: %v", c, err)
+		}
+	}
+	if want := filepath.Join(l.Path, "bin"); !strings.HasPrefix(os.Getenv("PATH"), want+string(os.PathListSeparator)) {
+		t.Errorf("PATH=%q, want it to start with %q", os.Getenv("PATH"), want)
+	}
+	if got, ok := l.BuildEnvironment["NUGET_PACKAGES.override"]; ok || os.Getenv("NUGET_PACKAGES") != "" {
+		t.Errorf("NUGET_PACKAGES=%q, want it unset for the other build steps", got+os.Getenv("NUGET_PACKAGES"))
+	}
+	shim, err := os.ReadFile(filepath.Join(l.Path, "bin", "dotnet-ef"))
+	if err != nil {
+		t.Fatalf("reading shim: %v", err)
+	}
+	if want := "NUGET_PACKAGES='" + filepath.Join(l.Path, "packages") + "' exec dotnet tool run dotnet-ef"; !strings.Contains(string(shim), want) {
+		t.Errorf("shim = %q, want it to contain %q", shim, want)
+	}
+}
+
+func writeAppFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("creating dir for %s: %v", name, err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("writing %s: %v", name, err)
+	}
+	return path
+}
@@ -0,0 +1,59 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dotnet
+
+import (
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// VerbosityEnv is an env var used to set the verbosity of dotnet restore and dotnet publish.
+	// Example: `d` or `detailed` prints detailed build logs.
+	VerbosityEnv = "GOOGLE_DOTNET_VERBOSITY"
+
+	defaultVerbosity = "minimal"
+)
+
+// verbosityLevels maps the verbosity levels of the dotnet CLI, and their abbreviations, to the
+// level names.
+var verbosityLevels = map[string]string{
+	"q":          "quiet",
+	"quiet":      "quiet",
+	"m":          "minimal",
+	"minimal":    "minimal",
+	"n":          "normal",
+	"normal":     "normal",
+	"d":          "detailed",
+	"detailed":   "detailed",
+	"diag":       "diagnostic",
+	"diagnostic": "diagnostic",
+}
+
+// VerbosityFlag returns the --verbosity flag of dotnet restore and dotnet publish for the given
+// value of GOOGLE_DOTNET_VERBOSITY, which is case-insensitive. The verbosity is minimal if the
+// value is empty.
+func VerbosityFlag(verbosity string) ([]string, error) {
+	v := strings.ToLower(strings.TrimSpace(verbosity))
+	if v == "" {
+		return []string{"--verbosity", defaultVerbosity}, nil
+	}
+	level, ok := verbosityLevels[v]
+	if !ok {
+		return nil, gcp.UserErrorf("invalid %s %q, must be one of q[uiet], m[inimal], n[ormal], d[etailed] or diag[nostic]", VerbosityEnv, verbosity)
+	}
+	return []string{"--verbosity", level}, nil
+}
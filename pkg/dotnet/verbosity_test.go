@@ -0,0 +1,61 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dotnet
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestVerbosityFlag(t *testing.T) {
+	testCases := []struct {
+		verbosity string
+		want      []string
+		wantErr   bool
+	}{
+		{verbosity: "", want: []string{"--verbosity", "minimal"}},
+		{verbosity: "q", want: []string{"--verbosity", "quiet"}},
+		{verbosity: "quiet", want: []string{"--verbosity", "quiet"}},
+		{verbosity: "m", want: []string{"--verbosity", "minimal"}},
+		{verbosity: "minimal", want: []string{"--verbosity", "minimal"}},
+		{verbosity: "n", want: []string{"--verbosity", "normal"}},
+		{verbosity: "normal", want: []string{"--verbosity", "normal"}},
+		{verbosity: "d", want: []string{"--verbosity", "detailed"}},
+		{verbosity: "detailed", want: []string{"--verbosity", "detailed"}},
+		{verbosity: "diag", want: []string{"--verbosity", "diagnostic"}},
+		{verbosity: "diagnostic", want: []string{"--verbosity", "diagnostic"}},
+		{verbosity: " Detailed ", want: []string{"--verbosity", "detailed"}},
+		{verbosity: "verbose", wantErr: true},
+		{verbosity: "x", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.verbosity, func(t *testing.T) {
+			got, err := VerbosityFlag(tc.verbosity)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("VerbosityFlag(%q) = %q, want error", tc.verbosity, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerbosityFlag(%q) got error: %v", tc.verbosity, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("VerbosityFlag(%q) mismatch (-want +got):\n%s", tc.verbosity, diff)
+			}
+		})
+	}
+}
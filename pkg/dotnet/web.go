@@ -0,0 +1,68 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dotnet
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// WebEnv is an environment variable that forces the classification of the application as an
+	// ASP.NET Core web app, `true`, or a console app, `false`, instead of detecting it from the SDK
+	// of the project.
+	WebEnv = "GOOGLE_DOTNET_WEB"
+
+	// webSdk is the MSBuild SDK of ASP.NET Core web apps.
+	webSdk = "Microsoft.NET.Sdk.Web"
+	// aspNetCoreURLsEnv is the environment variable of the URLs that ASP.NET Core listens on.
+	aspNetCoreURLsEnv = "ASPNETCORE_URLS"
+)
+
+// IsWebProject returns true if the project uses the ASP.NET Core web SDK, e.g.
+// <Project Sdk="Microsoft.NET.Sdk.Web">, optionally with a version, e.g. "Microsoft.NET.Sdk.Web/6.0.0".
+func IsWebProject(p Project) bool {
+	sdk := strings.TrimSpace(p.Sdk)
+	if i := strings.Index(sdk, "/"); i >= 0 {
+		sdk = sdk[:i]
+	}
+	return strings.EqualFold(sdk, webSdk)
+}
+
+// IsWebApp returns true if the application is an ASP.NET Core web app rather than a console app.
+// GOOGLE_DOTNET_WEB forces the classification if it is set, otherwise it is detected from the SDK
+// of the project.
+func IsWebApp(ctx *gcp.Context, p Project) (bool, error) {
+	if v := os.Getenv(WebEnv); v != "" {
+		web, err := strconv.ParseBool(v)
+		if err != nil {
+			return false, gcp.UserErrorf("parsing %s=%q: %v", WebEnv, v, err)
+		}
+		ctx.Logf("Using %s=%t to classify the application as a web app.", WebEnv, web)
+		return web, nil
+	}
+	return IsWebProject(p), nil
+}
+
+// WebEntrypoint returns the entrypoint of an ASP.NET Core web app, which listens on $PORT unless
+// ASPNETCORE_URLS is set at launch. $PORT is only known at launch, so ASPNETCORE_URLS is set by the
+// shell that runs the entrypoint.
+func WebEntrypoint(entrypoint string) string {
+	return fmt.Sprintf(`export %[1]s="${%[1]s:-http://*:${PORT:-8080}}" && %s`, aspNetCoreURLsEnv, entrypoint)
+}
@@ -0,0 +1,150 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dotnet
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+func TestIsWebApp(t *testing.T) {
+	testCases := []struct {
+		name    string
+		data    string
+		web     string
+		want    bool
+		wantErr bool
+	}{
+		{
+			name: "web sdk",
+			data: `<Project Sdk="Microsoft.NET.Sdk.Web">
+  <PropertyGroup>
+    <TargetFramework>net6.0</TargetFramework>
+  </PropertyGroup>
+</Project>`,
+			want: true,
+		},
+		{
+			name: "web sdk with version",
+			data: `<Project Sdk="Microsoft.NET.Sdk.Web/6.0.100">
+</Project>`,
+			want: true,
+		},
+		{
+			name: "web sdk with different case",
+			data: `<Project Sdk=" microsoft.net.sdk.web ">
+</Project>`,
+			want: true,
+		},
+		{
+			name: "console app",
+			data: `<Project Sdk="Microsoft.NET.Sdk">
+  <PropertyGroup>
+    <OutputType>Exe</OutputType>
+    <TargetFramework>net6.0</TargetFramework>
+  </PropertyGroup>
+</Project>`,
+		},
+		{
+			name: "worker service",
+			data: `<Project Sdk="Microsoft.NET.Sdk.Worker">
+</Project>`,
+		},
+		{
+			name: "no sdk",
+			data: `<Project>
+</Project>`,
+		},
+		{
+			name: "console app forced to web",
+			data: `<Project Sdk="Microsoft.NET.Sdk">
+</Project>`,
+			web:  "TRUE",
+			want: true,
+		},
+		{
+			name: "web app forced to console",
+			data: `<Project Sdk="Microsoft.NET.Sdk.Web">
+</Project>`,
+			web: "FALSE",
+		},
+		{
+			name: "invalid override",
+			data: `<Project Sdk="Microsoft.NET.Sdk.Web">
+</Project>`,
+			web:     "maybe",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(WebEnv, tc.web)
+			p, err := readProjectFile([]byte(tc.data), "app.csproj")
+			if err != nil {
+				t.Fatalf("readProjectFile() got error: %v", err)
+			}
+
+			got, err := IsWebApp(gcp.NewContext(), p)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("IsWebApp(ctx, %+v) got error: %v, want error: %t", p, err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("IsWebApp(ctx, %+v) = %t, want %t", p, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWebEntrypoint(t *testing.T) {
+	testCases := []struct {
+		name string
+		env  []string
+		want string
+	}{
+		{
+			name: "port",
+			env:  []string{"PORT=8081"},
+			want: "http://*:8081",
+		},
+		{
+			name: "no port",
+			want: "http://*:8080",
+		},
+		{
+			name: "urls set at launch",
+			env:  []string{"PORT=8081", "ASPNETCORE_URLS=http://localhost:5000"},
+			want: "http://localhost:5000",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ep := WebEntrypoint(`exec printenv ASPNETCORE_URLS`)
+
+			cmd := exec.Command("/bin/bash", "-c", ep)
+			cmd.Env = append([]string{"PATH=" + os.Getenv("PATH")}, tc.env...)
+			out, err := cmd.Output()
+			if err != nil {
+				t.Fatalf("running entrypoint %q: %v", ep, err)
+			}
+			if got := strings.TrimSpace(string(out)); got != tc.want {
+				t.Errorf("ASPNETCORE_URLS of entrypoint %q = %q, want %q", ep, got, tc.want)
+			}
+		})
+	}
+}
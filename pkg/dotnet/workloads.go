@@ -0,0 +1,95 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dotnet
+
+import (
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+var (
+	// workloadIDRegexp matches the syntax of SDK workload ids, e.g. "wasm-tools" or "maui-android".
+	workloadIDRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+	// platformWorkloads maps the platform component of a target framework, e.g. "android" in
+	// "net7.0-android", to the workload that provides it.
+	platformWorkloads = map[string]string{
+		"android":     "android",
+		"ios":         "ios",
+		"maccatalyst": "maccatalyst",
+		"macos":       "macos",
+		"tvos":        "tvos",
+	}
+)
+
+// RequiredWorkloads returns the sorted ids of the SDK workloads needed to build the project.
+// The ids listed in GOOGLE_DOTNET_WORKLOADS take precedence over the ones inferred from the
+// project file.
+func RequiredWorkloads(p Project) ([]string, error) {
+	var ids []string
+	if v := os.Getenv(WorkloadsEnv); v != "" {
+		ids = strings.FieldsFunc(v, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+	} else {
+		ids = projectWorkloads(p)
+	}
+
+	seen := map[string]bool{}
+	var result []string
+	for _, id := range ids {
+		id = strings.ToLower(id)
+		if !workloadIDRegexp.MatchString(id) {
+			return nil, gcp.UserErrorf("invalid workload id %q in %s, ids contain only letters, digits and dashes", id, WorkloadsEnv)
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		result = append(result, id)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// projectWorkloads infers the workloads a project needs from its properties: AOT compilation and
+// native builds of WebAssembly apps need wasm-tools, and platform-specific target frameworks need
+// the workload of their platform.
+func projectWorkloads(p Project) []string {
+	var ids []string
+	for _, pg := range p.PropertyGroups {
+		if strings.EqualFold(pg.RunAOTCompilation, "true") || strings.EqualFold(pg.WasmBuildNative, "true") {
+			ids = append(ids, "wasm-tools")
+		}
+		frameworks := strings.Split(pg.TargetFrameworks, ";")
+		frameworks = append(frameworks, pg.TargetFramework)
+		for _, tfm := range frameworks {
+			i := strings.Index(tfm, "-")
+			if i < 0 {
+				continue
+			}
+			// Strip the platform version, e.g. "ios16.1" in "net7.0-ios16.1".
+			platform := strings.TrimRight(strings.ToLower(strings.TrimSpace(tfm[i+1:])), "0123456789.")
+			if w, ok := platformWorkloads[platform]; ok {
+				ids = append(ids, w)
+			}
+		}
+	}
+	return ids
+}
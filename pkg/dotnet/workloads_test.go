@@ -0,0 +1,84 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dotnet
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRequiredWorkloads(t *testing.T) {
+	testCases := []struct {
+		name    string
+		env     string
+		project Project
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "none",
+			project: Project{PropertyGroups: []PropertyGroup{{TargetFramework: "net6.0"}}},
+		},
+		{
+			name:    "wasm aot",
+			project: Project{PropertyGroups: []PropertyGroup{{TargetFramework: "net7.0", RunAOTCompilation: "true"}}},
+			want:    []string{"wasm-tools"},
+		},
+		{
+			name:    "wasm native build",
+			project: Project{PropertyGroups: []PropertyGroup{{WasmBuildNative: "True"}, {RunAOTCompilation: "true"}}},
+			want:    []string{"wasm-tools"},
+		},
+		{
+			name:    "platform target frameworks",
+			project: Project{PropertyGroups: []PropertyGroup{{TargetFrameworks: "net7.0;net7.0-android;net7.0-ios16.1"}}},
+			want:    []string{"android", "ios"},
+		},
+		{
+			name:    "unknown platform",
+			project: Project{PropertyGroups: []PropertyGroup{{TargetFramework: "net7.0-windows"}}},
+		},
+		{
+			name:    "env overrides project",
+			env:     "maui-android, wasm-tools",
+			project: Project{PropertyGroups: []PropertyGroup{{TargetFramework: "net7.0-ios"}}},
+			want:    []string{"maui-android", "wasm-tools"},
+		},
+		{
+			name: "env dedupes and lowercases",
+			env:  "WASM-TOOLS wasm-tools",
+			want: []string{"wasm-tools"},
+		},
+		{
+			name:    "env invalid id",
+			env:     "wasm_tools",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(WorkloadsEnv, tc.env)
+
+			got, err := RequiredWorkloads(tc.project)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("RequiredWorkloads() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("RequiredWorkloads() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package entrypoint resolves the application entrypoint from the sources a user can configure it in.
+package entrypoint
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/appyaml"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// Source identifies where an entrypoint was resolved from.
+type Source string
+
+const (
+	// SourceEnv is the GOOGLE_ENTRYPOINT environment variable.
+	SourceEnv Source = "environment variable " + env.Entrypoint
+	// SourceProcfile is the Procfile at the application root.
+	SourceProcfile Source = "Procfile"
+	// SourceAppYAML is the entrypoint field of the app.yaml specified by GAE_APPLICATION_YAML_PATH.
+	SourceAppYAML Source = "app.yaml"
+	// SourceLanguage means no entrypoint was configured and the language buildpacks must detect one.
+	SourceLanguage Source = "language detection"
+
+	// Procfile is the name of the Procfile.
+	Procfile = "Procfile"
+)
+
+var (
+	// ProcessRe matches a single `name: command` line of a Procfile.
+	ProcessRe = regexp.MustCompile(`(?m)^(\w+):[ \t]*(.*)$`)
+)
+
+// Entrypoint is the result of resolving the entrypoint.
+type Entrypoint struct {
+	// Source is the source the entrypoint was resolved from.
+	Source Source
+	// Command is the web process command, empty if Source is SourceLanguage.
+	Command string
+	// Procfile holds the raw Procfile contents if Source is SourceProcfile.
+	Procfile string
+}
+
+// ResolveFromSources resolves the entrypoint by considering, in order of precedence,
+// GOOGLE_ENTRYPOINT, the Procfile, the app.yaml entrypoint and finally language detection.
+// The first source that is present wins and is logged. If the source cannot be read or parsed, the
+// returned error comes with an Entrypoint that only has its Source set.
+func ResolveFromSources(ctx *gcp.Context) (*Entrypoint, error) {
+	ep, err := resolve(ctx)
+	if err != nil {
+		return ep, err
+	}
+	if ep.Source == SourceLanguage {
+		ctx.Logf("No entrypoint found in %s, %s or %s, falling back to %s.", SourceEnv, SourceProcfile, SourceAppYAML, SourceLanguage)
+		return ep, nil
+	}
+	ctx.Logf("Using entrypoint from %s: %s", ep.Source, ep.Command)
+	return ep, nil
+}
+
+func resolve(ctx *gcp.Context) (*Entrypoint, error) {
+	if ep := os.Getenv(env.Entrypoint); ep != "" {
+		return &Entrypoint{Source: SourceEnv, Command: ep}, nil
+	}
+
+	path := filepath.Join(ctx.ApplicationRoot(), Procfile)
+	procExists, err := ctx.FileExists(path)
+	if err != nil {
+		return &Entrypoint{Source: SourceProcfile}, err
+	}
+	if procExists {
+		b, err := ctx.ReadFile(path)
+		if err != nil {
+			return &Entrypoint{Source: SourceProcfile}, err
+		}
+		content := string(b)
+		command, err := procfileWebCommand(content)
+		if err != nil {
+			return &Entrypoint{Source: SourceProcfile}, err
+		}
+		return &Entrypoint{Source: SourceProcfile, Command: command, Procfile: content}, nil
+	}
+
+	ep, err := appyaml.EntrypointIfExists(ctx.ApplicationRoot())
+	if err != nil {
+		return &Entrypoint{Source: SourceAppYAML}, err
+	}
+	if ep != "" {
+		return &Entrypoint{Source: SourceAppYAML, Command: ep}, nil
+	}
+
+	return &Entrypoint{Source: SourceLanguage}, nil
+}
+
+// procfileWebCommand returns the command of the first web process in the given Procfile contents.
+// It returns a user error if any process has an empty command.
+func procfileWebCommand(content string) (string, error) {
+	var web string
+	found := false
+	for _, match := range ProcessRe.FindAllStringSubmatch(content, -1) {
+		name, command := match[1], strings.TrimSpace(match[2])
+		if command == "" {
+			return "", gcp.UserErrorf("the %s process in %s has an empty command", name, Procfile)
+		}
+		if name == gcp.WebProcess && !found {
+			web, found = command, true
+		}
+	}
+	return web, nil
+}
@@ -0,0 +1,169 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package entrypoint
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+func TestResolveFromSources(t *testing.T) {
+	testCases := []struct {
+		name        string
+		entrypoint  string
+		appYAMLPath string
+		files       map[string]string
+		want        Entrypoint
+		wantLog     string
+		wantErr     bool
+	}{
+		{
+			name:    "no sources",
+			want:    Entrypoint{Source: SourceLanguage},
+			wantLog: "falling back to language detection",
+		},
+		{
+			name:       "env only",
+			entrypoint: "my entrypoint",
+			want:       Entrypoint{Source: SourceEnv, Command: "my entrypoint"},
+			wantLog:    "Using entrypoint from environment variable GOOGLE_ENTRYPOINT: my entrypoint",
+		},
+		{
+			name: "procfile only",
+			files: map[string]string{
+				"Procfile": "worker: my worker\nweb: my web\n",
+			},
+			want: Entrypoint{
+				Source:   SourceProcfile,
+				Command:  "my web",
+				Procfile: "worker: my worker\nweb: my web\n",
+			},
+			wantLog: "Using entrypoint from Procfile: my web",
+		},
+		{
+			name:        "app.yaml only",
+			appYAMLPath: "app.yaml",
+			files: map[string]string{
+				"app.yaml": "entrypoint: my app.yaml",
+			},
+			want:    Entrypoint{Source: SourceAppYAML, Command: "my app.yaml"},
+			wantLog: "Using entrypoint from app.yaml: my app.yaml",
+		},
+		{
+			name: "app.yaml without GAE_APPLICATION_YAML_PATH is ignored",
+			files: map[string]string{
+				"app.yaml": "entrypoint: my app.yaml",
+			},
+			want:    Entrypoint{Source: SourceLanguage},
+			wantLog: "falling back to language detection",
+		},
+		{
+			name:        "env wins over procfile and app.yaml",
+			entrypoint:  "my entrypoint",
+			appYAMLPath: "app.yaml",
+			files: map[string]string{
+				"Procfile": "web: my web",
+				"app.yaml": "entrypoint: my app.yaml",
+			},
+			want:    Entrypoint{Source: SourceEnv, Command: "my entrypoint"},
+			wantLog: "Using entrypoint from environment variable GOOGLE_ENTRYPOINT: my entrypoint",
+		},
+		{
+			name:        "procfile wins over app.yaml",
+			appYAMLPath: "app.yaml",
+			files: map[string]string{
+				"Procfile": "web: my web",
+				"app.yaml": "entrypoint: my app.yaml",
+			},
+			want:    Entrypoint{Source: SourceProcfile, Command: "my web", Procfile: "web: my web"},
+			wantLog: "Using entrypoint from Procfile: my web",
+		},
+		{
+			name:       "env wins over procfile",
+			entrypoint: "my entrypoint",
+			files: map[string]string{
+				"Procfile": "web: my web",
+			},
+			want:    Entrypoint{Source: SourceEnv, Command: "my entrypoint"},
+			wantLog: "Using entrypoint from environment variable GOOGLE_ENTRYPOINT: my entrypoint",
+		},
+		{
+			name: "procfile with an empty command",
+			files: map[string]string{
+				"Procfile": "web:\nworker: my worker\n",
+			},
+			wantErr: true,
+		},
+		{
+			name: "procfile with an empty worker command",
+			files: map[string]string{
+				"Procfile": "web: my web\nworker:  \n",
+			},
+			wantErr: true,
+		},
+		{
+			name:        "app.yaml without entrypoint",
+			appYAMLPath: "app.yaml",
+			files: map[string]string{
+				"app.yaml": "foo: bar",
+			},
+			wantErr: true,
+		},
+		{
+			name:        "missing app.yaml",
+			appYAMLPath: "app.yaml",
+			wantErr:     true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			root := t.TempDir()
+			for f, c := range tc.files {
+				if err := os.WriteFile(filepath.Join(root, f), []byte(c), 0644); err != nil {
+					t.Fatalf("writing %s: %v", f, err)
+				}
+			}
+			t.Setenv("GOOGLE_ENTRYPOINT", tc.entrypoint)
+			if tc.appYAMLPath != "" {
+				t.Setenv("GAE_APPLICATION_YAML_PATH", filepath.Join(root, tc.appYAMLPath))
+			}
+			buf := new(bytes.Buffer)
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(root), gcp.WithLogger(log.New(buf, "", 0)))
+
+			got, err := ResolveFromSources(ctx)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("ResolveFromSources() = %#v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ResolveFromSources() got error: %v", err)
+			}
+			if *got != tc.want {
+				t.Errorf("ResolveFromSources() = %#v, want %#v", *got, tc.want)
+			}
+			if !strings.Contains(buf.String(), tc.wantLog) {
+				t.Errorf("ResolveFromSources() logged %q, want it to contain %q", buf.String(), tc.wantLog)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package entrypoint
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// shellBuiltins are commands an entrypoint may start with that are not binaries on PATH.
+var shellBuiltins = map[string]bool{
+	".": true, "cd": true, "eval": true, "exec": true, "export": true, "set": true, "source": true, "ulimit": true, "umask": true,
+}
+
+// ValidateEntrypoint shell-parses the entrypoint s and returns an error if it is empty or has
+// unbalanced quotes or a dangling escape, which would otherwise only fail when the container starts.
+func ValidateEntrypoint(s string) error {
+	words, err := shellWords(s)
+	if err != nil {
+		return gcp.UserErrorf("invalid entrypoint %q: %v", s, err)
+	}
+	if len(words) == 0 {
+		return gcp.UserErrorf("invalid entrypoint %q: the command is empty", s)
+	}
+	return nil
+}
+
+// WarnIfNotOnPath warns if the binary the entrypoint s runs is not on the PATH of the build.
+// The check is best-effort: binaries referenced by a path or a variable, and shell builtins, are not checked,
+// and the binary may still be provided at launch by a later buildpack or the run image.
+func WarnIfNotOnPath(ctx *gcp.Context, s string) {
+	words, err := shellWords(s)
+	if err != nil {
+		return
+	}
+	for len(words) > 0 && isAssignment(words[0]) {
+		words = words[1:]
+	}
+	if len(words) == 0 {
+		return
+	}
+	bin := words[0]
+	if shellBuiltins[bin] || strings.ContainsAny(bin, "/$`") {
+		return
+	}
+	if _, err := exec.LookPath(bin); err != nil {
+		ctx.Warnf("The entrypoint runs %q, which was not found on the PATH of the build; the application may fail to start.", bin)
+	}
+}
+
+// ValidateScript validates the shell script that the entrypoint s runs, if any, see
+// gcp.ValidateScriptEntrypoint. Only scripts run directly by their path, e.g. `./start.sh`, are
+// validated, because scripts passed to a shell, e.g. `bash start.sh`, need neither the executable
+// bit nor a shebang.
+func ValidateScript(ctx *gcp.Context, s string) error {
+	script := scriptPath(s)
+	if script == "" {
+		return nil
+	}
+	return gcp.ValidateScriptEntrypoint(ctx, script)
+}
+
+// scriptPath returns the path of the .sh script that the entrypoint s runs, or "" if it does not
+// run a script directly. Paths with expansions are skipped.
+func scriptPath(s string) string {
+	words, err := shellWords(s)
+	if err != nil {
+		return ""
+	}
+	for len(words) > 0 && (isAssignment(words[0]) || words[0] == "exec") {
+		words = words[1:]
+	}
+	if len(words) == 0 || !strings.HasSuffix(words[0], ".sh") || strings.ContainsAny(words[0], "$`") {
+		return ""
+	}
+	return words[0]
+}
+
+// isAssignment returns true if the shell word w is a variable assignment, such as FOO=bar.
+func isAssignment(w string) bool {
+	i := strings.Index(w, "=")
+	if i <= 0 {
+		return false
+	}
+	for j, r := range w[:i] {
+		if r != '_' && !(r >= 'a' && r <= 'z') && !(r >= 'A' && r <= 'Z') && !(j > 0 && r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// shellWords splits s into words following the quoting rules of a POSIX shell:
+// single quotes preserve everything, backslashes escape the next character outside quotes,
+// and only $, `, ", \ and newline inside double quotes. Expansions are left as is.
+func shellWords(s string) ([]string, error) {
+	var (
+		words   []string
+		word    strings.Builder
+		inWord  bool
+		escaped bool
+		quote   rune
+	)
+	for _, r := range s {
+		switch {
+		case escaped:
+			if quote == '"' && !strings.ContainsRune("$`\"\\\n", r) {
+				word.WriteRune('\\')
+			}
+			word.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case quote == '"':
+			switch r {
+			case '"':
+				quote = 0
+			case '\\':
+				escaped = true
+			default:
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == '\\':
+			escaped = true
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if escaped {
+		return nil, fmt.Errorf("it ends with an unescaped backslash")
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("it has an unbalanced %c quote", quote)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
@@ -0,0 +1,178 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package entrypoint
+
+import (
+	"bytes"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+func TestValidateEntrypoint(t *testing.T) {
+	testCases := []struct {
+		name       string
+		entrypoint string
+		wantErr    bool
+	}{
+		{
+			name:       "simple",
+			entrypoint: "gunicorn -b :$PORT main:app",
+		},
+		{
+			name:       "balanced double quotes",
+			entrypoint: `sh -c "echo \"hello\" && ./server"`,
+		},
+		{
+			name:       "balanced single quotes",
+			entrypoint: `python3 -c 'print("hello")'`,
+		},
+		{
+			name:       "quoted empty argument",
+			entrypoint: `./server --flag ""`,
+		},
+		{
+			name:       "unbalanced double quote",
+			entrypoint: `java -jar "app.jar`,
+			wantErr:    true,
+		},
+		{
+			name:       "unbalanced single quote",
+			entrypoint: `python3 -c 'print(1)`,
+			wantErr:    true,
+		},
+		{
+			name:       "single quote in double quotes",
+			entrypoint: `echo "it's fine"`,
+		},
+		{
+			name:       "trailing backslash",
+			entrypoint: `./server \`,
+			wantErr:    true,
+		},
+		{
+			name:    "empty",
+			wantErr: true,
+		},
+		{
+			name:       "whitespace only",
+			entrypoint: " \t ",
+			wantErr:    true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateEntrypoint(tc.entrypoint)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("ValidateEntrypoint(%q) got error: %v, want error: %t", tc.entrypoint, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestShellWords(t *testing.T) {
+	testCases := []struct {
+		input string
+		want  []string
+	}{
+		{input: "a  b\tc", want: []string{"a", "b", "c"}},
+		{input: `a "b c" 'd e'`, want: []string{"a", "b c", "d e"}},
+		{input: `a b\ c`, want: []string{"a", "b c"}},
+		{input: `"a\"b" "\d" '\e'`, want: []string{`a"b`, `\d`, `\e`}},
+		{input: `a "" b`, want: []string{"a", "", "b"}},
+		{input: `x"y"'z'`, want: []string{"xyz"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			got, err := shellWords(tc.input)
+			if err != nil {
+				t.Fatalf("shellWords(%q) got error: %v", tc.input, err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("shellWords(%q) = %q, want %q", tc.input, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWarnIfNotOnPath(t *testing.T) {
+	testCases := []struct {
+		name       string
+		entrypoint string
+		wantWarn   bool
+	}{
+		{
+			name:       "on path",
+			entrypoint: "sh -c 'echo hello'",
+		},
+		{
+			name:       "not on path",
+			entrypoint: "no-such-binary-for-tests --port $PORT",
+			wantWarn:   true,
+		},
+		{
+			name:       "not on path after assignment",
+			entrypoint: "FOO=bar no-such-binary-for-tests",
+			wantWarn:   true,
+		},
+		{
+			name:       "path",
+			entrypoint: "./bin/server",
+		},
+		{
+			name:       "builtin",
+			entrypoint: "exec ./bin/server",
+		},
+		{
+			name:       "variable",
+			entrypoint: "$SERVER --port $PORT",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			ctx := gcp.NewContext(gcp.WithLogger(log.New(buf, "", 0)))
+
+			WarnIfNotOnPath(ctx, tc.entrypoint)
+
+			if gotWarn := strings.Contains(buf.String(), "not found on the PATH"); gotWarn != tc.wantWarn {
+				t.Errorf("WarnIfNotOnPath(%q) logged %q, want warning: %t", tc.entrypoint, buf.String(), tc.wantWarn)
+			}
+		})
+	}
+}
+
+func TestScriptPath(t *testing.T) {
+	testCases := []struct {
+		entrypoint string
+		want       string
+	}{
+		{entrypoint: "./start.sh", want: "./start.sh"},
+		{entrypoint: "exec scripts/run.sh --port $PORT", want: "scripts/run.sh"},
+		{entrypoint: "PORT=8080 /workspace/start.sh", want: "/workspace/start.sh"},
+		{entrypoint: "bash start.sh"},
+		{entrypoint: "./bin/server"},
+		{entrypoint: "$APP_HOME/start.sh"},
+		{entrypoint: "'unbalanced.sh"},
+	}
+	for _, tc := range testCases {
+		if got := scriptPath(tc.entrypoint); got != tc.want {
+			t.Errorf("scriptPath(%q) = %q, want %q", tc.entrypoint, got, tc.want)
+		}
+	}
+}
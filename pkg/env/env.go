@@ -19,6 +19,8 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"unicode"
 )
 
 const (
@@ -33,6 +35,17 @@ const (
 	// Example: `13.7.0` for Node.js, `1.14.1` for Go.
 	RuntimeVersion = "GOOGLE_RUNTIME_VERSION"
 
+	// RuntimeLocalDir is an env var used to install runtimes from tarballs in a local directory,
+	// named `<runtime>-<version>-<arch>.tar.gz`, instead of downloading them.
+	// Example: `/mnt/runtimes` will install Node.js 16.17.1 from /mnt/runtimes/nodejs-16.17.1-amd64.tar.gz.
+	RuntimeLocalDir = "GOOGLE_RUNTIME_LOCAL_DIR"
+
+	// RuntimeURLTemplate is an env var used to download runtime tarballs from a mirror instead of
+	// dl.google.com. The `{name}`, `{version}` and `{arch}` placeholders are replaced with the
+	// runtime, its version and the architecture of the build.
+	// Example: `https://mirror.example.com/{name}/{version}/{arch}.tar.gz`.
+	RuntimeURLTemplate = "GOOGLE_RUNTIME_URL_TEMPLATE"
+
 	// DebugMode enables more verbose logging.
 	// Example: `true`, `True`, `1` will enable development mode.
 	DebugMode = "GOOGLE_DEBUG"
@@ -42,6 +55,27 @@ const (
 	// Example: `true`, `True`, `1` will enable development mode.
 	DevMode = "GOOGLE_DEVMODE"
 
+	// AppType is an env var used to select the kind of application that is built: a `service` that
+	// serves requests on $PORT, the default, or a `job` that runs GOOGLE_JOB_COMMAND to completion,
+	// e.g. a Cloud Run job, and has no web process.
+	// Example: `job`.
+	AppType = "GOOGLE_APP_TYPE"
+	// AppTypeService is the GOOGLE_APP_TYPE of applications that serve requests.
+	AppTypeService = "service"
+	// AppTypeJob is the GOOGLE_APP_TYPE of applications that run to completion.
+	AppTypeJob = "job"
+
+	// JobCommand is an env var with the shell command that a job runs, required if GOOGLE_APP_TYPE
+	// is `job`.
+	// Example: `python3 tasks/cleanup.py`.
+	JobCommand = "GOOGLE_JOB_COMMAND"
+
+	// AdditionalBuildpacks is an env var used to list the languages, separated by commas or spaces,
+	// whose buildpacks build parts of the application before the buildpacks of its main language,
+	// e.g. a Node.js frontend that is served by a Go backend.
+	// Example: `nodejs`.
+	AdditionalBuildpacks = "GOOGLE_ADDITIONAL_BUILDPACKS"
+
 	// Entrypoint is an env var used to override the default entrypoint.
 	// Entrypoint should be respected by at least one buildpack in builders that are not product-specific.
 	// Example: `gunicorn -p :8080 main:app` for Python.
@@ -60,6 +94,11 @@ const (
 	// Example: `-Pprod` for Maven apps run "mvn clear package ... -Pprod" command.
 	BuildArgs = "GOOGLE_BUILD_ARGS"
 
+	// BuildDB is an env var used to provision an ephemeral database for the build step, which is
+	// removed once the build step is done. Its path is exposed as GOOGLE_BUILD_DB_PATH.
+	// Example: `sqlite` provisions an empty SQLite database.
+	BuildDB = "GOOGLE_BUILD_DB"
+
 	// GAEMain is an env var used to specify path or fully qualified package name of the main package in App Engine buildpacks.
 	// Behavior: In Go, the value is cleaned up and passed on to subsequent buildpacks as GOOGLE_BUILDABLE.
 	GAEMain = "GAE_YAML_MAIN"
@@ -99,6 +138,33 @@ const (
 	// GoLDFlags is an env var used to pass through linker flags to the Go linker.
 	// Example: `-s -w` is sometimes used to strip and reduce binary size.
 	GoLDFlags = "GOOGLE_GOLDFLAGS"
+	// GoBuildTags is an env var used to pass build tags to `go build` as `-tags`.
+	// Example: `netgo,osusergo` or `netgo osusergo`.
+	GoBuildTags = "GOOGLE_GOBUILD_TAGS"
+	// GoBuildLDFlags is an env var used to pass linker flags to `go build` as `-ldflags`. Arguments
+	// may be quoted but are not interpreted by a shell.
+	// Example: `-X 'main.version=1.2.3'` is used to stamp version information.
+	GoBuildLDFlags = "GOOGLE_GOBUILD_LDFLAGS"
+	// GoLinkmode is an env var used to select the linker mode, passed to `go build` as
+	// `-ldflags -linkmode=<mode>`.
+	// Example: `external` links cgo binaries with the external (system) linker.
+	GoLinkmode = "GOOGLE_GO_LINKMODE"
+	// GoGenerate is an env var used to run `go generate ./...` before `go build`.
+	// Example: `true`, `True`, `1` will run the generators.
+	GoGenerate = "GOOGLE_GO_GENERATE"
+	// GoTest is an env var used to run `go test ./...` before `go build` and fail the build if a test
+	// fails. The test results are cached in the GOCACHE layer across builds.
+	// Example: `true`, `True`, `1` will run the tests.
+	GoTest = "GOOGLE_GO_TEST"
+	// GoNetrc is an env var used to specify the path of a .netrc file with the credentials used to
+	// fetch private modules, both by the go command and by git.
+	// Example: `/secrets/netrc` is a file mounted into the build.
+	GoNetrc = "GOOGLE_GO_NETRC"
+	// GoProxy is an env var used to set the GOPROXY of the module downloads of the build: a list of
+	// proxy URLs and `direct` separated by `,` or `|`, or `off` to build only from the vendor
+	// directory and the module cache.
+	// Example: `https://proxy.example.com,https://proxy.golang.org,direct`.
+	GoProxy = "GOOGLE_GOPROXY"
 
 	// UseNativeImage is used to enable the GraalVM Java buildpack for native image compilation.
 	// Example: `true`, `True`, `1` will enable development mode.
@@ -115,9 +181,56 @@ const (
 	// lowercased, underscores changed to dashes, and is prefixed with "google.".
 	LabelPrefix = "GOOGLE_LABEL_"
 
+	// ExposePorts is an env var with a comma-separated list of the ports, in addition to $PORT, that
+	// the application listens on. The ports are recorded in the google.expose-ports image label.
+	// Example: `8080,9090`.
+	ExposePorts = "GOOGLE_EXPOSE_PORTS"
+
 	// ContainerMemoryHintMB is used to specify the amount of memory that will be allocated when running the container.
 	ContainerMemoryHintMB = "GOOGLE_CONTAINER_MEMORY_HINT_MB"
 
+	// RetainBuildLog is used to retain the log of each buildpack in a build.log file of a cached,
+	// non-launch layer for post-mortem debugging.
+	// Example: `true`, `True`, `1` will retain the build log.
+	RetainBuildLog = "GOOGLE_RETAIN_BUILD_LOG"
+
+	// KeepTests is used to keep test files, e.g. `*_test.go` or `__tests__`, in the application
+	// instead of pruning them from the image.
+	// Example: `true`, `True`, `1` will keep the test files.
+	KeepTests = "GOOGLE_KEEP_TESTS"
+
+	// BuildUmask is used to set the umask of the build process, in octal, so that files created
+	// during the build do not get overly permissive modes. Layers are also stripped of world-write
+	// permissions at the end of each buildpack when it is set.
+	// Example: `027` removes group-write and all permissions for others from new files.
+	BuildUmask = "GOOGLE_BUILD_UMASK"
+
+	// CacheSeed is used to invalidate all cached layers without changing the application: layers
+	// cached with a different seed are cleared, as are cache keys computed with a different seed.
+	// Example: `2022-10-01` invalidates layers cached before the seed was set or with another seed.
+	CacheSeed = "GOOGLE_CACHE_SEED"
+
+	// LintCommand is used to specify a shell command, e.g. a linter or formatter check, that runs in
+	// the application directory after dependencies are installed and fails the build if it fails.
+	// Example: `npx eslint .`, `test -z "$(gofmt -l .)"`, `black --check .`.
+	LintCommand = "GOOGLE_LINT_COMMAND"
+
+	// MakeTarget is used to build the application by running the named target of its Makefile, after
+	// the toolchain and dependencies are set up. The entrypoint is configured with GOOGLE_ENTRYPOINT,
+	// or, for Go, with GOOGLE_BUILDABLE set to the path of the binary that the target produces.
+	// Example: `build`.
+	MakeTarget = "GOOGLE_MAKE_TARGET"
+
+	// Task is used to build the application by running the named task of its Taskfile.yml, see
+	// https://taskfile.dev, like GOOGLE_MAKE_TARGET.
+	// Example: `build`.
+	Task = "GOOGLE_TASK"
+
+	// StrictDeps is used to fail the build instead of warning when an outdated package manager or
+	// dependency is detected, e.g. an npm that does not support `npm prune` or an old gunicorn.
+	// Example: `true`, `True`, `1` will fail the build on such warnings.
+	StrictDeps = "GOOGLE_STRICT_DEPS"
+
 	// XGoogleSkipRuntimeLaunch is used to enable an experimental builder feature to include the
 	// runtime layer in the builder image and omit it from the launch image.
 	XGoogleSkipRuntimeLaunch = "X_GOOGLE_SKIP_RUNTIME_LAUNCH"
@@ -147,6 +260,51 @@ func IsGCF() bool {
 	return TargetPlatformFunctions == os.Getenv(XGoogleTargetPlatform)
 }
 
+// GetAppType returns the application type set by GOOGLE_APP_TYPE, AppTypeService if it is not set.
+func GetAppType() (string, error) {
+	switch t := os.Getenv(AppType); t {
+	case "":
+		return AppTypeService, nil
+	case AppTypeService, AppTypeJob:
+		return t, nil
+	default:
+		return "", fmt.Errorf("invalid %s %q, must be %q or %q", AppType, t, AppTypeService, AppTypeJob)
+	}
+}
+
+// IsJob returns true if GOOGLE_APP_TYPE selects a job, which has no web process.
+func IsJob() bool {
+	return os.Getenv(AppType) == AppTypeJob
+}
+
+// GetAdditionalBuildpacks returns the lowercase languages listed in GOOGLE_ADDITIONAL_BUILDPACKS,
+// without duplicates.
+func GetAdditionalBuildpacks() []string {
+	var langs []string
+	seen := map[string]bool{}
+	for _, l := range strings.FieldsFunc(os.Getenv(AdditionalBuildpacks), func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	}) {
+		l = strings.ToLower(l)
+		if !seen[l] {
+			seen[l] = true
+			langs = append(langs, l)
+		}
+	}
+	return langs
+}
+
+// IsAdditionalBuildpack returns true if GOOGLE_ADDITIONAL_BUILDPACKS lists the given language, i.e.
+// the language is built alongside another language that owns GOOGLE_RUNTIME_VERSION.
+func IsAdditionalBuildpack(lang string) bool {
+	for _, l := range GetAdditionalBuildpacks() {
+		if l == lang {
+			return true
+		}
+	}
+	return false
+}
+
 // IsDebugMode returns true if the buildpack debug mode is enabled.
 func IsDebugMode() (bool, error) {
 	return IsPresentAndTrue(DebugMode)
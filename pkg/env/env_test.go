@@ -16,6 +16,7 @@ package env
 
 import (
 	"os"
+	"reflect"
 	"testing"
 )
 
@@ -55,6 +56,11 @@ func TestIsDebugMode(t *testing.T) {
 			value: "1",
 			want:  true,
 		},
+		{
+			name:  "set to capitalized true",
+			value: "True",
+			want:  true,
+		},
 		{
 			name:  "set to falsey",
 			value: "0",
@@ -90,3 +96,76 @@ func TestIsDebugMode(t *testing.T) {
 		})
 	}
 }
+
+func TestGetAppType(t *testing.T) {
+	testCases := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "", want: AppTypeService},
+		{value: "service", want: AppTypeService},
+		{value: "job", want: AppTypeJob},
+		{value: "worker", wantErr: true},
+		{value: "Job", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			t.Setenv(AppType, tc.value)
+
+			got, err := GetAppType()
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("GetAppType() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("GetAppType() = %q, want %q", got, tc.want)
+			}
+			if gotJob, wantJob := IsJob(), tc.want == AppTypeJob; gotJob != wantJob {
+				t.Errorf("IsJob() = %t, want %t", gotJob, wantJob)
+			}
+		})
+	}
+}
+
+func TestGetAdditionalBuildpacks(t *testing.T) {
+	testCases := []struct {
+		value string
+		want  []string
+	}{
+		{value: ""},
+		{value: "nodejs", want: []string{"nodejs"}},
+		{value: " NodeJS, python  ruby,,", want: []string{"nodejs", "python", "ruby"}},
+		{value: "nodejs,nodejs", want: []string{"nodejs"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			t.Setenv(AdditionalBuildpacks, tc.value)
+
+			if got := GetAdditionalBuildpacks(); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("GetAdditionalBuildpacks() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestIsAdditionalBuildpack(t *testing.T) {
+	testCases := []struct {
+		value string
+		lang  string
+		want  bool
+	}{
+		{value: "", lang: "nodejs"},
+		{value: "NodeJS", lang: "nodejs", want: true},
+		{value: "python ruby", lang: "nodejs"},
+		{value: "python,nodejs", lang: "nodejs", want: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			t.Setenv(AdditionalBuildpacks, tc.value)
+
+			if got := IsAdditionalBuildpack(tc.lang); got != tc.want {
+				t.Errorf("IsAdditionalBuildpack(%q) = %t, want %t", tc.lang, got, tc.want)
+			}
+		})
+	}
+}
@@ -43,6 +43,16 @@ func Tarball(url, dir string, stripComponents int) error {
 	return untar(dir, response.Body, stripComponents)
 }
 
+// UntarFile extracts the gzipped tarball at path into the provided directory.
+func UntarFile(path, dir string, stripComponents int) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return gcp.InternalErrorf("opening %s: %v", path, err)
+	}
+	defer f.Close()
+	return untar(dir, f, stripComponents)
+}
+
 // JSON fetches a JSON payload from a URL and unmarshalls it into the value pointed to by v.
 func JSON(url string, v interface{}) error {
 	response, err := doGet(url)
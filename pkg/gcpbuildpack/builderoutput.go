@@ -170,6 +170,7 @@ func (ctx *Context) saveSuccessOutput(duration time.Duration) {
 		BuildpackVersion: ctx.BuildpackVersion(),
 		DurationMs:       duration.Milliseconds(),
 		UserDurationMs:   ctx.stats.user.Milliseconds(),
+		Timings:          ctx.outputTimings(),
 	})
 	bo.Warnings = append(bo.Warnings, ctx.warnings...)
 
@@ -188,6 +189,11 @@ func (ctx *Context) saveSuccessOutput(duration time.Duration) {
 		if len(content) <= maxMessageBytes {
 			break
 		}
+		// Timings are only informative, so they are dropped before any warning.
+		if last := len(bo.Stats) - 1; bo.Stats[last].Timings != nil {
+			bo.Stats[last].Timings = nil
+			continue
+		}
 		// This is a defensive check; if there are no warnings, the message should be small enough.
 		// In either case, skip this stat.
 		if len(bo.Warnings) == 0 {
@@ -0,0 +1,68 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
+)
+
+const (
+	// buildLogLayer is the name of the layer that holds the retained build log.
+	buildLogLayer = "build-log"
+	// buildLogFile is the name of the retained build log file.
+	buildLogFile = "build.log"
+	// buildLogKey is the layer metadata key that holds the path of the retained build log.
+	buildLogKey = "build_log"
+)
+
+// RetainBuildLog tees all log lines and logged command output of the buildpack into a build.log
+// file. The file is stored in a cached layer that is not part of the launch image, and its path is
+// recorded in the layer metadata. It is called for every buildpack if GOOGLE_RETAIN_BUILD_LOG is set.
+func (ctx *Context) RetainBuildLog() error {
+	if ctx.buildLog != nil {
+		return nil
+	}
+	l, err := ctx.Layer(buildLogLayer, CacheLayer)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(l.Path, buildLogFile)
+	f, err := os.Create(path)
+	if err != nil {
+		return buildererror.Errorf(buildererror.StatusInternal, "creating %s: %v", path, err)
+	}
+	ctx.buildLog = f
+	ctx.logger = log.New(io.MultiWriter(ctx.logger.Writer(), f), ctx.logger.Prefix(), ctx.logger.Flags())
+	ctx.SetMetadata(l, buildLogKey, path)
+	ctx.Debugf("Retaining the build log in %s.", path)
+	return nil
+}
+
+// closeBuildLog closes the retained build log, if any.
+func (ctx *Context) closeBuildLog() {
+	if ctx.buildLog == nil {
+		return
+	}
+	err := ctx.buildLog.Close()
+	ctx.buildLog = nil
+	if err != nil {
+		ctx.Warnf("Failed to close the build log: %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/buildpacks/libcnb"
+)
+
+func TestRetainBuildLog(t *testing.T) {
+	layersDir := t.TempDir()
+	var buf bytes.Buffer
+	ctx := NewContext(WithLogger(log.New(&buf, "", 0)), WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: layersDir}}))
+
+	if err := ctx.RetainBuildLog(); err != nil {
+		t.Fatalf("RetainBuildLog() got error: %v", err)
+	}
+	ctx.Logf("message from Logf")
+	ctx.Warnf("message from Warnf")
+	ctx.Exec([]string{"echo", "output from exec"}, WithUserAttribution)
+	ctx.Exec([]string{"echo", "output from system exec"})
+	ctx.closeBuildLog()
+
+	path := filepath.Join(layersDir, buildLogLayer, buildLogFile)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading %s: %v", path, err)
+	}
+	for _, want := range []string{"message from Logf", "WARNING: message from Warnf", `Running "echo output from exec"`, "output from exec\n"} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("build log does not contain %q, got:\n%s", want, content)
+		}
+	}
+	if strings.Contains(string(content), "output from system exec") {
+		t.Errorf("build log contains output of a command that was not logged, got:\n%s", content)
+	}
+	if !strings.Contains(buf.String(), "message from Logf") {
+		t.Errorf("log output does not contain %q, got:\n%s", "message from Logf", buf.String())
+	}
+
+	if len(ctx.buildResult.Layers) != 1 {
+		t.Fatalf("got %d layers, want 1", len(ctx.buildResult.Layers))
+	}
+	l := ctx.buildResult.Layers[0].(layerContributor).l
+	if l.Launch || l.Build || !l.Cache {
+		t.Errorf("build log layer got launch=%t build=%t cache=%t, want launch=false build=false cache=true", l.Launch, l.Build, l.Cache)
+	}
+	if got := ctx.GetMetadata(l, buildLogKey); got != path {
+		t.Errorf("build log layer metadata %s=%q, want %q", buildLogKey, got, path)
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+const (
+	// BuildToolMake runs the targets of a Makefile.
+	BuildToolMake = "make"
+	// BuildToolTask runs the tasks of a Taskfile.yml, see https://taskfile.dev.
+	BuildToolTask = "task"
+)
+
+// buildFiles are the files that declare the targets of each build tool, in the order in which the
+// tools look them up.
+var buildFiles = map[string][]string{
+	BuildToolMake: {"GNUmakefile", "makefile", "Makefile"},
+	BuildToolTask: {"Taskfile.yml", "taskfile.yml", "Taskfile.yaml", "taskfile.yaml"},
+}
+
+// buildTargetEnvs are the environment variables that select the target of each build tool.
+var buildTargetEnvs = map[string]string{
+	BuildToolMake: env.MakeTarget,
+	BuildToolTask: env.Task,
+}
+
+// BuildTargetFromEnv returns the build tool and target selected with GOOGLE_MAKE_TARGET or
+// GOOGLE_TASK, or empty strings if neither is set. Setting both is a user error.
+func BuildTargetFromEnv() (string, string, error) {
+	makeTarget := strings.TrimSpace(os.Getenv(env.MakeTarget))
+	task := strings.TrimSpace(os.Getenv(env.Task))
+	switch {
+	case makeTarget != "" && task != "":
+		return "", "", UserErrorf("only one of %s and %s can be set", env.MakeTarget, env.Task)
+	case makeTarget != "":
+		return BuildToolMake, makeTarget, nil
+	case task != "":
+		return BuildToolTask, task, nil
+	}
+	return "", "", nil
+}
+
+// RunBuildTarget runs the target of the build tool, BuildToolMake or BuildToolTask, in the
+// application directory and returns a user error with the tail of its output if it fails. The
+// application must have a build file of the tool, e.g. a Makefile. It is a no-op if the target is
+// empty. The options are added to those of the command, e.g. to expose a build database.
+func (ctx *Context) RunBuildTarget(tool, target string, opts ...ExecOption) error {
+	if target == "" {
+		return nil
+	}
+	files, ok := buildFiles[tool]
+	if !ok {
+		return InternalErrorf("unsupported build tool %q", tool)
+	}
+	if strings.HasPrefix(target, "-") || strings.ContainsAny(target, " \t\n") {
+		return UserErrorf("invalid %s=%q: must be the name of a single target", buildTargetEnvs[tool], target)
+	}
+	found := false
+	for _, f := range files {
+		exists, err := ctx.FileExists(ctx.ApplicationRoot(), f)
+		if err != nil {
+			return err
+		}
+		if exists {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return UserErrorf("%s is set but the application has none of %s", buildTargetEnvs[tool], strings.Join(files, ", "))
+	}
+	ctx.Logf("Building the application with %s target %q from %s.", tool, target, buildTargetEnvs[tool])
+	opts = append([]ExecOption{WithWorkDir(ctx.ApplicationRoot()), WithUserAttribution, WithStreamedOutput, WithCombinedTail}, opts...)
+	if _, err := ctx.ExecWithErr([]string{tool, target}, opts...); err != nil {
+		err.Message = fmt.Sprintf("%s target %q failed: %s", tool, target, err.Message)
+		return err
+	}
+	return nil
+}
+
+// RunBuildTargetFromEnv runs the build target selected with GOOGLE_MAKE_TARGET or GOOGLE_TASK, if
+// any, see RunBuildTarget.
+func (ctx *Context) RunBuildTargetFromEnv(opts ...ExecOption) error {
+	tool, target, err := BuildTargetFromEnv()
+	if err != nil {
+		return err
+	}
+	return ctx.RunBuildTarget(tool, target, opts...)
+}
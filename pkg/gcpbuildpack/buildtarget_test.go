@@ -0,0 +1,209 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+func TestRunBuildTarget(t *testing.T) {
+	testCases := []struct {
+		name       string
+		tool       string
+		target     string
+		files      []string
+		script     string
+		wantCmd    string
+		wantErr    bool
+		wantStatus buildererror.Status
+		wantOutput string
+	}{
+		{
+			name:  "no target",
+			tool:  BuildToolMake,
+			files: []string{"Makefile"},
+		},
+		{
+			name:       "make target",
+			tool:       BuildToolMake,
+			target:     "build",
+			files:      []string{"Makefile"},
+			script:     "echo built server",
+			wantCmd:    "make build",
+			wantOutput: "built server",
+		},
+		{
+			name:       "GNUmakefile",
+			tool:       BuildToolMake,
+			target:     "all",
+			files:      []string{"GNUmakefile"},
+			script:     "echo built all",
+			wantCmd:    "make all",
+			wantOutput: "built all",
+		},
+		{
+			name:       "task",
+			tool:       BuildToolTask,
+			target:     "build",
+			files:      []string{"Taskfile.yml"},
+			script:     "echo task done",
+			wantCmd:    "task build",
+			wantOutput: "task done",
+		},
+		{
+			name:       "failing target",
+			tool:       BuildToolMake,
+			target:     "build",
+			files:      []string{"Makefile"},
+			script:     "echo main.c:1: error; exit 2",
+			wantCmd:    "make build",
+			wantErr:    true,
+			wantStatus: buildererror.StatusUnknown,
+			wantOutput: "main.c:1: error",
+		},
+		{
+			name:       "no Makefile",
+			tool:       BuildToolMake,
+			target:     "build",
+			files:      []string{"Taskfile.yml"},
+			wantErr:    true,
+			wantStatus: buildererror.StatusUnknown,
+			wantOutput: "none of GNUmakefile, makefile, Makefile",
+		},
+		{
+			name:       "flag as target",
+			tool:       BuildToolMake,
+			target:     "-f",
+			files:      []string{"Makefile"},
+			wantErr:    true,
+			wantStatus: buildererror.StatusUnknown,
+			wantOutput: "single target",
+		},
+		{
+			name:       "several targets",
+			tool:       BuildToolTask,
+			target:     "lint build",
+			files:      []string{"Taskfile.yml"},
+			wantErr:    true,
+			wantStatus: buildererror.StatusUnknown,
+			wantOutput: "single target",
+		},
+		{
+			name:       "unsupported tool",
+			tool:       "ninja",
+			target:     "build",
+			wantErr:    true,
+			wantStatus: buildererror.StatusInternal,
+			wantOutput: "unsupported build tool",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, f := range tc.files {
+				if err := os.WriteFile(filepath.Join(dir, f), nil, 0644); err != nil {
+					t.Fatalf("writing %s: %v", f, err)
+				}
+			}
+			var gotCmd string
+			execCmd := func(name string, args ...string) *exec.Cmd {
+				gotCmd = strings.Join(append([]string{name}, args...), " ")
+				return exec.Command("sh", "-c", tc.script)
+			}
+			var buf bytes.Buffer
+			ctx := NewContext(WithApplicationRoot(dir), WithLogger(log.New(&buf, "", 0)), WithExecCmd(execCmd))
+
+			err := ctx.RunBuildTarget(tc.tool, tc.target)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("RunBuildTarget(%q, %q) got error: %v, want error: %t", tc.tool, tc.target, err, tc.wantErr)
+			}
+			if gotCmd != tc.wantCmd {
+				t.Errorf("RunBuildTarget(%q, %q) ran %q, want %q", tc.tool, tc.target, gotCmd, tc.wantCmd)
+			}
+			if tc.wantErr {
+				be, ok := err.(*buildererror.Error)
+				if !ok {
+					t.Fatalf("RunBuildTarget(%q, %q) got error of type %T, want *buildererror.Error", tc.tool, tc.target, err)
+				}
+				if be.Status != tc.wantStatus {
+					t.Errorf("RunBuildTarget(%q, %q) got status %v, want %v", tc.tool, tc.target, be.Status, tc.wantStatus)
+				}
+				if !strings.Contains(be.Message, tc.wantOutput) {
+					t.Errorf("RunBuildTarget(%q, %q) got message %q, want it to contain %q", tc.tool, tc.target, be.Message, tc.wantOutput)
+				}
+				return
+			}
+			if !strings.Contains(buf.String(), tc.wantOutput) {
+				t.Errorf("RunBuildTarget(%q, %q) logged %q, want it to contain %q", tc.tool, tc.target, buf.String(), tc.wantOutput)
+			}
+		})
+	}
+}
+
+func TestBuildTargetFromEnv(t *testing.T) {
+	testCases := []struct {
+		name       string
+		makeTarget string
+		task       string
+		wantTool   string
+		wantTarget string
+		wantErr    bool
+	}{
+		{
+			name: "unset",
+		},
+		{
+			name:       "make target",
+			makeTarget: "build",
+			wantTool:   BuildToolMake,
+			wantTarget: "build",
+		},
+		{
+			name:       "task",
+			task:       " release ",
+			wantTool:   BuildToolTask,
+			wantTarget: "release",
+		},
+		{
+			name:       "both",
+			makeTarget: "build",
+			task:       "build",
+			wantErr:    true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(env.MakeTarget, tc.makeTarget)
+			t.Setenv(env.Task, tc.task)
+
+			tool, target, err := BuildTargetFromEnv()
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("BuildTargetFromEnv() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if tool != tc.wantTool || target != tc.wantTarget {
+				t.Errorf("BuildTargetFromEnv() = (%q, %q), want (%q, %q)", tool, target, tc.wantTool, tc.wantTarget)
+			}
+		})
+	}
+}
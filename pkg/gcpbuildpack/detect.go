@@ -25,11 +25,15 @@ import (
 type DetectResult interface {
 	Result() libcnb.DetectResult
 	Reason() string
+	// Metadata returns the metadata, such as the detected language version or framework, that
+	// annotates the build plan of the result.
+	Metadata() map[string]interface{}
 }
 
 type detectResult struct {
-	result libcnb.DetectResult
-	reason string
+	result   libcnb.DetectResult
+	reason   string
+	metadata map[string]interface{}
 }
 
 func (d *detectResult) Result() libcnb.DetectResult {
@@ -40,6 +44,10 @@ func (d *detectResult) Reason() string {
 	return d.reason
 }
 
+func (d *detectResult) Metadata() map[string]interface{} {
+	return d.metadata
+}
+
 // DetectResultOption is a function that returns strings to be hashed when computing a cache key.
 type DetectResultOption func(r *detectResult)
 
@@ -50,6 +58,19 @@ func WithBuildPlans(plans ...libcnb.BuildPlan) DetectResultOption {
 	}
 }
 
+// WithMetadata adds metadata to the detect result, which is written into the build plan before the
+// build, e.g. for platforms that route builds by the detected language version or framework.
+func WithMetadata(metadata map[string]interface{}) DetectResultOption {
+	return func(r *detectResult) {
+		if r.metadata == nil {
+			r.metadata = map[string]interface{}{}
+		}
+		for k, v := range metadata {
+			r.metadata[k] = v
+		}
+	}
+}
+
 // OptIn is used during the detect phase to opt in to the build process.
 func OptIn(reason string, opts ...DetectResultOption) DetectResult {
 	return opt(true, "Opting in: "+reason, opts...)
@@ -97,3 +118,38 @@ func opt(pass bool, reason string, opts ...DetectResultOption) DetectResult {
 	}
 	return r
 }
+
+// annotatePlans adds the metadata to the requirements of the build plans of a passing result, where
+// it does not override the metadata of a requirement. Plans without requirements, or a result
+// without plans, get a requirement of the buildpack id that the buildpack also provides.
+func annotatePlans(r libcnb.DetectResult, metadata map[string]interface{}, id string) libcnb.DetectResult {
+	if !r.Pass || len(metadata) == 0 {
+		return r
+	}
+	plans := r.Plans
+	if len(plans) == 0 {
+		plans = []libcnb.BuildPlan{{}}
+	}
+	r.Plans = make([]libcnb.BuildPlan, len(plans))
+	for i, p := range plans {
+		if len(p.Requires) == 0 {
+			p.Provides = append(append([]libcnb.BuildPlanProvide{}, p.Provides...), libcnb.BuildPlanProvide{Name: id})
+			p.Requires = []libcnb.BuildPlanRequire{{Name: id}}
+		}
+		requires := make([]libcnb.BuildPlanRequire, len(p.Requires))
+		for j, req := range p.Requires {
+			md := map[string]interface{}{}
+			for k, v := range metadata {
+				md[k] = v
+			}
+			for k, v := range req.Metadata {
+				md[k] = v
+			}
+			req.Metadata = md
+			requires[j] = req
+		}
+		p.Requires = requires
+		r.Plans[i] = p
+	}
+	return r
+}
@@ -204,3 +204,90 @@ func TestOptOutVariants(t *testing.T) {
 		t.Errorf(`OptOutEnvNotSet("MY_ENV", opt).Result() = %#v, want %#v`, got, want)
 	}
 }
+
+func TestAnnotatePlans(t *testing.T) {
+	metadata := map[string]interface{}{"version": "3.11.4", "framework": "django"}
+	testCases := []struct {
+		name     string
+		result   libcnb.DetectResult
+		metadata map[string]interface{}
+		want     libcnb.DetectResult
+	}{
+		{
+			name:   "no metadata",
+			result: libcnb.DetectResult{Pass: true},
+			want:   libcnb.DetectResult{Pass: true},
+		},
+		{
+			name:     "opt out",
+			result:   libcnb.DetectResult{Pass: false},
+			metadata: metadata,
+			want:     libcnb.DetectResult{Pass: false},
+		},
+		{
+			name:     "no plans",
+			result:   libcnb.DetectResult{Pass: true},
+			metadata: metadata,
+			want: libcnb.DetectResult{
+				Pass: true,
+				Plans: []libcnb.BuildPlan{{
+					Provides: []libcnb.BuildPlanProvide{{Name: "my-id"}},
+					Requires: []libcnb.BuildPlanRequire{{Name: "my-id", Metadata: metadata}},
+				}},
+			},
+		},
+		{
+			name: "requirement metadata takes precedence",
+			result: libcnb.DetectResult{
+				Pass: true,
+				Plans: []libcnb.BuildPlan{{
+					Requires: []libcnb.BuildPlanRequire{{Name: "python", Metadata: map[string]interface{}{"version": "3.10"}}},
+				}},
+			},
+			metadata: metadata,
+			want: libcnb.DetectResult{
+				Pass: true,
+				Plans: []libcnb.BuildPlan{{
+					Requires: []libcnb.BuildPlanRequire{{Name: "python", Metadata: map[string]interface{}{"version": "3.10", "framework": "django"}}},
+				}},
+			},
+		},
+		{
+			name: "plan without requirements",
+			result: libcnb.DetectResult{
+				Pass: true,
+				Plans: []libcnb.BuildPlan{
+					{Provides: []libcnb.BuildPlanProvide{{Name: "python"}}},
+					{Requires: []libcnb.BuildPlanRequire{{Name: "pip"}}},
+				},
+			},
+			metadata: metadata,
+			want: libcnb.DetectResult{
+				Pass: true,
+				Plans: []libcnb.BuildPlan{
+					{
+						Provides: []libcnb.BuildPlanProvide{{Name: "python"}, {Name: "my-id"}},
+						Requires: []libcnb.BuildPlanRequire{{Name: "my-id", Metadata: metadata}},
+					},
+					{Requires: []libcnb.BuildPlanRequire{{Name: "pip", Metadata: metadata}}},
+				},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := annotatePlans(tc.result, tc.metadata, "my-id"); !reflect.DeepEqual(tc.want, got) {
+				t.Errorf("annotatePlans() = %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWithMetadata(t *testing.T) {
+	result := OptIn("some reason", WithMetadata(map[string]interface{}{"version": "18"}), WithMetadata(map[string]interface{}{"framework": "next"}))
+
+	want := map[string]interface{}{"version": "18", "framework": "next"}
+	if got := result.Metadata(); !reflect.DeepEqual(want, got) {
+		t.Errorf("result.Metadata() = %#v, want %#v", got, want)
+	}
+}
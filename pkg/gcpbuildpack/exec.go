@@ -20,6 +20,8 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -30,6 +32,9 @@ import (
 
 var (
 	divider = strings.Repeat("-", 80)
+
+	// sleep waits between the attempts of ExecWithRetry, it is overridden in tests.
+	sleep = time.Sleep
 )
 
 // ExecResult bundles exec results.
@@ -47,7 +52,10 @@ type execParams struct {
 
 	userFailure     bool
 	userTiming      bool
+	streamOutput    bool
 	messageProducer MessageProducer
+	redact          func(string) string
+	retryable       func(*ExecResult) bool
 }
 
 // ExecOption configures Exec functions.
@@ -60,6 +68,22 @@ func WithEnv(env ...string) ExecOption {
 	}
 }
 
+// WithRedaction applies redact to the command and environment before they are logged, e.g. to
+// hide credentials passed with WithEnv.
+func WithRedaction(redact func(string) string) ExecOption {
+	return func(o *execParams) {
+		o.redact = redact
+	}
+}
+
+// WithRetryIf sets the predicate that decides whether ExecWithRetry retries a failed attempt, given
+// its result. By default, every attempt that exits with a non-zero exit code is retried.
+func WithRetryIf(retryable func(result *ExecResult) bool) ExecOption {
+	return func(o *execParams) {
+		o.retryable = retryable
+	}
+}
+
 // WithWorkDir sets a specific working directory.
 func WithWorkDir(dir string) ExecOption {
 	return func(o *execParams) {
@@ -83,6 +107,13 @@ var WithUserFailureAttribution = func(o *execParams) {
 	o.userFailure = true
 }
 
+// WithStreamedOutput logs each line of the combined stdout/stderr as soon as it is written, even
+// for commands whose output is otherwise only logged in debug mode. The full output is still
+// returned in the ExecResult.
+var WithStreamedOutput = func(o *execParams) {
+	o.streamOutput = true
+}
+
 // WithMessageProducer sets a custom MessageProducer to produce the error message.
 func WithMessageProducer(mp MessageProducer) ExecOption {
 	return func(o *execParams) {
@@ -158,6 +189,35 @@ func (ctx *Context) ExecWithErr(cmd []string, opts ...ExecOption) (*ExecResult,
 	return result, be
 }
 
+// ExecWithRetry runs the given command like ExecWithErr, making up to attempts attempts while it
+// exits with a non-zero exit code and the predicate set with WithRetryIf accepts the failure. The
+// delay between attempts starts at backoff and doubles after each attempt. If the command still
+// fails, the error holds the output of the last attempt.
+func (ctx *Context) ExecWithRetry(cmd []string, attempts int, backoff time.Duration, opts ...ExecOption) (*ExecResult, *buildererror.Error) {
+	params := execParams{}
+	for _, o := range opts {
+		o(&params)
+	}
+	for attempt := 1; ; attempt++ {
+		result, err := ctx.ExecWithErr(cmd, opts...)
+		if err == nil {
+			return result, nil
+		}
+		if result == nil || result.ExitCode == 0 || (params.retryable != nil && !params.retryable(result)) {
+			return result, err
+		}
+		if attempt >= attempts {
+			if attempt > 1 {
+				err.Message = fmt.Sprintf("failed after %d attempts: %s", attempt, err.Message)
+			}
+			return result, err
+		}
+		ctx.Logf("Attempt %d of %d failed with exit code %d, retrying in %v.", attempt, attempts, result.ExitCode, backoff)
+		sleep(backoff)
+		backoff *= 2
+	}
+}
+
 func (ctx *Context) configuredExec(params execParams) (*ExecResult, error) {
 	if len(params.cmd) < 1 {
 		return nil, fmt.Errorf("no command provided")
@@ -181,18 +241,30 @@ func (ctx *Context) configuredExec(params execParams) (*ExecResult, error) {
 
 	readableCmd := strings.Join(params.cmd, " ")
 	if len(params.env) > 0 {
-		env := strings.Join(params.env, " ")
-		readableCmd = fmt.Sprintf("%s (%s)", readableCmd, env)
+		env := params.env
+		if ctx.debug {
+			// Debug mode also logs system commands, which may be passed credentials.
+			env = make([]string, len(params.env))
+			for i, kv := range params.env {
+				env[i] = RedactEnv(kv)
+			}
+		}
+		readableCmd = fmt.Sprintf("%s (%s)", readableCmd, strings.Join(env, " "))
+	}
+	if params.redact != nil {
+		readableCmd = params.redact(readableCmd)
 	}
 	optionalLogf(divider)
 	optionalLogf("Running %q", readableCmd)
+	ctx.debugExecEnvironment(params)
 
 	status := buildererror.StatusInternal
+	truncated := readableCmd
+	if len(truncated) > 60 {
+		truncated = truncated[:60] + "..."
+	}
+	defer ctx.Timer(fmt.Sprintf("Exec %q", truncated))()
 	defer func(start time.Time) {
-		truncated := readableCmd
-		if len(truncated) > 60 {
-			truncated = truncated[:60] + "..."
-		}
 		optionalLogf("Done %q (%v)", truncated, time.Since(start))
 		ctx.Span(ctx.createSpanName(params.cmd), start, status)
 	}(time.Now())
@@ -210,6 +282,14 @@ func (ctx *Context) configuredExec(params execParams) (*ExecResult, error) {
 
 	var outb, errb bytes.Buffer
 	combinedb := lockingBuffer{log: shouldLog}
+	if shouldLog && ctx.buildLog != nil {
+		combinedb.tee = ctx.buildLog
+	}
+	if params.streamOutput {
+		// The logger writes to stderr and the build log, if any.
+		combinedb = lockingBuffer{logLine: ctx.logger.Print}
+		defer combinedb.flush()
+	}
 	ecmd.Stdout = io.MultiWriter(&outb, &combinedb)
 	ecmd.Stderr = io.MultiWriter(&errb, &combinedb)
 
@@ -253,6 +333,12 @@ type lockingBuffer struct {
 
 	// log tells the buffer to also log the output to stderr.
 	log bool
+	// tee, if set, receives a copy of the logged output.
+	tee io.Writer
+	// logLine, if set, is called with each complete line of output as it is written.
+	logLine func(v ...interface{})
+	// partial is the incomplete last line of output that has not been passed to logLine yet.
+	partial []byte
 }
 
 func (lb *lockingBuffer) Write(p []byte) (int, error) {
@@ -261,9 +347,85 @@ func (lb *lockingBuffer) Write(p []byte) (int, error) {
 	if lb.log {
 		os.Stderr.Write(p)
 	}
+	if lb.tee != nil {
+		lb.tee.Write(p)
+	}
+	if lb.logLine != nil {
+		lb.partial = append(lb.partial, p...)
+		for {
+			i := bytes.IndexByte(lb.partial, '\n')
+			if i < 0 {
+				break
+			}
+			lb.logLine(strings.TrimSuffix(string(lb.partial[:i]), "\r"))
+			lb.partial = lb.partial[i+1:]
+		}
+	}
 	return lb.buf.Write(p)
 }
 
+// flush passes the incomplete last line of output, if any, to logLine.
+func (lb *lockingBuffer) flush() {
+	lb.Lock()
+	defer lb.Unlock()
+	if lb.logLine != nil && len(lb.partial) > 0 {
+		lb.logLine(string(lb.partial))
+		lb.partial = nil
+	}
+}
+
 func (lb *lockingBuffer) Bytes() []byte {
 	return lb.buf.Bytes()
 }
+
+// debugExecEnvironment logs the working directory and the environment of a command in debug mode.
+// The inherited environment is listed by name only, once per buildpack. The values set for the
+// command are redacted as in RedactEnv, then the redaction of the command, if any, is applied.
+func (ctx *Context) debugExecEnvironment(params execParams) {
+	if !ctx.debug {
+		return
+	}
+	dir := params.dir
+	if dir == "" {
+		if wd, err := os.Getwd(); err == nil {
+			dir = wd
+		}
+	}
+	ctx.Debugf("Working directory: %s", dir)
+	if !ctx.execEnvLogged {
+		var names []string
+		for _, kv := range os.Environ() {
+			names = append(names, strings.SplitN(kv, "=", 2)[0])
+		}
+		sort.Strings(names)
+		ctx.Debugf("Inherited environment: %s", strings.Join(names, " "))
+		ctx.execEnvLogged = true
+	}
+	for _, kv := range params.env {
+		kv = RedactEnv(kv)
+		if params.redact != nil {
+			kv = params.redact(kv)
+		}
+		ctx.Debugf("Environment: %s", kv)
+	}
+}
+
+var (
+	// secretEnvNameRegexp matches the names of environment variables that likely hold secrets.
+	secretEnvNameRegexp = regexp.MustCompile(`(?i)(TOKEN|SECRET|PASSWORD|PASSWD|CREDENTIAL|API_?KEY|PRIVATE_?KEY|AUTH)`)
+	// urlUserInfoRegexp matches the user information of a URL, e.g. user:password@.
+	urlUserInfoRegexp = regexp.MustCompile(`(://)[^/@\s]+@`)
+)
+
+// RedactEnv redacts the value of an environment variable of the form "KEY=value" if its name
+// suggests that it holds a secret, and the user information of URLs in the value otherwise.
+func RedactEnv(kv string) string {
+	parts := strings.SplitN(kv, "=", 2)
+	if len(parts) != 2 {
+		return kv
+	}
+	if secretEnvNameRegexp.MatchString(parts[0]) && parts[1] != "" {
+		return parts[0] + "=REDACTED"
+	}
+	return parts[0] + "=" + urlUserInfoRegexp.ReplaceAllString(parts[1], "${1}REDACTED@")
+}
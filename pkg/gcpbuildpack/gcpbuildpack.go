@@ -24,6 +24,8 @@ import (
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -53,6 +55,8 @@ const (
 
 	// WebProcess is the name of the default web process.
 	WebProcess = "web"
+	// JobProcess is the name of the default process of jobs, see env.AppType.
+	JobProcess = "job"
 )
 
 var (
@@ -67,8 +71,9 @@ type DetectFn func(*Context) (DetectResult, error)
 type BuildFn func(*Context) error
 
 type stats struct {
-	spans []*spanInfo
-	user  time.Duration
+	spans   []*spanInfo
+	user    time.Duration
+	timings []timing
 }
 
 // Context provides contextually aware functions for buildpack authors.
@@ -77,10 +82,14 @@ type Context struct {
 	applicationRoot string
 	buildpackRoot   string
 	debug           bool
+	execEnvLogged   bool
 	logger          *log.Logger
 	stats           stats
 	exiter          Exiter
 	warnings        []string
+	buildLog        *os.File
+	versions        []VersionEntry
+	tempDirs        []string
 
 	// detect items
 	detectContext libcnb.DetectContext
@@ -201,6 +210,11 @@ func (ctx *Context) BuildpackRoot() string {
 	return ctx.buildpackRoot
 }
 
+// LayersDir returns the folder that holds the layers of the buildpack.
+func (ctx *Context) LayersDir() string {
+	return ctx.buildContext.Layers.Path
+}
+
 // StackID returns the stack id.
 func (ctx *Context) StackID() string {
 	return ctx.buildContext.StackID
@@ -240,7 +254,10 @@ func (gcpd gcpdetector) Detect(ldctx libcnb.DetectContext) (libcnb.DetectResult,
 		ctx.Span(fmt.Sprintf("Buildpack Detect %s", ctx.info.ID), now, status)
 	}(time.Now())
 
+	stop := ctx.Timer("Detect")
 	result, err := gcpd.detectFn(ctx)
+	stop()
+	ctx.logTimingSummary(ctx.Debugf)
 	if err != nil {
 		msg := fmt.Sprintf("Failed to run /bin/detect: %v", err)
 		var be *buildererror.Error
@@ -257,7 +274,7 @@ func (gcpd gcpdetector) Detect(ldctx libcnb.DetectContext) (libcnb.DetectResult,
 
 	status = buildererror.StatusOk
 	ctx.Logf(result.Reason())
-	return result.Result(), nil
+	return annotatePlans(result.Result(), result.Metadata(), ctx.info.ID), nil
 }
 
 // detect implements the /bin/detect phase of the buildpack.
@@ -273,6 +290,15 @@ type gcpbuilder struct {
 func (gcpb gcpbuilder) Build(lbctx libcnb.BuildContext) (libcnb.BuildResult, error) {
 	start := time.Now()
 	ctx := newBuildContext(lbctx)
+	if retain, err := env.IsPresentAndTrue(env.RetainBuildLog); err != nil {
+		ctx.Warnf("Not retaining the build log: %v", err)
+	} else if retain {
+		if err := ctx.RetainBuildLog(); err != nil {
+			ctx.Warnf("Not retaining the build log: %v", err)
+		}
+	}
+	defer ctx.closeBuildLog()
+	defer ctx.removeTempDirs()
 	ctx.Logf("=== %s (%s@%s) ===", ctx.BuildpackName(), ctx.BuildpackID(), ctx.BuildpackVersion())
 
 	status := buildererror.StatusInternal
@@ -280,7 +306,17 @@ func (gcpb gcpbuilder) Build(lbctx libcnb.BuildContext) (libcnb.BuildResult, err
 		ctx.Span(fmt.Sprintf("Buildpack Build %s", ctx.BuildpackID()), now, status)
 	}(time.Now())
 
-	if err := gcpb.buildFn(ctx); err != nil {
+	stop := ctx.Timer("Build")
+	umask, err := ctx.applyBuildUmask()
+	if err == nil {
+		err = gcpb.buildFn(ctx)
+	}
+	if err == nil && umask {
+		err = ctx.normalizeLayerPermissions()
+	}
+	stop()
+	ctx.logTimingSummary(ctx.Logf)
+	if err != nil {
 		msg := fmt.Sprintf("Failed to run /bin/build: %v", err)
 		var be *buildererror.Error
 		if errors.As(err, &be) {
@@ -290,6 +326,10 @@ func (gcpb gcpbuilder) Build(lbctx libcnb.BuildContext) (libcnb.BuildResult, err
 		ctx.Exit(1, buildererror.Errorf(status, msg))
 	}
 
+	if err := ctx.saveVersionsReport(); err != nil {
+		ctx.Warnf("Failed to save the versions report: %v", err)
+	}
+
 	status = buildererror.StatusOk
 	ctx.saveSuccessOutput(time.Since(start))
 	return ctx.buildResult, nil
@@ -307,6 +347,8 @@ func build(buildFn BuildFn) {
 
 // Exit causes the buildpack to exit with the given exit code and message.
 func (ctx *Context) Exit(exitCode int, be *buildererror.Error) {
+	// The exiter usually does not return, so deferred cleanups do not run.
+	ctx.removeTempDirs()
 	ctx.exiter.Exit(exitCode, be)
 }
 
@@ -329,6 +371,20 @@ func (ctx *Context) Warnf(format string, args ...interface{}) {
 	ctx.Logf("WARNING: "+format, args...)
 }
 
+// StrictWarnf emits a warning about an outdated package manager or dependency. If GOOGLE_STRICT_DEPS
+// is true, the warning is returned as a user error instead so that the build fails.
+func (ctx *Context) StrictWarnf(format string, args ...interface{}) error {
+	strict, err := env.IsPresentAndTrue(env.StrictDeps)
+	if err != nil {
+		return UserErrorf("%v", err)
+	}
+	if strict {
+		return UserErrorf("%s Failing the build because %s is true.", fmt.Sprintf(format, args...), env.StrictDeps)
+	}
+	ctx.Warnf(format, args...)
+	return nil
+}
+
 // Tipf emits a structured logging line for usage tips.
 func (ctx *Context) Tipf(format string, args ...interface{}) {
 	// Tips are only displayed for the gcp/base builder, not in GAE/GCF environments.
@@ -371,8 +427,23 @@ func (ctx *Context) AddBOMEntry(entry libcnb.BOMEntry) {
 }
 
 // AddWebProcess adds the given command as the web start process, overwriting any previous web start process.
+// The web process is executed directly and is the default process. Jobs, which run to completion
+// instead of serving requests, have no web process, so it is not added if GOOGLE_APP_TYPE is job.
 func (ctx *Context) AddWebProcess(cmd []string) {
-	ctx.AddProcess(WebProcess, cmd, AsDirectProcess(), AsDefaultProcess())
+	if env.IsJob() {
+		ctx.Debugf("Skipping %s process %q because %s is %s.", WebProcess, cmd, env.AppType, env.AppTypeJob)
+		return
+	}
+	current := ctx.buildResult.Processes
+	ctx.buildResult.Processes = []libcnb.Process{}
+	for _, p := range current {
+		if p.Type == WebProcess {
+			ctx.Debugf("Overwriting existing %s process %q.", WebProcess, p.Command)
+			continue // Do not add this item back to the ctx.processes; we are overwriting it.
+		}
+		ctx.buildResult.Processes = append(ctx.buildResult.Processes, p)
+	}
+	ctx.appendProcess(newProcess(WebProcess, cmd, AsDirectProcess(), AsDefaultProcess()))
 }
 
 // processOption configures the AddProcess function.
@@ -384,21 +455,27 @@ func AsDirectProcess() processOption {
 }
 
 // AsDefaultProcess marks the process as the default one for when launcher is invoked without arguments.
+// There is only one default process, the last process marked as default is used.
 func AsDefaultProcess() processOption {
 	return func(o *libcnb.Process) { o.Default = true }
 }
 
-// AddProcess adds the given command as named process, overwriting any previous process with the same name.
-func (ctx *Context) AddProcess(name string, cmd []string, opts ...processOption) {
-	current := ctx.buildResult.Processes
-	ctx.buildResult.Processes = []libcnb.Process{}
-	for _, p := range current {
+// AddProcess adds the given command as named process. It returns an error if a process with the
+// same name was already added; use AddWebProcess to overwrite the web process.
+func (ctx *Context) AddProcess(name string, cmd []string, opts ...processOption) error {
+	if len(cmd) == 0 {
+		return InternalErrorf("no command given for the %s process", name)
+	}
+	for _, p := range ctx.buildResult.Processes {
 		if p.Type == name {
-			ctx.Debugf("Overwriting existing %s process %q.", name, p.Command)
-			continue // Do not add this item back to the ctx.processes; we are overwriting it.
+			return InternalErrorf("duplicate %s process: %q is already defined", name, p.Command)
 		}
-		ctx.buildResult.Processes = append(ctx.buildResult.Processes, p)
 	}
+	ctx.appendProcess(newProcess(name, cmd, opts...))
+	return nil
+}
+
+func newProcess(name string, cmd []string, opts ...processOption) libcnb.Process {
 	p := libcnb.Process{
 		Type:    name,
 		Command: cmd[0],
@@ -409,6 +486,20 @@ func (ctx *Context) AddProcess(name string, cmd []string, opts ...processOption)
 	for _, opt := range opts {
 		opt(&p)
 	}
+	return p
+}
+
+// appendProcess appends p to the launch processes. If p is the default process, the processes
+// that were added before are no longer the default.
+func (ctx *Context) appendProcess(p libcnb.Process) {
+	if p.Default {
+		for i := range ctx.buildResult.Processes {
+			if ctx.buildResult.Processes[i].Default {
+				ctx.Debugf("Replacing default %s process with %s process.", ctx.buildResult.Processes[i].Type, p.Type)
+				ctx.buildResult.Processes[i].Default = false
+			}
+		}
+	}
 	ctx.buildResult.Processes = append(ctx.buildResult.Processes, p)
 }
 
@@ -421,6 +512,38 @@ func (ctx *Context) HTTPStatus(url string) (int, error) {
 	return res.StatusCode, nil
 }
 
+// ExposePorts records the ports, in addition to $PORT, that the application listens on in the
+// google.expose-ports label of the application image, so that the platform can configure
+// networking for them. The ports are sorted and deduplicated. It returns a user error if a port is
+// not a valid port number.
+func (ctx *Context) ExposePorts(ports []int) error {
+	if len(ports) == 0 {
+		return nil
+	}
+	if env.IsJob() {
+		ctx.Warnf("Ignoring the exposed ports %v because %s is %s, jobs do not serve requests.", ports, env.AppType, env.AppTypeJob)
+		return nil
+	}
+	seen := map[int]bool{}
+	var sorted []int
+	for _, p := range ports {
+		if p < 1 || p > 65535 {
+			return UserErrorf("invalid port %d, ports must be between 1 and 65535", p)
+		}
+		if !seen[p] {
+			seen[p] = true
+			sorted = append(sorted, p)
+		}
+	}
+	sort.Ints(sorted)
+	var values []string
+	for _, p := range sorted {
+		values = append(values, strconv.Itoa(p))
+	}
+	ctx.AddLabel("expose-ports", strings.Join(values, ","))
+	return nil
+}
+
 // AddLabel adds a label to the user's application container.
 func (ctx *Context) AddLabel(key, value string) {
 	if !labelKeyRegexp.MatchString(key) {
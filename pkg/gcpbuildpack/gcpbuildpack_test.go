@@ -15,8 +15,10 @@
 package gcpbuildpack
 
 import (
+	"bytes"
 	"encoding/json"
 	"io/ioutil"
+	"log"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -170,6 +172,25 @@ func TestDetectNilResult(t *testing.T) {
 	}
 }
 
+func TestDetectWritesMetadataToBuildPlan(t *testing.T) {
+	gcpd := gcpdetector{detectFn: func(c *Context) (DetectResult, error) {
+		return OptIn("some reason", WithMetadata(map[string]interface{}{"version": "3.11.4"})), nil
+	}}
+	ldctx := libcnb.DetectContext{Buildpack: libcnb.Buildpack{Info: libcnb.BuildpackInfo{ID: "my-id"}}}
+
+	result, err := gcpd.Detect(ldctx)
+	if err != nil {
+		t.Fatalf("Detect() got error: %v", err)
+	}
+	want := []libcnb.BuildPlan{{
+		Provides: []libcnb.BuildPlanProvide{{Name: "my-id"}},
+		Requires: []libcnb.BuildPlanRequire{{Name: "my-id", Metadata: map[string]interface{}{"version": "3.11.4"}}},
+	}}
+	if !reflect.DeepEqual(want, result.Plans) {
+		t.Errorf("Detect().Plans = %#v, want %#v", result.Plans, want)
+	}
+}
+
 func TestBuildContextInitialized(t *testing.T) {
 	setUpBuildEnvironment(t)
 
@@ -257,6 +278,59 @@ func TestBuildEmitsSuccessOutput(t *testing.T) {
 	}
 }
 
+func TestStrictWarnf(t *testing.T) {
+	testCases := []struct {
+		name        string
+		strictDeps  string
+		wantErr     bool
+		wantWarning bool
+	}{
+		{
+			name:        "unset",
+			wantWarning: true,
+		},
+		{
+			name:        "false",
+			strictDeps:  "false",
+			wantWarning: true,
+		},
+		{
+			name:       "true",
+			strictDeps: "true",
+			wantErr:    true,
+		},
+		{
+			name:       "invalid",
+			strictDeps: "sometimes",
+			wantErr:    true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.strictDeps != "" {
+				t.Setenv(env.StrictDeps, tc.strictDeps)
+			}
+			var buf bytes.Buffer
+			ctx := NewContext(WithLogger(log.New(&buf, "", 0)))
+
+			err := ctx.StrictWarnf("npm %s is outdated.", "5.6.0")
+
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("StrictWarnf() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if tc.wantErr && tc.strictDeps == "true" && !strings.Contains(err.Error(), "npm 5.6.0 is outdated.") {
+				t.Errorf("StrictWarnf() got error %q, want it to contain the warning", err)
+			}
+			if gotWarning := strings.Contains(buf.String(), "WARNING: npm 5.6.0 is outdated."); gotWarning != tc.wantWarning {
+				t.Errorf("StrictWarnf() logged warning: %t, want %t, output:\n%s", gotWarning, tc.wantWarning, buf.String())
+			}
+			if got := len(ctx.warnings); tc.wantWarning && got != 1 {
+				t.Errorf("StrictWarnf() recorded %d warnings, want 1", got)
+			}
+		})
+	}
+}
+
 func TestAddWebProcess(t *testing.T) {
 	ctx := NewContext()
 	ctx.AddWebProcess([]string{"/start"})
@@ -267,6 +341,40 @@ func TestAddWebProcess(t *testing.T) {
 	}
 }
 
+func TestJobHasNoWebProcessOrPorts(t *testing.T) {
+	t.Setenv(env.AppType, env.AppTypeJob)
+	ctx := NewContext()
+
+	ctx.AddWebProcess([]string{"/start"})
+	if err := ctx.ExposePorts([]int{8080}); err != nil {
+		t.Fatalf("ExposePorts() got error: %v", err)
+	}
+
+	if len(ctx.buildResult.Processes) != 0 {
+		t.Errorf("Processes got %#v, want no web process for a job", ctx.buildResult.Processes)
+	}
+	if len(ctx.buildResult.Labels) != 0 {
+		t.Errorf("Labels got %#v, want no exposed ports for a job", ctx.buildResult.Labels)
+	}
+}
+
+func TestAddWebProcessOverwrites(t *testing.T) {
+	ctx := NewContext()
+	if err := ctx.AddProcess("worker", []string{"/worker"}, AsDefaultProcess()); err != nil {
+		t.Fatalf("AddProcess() got error: %v", err)
+	}
+	ctx.AddWebProcess([]string{"/start"})
+	ctx.AddWebProcess([]string{"/OVERRIDE"})
+	want := []libcnb.Process{
+		libcnb.Process{Command: "/worker", Type: "worker"},
+		proc("/OVERRIDE", "web"),
+	}
+
+	if !reflect.DeepEqual(ctx.buildResult.Processes, want) {
+		t.Errorf("Processes not equal got %#v, want %#v", ctx.buildResult.Processes, want)
+	}
+}
+
 func TestAddProcess(t *testing.T) {
 	testCases := []struct {
 		desc    string
@@ -275,6 +383,7 @@ func TestAddProcess(t *testing.T) {
 		opts    []processOption
 		initial []libcnb.Process
 		want    []libcnb.Process
+		wantErr bool
 	}{
 		{
 			desc: "no args, no processes",
@@ -299,7 +408,7 @@ func TestAddProcess(t *testing.T) {
 			},
 		},
 		{
-			desc: "override existing",
+			desc: "duplicate name",
 			name: "web",
 			cmd:  []string{"/OVERRIDE"},
 			initial: []libcnb.Process{
@@ -309,8 +418,27 @@ func TestAddProcess(t *testing.T) {
 			},
 			want: []libcnb.Process{
 				libcnb.Process{Command: "/dev", Type: "dev"},
+				libcnb.Process{Command: "/web", Type: "web"},
 				libcnb.Process{Command: "/cli", Type: "cli"},
-				libcnb.Process{Command: "/OVERRIDE", Type: "web"},
+			},
+			wantErr: true,
+		},
+		{
+			desc:    "no command",
+			name:    "web",
+			wantErr: true,
+		},
+		{
+			desc: "replaces default",
+			name: "worker",
+			cmd:  []string{"/worker"},
+			opts: []processOption{AsDefaultProcess()},
+			initial: []libcnb.Process{
+				libcnb.Process{Command: "/web", Type: "web", Default: true},
+			},
+			want: []libcnb.Process{
+				libcnb.Process{Command: "/web", Type: "web"},
+				libcnb.Process{Command: "/worker", Type: "worker", Default: true},
 			},
 		},
 		{
@@ -363,8 +491,10 @@ func TestAddProcess(t *testing.T) {
 			ctx := NewContext()
 			ctx.buildResult.Processes = tc.initial
 
-			ctx.AddProcess(tc.name, tc.cmd, tc.opts...)
-
+			err := ctx.AddProcess(tc.name, tc.cmd, tc.opts...)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("AddProcess(%q) got error: %v, want error: %t", tc.name, err, tc.wantErr)
+			}
 			if !reflect.DeepEqual(ctx.buildResult.Processes, tc.want) {
 				t.Errorf("Processes not equal got %#v, want %#v", ctx.buildResult.Processes, tc.want)
 			}
@@ -428,6 +558,57 @@ func TestAddLabel(t *testing.T) {
 	}
 }
 
+func TestExposePorts(t *testing.T) {
+	testCases := []struct {
+		name    string
+		ports   []int
+		want    []libcnb.Label
+		wantErr bool
+	}{
+		{
+			name: "no ports",
+		},
+		{
+			name:  "single port",
+			ports: []int{9090},
+			want:  []libcnb.Label{{Key: "google.expose-ports", Value: "9090"}},
+		},
+		{
+			name:  "sorted and deduplicated",
+			ports: []int{9090, 8081, 9090, 1, 65535},
+			want:  []libcnb.Label{{Key: "google.expose-ports", Value: "1,8081,9090,65535"}},
+		},
+		{
+			name:    "zero",
+			ports:   []int{8080, 0},
+			wantErr: true,
+		},
+		{
+			name:    "too large",
+			ports:   []int{65536},
+			wantErr: true,
+		},
+		{
+			name:    "negative",
+			ports:   []int{-80},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := NewContext()
+
+			err := ctx.ExposePorts(tc.ports)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("ExposePorts(%v) got error: %v, want error: %t", tc.ports, err, tc.wantErr)
+			}
+			if !reflect.DeepEqual(ctx.buildResult.Labels, tc.want) {
+				t.Errorf("ExposePorts(%v) labels got %#v, want %#v", tc.ports, ctx.buildResult.Labels, tc.want)
+			}
+		})
+	}
+}
+
 func TestAddLabelErrors(t *testing.T) {
 	invalids := []string{"", "0", "00invalid", "abc def", "abd@def", "  abc", "def  ", "a__b"}
 
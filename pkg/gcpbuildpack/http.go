@@ -0,0 +1,208 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// progressInterval is the minimum time between two progress reports of HTTPGet, it is overridden
+// in tests.
+var progressInterval = 5 * time.Second
+
+// HTTPClientError is returned by HTTPGet if the server responds with a 4xx status code, e.g. if the
+// requested artifact does not exist.
+type HTTPClientError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *HTTPClientError) Error() string {
+	return fmt.Sprintf("fetching %s returned HTTP status %d", e.URL, e.StatusCode)
+}
+
+// HTTPServerError is returned by HTTPGet if the server responds with a 5xx status code, which is
+// often intermittent.
+type HTTPServerError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *HTTPServerError) Error() string {
+	return fmt.Sprintf("fetching %s returned HTTP status %d", e.URL, e.StatusCode)
+}
+
+// ProgressFunc receives the number of bytes of a download written so far and its total size, or -1
+// if the size is unknown.
+type ProgressFunc func(written, total int64)
+
+type httpGetParams struct {
+	maxSize  int64
+	progress ProgressFunc
+}
+
+// HTTPGetOption configures HTTPGet.
+type HTTPGetOption func(o *httpGetParams)
+
+// WithMaxSize limits the size of the downloaded file to maxSize bytes.
+func WithMaxSize(maxSize int64) HTTPGetOption {
+	return func(o *httpGetParams) {
+		o.maxSize = maxSize
+	}
+}
+
+// WithProgress sets the function that periodically receives the progress of the download, instead
+// of logging it.
+func WithProgress(progress ProgressFunc) HTTPGetOption {
+	return func(o *httpGetParams) {
+		o.progress = progress
+	}
+}
+
+// HTTPGet downloads the content at url into the file dest. If dest already holds the beginning of
+// the content, e.g. from an interrupted download, only the rest is requested with a Range header.
+// The progress is logged periodically. A 4xx or 5xx response is returned as an HTTPClientError or
+// HTTPServerError respectively.
+func (ctx *Context) HTTPGet(url, dest string, opts ...HTTPGetOption) error {
+	params := httpGetParams{progress: ctx.logProgress(url)}
+	for _, o := range opts {
+		o(&params)
+	}
+
+	var offset int64
+	if fi, err := os.Stat(dest); err == nil && fi.Mode().IsRegular() {
+		offset = fi.Size()
+	}
+	res, err := httpGet(url, offset)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		// The partial file does not match the content, download it again.
+		res.Body.Close()
+		offset = 0
+		if res, err = httpGet(url, 0); err != nil {
+			return err
+		}
+		defer res.Body.Close()
+	}
+	switch {
+	case res.StatusCode >= 400 && res.StatusCode < 500:
+		return &HTTPClientError{URL: url, StatusCode: res.StatusCode}
+	case res.StatusCode >= 500:
+		return &HTTPServerError{URL: url, StatusCode: res.StatusCode}
+	case res.StatusCode != http.StatusOK && res.StatusCode != http.StatusPartialContent:
+		return InternalErrorf("fetching %s returned unexpected HTTP status %d", url, res.StatusCode)
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if res.StatusCode == http.StatusPartialContent {
+		ctx.Debugf("Resuming the download of %s at byte %d.", url, offset)
+		flags = os.O_WRONLY | os.O_APPEND
+	} else {
+		offset = 0
+	}
+	total := int64(-1)
+	if res.ContentLength >= 0 {
+		total = offset + res.ContentLength
+	}
+	if params.maxSize > 0 && total > params.maxSize {
+		return UserErrorf("%s is %d bytes, which exceeds the maximum size of %d bytes", url, total, params.maxSize)
+	}
+
+	f, err := os.OpenFile(dest, flags, 0644)
+	if err != nil {
+		return InternalErrorf("opening %s: %v", dest, err)
+	}
+	pw := &progressWriter{w: f, written: offset, total: total, maxSize: params.maxSize, progress: params.progress, last: time.Now()}
+	_, err = io.Copy(pw, res.Body)
+	if cerr := f.Close(); err == nil && cerr != nil {
+		err = cerr
+	}
+	if err == errMaxSize {
+		if err := os.Remove(dest); err != nil {
+			ctx.Warnf("Failed to remove %s: %v", dest, err)
+		}
+		return UserErrorf("%s exceeds the maximum size of %d bytes", url, params.maxSize)
+	}
+	if err != nil {
+		// The partial file is kept, so that the download can be resumed.
+		return InternalErrorf("downloading %s to %s: %v", url, dest, err)
+	}
+	if pw.reported != pw.written {
+		params.progress(pw.written, total)
+	}
+	return nil
+}
+
+func httpGet(url string, offset int64) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, UserErrorf("fetching %s: %v", url, err)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, InternalErrorf("requesting %s: %v", url, err)
+	}
+	return res, nil
+}
+
+// logProgress returns a ProgressFunc that logs the progress of downloading url.
+func (ctx *Context) logProgress(url string) ProgressFunc {
+	return func(written, total int64) {
+		if total <= 0 {
+			ctx.Logf("Downloaded %d bytes of %s.", written, url)
+			return
+		}
+		ctx.Logf("Downloaded %d of %d bytes (%d%%) of %s.", written, total, written*100/total, url)
+	}
+}
+
+var errMaxSize = fmt.Errorf("maximum size exceeded")
+
+// progressWriter counts the bytes written to w and reports them to progress at most once per
+// progressInterval.
+type progressWriter struct {
+	w        io.Writer
+	written  int64
+	total    int64
+	maxSize  int64
+	progress ProgressFunc
+	// last is the time of the last progress report, reported the number of bytes it reported.
+	last     time.Time
+	reported int64
+}
+
+func (pw *progressWriter) Write(p []byte) (int, error) {
+	if pw.maxSize > 0 && pw.written+int64(len(p)) > pw.maxSize {
+		return 0, errMaxSize
+	}
+	n, err := pw.w.Write(p)
+	pw.written += int64(n)
+	if now := time.Now(); now.Sub(pw.last) >= progressInterval {
+		pw.last, pw.reported = now, pw.written
+		pw.progress(pw.written, pw.total)
+	}
+	return n, err
+}
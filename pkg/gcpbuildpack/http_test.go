@@ -0,0 +1,217 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// httpContent is larger than the 32KiB buffer of io.Copy, so that it is written in several chunks.
+var httpContent = bytes.Repeat([]byte("0123456789abcdef"), 8*1024)
+
+func newContentServer(t *testing.T, ranges *[]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		case "/broken":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			if ranges != nil {
+				*ranges = append(*ranges, r.Header.Get("Range"))
+			}
+			http.ServeContent(w, r, "content", time.Time{}, bytes.NewReader(httpContent))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestHTTPGet(t *testing.T) {
+	defer func(interval time.Duration) { progressInterval = interval }(progressInterval)
+	progressInterval = 0
+	server := newContentServer(t, nil)
+	dest := filepath.Join(t.TempDir(), "content")
+
+	var reports [][2]int64
+	progress := func(written, total int64) { reports = append(reports, [2]int64{written, total}) }
+	if err := NewContext().HTTPGet(server.URL+"/content", dest, WithProgress(progress)); err != nil {
+		t.Fatalf("HTTPGet() got error: %v", err)
+	}
+
+	got, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatalf("reading %s: %v", dest, err)
+	}
+	if !bytes.Equal(got, httpContent) {
+		t.Errorf("HTTPGet() wrote %d bytes, want %d bytes of content", len(got), len(httpContent))
+	}
+	if len(reports) < 2 {
+		t.Fatalf("HTTPGet() reported progress %d times, want several reports: %v", len(reports), reports)
+	}
+	for i, r := range reports {
+		if r[1] != int64(len(httpContent)) {
+			t.Errorf("progress report %d total=%d, want %d", i, r[1], len(httpContent))
+		}
+		if i > 0 && r[0] <= reports[i-1][0] {
+			t.Errorf("progress report %d written=%d, want more than %d", i, r[0], reports[i-1][0])
+		}
+	}
+	if last := reports[len(reports)-1]; last[0] != int64(len(httpContent)) {
+		t.Errorf("last progress report written=%d, want %d", last[0], len(httpContent))
+	}
+}
+
+func TestHTTPGetLogsProgress(t *testing.T) {
+	server := newContentServer(t, nil)
+	dest := filepath.Join(t.TempDir(), "content")
+	var buf bytes.Buffer
+	ctx := NewContext(WithLogger(log.New(&buf, "", 0)))
+
+	if err := ctx.HTTPGet(server.URL+"/content", dest); err != nil {
+		t.Fatalf("HTTPGet() got error: %v", err)
+	}
+	if want := "Downloaded 131072 of 131072 bytes (100%)"; !strings.Contains(buf.String(), want) {
+		t.Errorf("HTTPGet() logged %q, want it to contain %q", buf.String(), want)
+	}
+}
+
+func TestHTTPGetMaxSize(t *testing.T) {
+	testCases := []struct {
+		name    string
+		maxSize int64
+		wantErr bool
+	}{
+		{
+			name:    "below limit",
+			maxSize: int64(len(httpContent)) + 1,
+		},
+		{
+			name:    "at limit",
+			maxSize: int64(len(httpContent)),
+		},
+		{
+			name:    "above limit",
+			maxSize: int64(len(httpContent)) - 1,
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := newContentServer(t, nil)
+			dest := filepath.Join(t.TempDir(), "content")
+
+			err := NewContext().HTTPGet(server.URL+"/content", dest, WithMaxSize(tc.maxSize))
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("HTTPGet(maxSize=%d) got err=%t, want err=%t. err: %v", tc.maxSize, gotErr, tc.wantErr, err)
+			}
+			if _, err := os.Stat(dest); tc.wantErr && !os.IsNotExist(err) {
+				t.Errorf("HTTPGet(maxSize=%d) left %s behind, want it removed", tc.maxSize, dest)
+			}
+		})
+	}
+}
+
+func TestHTTPGetMaxSizeUnknownLength(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Flushing before writing the body makes the response chunked, without a Content-Length.
+		w.(http.Flusher).Flush()
+		w.Write(httpContent)
+	}))
+	defer server.Close()
+	dest := filepath.Join(t.TempDir(), "content")
+
+	if err := NewContext().HTTPGet(server.URL, dest, WithMaxSize(1024)); err == nil {
+		t.Fatalf("HTTPGet(maxSize=1024) got no error, want an error")
+	}
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Errorf("HTTPGet(maxSize=1024) left %s behind, want it removed", dest)
+	}
+}
+
+func TestHTTPGetResume(t *testing.T) {
+	var ranges []string
+	server := newContentServer(t, &ranges)
+	dest := filepath.Join(t.TempDir(), "content")
+	if err := os.WriteFile(dest, httpContent[:1000], 0644); err != nil {
+		t.Fatalf("writing %s: %v", dest, err)
+	}
+
+	if err := NewContext().HTTPGet(server.URL+"/content", dest); err != nil {
+		t.Fatalf("HTTPGet() got error: %v", err)
+	}
+
+	if len(ranges) != 1 || ranges[0] != "bytes=1000-" {
+		t.Errorf("HTTPGet() requested ranges %q, want [bytes=1000-]", ranges)
+	}
+	got, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatalf("reading %s: %v", dest, err)
+	}
+	if !bytes.Equal(got, httpContent) {
+		t.Errorf("HTTPGet() resumed to %d bytes, want %d bytes of content", len(got), len(httpContent))
+	}
+}
+
+func TestHTTPGetResumeOversizedPartialFile(t *testing.T) {
+	var ranges []string
+	server := newContentServer(t, &ranges)
+	dest := filepath.Join(t.TempDir(), "content")
+	if err := os.WriteFile(dest, append(httpContent, "extra"...), 0644); err != nil {
+		t.Fatalf("writing %s: %v", dest, err)
+	}
+
+	if err := NewContext().HTTPGet(server.URL+"/content", dest); err != nil {
+		t.Fatalf("HTTPGet() got error: %v", err)
+	}
+
+	if want := []string{"bytes=131077-", ""}; strings.Join(ranges, ",") != strings.Join(want, ",") {
+		t.Errorf("HTTPGet() requested ranges %q, want %q", ranges, want)
+	}
+	got, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatalf("reading %s: %v", dest, err)
+	}
+	if !bytes.Equal(got, httpContent) {
+		t.Errorf("HTTPGet() wrote %d bytes, want %d bytes of content", len(got), len(httpContent))
+	}
+}
+
+func TestHTTPGetStatusErrors(t *testing.T) {
+	server := newContentServer(t, nil)
+	dest := filepath.Join(t.TempDir(), "content")
+
+	err := NewContext().HTTPGet(server.URL+"/missing", dest)
+	var clientErr *HTTPClientError
+	if !errors.As(err, &clientErr) || clientErr.StatusCode != http.StatusNotFound {
+		t.Errorf("HTTPGet(/missing) got error %v, want an HTTPClientError with status 404", err)
+	}
+
+	err = NewContext().HTTPGet(server.URL+"/broken", dest)
+	var serverErr *HTTPServerError
+	if !errors.As(err, &serverErr) || serverErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("HTTPGet(/broken) got error %v, want an HTTPServerError with status 503", err)
+	}
+}
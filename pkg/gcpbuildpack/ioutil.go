@@ -23,19 +23,42 @@ import (
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
 )
 
-// TempDir creates a directory with the provided name in the buildpack temp layer and returns its path
-func (ctx *Context) TempDir(name string) (string, error) {
-	tmpLayer, err := ctx.Layer("gcpbuildpack-tmp")
+// TempDir creates a new directory in the buildpack scratch layer and returns its path. The directory
+// name is generated from pattern as with os.MkdirTemp. The directory is removed when the build
+// completes, whether it succeeds or fails.
+func (ctx *Context) TempDir(pattern string) (string, error) {
+	tmpLayer, err := ctx.Layer("gcpbuildpack-tmp", ScratchLayer)
 	if err != nil {
 		return "", fmt.Errorf("creating layer: %w", err)
 	}
-	directory := filepath.Join(tmpLayer.Path, name)
-	if err := ctx.MkdirAll(directory, 0755); err != nil {
-		return "", err
+	directory, err := os.MkdirTemp(tmpLayer.Path, pattern)
+	if err != nil {
+		return "", buildererror.Errorf(buildererror.StatusInternal, "creating temp directory in %s: %v", tmpLayer.Path, err)
 	}
+	ctx.tempDirs = append(ctx.tempDirs, directory)
 	return directory, nil
 }
 
+// isTempDir returns true if the directory is removed by removeTempDirs.
+func (ctx *Context) isTempDir(dir string) bool {
+	for _, d := range ctx.tempDirs {
+		if d == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// removeTempDirs removes the directories created with TempDir and the scratch layers.
+func (ctx *Context) removeTempDirs() {
+	for _, dir := range ctx.tempDirs {
+		if err := ctx.RemoveAll(dir); err != nil {
+			ctx.Warnf("Failed to remove temp directory: %v", err)
+		}
+	}
+	ctx.tempDirs = nil
+}
+
 // WriteFile is a pass through for ioutil.WriteFile(...) and returns any error with proper user / system attribution
 func (ctx *Context) WriteFile(filename string, data []byte, perm os.FileMode) error {
 	if err := ioutil.WriteFile(filename, data, perm); err != nil {
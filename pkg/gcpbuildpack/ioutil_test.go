@@ -0,0 +1,82 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestTempDir(t *testing.T) {
+	testCases := []struct {
+		name    string
+		cleanUp func(ctx *Context)
+	}{
+		{
+			name:    "build completes",
+			cleanUp: func(ctx *Context) { ctx.removeTempDirs() },
+		},
+		{
+			name: "build fails",
+			cleanUp: func(ctx *Context) {
+				ctx.Exit(1, buildererror.Errorf(buildererror.StatusInternal, "failed"))
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			layersDir := t.TempDir()
+			ctx := NewContext(WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: layersDir}}))
+			exiter := &fakeExiter{}
+			ctx.exiter = exiter
+
+			first, err := ctx.TempDir("scratch")
+			if err != nil {
+				t.Fatalf("TempDir() got error: %v", err)
+			}
+			second, err := ctx.TempDir("scratch")
+			if err != nil {
+				t.Fatalf("TempDir() got error: %v", err)
+			}
+			if first == second {
+				t.Errorf("TempDir() returned %q twice, want distinct directories", first)
+			}
+			for _, dir := range []string{first, second} {
+				if !strings.HasPrefix(filepath.Base(dir), "scratch") {
+					t.Errorf("TempDir() = %q, want a name starting with %q", dir, "scratch")
+				}
+				if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+					t.Fatalf("TempDir() did not create directory %q: %v", dir, err)
+				}
+				if err := os.WriteFile(filepath.Join(dir, "file.txt"), []byte("scratch"), 0644); err != nil {
+					t.Fatalf("writing to %s: %v", dir, err)
+				}
+			}
+
+			tc.cleanUp(ctx)
+
+			for _, dir := range []string{first, second} {
+				if _, err := os.Stat(dir); !os.IsNotExist(err) {
+					t.Errorf("temp directory %q still exists after cleanup: %v", dir, err)
+				}
+			}
+		})
+	}
+}
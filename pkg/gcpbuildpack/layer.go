@@ -15,6 +15,8 @@
 package gcpbuildpack
 
 import (
+	"encoding/json"
+	"fmt"
 	"os"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
@@ -24,6 +26,10 @@ import (
 
 const (
 	layerMode os.FileMode = 0755
+	// buildpackVersionKey is the layer metadata key of the version of the buildpack that created the layer.
+	buildpackVersionKey = "buildpack_version"
+	// cacheSeedKey is the layer metadata key of the GOOGLE_CACHE_SEED value the layer was cached with.
+	cacheSeedKey = "cache_seed"
 )
 
 type layerOption func(ctx *Context, l *libcnb.Layer) error
@@ -46,6 +52,18 @@ var LaunchLayer = func(ctx *Context, l *libcnb.Layer) error {
 	return nil
 }
 
+// ScratchLayer specifies a layer for intermediate files that are only used by the buildpack that
+// creates it, such as downloaded installers. The layer is neither cached, nor launched, nor
+// available to later buildpacks, and it is removed when the build of the buildpack ends. It cannot
+// be combined with BuildLayer, CacheLayer or LaunchLayer.
+var ScratchLayer = func(ctx *Context, l *libcnb.Layer) error {
+	l.Build = false
+	l.Cache = false
+	l.Launch = false
+	ctx.tempDirs = append(ctx.tempDirs, l.Path)
+	return nil
+}
+
 // LaunchLayerIfDevMode specifies a Launch layer, but only if dev mode is enabled.
 var LaunchLayerIfDevMode = func(ctx *Context, l *libcnb.Layer) error {
 	devMode, err := env.IsDevMode()
@@ -80,14 +98,29 @@ func (ctx *Context) Layer(name string, opts ...layerOption) (*libcnb.Layer, erro
 	if err := ctx.MkdirAll(l.Path, layerMode); err != nil {
 		return nil, buildererror.Errorf(buildererror.StatusInternal, "creating %s: %v", l.Path, err)
 	}
+	// The types of the layer are the ones requested with opts, not the ones restored from its
+	// metadata, e.g. a layer cached by a previous build may no longer be a cache layer.
+	l.Build, l.Cache, l.Launch = false, false, false
 	for _, o := range opts {
 		if err := o(ctx, &l); err != nil {
 			return nil, err
 		}
 	}
+	if (l.Build || l.Cache || l.Launch) && ctx.isTempDir(l.Path) {
+		return nil, buildererror.Errorf(buildererror.StatusInternal, "scratch layer %q cannot be a build, cache or launch layer", name)
+	}
 	if l.Metadata == nil {
 		l.Metadata = make(map[string]interface{})
 	}
+	if l.Cache {
+		if err := ctx.checkCacheSeed(&l); err != nil {
+			return nil, err
+		}
+	}
+	ctx.Debugf("Using layer %q at %s (build=%t, cache=%t, launch=%t).", name, l.Path, l.Build, l.Cache, l.Launch)
+	if len(l.Metadata) > 0 {
+		ctx.Debugf("Layer %q metadata: %v", name, l.Metadata)
+	}
 	ctx.buildResult.Layers = append(ctx.buildResult.Layers, layerContributor{&l})
 	return &l, nil
 }
@@ -108,6 +141,7 @@ func (lc layerContributor) Name() string {
 
 // ClearLayer erases the existing layer, and re-creates the directory.
 func (ctx *Context) ClearLayer(l *libcnb.Layer) error {
+	defer ctx.Timer(fmt.Sprintf("Clear layer %q", l.Name))()
 	if err := ctx.RemoveAll(l.Path); err != nil {
 		return err
 	}
@@ -134,3 +168,82 @@ func (ctx *Context) GetMetadata(l *libcnb.Layer, key string) string {
 	}
 	return s
 }
+
+// SetMetadataStruct sets the exported fields of the struct v as metadata on the layer, keyed by
+// their JSON names, next to the other metadata of the layer. Fields with null values are omitted.
+// Use GetMetadataStruct rather than GetMetadata to read non-string fields.
+func (ctx *Context) SetMetadataStruct(l *libcnb.Layer, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return buildererror.Errorf(buildererror.StatusInternal, "marshalling metadata of layer %s: %v", l.Name, err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return buildererror.Errorf(buildererror.StatusInternal, "metadata of layer %s must be a struct, got %T: %v", l.Name, v, err)
+	}
+	for k, f := range fields {
+		if f != nil {
+			l.Metadata[k] = f
+		}
+	}
+	return nil
+}
+
+// GetMetadataStruct reads the layer metadata into the fields of the struct pointed to by v, keyed
+// by their JSON names. Fields without metadata are left unchanged, and metadata without a field is
+// ignored. It returns an error if metadata cannot be converted to the type of its field, e.g. when
+// it was written by a version of the buildpack with a different struct.
+func (ctx *Context) GetMetadataStruct(l *libcnb.Layer, v interface{}) error {
+	b, err := json.Marshal(l.Metadata)
+	if err != nil {
+		return buildererror.Errorf(buildererror.StatusInternal, "marshalling metadata of layer %s: %v", l.Name, err)
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return buildererror.Errorf(buildererror.StatusInternal, "unmarshalling metadata of layer %s into %T: %v", l.Name, v, err)
+	}
+	return nil
+}
+
+// CheckBuildpackVersion invalidates a cached layer that was created by a different version of the
+// buildpack, whose contents or metadata may be incompatible with the running version. It returns
+// true if the layer was created by the running version. Otherwise the layer is cleared, its
+// metadata is reset to the running buildpack version, and false is returned.
+func (ctx *Context) CheckBuildpackVersion(l *libcnb.Layer) (bool, error) {
+	version := ctx.GetMetadata(l, buildpackVersionKey)
+	if version == ctx.BuildpackVersion() {
+		return true, nil
+	}
+	if version != "" {
+		ctx.Logf("Layer %s was created by %s version %s, clearing it for version %s.", l.Name, ctx.BuildpackID(), version, ctx.BuildpackVersion())
+	}
+	ctx.CacheMiss(l.Name)
+	if err := ctx.ClearLayer(l); err != nil {
+		return false, buildererror.Errorf(buildererror.StatusInternal, "clearing layer %q: %v", l.Name, err)
+	}
+	seed := l.Metadata[cacheSeedKey]
+	l.Metadata = map[string]interface{}{buildpackVersionKey: ctx.BuildpackVersion()}
+	if seed != nil {
+		l.Metadata[cacheSeedKey] = seed
+	}
+	return false, nil
+}
+
+// checkCacheSeed clears a cached layer whose contents were cached with a different
+// GOOGLE_CACHE_SEED, along with its metadata, and records the current seed in its metadata.
+func (ctx *Context) checkCacheSeed(l *libcnb.Layer) error {
+	seed := os.Getenv(env.CacheSeed)
+	if ctx.GetMetadata(l, cacheSeedKey) == seed {
+		return nil
+	}
+	if len(l.Metadata) > 0 {
+		ctx.Logf("Layer %s was cached with a different %s, clearing it.", l.Name, env.CacheSeed)
+	}
+	if err := ctx.ClearLayer(l); err != nil {
+		return buildererror.Errorf(buildererror.StatusInternal, "clearing layer %q: %v", l.Name, err)
+	}
+	l.Metadata = make(map[string]interface{})
+	if seed != "" {
+		l.Metadata[cacheSeedKey] = seed
+	}
+	return nil
+}
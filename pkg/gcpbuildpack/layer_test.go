@@ -0,0 +1,351 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/buildpacks/libcnb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCheckBuildpackVersion(t *testing.T) {
+	testCases := []struct {
+		name         string
+		metadata     map[string]interface{}
+		wantHit      bool
+		wantMetadata map[string]string
+	}{
+		{
+			name:         "matching version",
+			metadata:     map[string]interface{}{buildpackVersionKey: "1.2.0", "key": "value"},
+			wantHit:      true,
+			wantMetadata: map[string]string{buildpackVersionKey: "1.2.0", "key": "value"},
+		},
+		{
+			name:         "mismatched version",
+			metadata:     map[string]interface{}{buildpackVersionKey: "1.1.0", "key": "value"},
+			wantMetadata: map[string]string{buildpackVersionKey: "1.2.0", "key": ""},
+		},
+		{
+			name:         "no version",
+			metadata:     map[string]interface{}{"key": "value"},
+			wantMetadata: map[string]string{buildpackVersionKey: "1.2.0", "key": ""},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			layersDir := t.TempDir()
+			var buf bytes.Buffer
+			ctx := NewContext(
+				WithLogger(log.New(&buf, "", 0)),
+				WithBuildpackInfo(libcnb.BuildpackInfo{ID: "my-id", Version: "1.2.0"}),
+				WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: layersDir}}))
+			l, err := ctx.Layer("cached", CacheLayer)
+			if err != nil {
+				t.Fatalf("creating layer: %v", err)
+			}
+			l.Metadata = tc.metadata
+			cached := filepath.Join(l.Path, "cached.txt")
+			if err := os.WriteFile(cached, []byte("cached"), 0644); err != nil {
+				t.Fatalf("writing %s: %v", cached, err)
+			}
+
+			hit, err := ctx.CheckBuildpackVersion(l)
+			if err != nil {
+				t.Fatalf("CheckBuildpackVersion() got error: %v", err)
+			}
+			if hit != tc.wantHit {
+				t.Errorf("CheckBuildpackVersion() = %t, want %t", hit, tc.wantHit)
+			}
+			for k, want := range tc.wantMetadata {
+				if got := ctx.GetMetadata(l, k); got != want {
+					t.Errorf("layer metadata %s=%q, want %q", k, got, want)
+				}
+			}
+			_, err = os.Stat(cached)
+			if exists := err == nil; exists != tc.wantHit {
+				t.Errorf("cached file exists=%t, want %t", exists, tc.wantHit)
+			}
+		})
+	}
+}
+
+func TestLayerCacheSeed(t *testing.T) {
+	testCases := []struct {
+		name       string
+		cachedSeed string
+		seed       string
+		cacheLayer bool
+		wantHit    bool
+	}{
+		{
+			name:       "no seed",
+			cacheLayer: true,
+			wantHit:    true,
+		},
+		{
+			name:       "stable seed",
+			cachedSeed: "seed-1",
+			seed:       "seed-1",
+			cacheLayer: true,
+			wantHit:    true,
+		},
+		{
+			name:       "changed seed",
+			cachedSeed: "seed-1",
+			seed:       "seed-2",
+			cacheLayer: true,
+		},
+		{
+			name:       "seed added",
+			seed:       "seed-1",
+			cacheLayer: true,
+		},
+		{
+			name:       "seed removed",
+			cachedSeed: "seed-1",
+			cacheLayer: true,
+		},
+		{
+			name:       "non-cache layer",
+			cachedSeed: "seed-1",
+			seed:       "seed-2",
+			wantHit:    true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			layersDir := t.TempDir()
+			// Restore two layers as the lifecycle does from the cache.
+			names := []string{"first", "second"}
+			for _, name := range names {
+				metadata := "[metadata]\n  key = \"value\"\n"
+				if tc.cachedSeed != "" {
+					metadata += "  " + cacheSeedKey + " = \"" + tc.cachedSeed + "\"\n"
+				}
+				if err := os.WriteFile(filepath.Join(layersDir, name+".toml"), []byte("cache = true\n\n"+metadata), 0644); err != nil {
+					t.Fatalf("writing layer metadata: %v", err)
+				}
+				if err := os.MkdirAll(filepath.Join(layersDir, name), 0755); err != nil {
+					t.Fatalf("creating layer: %v", err)
+				}
+				if err := os.WriteFile(filepath.Join(layersDir, name, "cached.txt"), []byte("cached"), 0644); err != nil {
+					t.Fatalf("writing cached file: %v", err)
+				}
+			}
+			t.Setenv(env.CacheSeed, tc.seed)
+			ctx := NewContext(
+				WithLogger(log.New(&bytes.Buffer{}, "", 0)),
+				WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: layersDir}}))
+
+			for _, name := range names {
+				var opts []layerOption
+				if tc.cacheLayer {
+					opts = append(opts, CacheLayer)
+				}
+				l, err := ctx.Layer(name, opts...)
+				if err != nil {
+					t.Fatalf("Layer(%q) got error: %v", name, err)
+				}
+				_, err = os.Stat(filepath.Join(l.Path, "cached.txt"))
+				if exists := err == nil; exists != tc.wantHit {
+					t.Errorf("layer %s cached file exists=%t, want %t", name, exists, tc.wantHit)
+				}
+				wantValue := ""
+				if tc.wantHit {
+					wantValue = "value"
+				}
+				if got := ctx.GetMetadata(l, "key"); got != wantValue {
+					t.Errorf("layer %s metadata key=%q, want %q", name, got, wantValue)
+				}
+				if tc.cacheLayer {
+					if got := ctx.GetMetadata(l, cacheSeedKey); got != tc.seed {
+						t.Errorf("layer %s metadata %s=%q, want %q", name, cacheSeedKey, got, tc.seed)
+					}
+				}
+			}
+		})
+	}
+}
+
+type testMetadata struct {
+	Version  string            `json:"version"`
+	DevMode  bool              `json:"devMode"`
+	Workers  int               `json:"workers"`
+	Arch     string            `json:"arch,omitempty"`
+	Features []string          `json:"features"`
+	Labels   map[string]string `json:"labels"`
+}
+
+func TestMetadataStructRoundTrip(t *testing.T) {
+	layersDir := t.TempDir()
+	ctx := NewContext(WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: layersDir}}))
+	l, err := ctx.Layer("typed", CacheLayer)
+	if err != nil {
+		t.Fatalf("creating layer: %v", err)
+	}
+	ctx.SetMetadata(l, "other", "value")
+	want := testMetadata{
+		Version:  "6.0.100",
+		DevMode:  true,
+		Workers:  4,
+		Arch:     "arm64",
+		Features: []string{"a", "b"},
+		Labels:   map[string]string{"k": "v"},
+	}
+
+	if err := ctx.SetMetadataStruct(l, want); err != nil {
+		t.Fatalf("SetMetadataStruct() got error: %v", err)
+	}
+	// Persist the layer metadata and restore it, as the lifecycle does between builds.
+	f, err := os.Create(filepath.Join(layersDir, "typed.toml"))
+	if err != nil {
+		t.Fatalf("creating layer metadata file: %v", err)
+	}
+	if err := toml.NewEncoder(f).Encode(map[string]interface{}{"cache": true, "metadata": l.Metadata}); err != nil {
+		t.Fatalf("writing layer metadata: %v", err)
+	}
+	f.Close()
+	ctx = NewContext(WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: layersDir}}))
+	l, err = ctx.Layer("typed", CacheLayer)
+	if err != nil {
+		t.Fatalf("restoring layer: %v", err)
+	}
+
+	var got testMetadata
+	if err := ctx.GetMetadataStruct(l, &got); err != nil {
+		t.Fatalf("GetMetadataStruct() got error: %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("GetMetadataStruct() mismatch (-want +got):\n%s", diff)
+	}
+	if got := ctx.GetMetadata(l, "other"); got != "value" {
+		t.Errorf("GetMetadata(other) = %q, want %q", got, "value")
+	}
+}
+
+func TestMetadataStructDetectsChanges(t *testing.T) {
+	stored := testMetadata{Version: "6.0.100", Workers: 2, Arch: "amd64"}
+	testCases := []struct {
+		name    string
+		current testMetadata
+		want    bool
+	}{
+		{
+			name:    "unchanged",
+			current: stored,
+			want:    true,
+		},
+		{
+			name:    "version changed",
+			current: testMetadata{Version: "6.0.200", Workers: 2, Arch: "amd64"},
+		},
+		{
+			name:    "bool changed",
+			current: testMetadata{Version: "6.0.100", DevMode: true, Workers: 2, Arch: "amd64"},
+		},
+		{
+			name:    "arch changed",
+			current: testMetadata{Version: "6.0.100", Workers: 2, Arch: "arm64"},
+		},
+		{
+			name:    "int changed",
+			current: testMetadata{Version: "6.0.100", Workers: 3, Arch: "amd64"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := NewContext()
+			l := &libcnb.Layer{Name: "typed", Metadata: map[string]interface{}{}}
+			if err := ctx.SetMetadataStruct(l, stored); err != nil {
+				t.Fatalf("SetMetadataStruct() got error: %v", err)
+			}
+
+			var got testMetadata
+			if err := ctx.GetMetadataStruct(l, &got); err != nil {
+				t.Fatalf("GetMetadataStruct() got error: %v", err)
+			}
+			if equal := cmp.Equal(got, tc.current); equal != tc.want {
+				t.Errorf("stored metadata %+v equal to %+v = %t, want %t", got, tc.current, equal, tc.want)
+			}
+		})
+	}
+}
+
+func TestMetadataStructErrors(t *testing.T) {
+	ctx := NewContext()
+	l := &libcnb.Layer{Name: "typed", Metadata: map[string]interface{}{"devMode": "not a bool"}}
+
+	if err := ctx.SetMetadataStruct(l, "not a struct"); err == nil {
+		t.Error("SetMetadataStruct() with a string got no error, want error")
+	}
+	var got testMetadata
+	if err := ctx.GetMetadataStruct(l, &got); err == nil {
+		t.Error("GetMetadataStruct() with mismatched types got no error, want error")
+	}
+}
+
+func TestScratchLayer(t *testing.T) {
+	layersDir := t.TempDir()
+	ctx := NewContext(WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: layersDir}}))
+
+	l, err := ctx.Layer("installer", ScratchLayer)
+	if err != nil {
+		t.Fatalf("Layer() got error: %v", err)
+	}
+	if l.Build || l.Cache || l.Launch {
+		t.Errorf("Layer() got build=%t, cache=%t, launch=%t, want all false", l.Build, l.Cache, l.Launch)
+	}
+	installer := filepath.Join(l.Path, "installer.tar.gz")
+	if err := os.WriteFile(installer, []byte("installer"), 0644); err != nil {
+		t.Fatalf("writing %s: %v", installer, err)
+	}
+
+	ctx.removeTempDirs()
+
+	if _, err := os.Stat(l.Path); !os.IsNotExist(err) {
+		t.Errorf("scratch layer %s still exists after the build, stat error: %v", l.Path, err)
+	}
+	for _, lc := range ctx.buildResult.Layers {
+		if lc.Name() != l.Name {
+			continue
+		}
+		// Only cache and launch layers are exported by the lifecycle.
+		got, err := lc.Contribute(libcnb.Layer{})
+		if err != nil {
+			t.Fatalf("Contribute() got error: %v", err)
+		}
+		if got.Cache || got.Launch {
+			t.Errorf("scratch layer is exported with cache=%t, launch=%t, want neither", got.Cache, got.Launch)
+		}
+	}
+}
+
+func TestScratchLayerWithOtherOptions(t *testing.T) {
+	for _, opt := range []layerOption{BuildLayer, CacheLayer, LaunchLayer} {
+		ctx := NewContext(WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: t.TempDir()}}))
+
+		if _, err := ctx.Layer("installer", ScratchLayer, opt); err == nil {
+			t.Errorf("Layer() with a scratch layer that is also a build, cache or launch layer got nil error, want error")
+		}
+	}
+}
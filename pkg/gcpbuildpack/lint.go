@@ -0,0 +1,55 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+const (
+	// lintLayer is the name of the layer that marks the lint command as done for the build.
+	lintLayer = "lint"
+	// lintDoneEnv is set in the build environment of subsequent buildpacks once the lint command
+	// passed, so that it runs once per build even if several buildpacks of the group call LintGate.
+	lintDoneEnv = "X_GOOGLE_LINT_DONE"
+)
+
+// LintGate runs the given shell command, usually the value of GOOGLE_LINT_COMMAND, in the
+// application directory and returns a user error with the tail of its output if it fails. It is a
+// no-op if the command is empty or already passed in a previous buildpack of the build.
+func (ctx *Context) LintGate(command string) error {
+	if strings.TrimSpace(command) == "" {
+		return nil
+	}
+	if os.Getenv(lintDoneEnv) == "true" {
+		ctx.Debugf("Skipping lint command %q, it already passed in a previous buildpack.", command)
+		return nil
+	}
+	ctx.Logf("Running lint command %q from %s.", command, env.LintCommand)
+	if _, err := ctx.ExecWithErr([]string{"bash", "-c", command}, WithWorkDir(ctx.ApplicationRoot()), WithUserAttribution, WithStreamedOutput, WithCombinedTail); err != nil {
+		err.Message = fmt.Sprintf("lint command %q failed, fix the reported issues or unset %s: %s", command, env.LintCommand, err.Message)
+		return err
+	}
+	l, err := ctx.Layer(lintLayer, BuildLayer)
+	if err != nil {
+		return err
+	}
+	l.BuildEnvironment.Override(lintDoneEnv, "true")
+	return nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestLintGate(t *testing.T) {
+	testCases := []struct {
+		name       string
+		command    string
+		done       string
+		wantErr    bool
+		wantOutput string
+		wantDone   bool
+	}{
+		{
+			name: "unset",
+		},
+		{
+			name:    "blank",
+			command: "  ",
+		},
+		{
+			name:       "passing lint",
+			command:    "echo checked $(ls)",
+			wantOutput: "checked main.go",
+			wantDone:   true,
+		},
+		{
+			name:    "passed in a previous buildpack",
+			command: "echo checked; exit 1",
+			done:    "true",
+		},
+		{
+			name:       "failing lint",
+			command:    "echo main.go is not formatted; exit 1",
+			wantErr:    true,
+			wantOutput: "main.go is not formatted",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main"), 0644); err != nil {
+				t.Fatalf("writing main.go: %v", err)
+			}
+			t.Setenv(lintDoneEnv, tc.done)
+			var buf bytes.Buffer
+			ctx := NewContext(WithApplicationRoot(dir), WithLogger(log.New(&buf, "", 0)), WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: t.TempDir()}}))
+
+			err := ctx.LintGate(tc.command)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("LintGate(%q) got error: %v, want error: %t", tc.command, err, tc.wantErr)
+			}
+			if tc.wantErr {
+				be, ok := err.(*buildererror.Error)
+				if !ok {
+					t.Fatalf("LintGate(%q) got error of type %T, want *buildererror.Error", tc.command, err)
+				}
+				if be.Status != buildererror.StatusUnknown {
+					t.Errorf("LintGate(%q) got status %v, want %v", tc.command, be.Status, buildererror.StatusUnknown)
+				}
+				if !strings.Contains(be.Message, tc.wantOutput) {
+					t.Errorf("LintGate(%q) got message %q, want it to contain %q", tc.command, be.Message, tc.wantOutput)
+				}
+			}
+			if !strings.Contains(buf.String(), tc.wantOutput) {
+				t.Errorf("LintGate(%q) logged %q, want it to contain %q", tc.command, buf.String(), tc.wantOutput)
+			}
+			if tc.wantOutput == "" && buf.Len() != 0 {
+				t.Errorf("LintGate(%q) logged %q, want no output", tc.command, buf.String())
+			}
+			var gotDone bool
+			for _, lc := range ctx.buildResult.Layers {
+				if l := lc.(layerContributor).l; l.Name == lintLayer {
+					gotDone = l.BuildEnvironment[lintDoneEnv+".override"] == "true"
+				}
+			}
+			if gotDone != tc.wantDone {
+				t.Errorf("LintGate(%q) set %s in the build environment: %t, want %t", tc.command, lintDoneEnv, gotDone, tc.wantDone)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/buildpacks/libcnb"
+	"golang.org/x/sys/unix"
+)
+
+// worldWritable is the permission bit that lets any user write to a file or directory.
+const worldWritable fs.FileMode = 0002
+
+// buildUmask returns the umask requested with GOOGLE_BUILD_UMASK, if any.
+func buildUmask() (int, bool, error) {
+	v := os.Getenv(env.BuildUmask)
+	if v == "" {
+		return 0, false, nil
+	}
+	mask, err := strconv.ParseUint(v, 8, 32)
+	if err != nil || mask > 0777 {
+		return 0, false, UserErrorf("invalid %s %q, must be an octal value between 000 and 777", env.BuildUmask, v)
+	}
+	return int(mask), true, nil
+}
+
+// applyBuildUmask sets the umask of the build process, and of the commands it runs, to the value
+// of GOOGLE_BUILD_UMASK. It returns whether the umask was set.
+func (ctx *Context) applyBuildUmask() (bool, error) {
+	mask, ok, err := buildUmask()
+	if err != nil || !ok {
+		return false, err
+	}
+	old := unix.Umask(mask)
+	ctx.Debugf("Set umask to %03o (was %03o).", mask, old)
+	return true, nil
+}
+
+// NormalizePermissions removes world-write permissions from the files and directories of the
+// layer. Symbolic links are left untouched because their modes are not used.
+func (ctx *Context) NormalizePermissions(l *libcnb.Layer) error {
+	var normalized int
+	err := filepath.WalkDir(l.Path, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type()&fs.ModeSymlink != 0 {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		mode := info.Mode()
+		if mode.Perm()&worldWritable == 0 {
+			return nil
+		}
+		// Keep the setuid, setgid and sticky bits, which Chmod otherwise clears.
+		if err := os.Chmod(path, mode&^worldWritable&(fs.ModePerm|fs.ModeSetuid|fs.ModeSetgid|fs.ModeSticky)); err != nil {
+			return err
+		}
+		normalized++
+		return nil
+	})
+	if err != nil {
+		return buildererror.Errorf(buildererror.StatusInternal, "normalizing permissions of layer %s: %v", l.Name, err)
+	}
+	if normalized > 0 {
+		ctx.Debugf("Removed world-write permissions from %d files in layer %s.", normalized, l.Name)
+	}
+	return nil
+}
+
+// normalizeLayerPermissions removes world-write permissions from all the layers the buildpack
+// contributed.
+func (ctx *Context) normalizeLayerPermissions() error {
+	for _, lc := range ctx.buildResult.Layers {
+		c, ok := lc.(layerContributor)
+		if !ok {
+			continue
+		}
+		if err := ctx.NormalizePermissions(c.l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/buildpacks/libcnb"
+	"golang.org/x/sys/unix"
+)
+
+func TestNormalizePermissions(t *testing.T) {
+	layersDir := t.TempDir()
+	ctx := NewContext(WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: layersDir}}))
+	l, err := ctx.Layer("normalize", BuildLayer)
+	if err != nil {
+		t.Fatalf("creating layer: %v", err)
+	}
+
+	// Modes are set with Chmod because the umask of the test process may strip bits.
+	modes := map[string]fs.FileMode{
+		"world-writable.txt":     0666,
+		"private.txt":            0600,
+		"bin/tool":               0777,
+		"bin":                    0777,
+		"sticky":                 0777 | fs.ModeSticky,
+		"read-only/readable.txt": 0644,
+	}
+	want := map[string]fs.FileMode{
+		"world-writable.txt":     0664,
+		"private.txt":            0600,
+		"bin/tool":               0775,
+		"bin":                    0775,
+		"sticky":                 0775 | fs.ModeSticky,
+		"read-only/readable.txt": 0644,
+	}
+	for _, dir := range []string{"bin", "sticky", "read-only"} {
+		if err := os.MkdirAll(filepath.Join(l.Path, dir), 0755); err != nil {
+			t.Fatalf("creating %s: %v", dir, err)
+		}
+	}
+	for name := range modes {
+		path := filepath.Join(l.Path, name)
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+				t.Fatalf("writing %s: %v", name, err)
+			}
+		}
+	}
+	for name, mode := range modes {
+		if err := os.Chmod(filepath.Join(l.Path, name), mode); err != nil {
+			t.Fatalf("chmod %s: %v", name, err)
+		}
+	}
+	if err := os.Symlink("world-writable.txt", filepath.Join(l.Path, "link")); err != nil {
+		t.Fatalf("creating symlink: %v", err)
+	}
+
+	if err := ctx.NormalizePermissions(l); err != nil {
+		t.Fatalf("NormalizePermissions() got error: %v", err)
+	}
+
+	for name, wantMode := range want {
+		info, err := os.Stat(filepath.Join(l.Path, name))
+		if err != nil {
+			t.Fatalf("stat %s: %v", name, err)
+		}
+		if got := info.Mode() & (fs.ModePerm | fs.ModeSticky); got != wantMode {
+			t.Errorf("mode of %s = %v, want %v", name, got, wantMode)
+		}
+	}
+}
+
+func TestApplyBuildUmask(t *testing.T) {
+	testCases := []struct {
+		name     string
+		umask    string
+		wantSet  bool
+		wantMode fs.FileMode
+		wantErr  bool
+	}{
+		{
+			name:  "unset",
+			umask: "",
+		},
+		{
+			name:     "world-write removed",
+			umask:    "002",
+			wantSet:  true,
+			wantMode: 0664,
+		},
+		{
+			name:     "group and others restricted",
+			umask:    "0027",
+			wantSet:  true,
+			wantMode: 0640,
+		},
+		{
+			name:    "not octal",
+			umask:   "089",
+			wantErr: true,
+		},
+		{
+			name:    "out of range",
+			umask:   "1777",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(env.BuildUmask, tc.umask)
+			// Restore the umask of the test process.
+			old := unix.Umask(0)
+			unix.Umask(old)
+			defer unix.Umask(old)
+			ctx := NewContext()
+
+			set, err := ctx.applyBuildUmask()
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("applyBuildUmask() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if set != tc.wantSet {
+				t.Errorf("applyBuildUmask() = %t, want %t", set, tc.wantSet)
+			}
+			if !tc.wantSet {
+				return
+			}
+
+			path := filepath.Join(t.TempDir(), "new.txt")
+			if err := os.WriteFile(path, []byte("new"), 0666); err != nil {
+				t.Fatalf("writing %s: %v", path, err)
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatalf("stat %s: %v", path, err)
+			}
+			if got := info.Mode().Perm(); got != tc.wantMode {
+				t.Errorf("mode of new file = %v, want %v", got, tc.wantMode)
+			}
+		})
+	}
+}
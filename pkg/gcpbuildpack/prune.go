@@ -0,0 +1,111 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"io/fs"
+	"path/filepath"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+// testFilePatterns are the file and directory name patterns of test files, per language.
+type testFilePatterns struct {
+	files []string
+	dirs  []string
+}
+
+var languageTestFiles = map[string]testFilePatterns{
+	"go": {
+		files: []string{"*_test.go"},
+	},
+	"nodejs": {
+		files: []string{"*.spec.js", "*.test.js", "*.spec.mjs", "*.test.mjs", "*.spec.ts", "*.test.ts"},
+		dirs:  []string{"__tests__"},
+	},
+}
+
+// PruneTestFiles removes the test files of the given language, e.g. "go" or "nodejs", from the
+// application so that they are not shipped in the image. Dependencies in node_modules and hidden
+// directories are left untouched. Test files are kept if GOOGLE_KEEP_TESTS is true or in dev mode.
+func (ctx *Context) PruneTestFiles(lang string) error {
+	patterns, ok := languageTestFiles[lang]
+	if !ok {
+		return InternalErrorf("no test file patterns for language %q", lang)
+	}
+	keep, err := env.IsPresentAndTrue(env.KeepTests)
+	if err != nil {
+		return UserErrorf("%v", err)
+	}
+	if keep {
+		ctx.Logf("Keeping test files because %s is set.", env.KeepTests)
+		return nil
+	}
+	if devMode, err := env.IsDevMode(); err == nil && devMode {
+		ctx.Debugf("Keeping test files in dev mode.")
+		return nil
+	}
+
+	root := ctx.ApplicationRoot()
+	var pruned []string
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if name == "node_modules" || name[0] == '.' {
+				return filepath.SkipDir
+			}
+			if !matchesAny(patterns.dirs, name) {
+				return nil
+			}
+		} else if !matchesAny(patterns.files, name) {
+			return nil
+		}
+		if err := ctx.RemoveAll(path); err != nil {
+			return err
+		}
+		pruned = append(pruned, path)
+		if d.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		if _, ok := err.(*buildererror.Error); ok {
+			return err
+		}
+		return InternalErrorf("pruning test files: %v", err)
+	}
+	if len(pruned) > 0 {
+		ctx.Logf("Pruned %d test files and directories, set %s=true to keep them.", len(pruned), env.KeepTests)
+		ctx.Debugf("Pruned test files: %v", pruned)
+	}
+	return nil
+}
+
+func matchesAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := filepath.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+func TestPruneTestFiles(t *testing.T) {
+	testCases := []struct {
+		name       string
+		lang       string
+		envs       map[string]string
+		files      []string
+		wantPruned []string
+		wantKept   []string
+	}{
+		{
+			name:       "go test files are pruned by default",
+			lang:       "go",
+			files:      []string{"main.go", "main_test.go", "pkg/util/util.go", "pkg/util/util_test.go"},
+			wantPruned: []string{"main_test.go", "pkg/util/util_test.go"},
+			wantKept:   []string{"main.go", "pkg/util/util.go"},
+		},
+		{
+			name:       "nodejs test files are pruned by default",
+			lang:       "nodejs",
+			files:      []string{"index.js", "index.spec.js", "src/app.ts", "src/app.test.ts", "__tests__/app.js", "node_modules/dep/dep.test.js", ".github/workflow.test.js"},
+			wantPruned: []string{"index.spec.js", "src/app.test.ts", "__tests__"},
+			wantKept:   []string{"index.js", "src/app.ts", "node_modules/dep/dep.test.js", ".github/workflow.test.js"},
+		},
+		{
+			name:     "test files are kept when requested",
+			lang:     "go",
+			envs:     map[string]string{env.KeepTests: "1"},
+			files:    []string{"main.go", "main_test.go"},
+			wantKept: []string{"main.go", "main_test.go"},
+		},
+		{
+			name:     "test files are kept in dev mode",
+			lang:     "nodejs",
+			envs:     map[string]string{env.DevMode: "true"},
+			files:    []string{"index.js", "__tests__/app.js"},
+			wantKept: []string{"index.js", "__tests__/app.js"},
+		},
+		{
+			name:       "keep tests disabled",
+			lang:       "go",
+			envs:       map[string]string{env.KeepTests: "false"},
+			files:      []string{"main.go", "main_test.go"},
+			wantPruned: []string{"main_test.go"},
+			wantKept:   []string{"main.go"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, f := range tc.files {
+				path := filepath.Join(dir, f)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("creating dir for %s: %v", f, err)
+				}
+				if err := os.WriteFile(path, []byte{}, 0644); err != nil {
+					t.Fatalf("writing %s: %v", f, err)
+				}
+			}
+			for k, v := range tc.envs {
+				t.Setenv(k, v)
+			}
+			ctx := NewContext(WithApplicationRoot(dir))
+
+			if err := ctx.PruneTestFiles(tc.lang); err != nil {
+				t.Fatalf("PruneTestFiles(%q) got error: %v", tc.lang, err)
+			}
+			for _, f := range tc.wantPruned {
+				if _, err := os.Stat(filepath.Join(dir, f)); !os.IsNotExist(err) {
+					t.Errorf("PruneTestFiles(%q) did not prune %s", tc.lang, f)
+				}
+			}
+			for _, f := range tc.wantKept {
+				if _, err := os.Stat(filepath.Join(dir, f)); err != nil {
+					t.Errorf("PruneTestFiles(%q) did not keep %s: %v", tc.lang, f, err)
+				}
+			}
+		})
+	}
+}
+
+func TestPruneTestFilesUnknownLanguage(t *testing.T) {
+	ctx := NewContext(WithApplicationRoot(t.TempDir()))
+	if err := ctx.PruneTestFiles("cobol"); err == nil {
+		t.Error("PruneTestFiles(cobol) got nil error, want error")
+	}
+}
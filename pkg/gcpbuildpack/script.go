@@ -0,0 +1,74 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// executable is the permission bits that let the owner, group and others execute a file.
+const executable os.FileMode = 0111
+
+// ValidateScriptEntrypoint checks the shell script that an entrypoint runs, whose path is relative
+// to the application root unless absolute. Only scripts inside the application root are checked:
+// a bare name such as start.sh is looked up on the PATH, and other absolute paths are provided by
+// the image. It warns if the script does not exist, makes it executable if it is not, and warns if
+// it has no shebang, without which the kernel cannot execute it.
+func ValidateScriptEntrypoint(ctx *Context, path string) error {
+	if !strings.Contains(path, "/") {
+		return nil
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(ctx.ApplicationRoot(), path)
+	}
+	if rel, err := filepath.Rel(ctx.ApplicationRoot(), path); err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		ctx.Warnf("The entrypoint script %s does not exist, so the application may fail to start.", path)
+		return nil
+	}
+	if err != nil {
+		return InternalErrorf("stating %s: %v", path, err)
+	}
+	if info.IsDir() {
+		return UserErrorf("the entrypoint script %s is a directory", path)
+	}
+	if info.Mode().Perm()&executable != executable {
+		if err := os.Chmod(path, info.Mode().Perm()|executable); err != nil {
+			return InternalErrorf("making %s executable: %v", path, err)
+		}
+		ctx.Logf("Made the entrypoint script %s executable.", path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return InternalErrorf("opening %s: %v", path, err)
+	}
+	defer f.Close()
+	head := make([]byte, 2)
+	if _, err := io.ReadFull(f, head); err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return InternalErrorf("reading %s: %v", path, err)
+	}
+	if !bytes.Equal(head, []byte("#!")) {
+		ctx.Warnf("The entrypoint script %s does not start with a shebang such as #!/bin/sh, so it may fail to start.", path)
+	}
+	return nil
+}
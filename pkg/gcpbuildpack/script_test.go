@@ -0,0 +1,124 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateScriptEntrypoint(t *testing.T) {
+	testCases := []struct {
+		name     string
+		path     string
+		content  string
+		mode     os.FileMode
+		noScript bool
+		wantLog  string
+		wantWarn bool
+		wantErr  bool
+	}{
+		{
+			name:    "valid script",
+			content: "#!/bin/sh\nexec ./server\n",
+			mode:    0755,
+		},
+		{
+			name:    "non-executable script is fixed",
+			content: "#!/bin/bash\nexec ./server\n",
+			mode:    0644,
+			wantLog: "executable",
+		},
+		{
+			name:     "missing shebang is warned",
+			content:  "exec ./server\n",
+			mode:     0755,
+			wantWarn: true,
+		},
+		{
+			name:     "empty script is warned",
+			mode:     0755,
+			wantWarn: true,
+		},
+		{
+			name:     "missing script is warned",
+			noScript: true,
+			wantLog:  "does not exist",
+		},
+		{
+			name:     "script on the PATH is skipped",
+			path:     "start.sh",
+			noScript: true,
+		},
+		{
+			name:     "script outside of the application is skipped",
+			path:     "/usr/local/bin/run.sh",
+			noScript: true,
+		},
+		{
+			name:     "relative path outside of the application is skipped",
+			path:     "../run.sh",
+			noScript: true,
+		},
+		{
+			name:     "directory",
+			path:     "./",
+			noScript: true,
+			wantErr:  true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			appDir := t.TempDir()
+			script := filepath.Join(appDir, "start.sh")
+			if !tc.noScript {
+				if err := os.WriteFile(script, []byte(tc.content), tc.mode); err != nil {
+					t.Fatalf("writing %s: %v", script, err)
+				}
+			}
+			var buf bytes.Buffer
+			ctx := NewContext(WithApplicationRoot(appDir), WithLogger(log.New(&buf, "", 0)))
+
+			path := tc.path
+			if path == "" {
+				path = "./start.sh"
+			}
+			err := ValidateScriptEntrypoint(ctx, path)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("ValidateScriptEntrypoint() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if tc.wantLog != "" && !strings.Contains(buf.String(), tc.wantLog) {
+				t.Errorf("ValidateScriptEntrypoint() logged %q, want it to contain %q", buf.String(), tc.wantLog)
+			}
+			if tc.wantErr || tc.noScript {
+				return
+			}
+			info, err := os.Stat(script)
+			if err != nil {
+				t.Fatalf("stating %s: %v", script, err)
+			}
+			if info.Mode().Perm()&executable != executable {
+				t.Errorf("ValidateScriptEntrypoint() left mode %v, want executable", info.Mode().Perm())
+			}
+			if gotWarn := strings.Contains(buf.String(), "shebang"); gotWarn != tc.wantWarn {
+				t.Errorf("ValidateScriptEntrypoint() logged %q, want shebang warning: %t", buf.String(), tc.wantWarn)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import "encoding/json"
+
+// storeKey is the key of the store.toml metadata that holds the JSON-encoded value saved by
+// SaveStore. Storing JSON rather than TOML tables keeps values that TOML cannot represent, such as
+// null fields, and the types of the fields across builds.
+const storeKey = "json"
+
+// SaveStore saves v, encoded as JSON, to the store.toml of the buildpack, which is kept for the next
+// build of the application, e.g. to remember a resolved runtime version. It replaces any value
+// saved earlier in the build.
+func (ctx *Context) SaveStore(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return InternalErrorf("encoding %T for store.toml: %v", v, err)
+	}
+	if ctx.buildResult.PersistentMetadata == nil {
+		ctx.buildResult.PersistentMetadata = map[string]interface{}{}
+	}
+	ctx.buildResult.PersistentMetadata[storeKey] = string(data)
+	return nil
+}
+
+// LoadStore decodes the value that the previous build saved to store.toml with SaveStore into v,
+// which must be a pointer. It returns false and leaves v unchanged if nothing was saved.
+func (ctx *Context) LoadStore(v interface{}) (bool, error) {
+	stored, ok := ctx.buildContext.PersistentMetadata[storeKey]
+	if !ok {
+		return false, nil
+	}
+	data, ok := stored.(string)
+	if !ok {
+		return false, InternalErrorf("store.toml has %T for key %q, want a JSON string", stored, storeKey)
+	}
+	if err := json.Unmarshal([]byte(data), v); err != nil {
+		return false, InternalErrorf("decoding store.toml into %T: %v", v, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,96 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/buildpacks/libcnb"
+)
+
+type storedVersion struct {
+	Runtime  string
+	Version  string
+	Patches  []int
+	Resolved *string
+}
+
+func TestStoreRoundTrip(t *testing.T) {
+	want := storedVersion{Runtime: "python", Version: "3.11.4", Patches: []int{1, 2}}
+
+	ctx := NewContext(WithBuildContext(libcnb.BuildContext{}))
+	if err := ctx.SaveStore(want); err != nil {
+		t.Fatalf("SaveStore() got error: %v", err)
+	}
+
+	// Write and read store.toml the way libcnb does between builds.
+	path := filepath.Join(t.TempDir(), "store.toml")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("creating %s: %v", path, err)
+	}
+	if err := toml.NewEncoder(f).Encode(libcnb.Store{Metadata: ctx.buildResult.PersistentMetadata}); err != nil {
+		t.Fatalf("encoding %s: %v", path, err)
+	}
+	f.Close()
+	var store libcnb.Store
+	if _, err := toml.DecodeFile(path, &store); err != nil {
+		t.Fatalf("decoding %s: %v", path, err)
+	}
+
+	next := NewContext(WithBuildContext(libcnb.BuildContext{PersistentMetadata: store.Metadata}))
+	var got storedVersion
+	found, err := next.LoadStore(&got)
+	if err != nil {
+		t.Fatalf("LoadStore() got error: %v", err)
+	}
+	if !found {
+		t.Fatal("LoadStore() = false, want true")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadStore() = %#v, want %#v", got, want)
+	}
+}
+
+func TestLoadStoreEmpty(t *testing.T) {
+	ctx := NewContext(WithBuildContext(libcnb.BuildContext{}))
+	got := storedVersion{Version: "unchanged"}
+	found, err := ctx.LoadStore(&got)
+	if err != nil {
+		t.Fatalf("LoadStore() got error: %v", err)
+	}
+	if found || got.Version != "unchanged" {
+		t.Errorf("LoadStore() = %t, %#v, want false and an unchanged value", found, got)
+	}
+}
+
+func TestStoreErrors(t *testing.T) {
+	ctx := NewContext(WithBuildContext(libcnb.BuildContext{PersistentMetadata: map[string]interface{}{storeKey: "{not json"}}))
+	if err := ctx.SaveStore(make(chan int)); err == nil {
+		t.Error("SaveStore() of a channel got no error, want error")
+	}
+	var v storedVersion
+	if _, err := ctx.LoadStore(&v); err == nil {
+		t.Error("LoadStore() of invalid JSON got no error, want error")
+	}
+	ctx = NewContext(WithBuildContext(libcnb.BuildContext{PersistentMetadata: map[string]interface{}{storeKey: int64(1)}}))
+	if _, err := ctx.LoadStore(&v); err == nil {
+		t.Error("LoadStore() of a number got no error, want error")
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"sort"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/builderoutput"
+)
+
+// maxOutputTimings is the number of slowest timings recorded in the builder output,
+// which is shared by all buildpacks and limited in size.
+const maxOutputTimings = 3
+
+type timing struct {
+	name     string
+	duration time.Duration
+}
+
+// Timer starts timing the named operation, such as a phase of the build or a command, and returns
+// a function that stops the timer. The durations of stopped timers are summarized at the end of the build.
+// Stopping a timer more than once has no effect.
+func (ctx *Context) Timer(name string) func() {
+	start := time.Now()
+	stopped := false
+	return func() {
+		if stopped {
+			return
+		}
+		stopped = true
+		ctx.stats.timings = append(ctx.stats.timings, timing{name: name, duration: time.Since(start)})
+	}
+}
+
+// slowestTimings returns at most n timings, slowest first.
+func (ctx *Context) slowestTimings(n int) []timing {
+	timings := make([]timing, len(ctx.stats.timings))
+	copy(timings, ctx.stats.timings)
+	sort.SliceStable(timings, func(i, j int) bool {
+		return timings[i].duration > timings[j].duration
+	})
+	if n >= 0 && len(timings) > n {
+		timings = timings[:n]
+	}
+	return timings
+}
+
+// logTimingSummary logs the recorded timings, slowest first.
+func (ctx *Context) logTimingSummary(logf func(format string, args ...interface{})) {
+	if len(ctx.stats.timings) == 0 {
+		return
+	}
+	logf("Timings of %s:", ctx.BuildpackID())
+	for _, t := range ctx.slowestTimings(-1) {
+		logf("  %-12v %s", t.duration.Round(time.Millisecond), t.name)
+	}
+}
+
+// outputTimings returns the slowest timings for the builder output.
+func (ctx *Context) outputTimings() []builderoutput.BuilderTiming {
+	var timings []builderoutput.BuilderTiming
+	for _, t := range ctx.slowestTimings(maxOutputTimings) {
+		timings = append(timings, builderoutput.BuilderTiming{Name: t.name, DurationMs: t.duration.Milliseconds()})
+	}
+	return timings
+}
@@ -0,0 +1,117 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"bytes"
+	"log"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/builderoutput"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTimer(t *testing.T) {
+	ctx := NewContext()
+
+	stop := ctx.Timer("sleep")
+	time.Sleep(10 * time.Millisecond)
+	stop()
+	stop()
+
+	if len(ctx.stats.timings) != 1 {
+		t.Fatalf("len(timings)=%d, want 1", len(ctx.stats.timings))
+	}
+	got := ctx.stats.timings[0]
+	if got.name != "sleep" {
+		t.Errorf("timing name=%q, want %q", got.name, "sleep")
+	}
+	if got.duration < 10*time.Millisecond {
+		t.Errorf("timing duration=%v, want at least 10ms", got.duration)
+	}
+}
+
+func TestExecRecordsTiming(t *testing.T) {
+	ctx := NewContext()
+
+	ctx.Exec([]string{"echo", "hello"})
+
+	if len(ctx.stats.timings) != 1 || ctx.stats.timings[0].name != `Exec "echo hello"` {
+		t.Errorf("timings=%#v, want a single timing of the command", ctx.stats.timings)
+	}
+}
+
+func TestLogTimingSummary(t *testing.T) {
+	buf := new(bytes.Buffer)
+	ctx := NewContext(WithLogger(log.New(buf, "", 0)))
+	ctx.stats.timings = []timing{
+		{name: `Exec "npm run build"`, duration: 2 * time.Second},
+		{name: `Exec "npm ci"`, duration: 30 * time.Second},
+		{name: "Build", duration: 35 * time.Second},
+	}
+
+	ctx.logTimingSummary(ctx.Logf)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	want := []string{"Build", `Exec "npm ci"`, `Exec "npm run build"`}
+	if len(lines) != len(want)+1 {
+		t.Fatalf("logTimingSummary() logged %q, want a header and %d timings", buf.String(), len(want))
+	}
+	for i, name := range want {
+		if line := lines[i+1]; !strings.HasSuffix(line, name) {
+			t.Errorf("logTimingSummary() line %d = %q, want it to end with %q", i+1, line, name)
+		}
+	}
+	if !strings.Contains(lines[2], "30s") {
+		t.Errorf("logTimingSummary() line 2 = %q, want it to contain the duration 30s", lines[2])
+	}
+}
+
+func TestSaveSuccessOutputTimings(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("BUILDER_OUTPUT", tempDir)
+	ctx := NewContext()
+	ctx.stats.timings = []timing{
+		{name: "a", duration: 1 * time.Millisecond},
+		{name: "b", duration: 4 * time.Millisecond},
+		{name: "c", duration: 3 * time.Millisecond},
+		{name: "d", duration: 2 * time.Millisecond},
+	}
+
+	ctx.saveSuccessOutput(10 * time.Millisecond)
+
+	content, err := ctx.ReadFile(filepath.Join(tempDir, builderOutputFilename))
+	if err != nil {
+		t.Fatalf("Failed to read builder output: %v", err)
+	}
+	bo, err := builderoutput.FromJSON(content)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal builder output: %v", err)
+	}
+	if len(bo.Stats) != 1 {
+		t.Fatalf("len(Stats)=%d, want 1", len(bo.Stats))
+	}
+	want := []builderoutput.BuilderTiming{
+		{Name: "b", DurationMs: 4},
+		{Name: "c", DurationMs: 3},
+		{Name: "d", DurationMs: 2},
+	}
+	if diff := cmp.Diff(want, bo.Stats[0].Timings); diff != "" {
+		t.Errorf("Timings mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
+)
+
+const (
+	// VersionCategoryRuntime is the category of language runtimes, e.g. Node.js or the JDK.
+	VersionCategoryRuntime = "runtime"
+	// VersionCategoryPackageManager is the category of package managers, e.g. npm or Composer.
+	VersionCategoryPackageManager = "package_manager"
+	// VersionCategoryBuildTool is the category of build tools, e.g. Maven or Gradle.
+	VersionCategoryBuildTool = "build_tool"
+	// VersionCategoryLibrary is the category of native libraries, e.g. ICU.
+	VersionCategoryLibrary = "library"
+
+	// versionsLayer is the name of the layer that holds the versions recorded by a buildpack.
+	versionsLayer = "versions"
+	// versionsFile is the name of the versions report file.
+	versionsFile = "versions.json"
+	// versionsReportEnv lists the versions reports of all buildpacks, in the order they ran,
+	// separated by os.PathListSeparator.
+	versionsReportEnv = "X_GOOGLE_VERSIONS_REPORT"
+)
+
+// VersionEntry is a resolved version recorded by a buildpack.
+type VersionEntry struct {
+	Category  string `json:"category"`
+	Name      string `json:"name"`
+	Version   string `json:"version"`
+	Buildpack string `json:"buildpack"`
+}
+
+// VersionsReport lists the versions resolved by all buildpacks that contributed to an image.
+type VersionsReport struct {
+	Versions []VersionEntry `json:"versions"`
+}
+
+// RecordVersion records the resolved version of a runtime, package manager, build tool or library.
+// The recorded versions of all buildpacks are aggregated by ReadVersionsReport.
+func (ctx *Context) RecordVersion(category, name, version string) {
+	ctx.versions = append(ctx.versions, VersionEntry{
+		Category:  category,
+		Name:      name,
+		Version:   version,
+		Buildpack: ctx.BuildpackID(),
+	})
+}
+
+// ReadVersionsReport returns the versions recorded by all buildpacks that ran before, aggregated
+// from the reports listed in X_GOOGLE_VERSIONS_REPORT.
+func ReadVersionsReport() (VersionsReport, error) {
+	var report VersionsReport
+	for _, path := range filepath.SplitList(os.Getenv(versionsReportEnv)) {
+		if path == "" {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return VersionsReport{}, buildererror.Errorf(buildererror.StatusInternal, "reading %s: %v", path, err)
+		}
+		var r VersionsReport
+		if err := json.Unmarshal(data, &r); err != nil {
+			return VersionsReport{}, buildererror.Errorf(buildererror.StatusInternal, "parsing %s: %v", path, err)
+		}
+		report.Versions = append(report.Versions, r.Versions...)
+	}
+	return report, nil
+}
+
+// saveVersionsReport writes the versions recorded by this buildpack to a versions report in a
+// launch layer, and appends it to the reports listed in the layer environment, so that
+// ReadVersionsReport of subsequent buildpacks and the application includes them.
+func (ctx *Context) saveVersionsReport() error {
+	if len(ctx.versions) == 0 {
+		return nil
+	}
+	data, err := json.MarshalIndent(VersionsReport{Versions: ctx.versions}, "", "  ")
+	if err != nil {
+		return buildererror.Errorf(buildererror.StatusInternal, "marshalling versions report: %v", err)
+	}
+	l, err := ctx.Layer(versionsLayer, BuildLayer, LaunchLayer)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(l.Path, versionsFile)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return buildererror.Errorf(buildererror.StatusInternal, "writing %s: %v", path, err)
+	}
+	l.SharedEnvironment.Append(versionsReportEnv, string(os.PathListSeparator), path)
+	ctx.Debugf("Wrote the versions report to %s.", path)
+	return nil
+}
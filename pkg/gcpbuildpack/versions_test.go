@@ -0,0 +1,97 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/buildpacks/libcnb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSaveVersionsReport(t *testing.T) {
+	t.Setenv(versionsReportEnv, "")
+
+	nodeCtx := NewContext(WithBuildpackInfo(libcnb.BuildpackInfo{ID: "google.nodejs.runtime"}), WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: t.TempDir()}}))
+	nodeCtx.RecordVersion(VersionCategoryRuntime, "nodejs", "16.17.1")
+	nodeCtx.RecordVersion(VersionCategoryPackageManager, "npm", "8.15.0")
+	if err := nodeCtx.saveVersionsReport(); err != nil {
+		t.Fatalf("saveVersionsReport() got error: %v", err)
+	}
+	nodeReport := versionsReportLayer(t, nodeCtx)
+	if got, want := nodeReport.SharedEnvironment["X_GOOGLE_VERSIONS_REPORT.append"], filepath.Join(nodeReport.Path, versionsFile); got != want {
+		t.Fatalf("versions layer environment %s=%q, want %q", versionsReportEnv, got, want)
+	}
+
+	// Subsequent buildpacks see the report of the previous buildpack through the layer environment,
+	// and only write the versions they record.
+	t.Setenv(versionsReportEnv, filepath.Join(nodeReport.Path, versionsFile))
+	yarnCtx := NewContext(WithBuildpackInfo(libcnb.BuildpackInfo{ID: "google.nodejs.yarn"}), WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: t.TempDir()}}))
+	yarnCtx.RecordVersion(VersionCategoryPackageManager, "yarn", "1.22.19")
+	if err := yarnCtx.saveVersionsReport(); err != nil {
+		t.Fatalf("saveVersionsReport() got error: %v", err)
+	}
+	yarnReport := filepath.Join(versionsReportLayer(t, yarnCtx).Path, versionsFile)
+	data, err := os.ReadFile(yarnReport)
+	if err != nil {
+		t.Fatalf("reading versions report: %v", err)
+	}
+	var own VersionsReport
+	if err := json.Unmarshal(data, &own); err != nil {
+		t.Fatalf("parsing versions report: %v", err)
+	}
+	if len(own.Versions) != 1 {
+		t.Errorf("versions report of google.nodejs.yarn has %d entries, want 1", len(own.Versions))
+	}
+
+	t.Setenv(versionsReportEnv, os.Getenv(versionsReportEnv)+string(os.PathListSeparator)+yarnReport)
+	got, err := ReadVersionsReport()
+	if err != nil {
+		t.Fatalf("ReadVersionsReport() got error: %v", err)
+	}
+	want := VersionsReport{Versions: []VersionEntry{
+		{Category: "runtime", Name: "nodejs", Version: "16.17.1", Buildpack: "google.nodejs.runtime"},
+		{Category: "package_manager", Name: "npm", Version: "8.15.0", Buildpack: "google.nodejs.runtime"},
+		{Category: "package_manager", Name: "yarn", Version: "1.22.19", Buildpack: "google.nodejs.yarn"},
+	}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ReadVersionsReport() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestSaveVersionsReportNothingRecorded(t *testing.T) {
+	ctx := NewContext(WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: t.TempDir()}}))
+	if err := ctx.saveVersionsReport(); err != nil {
+		t.Fatalf("saveVersionsReport() got error: %v", err)
+	}
+	if len(ctx.buildResult.Layers) != 0 {
+		t.Errorf("got %d layers, want 0", len(ctx.buildResult.Layers))
+	}
+}
+
+func versionsReportLayer(t *testing.T, ctx *Context) *libcnb.Layer {
+	t.Helper()
+	if len(ctx.buildResult.Layers) != 1 {
+		t.Fatalf("got %d layers, want 1", len(ctx.buildResult.Layers))
+	}
+	l := ctx.buildResult.Layers[0].(layerContributor).l
+	if !l.Launch || !l.Build || l.Cache {
+		t.Errorf("versions layer got launch=%t build=%t cache=%t, want launch=true build=true cache=false", l.Launch, l.Build, l.Cache)
+	}
+	return l
+}
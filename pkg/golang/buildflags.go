@@ -0,0 +1,121 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// buildTagRegexp matches a single valid build tag.
+var buildTagRegexp = regexp.MustCompile(`^[A-Za-z0-9_.]+$`)
+
+// BuildTags returns the comma-separated build tags configured with GOOGLE_GOBUILD_TAGS. Tags may be
+// separated by commas or whitespace and may only contain letters, digits, underscores and dots.
+func BuildTags() (string, error) {
+	v := os.Getenv(env.GoBuildTags)
+	tags := strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' || r == '\n' })
+	for _, tag := range tags {
+		if !buildTagRegexp.MatchString(tag) {
+			return "", gcp.UserErrorf("invalid build tag %q in %s: tags may only contain letters, digits, underscores and dots", tag, env.GoBuildTags)
+		}
+	}
+	return strings.Join(tags, ","), nil
+}
+
+// BuildLDFlags returns the linker flags configured with GOOGLE_GOBUILD_LDFLAGS, normalized to a
+// single -ldflags value. The value is split without a shell, see SplitArgs.
+func BuildLDFlags() (string, error) {
+	args, err := SplitArgs(os.Getenv(env.GoBuildLDFlags))
+	if err != nil {
+		return "", gcp.UserErrorf("parsing %s: %v", env.GoBuildLDFlags, err)
+	}
+	return JoinArgs(args), nil
+}
+
+// linkmodes are the linker modes accepted by the -linkmode flag of go tool link.
+var linkmodes = []string{"auto", "internal", "external"}
+
+// Linkmode returns the linker mode configured with GOOGLE_GO_LINKMODE, or "" if it is not set.
+func Linkmode() string {
+	return strings.TrimSpace(os.Getenv(env.GoLinkmode))
+}
+
+// LinkmodeFlag returns the linker flag that selects the given linker mode, e.g. -linkmode=external,
+// or "" if mode is empty. It returns an error if mode is not a mode known to the Go linker.
+func LinkmodeFlag(mode string) (string, error) {
+	if mode == "" {
+		return "", nil
+	}
+	for _, m := range linkmodes {
+		if mode == m {
+			return "-linkmode=" + mode, nil
+		}
+	}
+	return "", gcp.UserErrorf("invalid linker mode %q in %s, must be one of %s", mode, env.GoLinkmode, strings.Join(linkmodes, ", "))
+}
+
+// SplitArgs splits s into arguments separated by whitespace. An argument may be enclosed in single
+// or double quotes to include whitespace; there are no escape sequences and no other shell
+// expansion. This matches how the go command splits the values of flags such as -ldflags.
+func SplitArgs(s string) ([]string, error) {
+	var args []string
+	for {
+		s = strings.TrimLeft(s, " \t\n\r")
+		if s == "" {
+			return args, nil
+		}
+		if q := s[0]; q == '\'' || q == '"' {
+			end := strings.IndexByte(s[1:], q)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated %c quote in %q", q, s)
+			}
+			args = append(args, s[1:end+1])
+			s = s[end+2:]
+			continue
+		}
+		end := strings.IndexAny(s, " \t\n\r")
+		if end < 0 {
+			end = len(s)
+		}
+		arg := s[:end]
+		if strings.ContainsAny(arg, `'"`) {
+			return nil, fmt.Errorf("quotes must enclose an entire argument in %q", arg)
+		}
+		args = append(args, arg)
+		s = s[end:]
+	}
+}
+
+// JoinArgs is the inverse of SplitArgs, quoting arguments that contain whitespace or quotes.
+func JoinArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		switch {
+		case strings.ContainsRune(arg, '\''):
+			quoted[i] = `"` + arg + `"`
+		case arg == "" || strings.ContainsAny(arg, " \t\n\r\""):
+			quoted[i] = "'" + arg + "'"
+		default:
+			quoted[i] = arg
+		}
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,183 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+func TestSplitArgs(t *testing.T) {
+	testCases := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:  "whitespace separated",
+			input: " -s  -w\t-X main.version=1.2.3 ",
+			want:  []string{"-s", "-w", "-X", "main.version=1.2.3"},
+		},
+		{
+			name:  "single quoted",
+			input: `-X 'main.message=hello world'`,
+			want:  []string{"-X", "main.message=hello world"},
+		},
+		{
+			name:  "double quoted with single quote",
+			input: `-X "main.message=it's here"`,
+			want:  []string{"-X", "main.message=it's here"},
+		},
+		{
+			name:  "no shell expansion",
+			input: `-X main.version=$(git describe) -X main.home=$HOME;rm`,
+			want:  []string{"-X", "main.version=$(git", "describe)", "-X", "main.home=$HOME;rm"},
+		},
+		{
+			name:  "empty quoted argument",
+			input: `-X ''`,
+			want:  []string{"-X", ""},
+		},
+		{
+			name:    "unterminated quote",
+			input:   `-X 'main.version=1.2.3`,
+			wantErr: true,
+		},
+		{
+			name:    "quote inside argument",
+			input:   `-X main.version='1.2.3'`,
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := SplitArgs(tc.input)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("SplitArgs(%q) got err=%t, want err=%t. err: %v", tc.input, gotErr, tc.wantErr, err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("SplitArgs(%q)=%q, want %q", tc.input, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestJoinArgsRoundTrip(t *testing.T) {
+	for _, args := range [][]string{
+		{"-s", "-w"},
+		{"-X", "main.message=hello world"},
+		{"-X", "main.message=it's here"},
+		{"-X", `main.message=say "hi"`},
+		{"-X", ""},
+	} {
+		joined := JoinArgs(args)
+		got, err := SplitArgs(joined)
+		if err != nil {
+			t.Fatalf("SplitArgs(JoinArgs(%q)) got error: %v", args, err)
+		}
+		if !reflect.DeepEqual(got, args) {
+			t.Errorf("SplitArgs(JoinArgs(%q))=%q via %q", args, got, joined)
+		}
+	}
+}
+
+func TestLinkmodeFlag(t *testing.T) {
+	testCases := []struct {
+		mode    string
+		want    string
+		wantErr bool
+	}{
+		{mode: ""},
+		{mode: "auto", want: "-linkmode=auto"},
+		{mode: "internal", want: "-linkmode=internal"},
+		{mode: "external", want: "-linkmode=external"},
+		{mode: "static", wantErr: true},
+		{mode: "External", wantErr: true},
+		{mode: "external -X main.version=1", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.mode, func(t *testing.T) {
+			got, err := LinkmodeFlag(tc.mode)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("LinkmodeFlag(%q) got err=%t, want err=%t. err: %v", tc.mode, gotErr, tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Errorf("LinkmodeFlag(%q)=%q, want %q", tc.mode, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBuildTags(t *testing.T) {
+	testCases := []struct {
+		name    string
+		env     string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name: "comma separated",
+			env:  "netgo,osusergo",
+			want: "netgo,osusergo",
+		},
+		{
+			name: "whitespace separated",
+			env:  " netgo  osusergo go1.18 ",
+			want: "netgo,osusergo,go1.18",
+		},
+		{
+			name:    "flag injection",
+			env:     "netgo -ldflags=-X",
+			wantErr: true,
+		},
+		{
+			name:    "shell metacharacters",
+			env:     "netgo;rm",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(env.GoBuildTags, tc.env)
+			got, err := BuildTags()
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("BuildTags() got err=%t, want err=%t. err: %v", gotErr, tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Errorf("BuildTags()=%q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBuildLDFlags(t *testing.T) {
+	t.Setenv(env.GoBuildLDFlags, `-s  -w -X "main.message=hello world"`)
+	got, err := BuildLDFlags()
+	if err != nil {
+		t.Fatalf("BuildLDFlags() got error: %v", err)
+	}
+	if want := `-s -w -X 'main.message=hello world'`; got != want {
+		t.Errorf("BuildLDFlags()=%q, want %q", got, want)
+	}
+}
@@ -232,7 +232,25 @@ func goModPath(ctx *gcp.Context) string {
 // Before Go 1.14, Go would fall back to direct only if a 404 or 410 error ocurred, for those
 // versions, we explictly disable GOPROXY and try again on any error.
 // For newer versions of Go, we take advantage of the "pipe" character which has the same effect.
+// The command runs with the module download environment, see ModuleDownloadEnv. A GOPROXY set by
+// the user, or by GOOGLE_GOPROXY, is used as is, without a fallback.
 func ExecWithGoproxyFallback(ctx *gcp.Context, cmd []string, opts ...gcp.ExecOption) (*gcp.ExecResult, error) {
+	menvOpts, err := ModuleDownloadOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(menvOpts, opts...)
+	userProxy, err := ProxySettings(os.Getenv(env.GoProxy))
+	if err != nil {
+		return nil, err
+	}
+	if userProxy == ProxyOff {
+		return ctx.Exec(cmd, append(opts, gcp.WithEnv("GOPROXY="+ProxyOff))...), nil
+	}
+	if proxy := os.Getenv("GOPROXY"); userProxy != "" || (proxy != "" && proxy != ProxyOff) {
+		return ctx.Exec(cmd, opts...), nil
+	}
+
 	supportsGoProxy, err := SupportsGoProxyFallback(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("checking for go proxy support: %w", err)
@@ -252,6 +270,62 @@ func ExecWithGoproxyFallback(ctx *gcp.Context, cmd []string, opts ...gcp.ExecOpt
 	return ctx.Exec(cmd, opts...), nil
 }
 
+// RunGenerate runs `go generate ./...` in the build directory if GOOGLE_GO_GENERATE is enabled. The
+// generated files are written to the application source and compiled by the subsequent `go build`,
+// they are not cached separately. A failing generator fails the build.
+func RunGenerate(ctx *gcp.Context) error {
+	enabled, err := env.IsPresentAndTrue(env.GoGenerate)
+	if err != nil {
+		return gcp.UserErrorf("%v", err)
+	}
+	if !enabled {
+		ctx.Debugf("Skipping go generate because %s is not set.", env.GoGenerate)
+		return nil
+	}
+	workdir := os.Getenv(BuildDirEnv)
+	if workdir == "" {
+		workdir = ctx.ApplicationRoot()
+	}
+	if _, err := ctx.ExecWithErr([]string{"go", "generate", "./..."}, gcp.WithWorkDir(workdir), gcp.WithUserAttribution); err != nil {
+		return err
+	}
+	return nil
+}
+
+// TestGateEnabled returns whether GOOGLE_GO_TEST requests running the tests before the build.
+func TestGateEnabled() (bool, error) {
+	enabled, err := env.IsPresentAndTrue(env.GoTest)
+	if err != nil {
+		return false, gcp.UserErrorf("%v", err)
+	}
+	return enabled, nil
+}
+
+// TestGate runs `go test ./...` in the build directory if GOOGLE_GO_TEST is enabled. A failing test
+// fails the build. The tests use the GOCACHE of the build environment, which the go/build buildpack
+// points at its GOCACHE layer and keeps across builds while the gate is enabled, so that the
+// results of tests whose inputs have not changed are reused instead of running them again.
+func TestGate(ctx *gcp.Context) error {
+	enabled, err := TestGateEnabled()
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		ctx.Debugf("Skipping go test because %s is not set.", env.GoTest)
+		return nil
+	}
+	workdir := os.Getenv(BuildDirEnv)
+	if workdir == "" {
+		workdir = ctx.ApplicationRoot()
+	}
+	ctx.Logf("Running go test ./... with GOCACHE=%s.", os.Getenv("GOCACHE"))
+	if _, err := ctx.ExecWithErr([]string{"go", "test", "./..."}, gcp.WithWorkDir(workdir), gcp.WithUserAttribution, gcp.WithStreamedOutput, gcp.WithCombinedTail); err != nil {
+		err.Message = fmt.Sprintf("go test failed, fix the failing tests or unset %s: %s", env.GoTest, err.Message)
+		return err
+	}
+	return nil
+}
+
 // IsGo111Runtime returns true when the GOOGLE_RUNTIME is go111. This will be
 // true when using GCF or GAE with go 1.11.
 func IsGo111Runtime() bool {
@@ -15,12 +15,17 @@
 package golang
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
+	"log"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/testdata"
 	"github.com/buildpacks/libcnb"
 
@@ -534,3 +539,65 @@ func mockCleanModCache(t *testing.T) {
 		cleanModCache = origCleanModCache
 	})
 }
+
+func TestTestGateReusesCache(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiling the tests into an empty GOCACHE is slow")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go is not installed")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":      "module example.com/app\n\ngo 1.16\n",
+		"app.go":      "package app\n\nfunc Add(a, b int) int { return a + b }\n",
+		"app_test.go": "package app\n\nimport \"testing\"\n\nfunc TestAdd(t *testing.T) {\n\tif Add(1, 2) != 3 {\n\t\tt.Error(\"Add(1, 2) != 3\")\n\t}\n}\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("writing %s: %v", name, err)
+		}
+	}
+	gocache := t.TempDir()
+	t.Setenv("GOCACHE", gocache)
+	t.Setenv("GOFLAGS", "")
+	t.Setenv(env.GoTest, "true")
+	t.Setenv(BuildDirEnv, "")
+
+	run := func() string {
+		t.Helper()
+		var buf bytes.Buffer
+		ctx := gcp.NewContext(gcp.WithApplicationRoot(dir), gcp.WithLogger(log.New(&buf, "", 0)))
+		if err := TestGate(ctx); err != nil {
+			t.Fatalf("TestGate() got error: %v", err)
+		}
+		return buf.String()
+	}
+
+	if first := run(); strings.Contains(first, "(cached)") {
+		t.Errorf("TestGate() first run got cached results, want the tests to run:\n%s", first)
+	}
+	entries, err := ioutil.ReadDir(gocache)
+	if err != nil {
+		t.Fatalf("reading GOCACHE: %v", err)
+	}
+	if len(entries) == 0 {
+		t.Errorf("TestGate() did not write to GOCACHE %s", gocache)
+	}
+	if second := run(); !strings.Contains(second, "(cached)") {
+		t.Errorf("TestGate() second run did not reuse the cached test results:\n%s", second)
+	}
+}
+
+func TestTestGateDisabled(t *testing.T) {
+	t.Setenv(env.GoTest, "false")
+	var buf bytes.Buffer
+	ctx := gcp.NewContext(gcp.WithApplicationRoot(t.TempDir()), gcp.WithLogger(log.New(&buf, "", 0)))
+
+	if err := TestGate(ctx); err != nil {
+		t.Fatalf("TestGate() got error: %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("TestGate() logged %q, want no output", buf.String())
+	}
+}
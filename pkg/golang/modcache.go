@@ -0,0 +1,69 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+// goModCacheLayerName is the name of the layer where the module cache is stored.
+const goModCacheLayerName = "gomodcache"
+
+// goModCacheMaxBytes is the size above which the module cache layer is cleared before modules are
+// downloaded.
+var goModCacheMaxBytes int64 = 2 << 30
+
+// cleanGoModCache deletes the module cache in dir using `go clean -modcache`, as its files are
+// read-only. It can be overridden for testing.
+var cleanGoModCache = func(ctx *gcp.Context, dir string) error {
+	if _, err := ctx.ExecWithErr([]string{"go", "clean", "-modcache"}, gcp.WithEnv("GOMODCACHE="+dir)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// NewGoModCacheLayer returns a layer for GOMODCACHE, the module download cache. Unlike the GOPATH
+// layer, it is not cleared when go.mod changes, so that a build after a code-only or dependency
+// change only downloads the modules that it has not already fetched. The layer is cleared once it
+// exceeds its maximum size. GOMODCACHE requires Go 1.15+; older versions keep modules in GOPATH.
+func NewGoModCacheLayer(ctx *gcp.Context) (*libcnb.Layer, error) {
+	l, err := ctx.Layer(goModCacheLayerName, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayerIfDevMode)
+	if err != nil {
+		return nil, fmt.Errorf("creating %v layer: %w", goModCacheLayerName, err)
+	}
+	l.BuildEnvironment.Override("GOMODCACHE", l.Path)
+
+	size, err := cache.Size(l.Path)
+	if err != nil {
+		return nil, err
+	}
+	ctx.Debugf("Module cache holds %d bytes, the maximum is %d bytes.", size, goModCacheMaxBytes)
+	if size <= goModCacheMaxBytes {
+		if size > 0 {
+			ctx.CacheHit(goModCacheLayerName)
+		}
+		return l, nil
+	}
+	ctx.Logf("Clearing the module cache, which holds %d bytes, more than the maximum of %d bytes.", size, goModCacheMaxBytes)
+	ctx.CacheMiss(goModCacheLayerName)
+	if err := cleanGoModCache(ctx, l.Path); err != nil {
+		return nil, fmt.Errorf("clearing the module cache: %w", err)
+	}
+	return l, nil
+}
@@ -0,0 +1,108 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestNewGoModCacheLayer(t *testing.T) {
+	testCases := []struct {
+		name      string
+		cached    map[string]string
+		maxBytes  int64
+		wantClean bool
+	}{
+		{
+			name:     "empty cache",
+			maxBytes: 10,
+		},
+		{
+			name:     "cache under the maximum",
+			cached:   map[string]string{"cache/download/example.com/m/@v/v1.0.0.zip": "12345"},
+			maxBytes: 10,
+		},
+		{
+			name: "cache over the maximum",
+			cached: map[string]string{
+				"cache/download/example.com/m/@v/v1.0.0.zip": "12345",
+				"example.com/m@v1.0.0/m.go":                  "package m",
+			},
+			maxBytes:  10,
+			wantClean: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			layers := t.TempDir()
+			for name, content := range tc.cached {
+				path := filepath.Join(layers, goModCacheLayerName, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("creating directory: %v", err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatalf("writing %s: %v", path, err)
+				}
+			}
+			origMaxBytes := goModCacheMaxBytes
+			goModCacheMaxBytes = tc.maxBytes
+			t.Cleanup(func() { goModCacheMaxBytes = origMaxBytes })
+			var cleaned string
+			origClean := cleanGoModCache
+			cleanGoModCache = func(_ *gcp.Context, dir string) error {
+				cleaned = dir
+				return nil
+			}
+			t.Cleanup(func() { cleanGoModCache = origClean })
+
+			var buf bytes.Buffer
+			ctx := gcp.NewContext(
+				gcp.WithApplicationRoot(t.TempDir()),
+				gcp.WithLogger(log.New(&buf, "", 0)),
+				gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: layers}}))
+
+			l, err := NewGoModCacheLayer(ctx)
+			if err != nil {
+				t.Fatalf("NewGoModCacheLayer() got error: %v", err)
+			}
+			if want := filepath.Join(layers, goModCacheLayerName); l.Path != want {
+				t.Errorf("layer path = %q, want %q", l.Path, want)
+			}
+			if !l.Cache {
+				t.Error("layer.Cache = false, want true")
+			}
+			if got := l.BuildEnvironment["GOMODCACHE.override"]; got != l.Path {
+				t.Errorf("GOMODCACHE = %q, want the layer path %q", got, l.Path)
+			}
+			if gotClean := cleaned != ""; gotClean != tc.wantClean {
+				t.Errorf("module cache cleared: %t, want %t, logs: %s", gotClean, tc.wantClean, buf.String())
+			}
+			if tc.wantClean && cleaned != l.Path {
+				t.Errorf("cleared module cache %q, want %q", cleaned, l.Path)
+			}
+			if tc.wantClean && !strings.Contains(buf.String(), "Clearing the module cache") {
+				t.Errorf("logs = %q, want a note that the module cache was cleared", buf.String())
+			}
+		})
+	}
+}
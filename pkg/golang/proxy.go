@@ -0,0 +1,99 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// ProxyOff is the GOPROXY that disallows module downloads.
+	ProxyOff = "off"
+	// proxyDirect is the GOPROXY element that downloads modules directly from their repositories.
+	proxyDirect = "direct"
+)
+
+// proxySeparatorRegexp matches the separators of a GOPROXY list: `,` falls back to the next
+// element only on a 404 or 410 response, `|` on any error.
+var proxySeparatorRegexp = regexp.MustCompile(`[,|]`)
+
+// ProxySettings returns the GOPROXY for the module downloads of the build from value, the value of
+// GOOGLE_GOPROXY, with the whitespace around its elements removed. It returns an empty string if
+// value is empty, in which case GOPROXY is left to the build environment. Each element must be
+// `direct`, `off` or an http, https or file URL.
+func ProxySettings(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", nil
+	}
+	elements := proxySeparatorRegexp.Split(value, -1)
+	separators := proxySeparatorRegexp.FindAllString(value, -1)
+	var b strings.Builder
+	for i, e := range elements {
+		e = strings.TrimSpace(e)
+		if err := validateProxy(e); err != nil {
+			return "", gcp.UserErrorf("invalid %s=%q: %v", env.GoProxy, RedactCredentials(value), err)
+		}
+		b.WriteString(e)
+		if i < len(separators) {
+			b.WriteString(separators[i])
+		}
+	}
+	return b.String(), nil
+}
+
+// validateProxy returns an error if e is not a valid element of a GOPROXY list.
+func validateProxy(e string) error {
+	switch e {
+	case "":
+		return fmt.Errorf("empty proxy in the list")
+	case proxyDirect, ProxyOff:
+		return nil
+	}
+	u, err := url.Parse(e)
+	if err != nil {
+		return fmt.Errorf("proxy %q is not a URL", RedactCredentials(e))
+	}
+	switch u.Scheme {
+	case "http", "https":
+		if u.Host == "" {
+			return fmt.Errorf("proxy %q has no host", RedactCredentials(e))
+		}
+	case "file":
+		if u.Path == "" {
+			return fmt.Errorf("proxy %q has no path", e)
+		}
+	default:
+		return fmt.Errorf("proxy %q must be an http, https or file URL, or %q or %q", RedactCredentials(e), proxyDirect, ProxyOff)
+	}
+	return nil
+}
+
+// ModuleDownloadsDisabled returns true if GOOGLE_GOPROXY is `off`, in which case modules are not
+// downloaded and the build uses the vendor directory and the module cache.
+func ModuleDownloadsDisabled() (bool, error) {
+	proxy, err := ProxySettings(os.Getenv(env.GoProxy))
+	if err != nil {
+		return false, err
+	}
+	return proxy == ProxyOff, nil
+}
@@ -0,0 +1,153 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// ModVendor is the -mod mode that builds using the vendor directory.
+	ModVendor = "vendor"
+	// ModMod is the -mod mode that ignores the vendor directory and downloads modules.
+	ModMod = "mod"
+)
+
+// VendorMode returns the -mod mode to build with: ModVendor if the application has a vendor
+// directory consistent with go.mod, ModMod if the vendor directory appears stale, and an empty string
+// if there's no vendor/modules.txt, the Go version does not support vendoring with modules or the
+// mode is set by the user with -mod in GOFLAGS.
+func VendorMode(ctx *gcp.Context) (string, error) {
+	if m := GoFlagsMod(); m != "" {
+		ctx.Debugf("Using -mod=%s from GOFLAGS.", m)
+		return "", nil
+	}
+	modulesTxtPath := filepath.Join(ctx.ApplicationRoot(), "vendor", "modules.txt")
+	modulesTxtExists, err := ctx.FileExists(modulesTxtPath)
+	if err != nil || !modulesTxtExists {
+		return "", err
+	}
+	supported, err := SupportsAutoVendor(ctx)
+	if err != nil {
+		return "", fmt.Errorf("checking for auto vendor support: %w", err)
+	}
+	if !supported {
+		return "", nil
+	}
+	goMod, err := readGoMod(ctx)
+	if err != nil {
+		return "", fmt.Errorf("reading go.mod: %w", err)
+	}
+	modulesTxt, err := ctx.ReadFile(modulesTxtPath)
+	if err != nil {
+		return "", err
+	}
+	if stale := staleVendoredModules(goMod, string(modulesTxt)); len(stale) > 0 {
+		ctx.Warnf("Ignoring \"vendor\" directory: vendor/modules.txt is inconsistent with go.mod for %s. Run `go mod vendor` to update it. Modules will be downloaded instead.", strings.Join(stale, ", "))
+		return ModMod, nil
+	}
+	return ModVendor, nil
+}
+
+// GoFlagsMod returns the value of the -mod flag set in GOFLAGS, or an empty string if it is not set.
+func GoFlagsMod() string {
+	mod := ""
+	for _, f := range strings.Fields(os.Getenv("GOFLAGS")) {
+		for _, prefix := range []string{"-mod=", "--mod="} {
+			if strings.HasPrefix(f, prefix) {
+				mod = strings.TrimPrefix(f, prefix)
+			}
+		}
+	}
+	return mod
+}
+
+// staleVendoredModules returns the modules whose requirement in go.mod does not match
+// vendor/modules.txt: modules required at a different version or missing from modules.txt, and
+// modules marked as explicitly required in modules.txt that are no longer required by go.mod.
+func staleVendoredModules(goMod, modulesTxt string) []string {
+	required := goModRequirements(goMod)
+	vendored := map[string]string{}
+	explicit := map[string]bool{}
+	var current string
+	for _, line := range strings.Split(modulesTxt, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "## "):
+			// Annotations are separated by semicolons, e.g. "## explicit; go 1.17".
+			for _, a := range strings.Split(line[3:], ";") {
+				if strings.TrimSpace(a) == "explicit" && current != "" {
+					explicit[current] = true
+				}
+			}
+		case strings.HasPrefix(line, "# "):
+			// The format is "# path version" optionally followed by "=> replacement".
+			fields := strings.Fields(line[2:])
+			current = ""
+			if len(fields) >= 2 && fields[0] != "=>" && fields[1] != "=>" {
+				current = fields[0]
+				vendored[current] = fields[1]
+			}
+		}
+	}
+
+	var stale []string
+	for path, version := range required {
+		if vendored[path] != version {
+			stale = append(stale, path)
+		}
+	}
+	for path := range explicit {
+		if _, ok := required[path]; !ok {
+			stale = append(stale, path)
+		}
+	}
+	sort.Strings(stale)
+	return stale
+}
+
+// goModRequirements returns the module versions required by the given go.mod contents.
+func goModRequirements(goMod string) map[string]string {
+	required := map[string]string{}
+	inBlock := false
+	for _, line := range strings.Split(goMod, "\n") {
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch {
+		case inBlock && fields[0] == ")":
+			inBlock = false
+		case inBlock:
+			if len(fields) >= 2 {
+				required[fields[0]] = fields[1]
+			}
+		case fields[0] == "require" && len(fields) == 2 && fields[1] == "(":
+			inBlock = true
+		case fields[0] == "require" && len(fields) >= 3:
+			required[fields[1]] = fields[2]
+		}
+	}
+	return required
+}
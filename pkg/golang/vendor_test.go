@@ -0,0 +1,166 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+const (
+	vendorTestGoMod = `module example.com/app
+
+go 1.17
+
+require (
+	github.com/google/uuid v1.3.0
+	golang.org/x/text v0.3.7 // indirect
+)
+
+require github.com/pkg/errors v0.9.1
+`
+	vendorTestModulesTxt = `# github.com/google/uuid v1.3.0
+## explicit
+github.com/google/uuid
+# github.com/pkg/errors v0.9.1
+## explicit
+github.com/pkg/errors
+# golang.org/x/text v0.3.7
+## explicit; go 1.17
+golang.org/x/text/language
+`
+)
+
+func TestStaleVendoredModules(t *testing.T) {
+	testCases := []struct {
+		name       string
+		goMod      string
+		modulesTxt string
+		want       []string
+	}{
+		{
+			name:       "consistent",
+			goMod:      vendorTestGoMod,
+			modulesTxt: vendorTestModulesTxt,
+		},
+		{
+			name:  "consistent with replacement",
+			goMod: "module example.com/app\n\nrequire github.com/pkg/errors v0.9.1\n\nreplace github.com/pkg/errors => ../errors\n",
+			modulesTxt: `# github.com/pkg/errors v0.9.1 => ../errors
+## explicit
+github.com/pkg/errors
+# github.com/pkg/errors => ../errors
+`,
+		},
+		{
+			name:       "different version",
+			goMod:      "module example.com/app\n\nrequire github.com/google/uuid v1.4.0\n",
+			modulesTxt: "# github.com/google/uuid v1.3.0\n## explicit\ngithub.com/google/uuid\n",
+			want:       []string{"github.com/google/uuid"},
+		},
+		{
+			name:       "missing from modules.txt",
+			goMod:      vendorTestGoMod,
+			modulesTxt: "# github.com/google/uuid v1.3.0\n## explicit\n# golang.org/x/text v0.3.7\n## explicit\n",
+			want:       []string{"github.com/pkg/errors"},
+		},
+		{
+			name:       "no longer required",
+			goMod:      "module example.com/app\n",
+			modulesTxt: "# github.com/google/uuid v1.3.0\n## explicit\ngithub.com/google/uuid\n",
+			want:       []string{"github.com/google/uuid"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := staleVendoredModules(tc.goMod, tc.modulesTxt)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("staleVendoredModules() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestVendorMode(t *testing.T) {
+	testCases := []struct {
+		name       string
+		goVersion  string
+		goMod      string
+		modulesTxt string
+		goflags    string
+		want       string
+	}{
+		{
+			name:       "consistent vendor directory",
+			goVersion:  "go version go1.17 linux/amd64",
+			goMod:      vendorTestGoMod,
+			modulesTxt: vendorTestModulesTxt,
+			want:       ModVendor,
+		},
+		{
+			name:       "stale vendor directory",
+			goVersion:  "go version go1.17 linux/amd64",
+			goMod:      vendorTestGoMod,
+			modulesTxt: "# github.com/google/uuid v1.2.0\n## explicit\n",
+			want:       ModMod,
+		},
+		{
+			name:      "no modules.txt",
+			goVersion: "go version go1.17 linux/amd64",
+			goMod:     vendorTestGoMod,
+		},
+		{
+			name:       "unsupported Go version",
+			goVersion:  "go version go1.13 linux/amd64",
+			goMod:      "module example.com/app\n\ngo 1.13\n",
+			modulesTxt: vendorTestModulesTxt,
+		},
+		{
+			name:       "GOFLAGS overrides",
+			goVersion:  "go version go1.17 linux/amd64",
+			goMod:      vendorTestGoMod,
+			modulesTxt: vendorTestModulesTxt,
+			goflags:    "-trimpath -mod=mod",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tc.modulesTxt != "" {
+				if err := os.MkdirAll(filepath.Join(dir, "vendor"), 0755); err != nil {
+					t.Fatalf("creating vendor dir: %v", err)
+				}
+				if err := os.WriteFile(filepath.Join(dir, "vendor", "modules.txt"), []byte(tc.modulesTxt), 0644); err != nil {
+					t.Fatalf("writing modules.txt: %v", err)
+				}
+			}
+			t.Setenv("GOFLAGS", tc.goflags)
+			mockReadGoVersion(t, tc.goVersion)
+			mockReadGoMod(t, tc.goMod)
+
+			got, err := VendorMode(gcp.NewContext(gcp.WithApplicationRoot(dir)))
+			if err != nil {
+				t.Fatalf("VendorMode() got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("VendorMode()=%q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"archive/zip"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -42,6 +43,9 @@ const (
 
 	// FFJarPathEnv is an environment variable which is used to store the path to the functions framework invoker jar.
 	FFJarPathEnv = "GOOGLE_INTERNAL_FUNCTIONS_FRAMEWORK_JAR"
+	// ModuleDirEnv is an environment variable which is used to store the directory of the module of a
+	// multi-module project whose executable jar is launched, relative to the application root.
+	ModuleDirEnv = "GOOGLE_INTERNAL_JAVA_MODULE_DIR"
 )
 
 var (
@@ -56,9 +60,11 @@ var (
 )
 
 // ExecutableJar looks for the jar with a Main-Class manifest. If there is not exactly 1 of these jars, throw an error.
+// The jar of the module in ModuleDirEnv, if set, is looked for instead.
 func ExecutableJar(ctx *gcp.Context) (string, error) {
+	root := filepath.Join(ctx.ApplicationRoot(), os.Getenv(ModuleDirEnv))
 	for i, path := range jarPaths {
-		path = append([]string{ctx.ApplicationRoot()}, path...)
+		path = append([]string{root}, path...)
 		path = append(path, "*.jar")
 		jars, err := ctx.Glob(filepath.Join(path...))
 		if err != nil {
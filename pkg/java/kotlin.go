@@ -0,0 +1,131 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+var (
+	// kotlinBuildFiles are the build files of Kotlin projects, in order of precedence. Multi-project
+	// Gradle builds may only have a settings file at the root.
+	kotlinBuildFiles = []string{"pom.xml", "build.gradle.kts", "build.gradle", "settings.gradle.kts", "settings.gradle"}
+	// kotlinPluginMarkers are the ways the Kotlin compiler plugin is referenced in Maven and Gradle
+	// build files, e.g. kotlin("jvm") or id("org.jetbrains.kotlin.jvm").
+	kotlinPluginMarkers = []string{"kotlin-maven-plugin", "kotlin-gradle-plugin", "org.jetbrains.kotlin", "kotlin(\""}
+	// skippedDirs are not searched for Kotlin sources and build files.
+	skippedDirs = map[string]bool{"build": true, "target": true, "node_modules": true}
+
+	errFound = errors.New("found")
+)
+
+// DetectKotlin returns the reason to opt in a Kotlin project in dir, i.e. one with *.kt sources,
+// e.g. in src/main/kotlin, and a Maven or Gradle build file, or "" if dir is not a Kotlin project.
+// It also returns the build file.
+func DetectKotlin(dir string) (string, string, error) {
+	var buildFile string
+	for _, f := range kotlinBuildFiles {
+		if _, err := os.Stat(filepath.Join(dir, f)); err == nil {
+			buildFile = f
+			break
+		} else if !os.IsNotExist(err) {
+			return "", "", gcp.InternalErrorf("stating %s: %v", f, err)
+		}
+	}
+	if buildFile == "" {
+		return "", "", nil
+	}
+	found, err := walkProject(dir, func(path string) bool { return strings.HasSuffix(path, ".kt") })
+	if err != nil || !found {
+		return "", "", err
+	}
+	return "found Kotlin sources and " + buildFile, buildFile, nil
+}
+
+// KotlinPluginConfigured returns whether a Maven or Gradle build file of the project in dir, or of
+// one of its modules, applies the Kotlin compiler plugin. Gradle version catalogs, e.g.
+// gradle/libs.versions.toml, are searched too, since build files then apply the plugin by its alias,
+// e.g. alias(libs.plugins.kotlin.jvm).
+func KotlinPluginConfigured(dir string) (bool, error) {
+	return walkProject(dir, func(path string) bool {
+		name := filepath.Base(path)
+		if name != "pom.xml" && !strings.HasPrefix(name, "build.gradle") && !strings.HasPrefix(name, "settings.gradle") && !strings.HasSuffix(name, ".versions.toml") {
+			return false
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return false
+		}
+		for _, m := range kotlinPluginMarkers {
+			if strings.Contains(string(content), m) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// CheckKotlinPlugin fails the build of a Kotlin project that does not apply the Kotlin compiler
+// plugin, which would otherwise build without compiling the Kotlin sources.
+func CheckKotlinPlugin(ctx *gcp.Context) error {
+	reason, buildFile, err := DetectKotlin(ctx.ApplicationRoot())
+	if err != nil || reason == "" {
+		return err
+	}
+	configured, err := KotlinPluginConfigured(ctx.ApplicationRoot())
+	if err != nil {
+		return err
+	}
+	if !configured {
+		if buildFile == "pom.xml" {
+			return gcp.UserErrorf("found Kotlin sources, but pom.xml does not configure the org.jetbrains.kotlin:kotlin-maven-plugin to compile them")
+		}
+		return gcp.UserErrorf("found Kotlin sources, but no Gradle build file applies the org.jetbrains.kotlin.jvm plugin to compile them")
+	}
+	return nil
+}
+
+// walkProject returns whether match returns true for any file of the project in dir. Hidden and
+// build output directories are skipped.
+func walkProject(dir string, match func(path string) bool) (bool, error) {
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && (strings.HasPrefix(d.Name(), ".") || skippedDirs[d.Name()]) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if match(path) {
+			return errFound
+		}
+		return nil
+	})
+	if err == errFound {
+		return true, nil
+	}
+	if err != nil {
+		return false, gcp.InternalErrorf("searching %s: %v", dir, err)
+	}
+	return false, nil
+}
@@ -0,0 +1,152 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"testing"
+)
+
+func TestDetectKotlin(t *testing.T) {
+	testCases := []struct {
+		name          string
+		files         map[string]string
+		wantReason    string
+		wantBuildFile string
+	}{
+		{
+			name: "gradle kotlin dsl with src/main/kotlin",
+			files: map[string]string{
+				"build.gradle.kts":                       `plugins { kotlin("jvm") version "1.7.20" }`,
+				"src/main/kotlin/com/example/App.kt":     "fun main() {}",
+				"src/test/kotlin/com/example/AppTest.kt": "class AppTest",
+			},
+			wantReason:    "found Kotlin sources and build.gradle.kts",
+			wantBuildFile: "build.gradle.kts",
+		},
+		{
+			name: "maven with src/main/kotlin",
+			files: map[string]string{
+				"pom.xml":                            "<project/>",
+				"src/main/kotlin/com/example/App.kt": "fun main() {}",
+			},
+			wantReason:    "found Kotlin sources and pom.xml",
+			wantBuildFile: "pom.xml",
+		},
+		{
+			name: "multi-project gradle with settings only",
+			files: map[string]string{
+				"settings.gradle.kts":                           `include("app")`,
+				"app/build.gradle.kts":                          `plugins { kotlin("jvm") }`,
+				"app/src/main/kotlin/com/example/Main.kt":       "fun main() {}",
+				"app/src/main/resources/application.properties": "",
+			},
+			wantReason:    "found Kotlin sources and settings.gradle.kts",
+			wantBuildFile: "settings.gradle.kts",
+		},
+		{
+			name: "kotlin sources without build file",
+			files: map[string]string{
+				"src/main/kotlin/App.kt": "fun main() {}",
+			},
+		},
+		{
+			name: "java project",
+			files: map[string]string{
+				"build.gradle":                       "plugins { id 'java' }",
+				"src/main/java/com/example/App.java": "class App {}",
+			},
+		},
+		{
+			name: "kotlin only in build output",
+			files: map[string]string{
+				"pom.xml":                  "<project/>",
+				"target/generated/Gen.kt":  "",
+				".gradle/kotlin/Cached.kt": "",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeMavenFiles(t, dir, tc.files)
+
+			reason, buildFile, err := DetectKotlin(dir)
+			if err != nil {
+				t.Fatalf("DetectKotlin(%q) got error: %v", dir, err)
+			}
+			if reason != tc.wantReason || buildFile != tc.wantBuildFile {
+				t.Errorf("DetectKotlin(%q) = (%q, %q), want (%q, %q)", dir, reason, buildFile, tc.wantReason, tc.wantBuildFile)
+			}
+		})
+	}
+}
+
+func TestKotlinPluginConfigured(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  bool
+	}{
+		{
+			name:  "gradle kotlin dsl",
+			files: map[string]string{"build.gradle.kts": `plugins { kotlin("jvm") version "1.7.20" }`},
+			want:  true,
+		},
+		{
+			name:  "gradle plugin id",
+			files: map[string]string{"build.gradle": `plugins { id 'org.jetbrains.kotlin.jvm' version '1.7.20' }`},
+			want:  true,
+		},
+		{
+			name:  "maven plugin",
+			files: map[string]string{"pom.xml": "<project><build><plugins><plugin><groupId>org.jetbrains.kotlin</groupId><artifactId>kotlin-maven-plugin</artifactId></plugin></plugins></build></project>"},
+			want:  true,
+		},
+		{
+			name: "subproject",
+			files: map[string]string{
+				"settings.gradle.kts":  `include("app")`,
+				"app/build.gradle.kts": `plugins { kotlin("jvm") }`,
+			},
+			want: true,
+		},
+		{
+			name: "version catalog",
+			files: map[string]string{
+				"build.gradle.kts":          "plugins { alias(libs.plugins.kotlin.jvm) }",
+				"gradle/libs.versions.toml": "[plugins]\nkotlin-jvm = { id = \"org.jetbrains.kotlin.jvm\", version = \"1.9.0\" }\n",
+			},
+			want: true,
+		},
+		{
+			name:  "java plugin only",
+			files: map[string]string{"build.gradle.kts": "plugins { java }"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeMavenFiles(t, dir, tc.files)
+
+			got, err := KotlinPluginConfigured(dir)
+			if err != nil {
+				t.Fatalf("KotlinPluginConfigured(%q) got error: %v", dir, err)
+			}
+			if got != tc.want {
+				t.Errorf("KotlinPluginConfigured(%q) = %t, want %t", dir, got, tc.want)
+			}
+		})
+	}
+}
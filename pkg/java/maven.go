@@ -15,17 +15,60 @@
 package java
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/xml"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 )
 
+const (
+	// MavenGoalsEnv is the environment variable that overrides the goals and properties of the Maven build.
+	MavenGoalsEnv = "GOOGLE_MAVEN_GOALS"
+	// MavenModuleEnv is the environment variable that selects the module of a multi-module Maven
+	// project whose artifact is launched.
+	MavenModuleEnv = "GOOGLE_MAVEN_MODULE"
+	// DefaultMavenGoals are the goals and properties of the Maven build if MavenGoalsEnv is not set.
+	DefaultMavenGoals = "clean package -DskipTests"
+	// mavenWrapperProperties is the path of the Maven Wrapper configuration relative to the application root.
+	mavenWrapperProperties = ".mvn/wrapper/maven-wrapper.properties"
+)
+
 // MavenProject is the root struct that contains the unmarshalled pom.xml.
 type MavenProject struct {
-	Plugins    []MavenPlugin  `xml:"build>plugins>plugin"`
-	Profiles   []MavenProfile `xml:"profiles>profile"`
-	ArtifactID string         `xml:"artifactId"`
-	Version    string         `xml:"version"`
+	Plugins    []MavenPlugin   `xml:"build>plugins>plugin"`
+	Profiles   []MavenProfile  `xml:"profiles>profile"`
+	Modules    []string        `xml:"modules>module"`
+	Properties MavenProperties `xml:"properties"`
+	ArtifactID string          `xml:"artifactId"`
+	Version    string          `xml:"version"`
+}
+
+// MavenProperties are the properties defined in the pom.xml.
+type MavenProperties struct {
+	Entries []MavenProperty `xml:",any"`
+}
+
+// MavenProperty is a property defined in the pom.xml.
+type MavenProperty struct {
+	XMLName xml.Name
+	Value   string `xml:",chardata"`
+}
+
+// Map returns the properties by name.
+func (p MavenProperties) Map() map[string]string {
+	m := map[string]string{}
+	for _, e := range p.Entries {
+		m[e.XMLName.Local] = strings.TrimSpace(e.Value)
+	}
+	return m
 }
 
 // MavenProfile describes a profile defined in the pom.xml.
@@ -56,3 +99,187 @@ func ParsePomFile(pomFile []byte) (*MavenProject, error) {
 
 	return &proj, nil
 }
+
+// MavenGoals returns the goals and properties to run Maven with, from MavenGoalsEnv or DefaultMavenGoals.
+func MavenGoals() []string {
+	if goals := strings.TrimSpace(os.Getenv(MavenGoalsEnv)); goals != "" {
+		return strings.Fields(goals)
+	}
+	return strings.Fields(DefaultMavenGoals)
+}
+
+// MavenWrapper returns whether the application in dir has a Maven Wrapper script, mvnw.
+func MavenWrapper(dir string) (bool, error) {
+	fi, err := os.Stat(filepath.Join(dir, "mvnw"))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, gcp.InternalErrorf("stating mvnw: %v", err)
+	}
+	return !fi.IsDir(), nil
+}
+
+// WrapperDistributionURL returns the Maven distribution pinned by the distributionUrl of the
+// Maven Wrapper properties of the application in dir, or "" if there is none.
+func WrapperDistributionURL(dir string) (string, error) {
+	content, err := os.ReadFile(filepath.Join(dir, mavenWrapperProperties))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", gcp.InternalErrorf("reading %s: %v", mavenWrapperProperties, err)
+	}
+	s := bufio.NewScanner(bytes.NewReader(content))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if strings.HasPrefix(line, "#") || strings.HasPrefix(line, "!") {
+			continue
+		}
+		i := strings.IndexAny(line, "=:")
+		if i < 0 || strings.TrimSpace(line[:i]) != "distributionUrl" {
+			continue
+		}
+		// Properties files may escape colons in values, e.g. https\://repo.maven.apache.org.
+		return strings.ReplaceAll(strings.TrimSpace(line[i+1:]), `\:`, ":"), nil
+	}
+	return "", nil
+}
+
+// PomFiles returns the pom.xml files of the application in dir and its modules, relative to dir
+// and sorted. Build output directories are skipped.
+func PomFiles(dir string) ([]string, error) {
+	var poms []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && (d.Name() == "target" || strings.HasPrefix(d.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Name() == "pom.xml" {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			poms = append(poms, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, gcp.InternalErrorf("finding pom.xml files: %v", err)
+	}
+	sort.Strings(poms)
+	return poms, nil
+}
+
+// MavenRepositoryCacheKey returns the key of the cached local Maven repository of the application
+// in dir, computed from the paths and contents of its pom.xml files.
+func MavenRepositoryCacheKey(ctx *gcp.Context, dir string) (string, error) {
+	poms, err := PomFiles(dir)
+	if err != nil {
+		return "", err
+	}
+	var paths []string
+	for _, p := range poms {
+		paths = append(paths, filepath.Join(dir, p))
+	}
+	key, err := cache.Hash(ctx, cache.WithStrings(poms...), cache.WithFiles(paths...))
+	if err != nil {
+		return "", gcp.InternalErrorf("computing Maven repository cache key: %v", err)
+	}
+	return key, nil
+}
+
+// MavenModules returns the modules of the Maven project in dir, including the modules of
+// aggregated modules, as paths relative to dir and sorted. A project without modules has none.
+func MavenModules(dir string) ([]string, error) {
+	found := map[string]bool{}
+	if err := collectMavenModules(dir, ".", found); err != nil {
+		return nil, err
+	}
+	var modules []string
+	for m := range found {
+		modules = append(modules, m)
+	}
+	sort.Strings(modules)
+	return modules, nil
+}
+
+// collectMavenModules adds the modules of the project in the module directory rel of dir to found.
+func collectMavenModules(dir, rel string, found map[string]bool) error {
+	pom := filepath.Join(dir, rel, "pom.xml")
+	content, err := os.ReadFile(pom)
+	if os.IsNotExist(err) {
+		if rel == "." {
+			return nil
+		}
+		return gcp.UserErrorf("module %s does not have a pom.xml", filepath.ToSlash(rel))
+	}
+	if err != nil {
+		return gcp.InternalErrorf("reading %s: %v", pom, err)
+	}
+	proj, err := ParsePomFile(content)
+	if err != nil {
+		return err
+	}
+	for _, m := range proj.Modules {
+		// A module may also name the pom.xml of the module explicitly.
+		m = strings.TrimSuffix(filepath.ToSlash(strings.TrimSpace(m)), "/pom.xml")
+		module := filepath.Join(rel, m)
+		key := filepath.ToSlash(module)
+		if found[key] {
+			continue
+		}
+		found[key] = true
+		if err := collectMavenModules(dir, module, found); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RequestedMavenModule returns the module selected by MavenModuleEnv, or "" if it is not set. It
+// fails if the module is not one of the given modules of the project.
+func RequestedMavenModule(modules []string) (string, error) {
+	requested := os.Getenv(MavenModuleEnv)
+	if requested == "" {
+		return "", nil
+	}
+	if len(modules) == 0 {
+		return "", gcp.UserErrorf("%s=%s is set, but the project does not have modules", MavenModuleEnv, requested)
+	}
+	module := filepath.ToSlash(filepath.Clean(requested))
+	for _, m := range modules {
+		if m == module {
+			return module, nil
+		}
+	}
+	return "", gcp.UserErrorf("%s=%s is not a module of the project, must be one of %s", MavenModuleEnv, requested, strings.Join(modules, ", "))
+}
+
+// ExecutableMavenModule returns the module of the built Maven project in dir whose target
+// directory has the only executable jar among all modules, or "" if there is no such module, or
+// more than one, so that the entrypoint is left to the java/entrypoint buildpack.
+func ExecutableMavenModule(ctx *gcp.Context, dir string, modules []string) (string, error) {
+	var executables []string
+	for _, m := range modules {
+		jars, err := ctx.Glob(filepath.Join(dir, m, "target", "*.jar"))
+		if err != nil {
+			return "", fmt.Errorf("finding jars: %w", err)
+		}
+		if len(filterExecutables(ctx, jars)) > 0 {
+			executables = append(executables, m)
+		}
+	}
+	if len(executables) > 1 {
+		ctx.Logf("More than one module built a jar with a Main-Class manifest entry: %s, set %s to select one of them.", strings.Join(executables, ", "), MavenModuleEnv)
+	}
+	if len(executables) != 1 {
+		return "", nil
+	}
+	return executables[0], nil
+}
@@ -16,8 +16,12 @@ package java
 
 import (
 	"embed"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 )
 
 //go:embed testdata/*
@@ -104,3 +108,386 @@ func TestParseInvalidPom(t *testing.T) {
 		})
 	}
 }
+
+func TestMavenGoals(t *testing.T) {
+	testCases := []struct {
+		name  string
+		goals string
+		want  []string
+	}{
+		{
+			name: "default",
+			want: []string{"clean", "package", "-DskipTests"},
+		},
+		{
+			name:  "override",
+			goals: "  package -DskipTests -Pprod ",
+			want:  []string{"package", "-DskipTests", "-Pprod"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(MavenGoalsEnv, tc.goals)
+
+			if got := MavenGoals(); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("MavenGoals() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMavenWrapper(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		dirs  []string
+		want  bool
+	}{
+		{
+			name:  "mvnw",
+			files: map[string]string{"mvnw": "#!/bin/sh\n", "pom.xml": ""},
+			want:  true,
+		},
+		{
+			name:  "no mvnw",
+			files: map[string]string{"pom.xml": ""},
+		},
+		{
+			name: "mvnw directory",
+			dirs: []string{"mvnw"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeMavenFiles(t, dir, tc.files)
+			for _, d := range tc.dirs {
+				if err := os.MkdirAll(filepath.Join(dir, d), 0755); err != nil {
+					t.Fatalf("creating %s: %v", d, err)
+				}
+			}
+
+			got, err := MavenWrapper(dir)
+			if err != nil {
+				t.Fatalf("MavenWrapper(%q) got error: %v", dir, err)
+			}
+			if got != tc.want {
+				t.Errorf("MavenWrapper(%q) = %t, want %t", dir, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWrapperDistributionURL(t *testing.T) {
+	testCases := []struct {
+		name       string
+		properties string
+		want       string
+	}{
+		{
+			name: "no properties",
+		},
+		{
+			name:       "distribution url",
+			properties: "distributionUrl=https://repo.maven.apache.org/maven2/org/apache/maven/apache-maven/3.8.6/apache-maven-3.8.6-bin.zip\nwrapperUrl=https://example.com/wrapper.jar\n",
+			want:       "https://repo.maven.apache.org/maven2/org/apache/maven/apache-maven/3.8.6/apache-maven-3.8.6-bin.zip",
+		},
+		{
+			name:       "escaped colon and spaces",
+			properties: "# pinned\n distributionUrl = https\\://example.com/apache-maven-3.9.0-bin.tar.gz\n",
+			want:       "https://example.com/apache-maven-3.9.0-bin.tar.gz",
+		},
+		{
+			name:       "commented out",
+			properties: "#distributionUrl=https://example.com/apache-maven-3.9.0-bin.zip\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tc.properties != "" {
+				writeMavenFiles(t, dir, map[string]string{mavenWrapperProperties: tc.properties})
+			}
+
+			got, err := WrapperDistributionURL(dir)
+			if err != nil {
+				t.Fatalf("WrapperDistributionURL(%q) got error: %v", dir, err)
+			}
+			if got != tc.want {
+				t.Errorf("WrapperDistributionURL(%q) = %q, want %q", dir, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPomFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeMavenFiles(t, dir, map[string]string{
+		"pom.xml":                 "<project/>",
+		"web/pom.xml":             "<project/>",
+		"core/pom.xml":            "<project/>",
+		"core/target/pom.xml":     "<project/>",
+		".mvn/wrapper/pom.xml":    "<project/>",
+		"core/src/main/App.java":  "class App {}",
+		"docs/pom-template.xml":   "<project/>",
+		"services/api/pom.xml":    "<project/>",
+		"services/api/README.txt": "",
+	})
+	want := []string{"core/pom.xml", "pom.xml", "services/api/pom.xml", "web/pom.xml"}
+
+	got, err := PomFiles(dir)
+	if err != nil {
+		t.Fatalf("PomFiles(%q) got error: %v", dir, err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("PomFiles(%q) = %q, want %q", dir, got, want)
+	}
+}
+
+func TestMavenRepositoryCacheKey(t *testing.T) {
+	ctx := gcp.NewContext()
+	base := map[string]string{
+		"pom.xml":      "<project><modules><module>core</module></modules></project>",
+		"core/pom.xml": "<project><artifactId>core</artifactId></project>",
+	}
+	key := func(files map[string]string) string {
+		t.Helper()
+		dir := t.TempDir()
+		writeMavenFiles(t, dir, files)
+		got, err := MavenRepositoryCacheKey(ctx, dir)
+		if err != nil {
+			t.Fatalf("MavenRepositoryCacheKey(%q) got error: %v", dir, err)
+		}
+		return got
+	}
+	with := func(name, content string) map[string]string {
+		files := map[string]string{}
+		for k, v := range base {
+			files[k] = v
+		}
+		files[name] = content
+		return files
+	}
+	want := key(base)
+
+	testCases := []struct {
+		name    string
+		files   map[string]string
+		changed bool
+	}{
+		{
+			name:  "same poms",
+			files: base,
+		},
+		{
+			name:  "source change",
+			files: with("core/src/main/java/App.java", "class App {}"),
+		},
+		{
+			name:  "build output pom",
+			files: with("core/target/pom.xml", "<project/>"),
+		},
+		{
+			name:    "module pom change",
+			files:   with("core/pom.xml", "<project><artifactId>core2</artifactId></project>"),
+			changed: true,
+		},
+		{
+			name:    "new module",
+			files:   with("web/pom.xml", "<project/>"),
+			changed: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := key(tc.files); (got != want) != tc.changed {
+				t.Errorf("MavenRepositoryCacheKey() = %q, base key %q, want changed=%t", got, want, tc.changed)
+			}
+		})
+	}
+}
+
+func writeMavenFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("creating directory for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("writing %s: %v", name, err)
+		}
+	}
+}
+
+func TestMavenModules(t *testing.T) {
+	testCases := []struct {
+		name    string
+		files   map[string]string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "no pom.xml",
+			files: map[string]string{"build.gradle": ""},
+		},
+		{
+			name:  "single module",
+			files: map[string]string{"pom.xml": "<project><artifactId>app</artifactId></project>"},
+		},
+		{
+			name: "aggregator",
+			files: map[string]string{
+				"pom.xml":      "<project><packaging>pom</packaging><modules><module>web</module><module>core/</module></modules></project>",
+				"web/pom.xml":  "<project><artifactId>web</artifactId></project>",
+				"core/pom.xml": "<project><artifactId>core</artifactId></project>",
+			},
+			want: []string{"core", "web"},
+		},
+		{
+			name: "nested aggregator",
+			files: map[string]string{
+				"pom.xml":                   "<project><modules><module>services</module><module>lib/pom.xml</module></modules></project>",
+				"lib/pom.xml":               "<project/>",
+				"services/pom.xml":          "<project><modules><module>api</module><module>worker</module></modules></project>",
+				"services/api/pom.xml":      "<project/>",
+				"services/worker/pom.xml":   "<project/>",
+				"services/unlisted/pom.xml": "<project/>",
+			},
+			want: []string{"lib", "services", "services/api", "services/worker"},
+		},
+		{
+			name: "missing module pom.xml",
+			files: map[string]string{
+				"pom.xml": "<project><modules><module>web</module></modules></project>",
+			},
+			wantErr: true,
+		},
+		{
+			name:    "invalid pom.xml",
+			files:   map[string]string{"pom.xml": "<project>"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeMavenFiles(t, dir, tc.files)
+
+			got, err := MavenModules(dir)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("MavenModules(%q) got error: %v, want error %t", dir, err, tc.wantErr)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("MavenModules(%q) = %q, want %q", dir, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRequestedMavenModule(t *testing.T) {
+	testCases := []struct {
+		name      string
+		requested string
+		modules   []string
+		want      string
+		wantErr   bool
+	}{
+		{
+			name:    "not set",
+			modules: []string{"core", "web"},
+		},
+		{
+			name:      "module",
+			requested: "web",
+			modules:   []string{"core", "web"},
+			want:      "web",
+		},
+		{
+			name:      "nested module with trailing slash",
+			requested: "services/api/",
+			modules:   []string{"services", "services/api"},
+			want:      "services/api",
+		},
+		{
+			name:      "unknown module",
+			requested: "api",
+			modules:   []string{"core", "web"},
+			wantErr:   true,
+		},
+		{
+			name:      "project without modules",
+			requested: "web",
+			wantErr:   true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(MavenModuleEnv, tc.requested)
+
+			got, err := RequestedMavenModule(tc.modules)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("RequestedMavenModule(%q) got error: %v, want error %t", tc.modules, err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("RequestedMavenModule(%q) = %q, want %q", tc.modules, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestExecutableMavenModule(t *testing.T) {
+	executable, err := os.ReadFile(setupTestJar(t, []byte("Main-Class: com.example.Main\n")))
+	if err != nil {
+		t.Fatalf("reading executable jar: %v", err)
+	}
+	library, err := os.ReadFile(setupTestJar(t, []byte("Manifest-Version: 1.0\n")))
+	if err != nil {
+		t.Fatalf("reading library jar: %v", err)
+	}
+	modules := []string{"core", "services/api", "web"}
+	testCases := []struct {
+		name string
+		jars map[string][]byte
+		want string
+	}{
+		{
+			name: "one executable module",
+			jars: map[string][]byte{
+				"core/target/core.jar":        library,
+				"services/api/target/api.jar": executable,
+				"web/target/web.jar":          library,
+			},
+			want: "services/api",
+		},
+		{
+			name: "no executable module",
+			jars: map[string][]byte{
+				"core/target/core.jar": library,
+			},
+		},
+		{
+			name: "several executable modules",
+			jars: map[string][]byte{
+				"services/api/target/api.jar": executable,
+				"web/target/web.jar":          executable,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tc.jars {
+				writeMavenFiles(t, dir, map[string]string{name: string(content)})
+			}
+
+			got, err := ExecutableMavenModule(gcp.NewContext(), dir, modules)
+			if err != nil {
+				t.Fatalf("ExecutableMavenModule() got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("ExecutableMavenModule() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
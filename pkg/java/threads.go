@@ -0,0 +1,76 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// MavenThreadsEnv is the environment variable that sets the number of threads of a Maven build.
+	MavenThreadsEnv = "GOOGLE_MAVEN_THREADS"
+	// GradleWorkersEnv is the environment variable that sets the maximum number of workers of a Gradle build.
+	GradleWorkersEnv = "GOOGLE_GRADLE_WORKERS"
+
+	// ToolMaven identifies Maven to BuildThreadFlags.
+	ToolMaven = "maven"
+	// ToolGradle identifies Gradle to BuildThreadFlags.
+	ToolGradle = "gradle"
+)
+
+var (
+	// perCoreRegexp matches Maven's per-core thread count, e.g. 1C or 0.5C.
+	perCoreRegexp = regexp.MustCompile(`^(\d+(?:\.\d+)?)C$`)
+)
+
+// BuildThreadFlags returns the flags that set the parallelism of a build with the given tool to
+// value: Maven's -T, which accepts a positive number of threads or a per-core multiplier such as
+// 1C, or Gradle's --max-workers, which accepts a positive number of workers. It returns no flags
+// if value is empty.
+func BuildThreadFlags(tool, value string) ([]string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	switch tool {
+	case ToolMaven:
+		if m := perCoreRegexp.FindStringSubmatch(value); m != nil {
+			if f, err := strconv.ParseFloat(m[1], 64); err != nil || f <= 0 {
+				return nil, gcp.UserErrorf("invalid %s %q: the per-core multiplier must be positive", MavenThreadsEnv, value)
+			}
+			return []string{"-T", value}, nil
+		}
+		if !positiveInt(value) {
+			return nil, gcp.UserErrorf("invalid %s %q: must be a positive integer or a per-core count such as 1C", MavenThreadsEnv, value)
+		}
+		return []string{"-T", value}, nil
+	case ToolGradle:
+		if !positiveInt(value) {
+			return nil, gcp.UserErrorf("invalid %s %q: must be a positive integer", GradleWorkersEnv, value)
+		}
+		return []string{"--max-workers=" + value}, nil
+	default:
+		return nil, gcp.InternalErrorf("unknown build tool %q", tool)
+	}
+}
+
+func positiveInt(s string) bool {
+	n, err := strconv.Atoi(s)
+	return err == nil && n > 0
+}
@@ -0,0 +1,122 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildThreadFlags(t *testing.T) {
+	testCases := []struct {
+		name    string
+		tool    string
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "maven unset",
+			tool: ToolMaven,
+		},
+		{
+			name:  "maven threads",
+			tool:  ToolMaven,
+			value: "4",
+			want:  []string{"-T", "4"},
+		},
+		{
+			name:  "maven per core",
+			tool:  ToolMaven,
+			value: "1C",
+			want:  []string{"-T", "1C"},
+		},
+		{
+			name:  "maven fractional per core",
+			tool:  ToolMaven,
+			value: " 1.5C ",
+			want:  []string{"-T", "1.5C"},
+		},
+		{
+			name:    "maven zero threads",
+			tool:    ToolMaven,
+			value:   "0",
+			wantErr: true,
+		},
+		{
+			name:    "maven zero per core",
+			tool:    ToolMaven,
+			value:   "0C",
+			wantErr: true,
+		},
+		{
+			name:    "maven negative threads",
+			tool:    ToolMaven,
+			value:   "-2",
+			wantErr: true,
+		},
+		{
+			name:    "maven lowercase per core",
+			tool:    ToolMaven,
+			value:   "2c",
+			wantErr: true,
+		},
+		{
+			name:    "maven not a number",
+			tool:    ToolMaven,
+			value:   "many",
+			wantErr: true,
+		},
+		{
+			name: "gradle unset",
+			tool: ToolGradle,
+		},
+		{
+			name:  "gradle workers",
+			tool:  ToolGradle,
+			value: "8",
+			want:  []string{"--max-workers=8"},
+		},
+		{
+			name:    "gradle per core",
+			tool:    ToolGradle,
+			value:   "1C",
+			wantErr: true,
+		},
+		{
+			name:    "gradle zero workers",
+			tool:    ToolGradle,
+			value:   "0",
+			wantErr: true,
+		},
+		{
+			name:    "unknown tool",
+			tool:    "ant",
+			value:   "2",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := BuildThreadFlags(tc.tool, tc.value)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("BuildThreadFlags(%q, %q) got error: %v, want error %t", tc.tool, tc.value, err, tc.wantErr)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("BuildThreadFlags(%q, %q) = %q, want %q", tc.tool, tc.value, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// DefaultJavaVersion is the Java feature version installed if the application does not request one.
+	DefaultJavaVersion = "11"
+	sdkmanrc           = ".sdkmanrc"
+)
+
+var (
+	// javaVersionRegexp matches the numeric part of a Java version, e.g. 17.0.2 in 17.0.2-tem.
+	javaVersionRegexp = regexp.MustCompile(`^\d+(?:\.\d+){0,2}`)
+	// propertyRefRegexp matches a reference to a Maven property, e.g. ${java.version}.
+	propertyRefRegexp = regexp.MustCompile(`^\$\{([^}]+)\}$`)
+)
+
+// RequestedJavaVersion returns the Java version requested by the application in dir and where it
+// was requested: GOOGLE_RUNTIME_VERSION, the maven.compiler.release or maven.compiler.target
+// properties of pom.xml, or the java candidate of .sdkmanrc, in this order of precedence. If none
+// of them requests a version, DefaultJavaVersion is returned.
+func RequestedJavaVersion(dir string) (string, string, error) {
+	if v := os.Getenv(env.RuntimeVersion); v != "" {
+		return v, env.RuntimeVersion, nil
+	}
+	v, source, err := pomJavaVersion(dir)
+	if err != nil {
+		return "", "", err
+	}
+	if v != "" {
+		return v, source, nil
+	}
+	v, err = sdkmanJavaVersion(dir)
+	if err != nil {
+		return "", "", err
+	}
+	if v != "" {
+		return v, sdkmanrc, nil
+	}
+	return DefaultJavaVersion, "", nil
+}
+
+// pomJavaVersion returns the Java version of the compiler properties of pom.xml in dir, and the
+// property that set it.
+func pomJavaVersion(dir string) (string, string, error) {
+	content, err := os.ReadFile(filepath.Join(dir, "pom.xml"))
+	if os.IsNotExist(err) {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", gcp.InternalErrorf("reading pom.xml: %v", err)
+	}
+	proj, err := ParsePomFile(content)
+	if err != nil {
+		return "", "", err
+	}
+	props := proj.Properties.Map()
+	for _, p := range []string{"maven.compiler.release", "maven.compiler.target"} {
+		value := props[p]
+		// Resolve a reference to another property, e.g. ${java.version}.
+		if m := propertyRefRegexp.FindStringSubmatch(value); m != nil {
+			value = props[m[1]]
+		}
+		if value == "" {
+			continue
+		}
+		v := normalizeJavaVersion(value)
+		if v == "" {
+			return "", "", gcp.UserErrorf("invalid Java version %q in the %s property of pom.xml", value, p)
+		}
+		return v, "pom.xml " + p, nil
+	}
+	return "", "", nil
+}
+
+// sdkmanJavaVersion returns the Java version of the java candidate of the .sdkmanrc in dir.
+func sdkmanJavaVersion(dir string) (string, error) {
+	content, err := os.ReadFile(filepath.Join(dir, sdkmanrc))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", gcp.InternalErrorf("reading %s: %v", sdkmanrc, err)
+	}
+	s := bufio.NewScanner(bytes.NewReader(content))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if !strings.HasPrefix(line, "java=") {
+			continue
+		}
+		value := strings.TrimSpace(strings.TrimPrefix(line, "java="))
+		v := normalizeJavaVersion(value)
+		if v == "" {
+			return "", gcp.UserErrorf("invalid Java version %q in %s", value, sdkmanrc)
+		}
+		return v, nil
+	}
+	return "", nil
+}
+
+// normalizeJavaVersion returns the version constraint of a Java version as it is written in
+// build files: the legacy 1.x form of Java 8 and earlier is reduced to x, and vendor suffixes such
+// as -tem are removed. It returns "" if the version is not numeric.
+func normalizeJavaVersion(v string) string {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "1.")
+	return javaVersionRegexp.FindString(v)
+}
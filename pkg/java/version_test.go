@@ -0,0 +1,132 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+func TestRequestedJavaVersion(t *testing.T) {
+	pom := func(props string) string {
+		return "<project><artifactId>app</artifactId><properties>" + props + "</properties></project>"
+	}
+	testCases := []struct {
+		name       string
+		env        string
+		files      map[string]string
+		want       string
+		wantSource string
+		wantErr    bool
+	}{
+		{
+			name: "default",
+			want: DefaultJavaVersion,
+		},
+		{
+			name:       "runtime version",
+			env:        "17",
+			want:       "17",
+			wantSource: env.RuntimeVersion,
+		},
+		{
+			name:       "compiler release",
+			files:      map[string]string{"pom.xml": pom("<maven.compiler.release>17</maven.compiler.release>")},
+			want:       "17",
+			wantSource: "pom.xml maven.compiler.release",
+		},
+		{
+			name:       "legacy compiler target",
+			files:      map[string]string{"pom.xml": pom("<maven.compiler.source>1.8</maven.compiler.source><maven.compiler.target>1.8</maven.compiler.target>")},
+			want:       "8",
+			wantSource: "pom.xml maven.compiler.target",
+		},
+		{
+			name:       "compiler release over target",
+			files:      map[string]string{"pom.xml": pom("<maven.compiler.target>11</maven.compiler.target><maven.compiler.release>17</maven.compiler.release>")},
+			want:       "17",
+			wantSource: "pom.xml maven.compiler.release",
+		},
+		{
+			name:       "property reference",
+			files:      map[string]string{"pom.xml": pom("<java.version>21</java.version><maven.compiler.release>${java.version}</maven.compiler.release>")},
+			want:       "21",
+			wantSource: "pom.xml maven.compiler.release",
+		},
+		{
+			name:       "sdkmanrc",
+			files:      map[string]string{".sdkmanrc": "# Enable auto-env through the sdkman_auto_env config\nmaven=3.8.6\njava=17.0.2-tem\n"},
+			want:       "17.0.2",
+			wantSource: ".sdkmanrc",
+		},
+		{
+			name:       "sdkmanrc without java",
+			files:      map[string]string{".sdkmanrc": "maven=3.8.6\n"},
+			want:       DefaultJavaVersion,
+			wantSource: "",
+		},
+		{
+			name: "pom.xml over sdkmanrc",
+			files: map[string]string{
+				"pom.xml":   pom("<maven.compiler.release>11</maven.compiler.release>"),
+				".sdkmanrc": "java=17.0.2-tem\n",
+			},
+			want:       "11",
+			wantSource: "pom.xml maven.compiler.release",
+		},
+		{
+			name: "runtime version over pom.xml and sdkmanrc",
+			env:  "21",
+			files: map[string]string{
+				"pom.xml":   pom("<maven.compiler.release>11</maven.compiler.release>"),
+				".sdkmanrc": "java=17.0.2-tem\n",
+			},
+			want:       "21",
+			wantSource: env.RuntimeVersion,
+		},
+		{
+			name:       "pom.xml without compiler properties",
+			files:      map[string]string{"pom.xml": pom("<project.build.sourceEncoding>UTF-8</project.build.sourceEncoding>"), ".sdkmanrc": "java=8.0.302-open\n"},
+			want:       "8.0.302",
+			wantSource: ".sdkmanrc",
+		},
+		{
+			name:    "invalid compiler release",
+			files:   map[string]string{"pom.xml": pom("<maven.compiler.release>latest</maven.compiler.release>")},
+			wantErr: true,
+		},
+		{
+			name:    "invalid sdkmanrc",
+			files:   map[string]string{".sdkmanrc": "java=tem\n"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(env.RuntimeVersion, tc.env)
+			dir := t.TempDir()
+			writeMavenFiles(t, dir, tc.files)
+
+			got, source, err := RequestedJavaVersion(dir)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("RequestedJavaVersion(%q) got error: %v, want error %t", dir, err, tc.wantErr)
+			}
+			if got != tc.want || source != tc.wantSource {
+				t.Errorf("RequestedJavaVersion(%q) = (%q, %q), want (%q, %q)", dir, got, source, tc.want, tc.wantSource)
+			}
+		})
+	}
+}
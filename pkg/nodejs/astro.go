@@ -0,0 +1,140 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"fmt"
+	"path/filepath"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// AstroStatic is the mode of an Astro app that is built to static files in dist/.
+	AstroStatic = "static"
+	// AstroSSR is the mode of an Astro app that is server-side rendered with the Node.js adapter.
+	AstroSSR = "ssr"
+
+	astroPackage       = "astro"
+	astroNodeAdapter   = "@astrojs/node"
+	astroOutDir        = "dist"
+	astroServerEntry   = "./dist/server/entry.mjs"
+	astroLayer         = "astro"
+	astroStaticServer  = "serve-static.mjs"
+	astroDefaultHostIP = "0.0.0.0"
+)
+
+// AstroMode returns the mode of the Astro app in dir: AstroSSR if it depends on the Node.js adapter,
+// AstroStatic otherwise. It returns an empty string if dir is not an Astro app.
+func AstroMode(dir string) (string, error) {
+	p, err := ReadPackageJSONIfExists(dir)
+	if err != nil || p == nil {
+		return "", err
+	}
+	if !hasDependency(p, astroPackage) {
+		return "", nil
+	}
+	if hasDependency(p, astroNodeAdapter) {
+		return AstroSSR, nil
+	}
+	return AstroStatic, nil
+}
+
+// hasDependency returns true if the package.json lists the package in its dependencies or
+// devDependencies.
+func hasDependency(p *PackageJSON, pkg string) bool {
+	if _, ok := p.Dependencies[pkg]; ok {
+		return true
+	}
+	_, ok := p.DevDependencies[pkg]
+	return ok
+}
+
+// AstroBuildCommand returns the command that builds the Astro app in dir: the build script of
+// package.json if there is one, `astro build` otherwise.
+func AstroBuildCommand(dir string) ([]string, error) {
+	p, err := ReadPackageJSONIfExists(dir)
+	if err != nil {
+		return nil, err
+	}
+	if p != nil && p.Scripts.Build != "" {
+		return []string{"npm", "run", "build"}, nil
+	}
+	return []string{"npx", "--no-install", "astro", "build"}, nil
+}
+
+// AstroWebProcess returns the command that serves an Astro app in the given mode. SSR apps run the
+// standalone server of the Node.js adapter, which is configured to listen on all interfaces. Static
+// apps are served from dist/ by a minimal Node.js file server added to a launch layer.
+func AstroWebProcess(ctx *gcp.Context, mode string) ([]string, error) {
+	l, err := ctx.Layer(astroLayer, gcp.LaunchLayer)
+	if err != nil {
+		return nil, fmt.Errorf("creating %v layer: %w", astroLayer, err)
+	}
+	switch mode {
+	case AstroSSR:
+		l.LaunchEnvironment.Default("HOST", astroDefaultHostIP)
+		return []string{"node", astroServerEntry}, nil
+	case AstroStatic:
+		server := filepath.Join(l.Path, astroStaticServer)
+		if err := ctx.WriteFile(server, []byte(staticServerScript), 0644); err != nil {
+			return nil, err
+		}
+		return []string{"node", server, filepath.Join(ctx.ApplicationRoot(), astroOutDir)}, nil
+	}
+	return nil, gcp.InternalErrorf("unknown Astro mode %q", mode)
+}
+
+// staticServerScript serves the files of the directory given as its argument on $PORT. Directories
+// are served from their index.html and missing files from 404.html, matching Astro's output.
+const staticServerScript = `import { createReadStream } from "node:fs";
+import { stat } from "node:fs/promises";
+import { createServer } from "node:http";
+import { extname, join, normalize } from "node:path";
+
+const root = process.argv[2];
+const types = {
+  ".css": "text/css", ".gif": "image/gif", ".html": "text/html; charset=utf-8",
+  ".ico": "image/x-icon", ".jpeg": "image/jpeg", ".jpg": "image/jpeg",
+  ".js": "text/javascript", ".json": "application/json", ".mjs": "text/javascript",
+  ".png": "image/png", ".svg": "image/svg+xml", ".txt": "text/plain; charset=utf-8",
+  ".webmanifest": "application/manifest+json", ".webp": "image/webp",
+  ".woff": "font/woff", ".woff2": "font/woff2", ".xml": "application/xml",
+};
+
+async function resolve(pathname) {
+  const file = join(root, normalize(decodeURIComponent(pathname)).replace(/^(\.\.[\/\\])+/, ""));
+  for (const candidate of [file, join(file, "index.html"), file + ".html"]) {
+    const s = await stat(candidate).catch(() => null);
+    if (s && s.isFile()) return candidate;
+  }
+  return null;
+}
+
+createServer(async (req, res) => {
+  let status = 200;
+  let file = await resolve(new URL(req.url, "http://localhost").pathname).catch(() => null);
+  if (!file) {
+    status = 404;
+    file = await resolve("/404.html");
+  }
+  if (!file) {
+    res.writeHead(404).end("Not Found");
+    return;
+  }
+  res.writeHead(status, { "Content-Type": types[extname(file)] || "application/octet-stream" });
+  createReadStream(file).pipe(res);
+}).listen(process.env.PORT || 8080);
+`
@@ -0,0 +1,150 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAstroMode(t *testing.T) {
+	testCases := []struct {
+		name        string
+		packageJSON string
+		want        string
+		wantErr     bool
+	}{
+		{
+			name: "no package.json",
+		},
+		{
+			name:        "not astro",
+			packageJSON: `{"dependencies": {"express": "^4.18.0"}}`,
+		},
+		{
+			name:        "static",
+			packageJSON: `{"scripts": {"build": "astro build"}, "dependencies": {"astro": "^2.0.0"}}`,
+			want:        AstroStatic,
+		},
+		{
+			name:        "static with astro in devDependencies",
+			packageJSON: `{"devDependencies": {"astro": "^2.0.0", "@astrojs/tailwind": "^3.0.0"}}`,
+			want:        AstroStatic,
+		},
+		{
+			name:        "ssr with the node adapter",
+			packageJSON: `{"dependencies": {"astro": "^2.0.0", "@astrojs/node": "^5.0.0"}}`,
+			want:        AstroSSR,
+		},
+		{
+			name:        "node adapter without astro",
+			packageJSON: `{"dependencies": {"@astrojs/node": "^5.0.0"}}`,
+		},
+		{
+			name:        "invalid package.json",
+			packageJSON: `{"dependencies": `,
+			wantErr:     true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tc.packageJSON != "" {
+				writeFiles(t, dir, map[string]string{"package.json": tc.packageJSON})
+			}
+
+			got, err := AstroMode(dir)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("AstroMode() got err=%t, want err=%t. err: %v", gotErr, tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Errorf("AstroMode() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAstroBuildCommand(t *testing.T) {
+	testCases := []struct {
+		name        string
+		packageJSON string
+		want        []string
+	}{
+		{
+			name:        "build script",
+			packageJSON: `{"scripts": {"build": "astro check && astro build"}, "dependencies": {"astro": "^2.0.0"}}`,
+			want:        []string{"npm", "run", "build"},
+		},
+		{
+			name:        "no build script",
+			packageJSON: `{"dependencies": {"astro": "^2.0.0"}}`,
+			want:        []string{"npx", "--no-install", "astro", "build"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{"package.json": tc.packageJSON})
+
+			got, err := AstroBuildCommand(dir)
+			if err != nil {
+				t.Fatalf("AstroBuildCommand() got error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("AstroBuildCommand() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAstroWebProcess(t *testing.T) {
+	appDir := t.TempDir()
+	layersDir := t.TempDir()
+	ctx := gcp.NewContext(gcp.WithApplicationRoot(appDir), gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: layersDir}}))
+
+	ssr, err := AstroWebProcess(ctx, AstroSSR)
+	if err != nil {
+		t.Fatalf("AstroWebProcess(ctx, %q) got error: %v", AstroSSR, err)
+	}
+	if diff := cmp.Diff([]string{"node", "./dist/server/entry.mjs"}, ssr); diff != "" {
+		t.Errorf("AstroWebProcess(ctx, %q) mismatch (-want +got):\n%s", AstroSSR, diff)
+	}
+
+	static, err := AstroWebProcess(ctx, AstroStatic)
+	if err != nil {
+		t.Fatalf("AstroWebProcess(ctx, %q) got error: %v", AstroStatic, err)
+	}
+	server := filepath.Join(layersDir, astroLayer, astroStaticServer)
+	if diff := cmp.Diff([]string{"node", server, filepath.Join(appDir, "dist")}, static); diff != "" {
+		t.Errorf("AstroWebProcess(ctx, %q) mismatch (-want +got):\n%s", AstroStatic, diff)
+	}
+	script, err := os.ReadFile(server)
+	if err != nil {
+		t.Fatalf("reading static server: %v", err)
+	}
+	if !strings.Contains(string(script), "process.env.PORT") {
+		t.Errorf("static server does not listen on $PORT:\n%s", script)
+	}
+
+	if _, err := AstroWebProcess(ctx, "hybrid"); err == nil {
+		t.Errorf("AstroWebProcess(ctx, %q) got no error, want error", "hybrid")
+	}
+}
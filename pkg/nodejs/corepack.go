@@ -0,0 +1,135 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	corepackLayer      = "corepack"
+	packageManagerKey  = "package_manager"
+	corepackHomeEnvVar = "COREPACK_HOME"
+)
+
+var (
+	// supportedPackageManagers are the package managers that can be activated by corepack.
+	supportedPackageManagers = []string{"npm", "pnpm", "yarn"}
+	// packageManagerRe matches the "packageManager" field of package.json, for example
+	// "yarn@3.6.0" or "pnpm@8.6.0+sha224.abc123".
+	packageManagerRe = regexp.MustCompile(`^([a-z][a-z0-9-]*)@([^+\s]+)(?:\+([a-z0-9]+\.[0-9a-fA-F]+))?$`)
+	// exactVersionRe matches an exact semantic version, corepack does not accept ranges.
+	exactVersionRe = regexp.MustCompile(`^\d+\.\d+\.\d+(?:-[0-9A-Za-z.-]+)?$`)
+)
+
+// PackageManager is a package manager version pinned by the "packageManager" field of package.json.
+type PackageManager struct {
+	// Name is the name of the package manager, e.g. yarn.
+	Name string
+	// Version is the exact version of the package manager.
+	Version string
+	// Hash is the optional integrity hash of the package manager, e.g. sha224.abc123.
+	Hash string
+}
+
+// String returns the package manager reference in the format expected by corepack.
+func (pm *PackageManager) String() string {
+	s := pm.Name + "@" + pm.Version
+	if pm.Hash != "" {
+		s += "+" + pm.Hash
+	}
+	return s
+}
+
+// ParsePackageManager parses the value of the "packageManager" field of package.json.
+func ParsePackageManager(value string) (*PackageManager, error) {
+	m := packageManagerRe.FindStringSubmatch(strings.TrimSpace(value))
+	if m == nil {
+		return nil, gcp.UserErrorf(`invalid "packageManager" %q in package.json, expected the format <name>@<version>[+<hash>], e.g. "yarn@3.6.0"`, value)
+	}
+	pm := &PackageManager{Name: m[1], Version: m[2], Hash: m[3]}
+	if !isSupportedPackageManager(pm.Name) {
+		return nil, gcp.UserErrorf(`unsupported package manager %q in the "packageManager" field of package.json, supported package managers are: %s`, pm.Name, strings.Join(supportedPackageManagers, ", "))
+	}
+	if !exactVersionRe.MatchString(pm.Version) {
+		return nil, gcp.UserErrorf(`invalid version %q in the "packageManager" field of package.json, an exact version such as "3.6.0" is required`, pm.Version)
+	}
+	return pm, nil
+}
+
+func isSupportedPackageManager(name string) bool {
+	for _, s := range supportedPackageManagers {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+// RequestedPackageManager returns the package manager pinned by the "packageManager" field of the
+// package.json in the given dir, or nil if the field is not set.
+func RequestedPackageManager(dir string) (*PackageManager, error) {
+	pjs, err := ReadPackageJSONIfExists(dir)
+	if err != nil || pjs == nil || pjs.PackageManager == "" {
+		return nil, err
+	}
+	return ParsePackageManager(pjs.PackageManager)
+}
+
+// InstallPackageManager uses corepack to activate the exact version of the given package manager in
+// a cached layer and adds it to the PATH.
+func InstallPackageManager(ctx *gcp.Context, pm *PackageManager) error {
+	l, err := ctx.Layer(corepackLayer, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayer)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", corepackLayer, err)
+	}
+	binDir := filepath.Join(l.Path, "bin")
+	home := filepath.Join(l.Path, "home")
+	l.SharedEnvironment.Override(corepackHomeEnvVar, home)
+	l.SharedEnvironment.Prepend("PATH", string(os.PathListSeparator), binDir)
+	if err := ctx.Setenv(corepackHomeEnvVar, home); err != nil {
+		return err
+	}
+	// Update the path here to ensure the activated package manager takes precedence over the one
+	// bundled with Node.js.
+	if err := ctx.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH")); err != nil {
+		return err
+	}
+
+	if ctx.GetMetadata(l, packageManagerKey) == pm.String() {
+		ctx.CacheHit(corepackLayer)
+		ctx.Logf("%s cache hit, skipping activation.", pm)
+		return nil
+	}
+	ctx.CacheMiss(corepackLayer)
+	if err := ctx.ClearLayer(l); err != nil {
+		return fmt.Errorf("clearing layer %q: %w", l.Name, err)
+	}
+	if err := ctx.MkdirAll(binDir, 0755); err != nil {
+		return err
+	}
+
+	ctx.Logf("Activating %s with corepack.", pm)
+	ctx.Exec([]string{"corepack", "enable", "--install-directory", binDir, pm.Name}, gcp.WithUserAttribution)
+	ctx.Exec([]string{"corepack", "prepare", pm.String(), "--activate"}, gcp.WithUserAttribution)
+	ctx.SetMetadata(l, packageManagerKey, pm.String())
+	return nil
+}
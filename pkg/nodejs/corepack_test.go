@@ -0,0 +1,139 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParsePackageManager(t *testing.T) {
+	testCases := []struct {
+		value   string
+		want    *PackageManager
+		wantErr bool
+	}{
+		{
+			value: "yarn@3.6.0",
+			want:  &PackageManager{Name: "yarn", Version: "3.6.0"},
+		},
+		{
+			value: "npm@9.8.1",
+			want:  &PackageManager{Name: "npm", Version: "9.8.1"},
+		},
+		{
+			value: "pnpm@8.6.0+sha224.953c8233f7a92884eee2de69a1b92d1f2ec1655e66d08071ba9a02fa",
+			want:  &PackageManager{Name: "pnpm", Version: "8.6.0", Hash: "sha224.953c8233f7a92884eee2de69a1b92d1f2ec1655e66d08071ba9a02fa"},
+		},
+		{
+			value: "yarn@4.0.0-rc.42",
+			want:  &PackageManager{Name: "yarn", Version: "4.0.0-rc.42"},
+		},
+		{
+			value: " yarn@1.22.19 ",
+			want:  &PackageManager{Name: "yarn", Version: "1.22.19"},
+		},
+		{
+			value:   "yarn",
+			wantErr: true,
+		},
+		{
+			value:   "yarn@",
+			wantErr: true,
+		},
+		{
+			value:   "yarn@^3.6.0",
+			wantErr: true,
+		},
+		{
+			value:   "yarn@3",
+			wantErr: true,
+		},
+		{
+			value:   "yarn@3.6.0+",
+			wantErr: true,
+		},
+		{
+			value:   "yarn@3.6.0+sha224",
+			wantErr: true,
+		},
+		{
+			value:   "bun@1.0.0",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			got, err := ParsePackageManager(tc.value)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("ParsePackageManager(%q) got err=%t, want err=%t. err: %v", tc.value, gotErr, tc.wantErr, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("ParsePackageManager(%q) mismatch (-want +got):\n%s", tc.value, diff)
+			}
+		})
+	}
+}
+
+func TestPackageManagerString(t *testing.T) {
+	for _, value := range []string{"yarn@3.6.0", "pnpm@8.6.0+sha224.953c8233"} {
+		pm, err := ParsePackageManager(value)
+		if err != nil {
+			t.Fatalf("ParsePackageManager(%q) got error: %v", value, err)
+		}
+		if got := pm.String(); got != value {
+			t.Errorf("PackageManager.String()=%q, want %q", got, value)
+		}
+	}
+}
+
+func TestRequestedPackageManager(t *testing.T) {
+	testCases := []struct {
+		name        string
+		packageJSON string
+		want        *PackageManager
+		wantErr     bool
+	}{
+		{
+			name:        "not set",
+			packageJSON: `{"engines": {"yarn": "3.x"}}`,
+		},
+		{
+			name:        "pinned",
+			packageJSON: `{"packageManager": "yarn@3.6.0"}`,
+			want:        &PackageManager{Name: "yarn", Version: "3.6.0"},
+		},
+		{
+			name:        "unsupported",
+			packageJSON: `{"packageManager": "bun@1.0.0"}`,
+			wantErr:     true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{"package.json": tc.packageJSON})
+
+			got, err := RequestedPackageManager(dir)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("RequestedPackageManager() got err=%t, want err=%t. err: %v", gotErr, tc.wantErr, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("RequestedPackageManager() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// EnvHeapPercent can be used to specify the percentage of the container memory limit that is used
+	// for the V8 old space, i.e. --max-old-space-size.
+	EnvHeapPercent = "GOOGLE_NODE_HEAP_PERCENT"
+
+	defaultHeapPercent = 75
+	heapLayer          = "node_heap"
+	heapExecD          = "max-old-space-size"
+	maxOldSpaceFlag    = "--max-old-space-size"
+
+	// unconstrainedMemoryBytes is the limit at or above which the memory is considered unconstrained.
+	// cgroup v1 reports an unconstrained limit as a page-aligned value close to math.MaxInt64.
+	unconstrainedMemoryBytes int64 = 1 << 50
+)
+
+// cgroupMemoryLimitFiles are the files holding the container memory limit, in order of precedence:
+// cgroup v2 and cgroup v1. cgroup v2 reports an unconstrained limit as "max".
+var cgroupMemoryLimitFiles = []string{
+	"/sys/fs/cgroup/memory.max",
+	"/sys/fs/cgroup/memory/memory.limit_in_bytes",
+}
+
+// HeapPercent returns the percentage of the container memory limit configured with
+// GOOGLE_NODE_HEAP_PERCENT, or the default of 75.
+func HeapPercent() (int, error) {
+	v, ok := os.LookupEnv(EnvHeapPercent)
+	if !ok || v == "" {
+		return defaultHeapPercent, nil
+	}
+	p, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(v), "%"))
+	if err != nil || p < 1 || p > 100 {
+		return 0, gcp.UserErrorf("%s=%q must be an integer percentage between 1 and 100", EnvHeapPercent, v)
+	}
+	return p, nil
+}
+
+// AddHeapCalculator adds an exec.d script to a launch layer that sets --max-old-space-size in
+// NODE_OPTIONS at launch, based on the memory limit of the container and GOOGLE_NODE_HEAP_PERCENT.
+// An existing --max-old-space-size in NODE_OPTIONS is respected.
+func AddHeapCalculator(ctx *gcp.Context) error {
+	percent, err := HeapPercent()
+	if err != nil {
+		return err
+	}
+	l, err := ctx.Layer(heapLayer, gcp.LaunchLayer)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", heapLayer, err)
+	}
+	if err := ctx.MkdirAll(l.Exec.Path, 0755); err != nil {
+		return err
+	}
+	ctx.Logf("Setting the Node.js heap size to %d%% of the container memory limit at launch.", percent)
+	return ctx.WriteFile(filepath.Join(l.Exec.Path, heapExecD), []byte(heapScript(percent, cgroupMemoryLimitFiles)), 0755)
+}
+
+// heapScript returns an exec.d script that appends --max-old-space-size to NODE_OPTIONS at launch,
+// set to percent of the memory limit read from the first readable of limitFiles, unless the memory
+// is unconstrained or NODE_OPTIONS already sets it. exec.d scripts write the environment variables
+// to set as TOML to file descriptor 3.
+func heapScript(percent int, limitFiles []string) string {
+	return fmt.Sprintf(`#!/usr/bin/env bash
+# Sets %[1]s in NODE_OPTIONS to %[2]d%% of the container memory limit.
+for opt in ${NODE_OPTIONS}; do
+  [[ "${opt}" == %[1]s=* ]] && exit 0
+done
+limit=""
+for f in %[3]s; do
+  if [[ -r "${f}" ]]; then
+    limit="$(< "${f}")"
+    break
+  fi
+done
+# cgroup v2 reports an unconstrained limit as "max".
+[[ "${limit}" =~ ^[0-9]+$ ]] || exit 0
+(( limit > 0 && limit < %[4]d )) || exit 0
+mb=$(( limit * %[2]d / 100 / 1048576 ))
+(( mb > 0 )) || exit 0
+opts="${NODE_OPTIONS:+${NODE_OPTIONS} }%[1]s=${mb}"
+opts="${opts//\\/\\\\}"
+opts="${opts//\"/\\\"}"
+echo "NODE_OPTIONS = \"${opts}\"" >&3
+`, maxOldSpaceFlag, percent, strings.Join(limitFiles, " "), unconstrainedMemoryBytes)
+}
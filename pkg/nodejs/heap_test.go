@@ -0,0 +1,148 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHeapPercent(t *testing.T) {
+	testCases := []struct {
+		env     string
+		want    int
+		wantErr bool
+	}{
+		{env: "", want: 75},
+		{env: "50", want: 50},
+		{env: "90%", want: 90},
+		{env: "0", wantErr: true},
+		{env: "101", wantErr: true},
+		{env: "half", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.env, func(t *testing.T) {
+			t.Setenv(EnvHeapPercent, tc.env)
+			got, err := HeapPercent()
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("HeapPercent() got err=%t, want err=%t. err: %v", gotErr, tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Errorf("HeapPercent()=%d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHeapScript(t *testing.T) {
+	testCases := []struct {
+		name        string
+		limit       string
+		percent     int
+		nodeOptions string
+		want        string
+	}{
+		{
+			name:  "constrained",
+			limit: "268435456",
+			want:  `NODE_OPTIONS = "--max-old-space-size=192"`,
+		},
+		{
+			name:  "large limit",
+			limit: "34359738368",
+			want:  `NODE_OPTIONS = "--max-old-space-size=24576"`,
+		},
+		{
+			name:    "percentage override",
+			limit:   "2147483648",
+			percent: 50,
+			want:    `NODE_OPTIONS = "--max-old-space-size=1024"`,
+		},
+		{
+			name:    "rounds down",
+			limit:   "1048576000",
+			percent: 33,
+			want:    `NODE_OPTIONS = "--max-old-space-size=330"`,
+		},
+		{
+			name:  "limit too small",
+			limit: "1048576",
+		},
+		{
+			name:        "appends to existing NODE_OPTIONS",
+			limit:       "268435456",
+			nodeOptions: `--require "./a b.js"`,
+			want:        `NODE_OPTIONS = "--require \"./a b.js\" --max-old-space-size=192"`,
+		},
+		{
+			name:        "respects existing heap size",
+			limit:       "268435456",
+			nodeOptions: "--max-old-space-size=512",
+		},
+		{
+			name:  "unconstrained cgroup v2",
+			limit: "max",
+		},
+		{
+			name:  "unconstrained cgroup v1",
+			limit: "9223372036854771712",
+		},
+		{
+			name: "no cgroup file",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			limitFile := filepath.Join(dir, "memory.max")
+			if tc.limit != "" {
+				writeFiles(t, dir, map[string]string{"memory.max": tc.limit + "\n"})
+			}
+			percent := tc.percent
+			if percent == 0 {
+				percent = defaultHeapPercent
+			}
+			script := filepath.Join(dir, "exec.d")
+			if err := os.WriteFile(script, []byte(heapScript(percent, []string{limitFile})), 0755); err != nil {
+				t.Fatalf("writing script: %v", err)
+			}
+
+			r, w, err := os.Pipe()
+			if err != nil {
+				t.Fatalf("creating pipe: %v", err)
+			}
+			cmd := exec.Command("bash", script)
+			cmd.Env = []string{"NODE_OPTIONS=" + tc.nodeOptions}
+			cmd.ExtraFiles = []*os.File{w}
+			var stderr bytes.Buffer
+			cmd.Stderr = &stderr
+			if err := cmd.Run(); err != nil {
+				t.Fatalf("running script: %v, stderr: %s", err, stderr.String())
+			}
+			w.Close()
+			var out bytes.Buffer
+			if _, err := out.ReadFrom(r); err != nil {
+				t.Fatalf("reading script output: %v", err)
+			}
+			if got := strings.TrimSpace(out.String()); got != tc.want {
+				t.Errorf("script output=%q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// ResolveHoistedModules replaces the symlinks in the node_modules directories of dir that point
+// outside of dir, e.g. into a node_modules directory hoisted to the root of a monorepo, with copies
+// of the files they point to. Such links would dangle in the application image, which only
+// contains dir. Symlinks to targets within dir or within one of the keep directories, e.g. the
+// layers that the buildpacks install node_modules to, are kept, as are the executables linked in
+// node_modules/.bin. It returns the paths of the replaced symlinks relative to dir.
+func ResolveHoistedModules(dir string, keep ...string) ([]string, error) {
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return nil, fmt.Errorf("resolving %s: %w", dir, err)
+	}
+	var resolved []string
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type()&fs.ModeSymlink == 0 || !inNodeModules(root, path) || filepath.Base(filepath.Dir(path)) == ".bin" {
+			return nil
+		}
+		target, err := filepath.EvalSymlinks(path)
+		if os.IsNotExist(err) {
+			// A dangling symlink has no content to copy.
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("resolving %s: %w", path, err)
+		}
+		if within(root, target) || withinAny(keep, target) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("removing %s: %w", path, err)
+		}
+		if err := copyResolved(target, path, map[string]bool{}); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		resolved = append(resolved, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("resolving hoisted modules: %w", err)
+	}
+	return resolved, nil
+}
+
+// ResolveHoistedAppModules calls ResolveHoistedModules on the application root, keeping the links
+// into the layers of the buildpacks, unless GOOGLE_NODEJS_KEEP_SYMLINKS is set.
+func ResolveHoistedAppModules(ctx *gcp.Context) error {
+	keep, err := env.IsPresentAndTrue(EnvKeepSymlinks)
+	if err != nil {
+		return gcp.UserErrorf("%v", err)
+	}
+	if keep {
+		ctx.Logf("Keeping symlinks in node_modules because %s is set.", EnvKeepSymlinks)
+		return nil
+	}
+	var layers []string
+	if dir := ctx.LayersDir(); dir != "" {
+		// The layers of all buildpacks are siblings of the layers of this buildpack.
+		layers = append(layers, filepath.Dir(dir))
+	}
+	resolved, err := ResolveHoistedModules(ctx.ApplicationRoot(), layers...)
+	if err != nil {
+		return gcp.InternalErrorf("%v", err)
+	}
+	for _, path := range resolved {
+		ctx.Logf("Replaced symlink %s with the files it points to.", path)
+	}
+	return nil
+}
+
+// copyResolved copies the file or directory src to dst, replacing the symlinks in it with the
+// content they point to. active holds the directories being copied, to stop on symlink cycles.
+func copyResolved(src, dst string, active map[string]bool) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return copyFile(src, dst, info.Mode().Perm())
+	}
+	real, err := filepath.EvalSymlinks(src)
+	if err != nil {
+		return err
+	}
+	if active[real] {
+		return fmt.Errorf("symlink cycle at %s", src)
+	}
+	active[real] = true
+	defer delete(active, real)
+
+	if err := os.MkdirAll(dst, info.Mode().Perm()); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := copyResolved(filepath.Join(src, e.Name()), filepath.Join(dst, e.Name()), active); err != nil {
+			// Skip dangling symlinks, there is nothing to copy.
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// inNodeModules returns true if path is a node_modules directory under root, or inside one.
+func inNodeModules(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		if part == "node_modules" {
+			return true
+		}
+	}
+	return false
+}
+
+// withinAny returns true if path is one of roots or inside one of them.
+func withinAny(roots []string, path string) bool {
+	for _, root := range roots {
+		if real, err := filepath.EvalSymlinks(root); err == nil && within(real, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// within returns true if path is root or inside root.
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
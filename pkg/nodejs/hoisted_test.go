@@ -0,0 +1,141 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestResolveHoistedModules(t *testing.T) {
+	// repo is a monorepo with dependencies hoisted to its root node_modules, which the node_modules
+	// of the app workspace links to.
+	repo := t.TempDir()
+	writeFiles(t, repo, map[string]string{
+		"node_modules/express/package.json":        `{"name": "express"}`,
+		"node_modules/express/lib/index.js":        "module.exports = {};",
+		"node_modules/left-pad/index.js":           "module.exports = () => {};",
+		"packages/app/package.json":                `{"name": "app"}`,
+		"packages/app/node_modules/local/index.js": "module.exports = 1;",
+	})
+	app := filepath.Join(repo, "packages", "app")
+	symlink(t, filepath.Join(repo, "node_modules", "express"), filepath.Join(app, "node_modules", "express"))
+	// A scoped package that links to a hoisted package, which itself contains a relative link.
+	symlink(t, filepath.Join(repo, "node_modules", "left-pad"), filepath.Join(app, "node_modules", "@acme", "pad"))
+	symlink(t, "index.js", filepath.Join(repo, "node_modules", "left-pad", "main.js"))
+	// Links within the app are kept.
+	symlink(t, "../local/index.js", filepath.Join(app, "node_modules", ".bin", "local"))
+	// Executables are kept as links, even to hoisted modules.
+	symlink(t, filepath.Join(repo, "node_modules", "express", "lib", "index.js"), filepath.Join(app, "node_modules", ".bin", "express"))
+	// Dangling links are left alone.
+	symlink(t, filepath.Join(repo, "missing"), filepath.Join(app, "node_modules", "missing"))
+
+	got, err := ResolveHoistedModules(app)
+	if err != nil {
+		t.Fatalf("ResolveHoistedModules(%q) got error: %v", app, err)
+	}
+	want := []string{"node_modules/@acme/pad", "node_modules/express"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ResolveHoistedModules(%q) mismatch (-want +got):\n%s", app, diff)
+	}
+
+	for path, content := range map[string]string{
+		"node_modules/express/package.json": `{"name": "express"}`,
+		"node_modules/express/lib/index.js": "module.exports = {};",
+		"node_modules/@acme/pad/index.js":   "module.exports = () => {};",
+		"node_modules/@acme/pad/main.js":    "module.exports = () => {};",
+	} {
+		full := filepath.Join(app, path)
+		fi, err := os.Lstat(full)
+		if err != nil {
+			t.Errorf("stat %s: %v", path, err)
+			continue
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			t.Errorf("%s is a symlink, want a real file", path)
+		}
+		if got, err := os.ReadFile(full); err != nil || string(got) != content {
+			t.Errorf("reading %s = %q, %v, want %q", path, got, err, content)
+		}
+	}
+	for _, dir := range []string{"node_modules/express", "node_modules/@acme/pad"} {
+		if fi, err := os.Lstat(filepath.Join(app, dir)); err != nil || !fi.IsDir() {
+			t.Errorf("%s is not a directory: %v", dir, err)
+		}
+	}
+	for _, link := range []string{"node_modules/.bin/local", "node_modules/.bin/express", "node_modules/missing"} {
+		if fi, err := os.Lstat(filepath.Join(app, link)); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+			t.Errorf("%s is not a symlink anymore: %v", link, err)
+		}
+	}
+	// The hoisted modules are untouched.
+	if fi, err := os.Lstat(filepath.Join(repo, "node_modules", "left-pad", "main.js")); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("hoisted left-pad/main.js is not a symlink anymore: %v", err)
+	}
+}
+
+func TestResolveHoistedModulesLinkedNodeModules(t *testing.T) {
+	repo := t.TempDir()
+	writeFiles(t, repo, map[string]string{
+		"node_modules/express/index.js": "module.exports = {};",
+	})
+	app := filepath.Join(repo, "app")
+	symlink(t, filepath.Join(repo, "node_modules"), filepath.Join(app, "node_modules"))
+
+	got, err := ResolveHoistedModules(app)
+	if err != nil {
+		t.Fatalf("ResolveHoistedModules(%q) got error: %v", app, err)
+	}
+	if diff := cmp.Diff([]string{"node_modules"}, got); diff != "" {
+		t.Errorf("ResolveHoistedModules(%q) mismatch (-want +got):\n%s", app, diff)
+	}
+	if fi, err := os.Lstat(filepath.Join(app, "node_modules", "express", "index.js")); err != nil || !fi.Mode().IsRegular() {
+		t.Errorf("node_modules/express/index.js is not a regular file: %v", err)
+	}
+}
+
+func TestResolveHoistedModulesKeepsLayerLinks(t *testing.T) {
+	// The yarn buildpack installs node_modules in a layer and links the node_modules of the app to it.
+	layers := t.TempDir()
+	writeFiles(t, layers, map[string]string{
+		"yarn/node_modules/express/index.js": "module.exports = {};",
+	})
+	app := t.TempDir()
+	symlink(t, filepath.Join(layers, "yarn", "node_modules"), filepath.Join(app, "node_modules"))
+
+	got, err := ResolveHoistedModules(app, layers)
+	if err != nil {
+		t.Fatalf("ResolveHoistedModules(%q) got error: %v", app, err)
+	}
+	if len(got) != 0 {
+		t.Errorf("ResolveHoistedModules(%q) = %v, want no resolved links", app, got)
+	}
+	if fi, err := os.Lstat(filepath.Join(app, "node_modules")); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("node_modules is not a symlink anymore: %v", err)
+	}
+}
+
+func symlink(t *testing.T, target, link string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(link), 0755); err != nil {
+		t.Fatalf("creating dir for %s: %v", link, err)
+	}
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatalf("creating symlink %s: %v", link, err)
+	}
+}
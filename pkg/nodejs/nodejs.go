@@ -37,6 +37,24 @@ const (
 	EnvProduction = "production"
 	// EnvNodeVersion can be used to specify the version of Node.js is used for an app.
 	EnvNodeVersion = "GOOGLE_NODEJS_VERSION"
+	// EnvWorkspace can be used to select the workspace, by package name or directory, that is run
+	// by the web process of a workspaces monorepo. With npm, only its dependencies are installed.
+	EnvWorkspace = "GOOGLE_NODEJS_WORKSPACE"
+	// EnvOfflineMirror can be used to specify a directory of pre-staged packages that dependencies are
+	// installed from without network access.
+	EnvOfflineMirror = "GOOGLE_NODE_OFFLINE_MIRROR"
+	// EnvIncludeDev can be used to keep the devDependencies in the application image.
+	EnvIncludeDev = "GOOGLE_NODEJS_INCLUDE_DEV"
+	// EnvKeepSymlinks can be used to keep symlinks in node_modules that point outside the
+	// application instead of replacing them with the files they point to.
+	EnvKeepSymlinks = "GOOGLE_NODEJS_KEEP_SYMLINKS"
+	// EnvBuildScript can be used to specify the name of the package.json script that builds the app.
+	EnvBuildScript = "GOOGLE_NODEJS_BUILD_SCRIPT"
+	// EnvNPMStrictSSL can be set to false to disable the validation of the SSL certificate of the npm
+	// registry during the build, e.g. behind a proxy that intercepts TLS.
+	EnvNPMStrictSSL = "GOOGLE_NPM_STRICT_SSL"
+
+	defaultBuildScript = "build"
 
 	nodeVersionKey    = "node_version"
 	dependencyHashKey = "dependency_hash"
@@ -51,20 +69,37 @@ type packageEnginesJSON struct {
 	Yarn string `json:"yarn"`
 }
 
+// packageScriptsJSON is the "scripts" section of package.json, see UnmarshalJSON.
 type packageScriptsJSON struct {
-	Start    string `json:"start"`
-	GCPBuild string `json:"gcp-build"`
+	// Start, GCPBuild and Build are the "start", "gcp-build" and "build" scripts.
+	Start    string
+	GCPBuild string
+	Build    string
+	// All holds every script by name, including the ones above.
+	All map[string]string
+}
+
+// UnmarshalJSON unmarshals the "scripts" section of package.json, keeping every script in All.
+func (s *packageScriptsJSON) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &s.All); err != nil {
+		return err
+	}
+	s.Start, s.GCPBuild, s.Build = s.All["start"], s.All["gcp-build"], s.All["build"]
+	return nil
 }
 
 // PackageJSON represents the contents of a package.json file.
 type PackageJSON struct {
-	Main            string             `json:"main"`
-	Type            string             `json:"type"`
-	Version         string             `json:"version"`
-	Engines         packageEnginesJSON `json:"engines"`
-	Scripts         packageScriptsJSON `json:"scripts"`
-	Dependencies    map[string]string  `json:"dependencies"`
-	DevDependencies map[string]string  `json:"devDependencies"`
+	Name            string                `json:"name"`
+	Main            string                `json:"main"`
+	Type            string                `json:"type"`
+	Version         string                `json:"version"`
+	Engines         packageEnginesJSON    `json:"engines"`
+	Scripts         packageScriptsJSON    `json:"scripts"`
+	Dependencies    map[string]string     `json:"dependencies"`
+	DevDependencies map[string]string     `json:"devDependencies"`
+	Workspaces      packageWorkspacesJSON `json:"workspaces"`
+	PackageManager  string                `json:"packageManager"`
 }
 
 // ReadPackageJSONIfExists returns deserialized package.json from the given dir. If the provided dir
@@ -88,6 +123,17 @@ func ReadPackageJSONIfExists(dir string) (*PackageJSON, error) {
 	return &pjs, nil
 }
 
+// PackageJSONOptOut returns an OptOut result and true if the application has no package.json,
+// e.g. a static site, so that dependency buildpacks leave it to other buildpacks instead of failing
+// to install its dependencies.
+func PackageJSONOptOut(ctx *gcp.Context) (gcp.DetectResult, bool, error) {
+	exists, err := ctx.FileExists(ctx.ApplicationRoot(), "package.json")
+	if err != nil || exists {
+		return nil, false, err
+	}
+	return gcp.OptOut("package.json not found, there are no Node.js dependencies to install"), true, nil
+}
+
 // HasGCPBuild returns true if the given directory contains a package.json file that includes a
 // non-empty "gcp-build" script.
 func HasGCPBuild(dir string) (bool, error) {
@@ -98,6 +144,28 @@ func HasGCPBuild(dir string) (bool, error) {
 	return p.Scripts.GCPBuild != "", nil
 }
 
+// BuildScript returns the name of the package.json script that builds the application, which is
+// "build" unless overridden by GOOGLE_NODEJS_BUILD_SCRIPT, or "" if package.json has no such script.
+func BuildScript(ctx *gcp.Context) (string, error) {
+	name := defaultBuildScript
+	if v := os.Getenv(EnvBuildScript); v != "" {
+		name = v
+	}
+	p, err := ReadPackageJSONIfExists(ctx.ApplicationRoot())
+	if err != nil || p == nil {
+		return "", err
+	}
+	if p.Scripts.All[name] == "" {
+		if name != defaultBuildScript {
+			ctx.Logf("Skipping the build, package.json has no %q script named by %s.", name, EnvBuildScript)
+		} else {
+			ctx.Debugf("Skipping the build, package.json has no %q script.", name)
+		}
+		return "", nil
+	}
+	return name, nil
+}
+
 // HasDevDependencies returns true if the given directory contains a package.json file that lists
 // more one or more devDependencies.
 func HasDevDependencies(dir string) (bool, error) {
@@ -109,15 +177,20 @@ func HasDevDependencies(dir string) (bool, error) {
 }
 
 // RequestedNodejsVersion returns any customer provided Node.js version constraint by inspecting the
-// environment and the package.json.
+// environment and the package.json. The constraint may be a range such as ">=18 <21", which
+// runtime.ResolveVersion resolves to the newest available version.
 func RequestedNodejsVersion(ctx *gcp.Context, dir string) (string, error) {
 	if version := os.Getenv(EnvNodeVersion); version != "" {
 		ctx.Logf("Using runtime version from %s: %s", EnvNodeVersion, version)
 		return version, nil
 	}
 	if version := os.Getenv(env.RuntimeVersion); version != "" {
-		ctx.Logf("Using runtime version from %s: %s", env.RuntimeVersion, version)
-		return version, nil
+		// GOOGLE_RUNTIME_VERSION belongs to the language that lists Node.js as an additional buildpack.
+		if !env.IsAdditionalBuildpack("nodejs") {
+			ctx.Logf("Using runtime version from %s: %s", env.RuntimeVersion, version)
+			return version, nil
+		}
+		ctx.Debugf("Ignoring %s since Node.js is listed in %s, set %s to select the Node.js version", env.RuntimeVersion, env.AdditionalBuildpacks, EnvNodeVersion)
 	}
 	pjs, err := ReadPackageJSONIfExists(dir)
 	if err != nil || pjs == nil {
@@ -152,6 +225,22 @@ func NodeEnv() string {
 	return nodeEnv
 }
 
+// KeepDevDependencies returns whether the devDependencies are kept in the application image, i.e.
+// GOOGLE_NODEJS_INCLUDE_DEV is true or NODE_ENV is not production, and the reason if they are.
+func KeepDevDependencies() (bool, string, error) {
+	includeDev, err := env.IsPresentAndTrue(EnvIncludeDev)
+	if err != nil {
+		return false, "", gcp.UserErrorf("%v", err)
+	}
+	if includeDev {
+		return true, fmt.Sprintf("%s is set", EnvIncludeDev), nil
+	}
+	if nodeEnv := NodeEnv(); nodeEnv != EnvProduction {
+		return true, fmt.Sprintf("$NODE_ENV=%q", nodeEnv), nil
+	}
+	return false, "", nil
+}
+
 // CheckCache checks whether cached dependencies exist and match.
 func CheckCache(ctx *gcp.Context, l *libcnb.Layer, opts ...cache.Option) (bool, error) {
 	currentNodeVersion := nodeVersion(ctx)
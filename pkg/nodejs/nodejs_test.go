@@ -19,6 +19,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
@@ -33,6 +34,7 @@ func TestReadPackageJSONIfExists(t *testing.T) {
 		},
 		Scripts: packageScriptsJSON{
 			Start: "my-start",
+			All:   map[string]string{"start": "my-start"},
 		},
 		Dependencies: map[string]string{
 			"a": "1.0",
@@ -172,11 +174,80 @@ func TestHasGCPBuild(t *testing.T) {
 	}
 }
 
+func TestBuildScript(t *testing.T) {
+	testCases := []struct {
+		name        string
+		packageJSON string
+		buildScript string
+		want        string
+		wantErr     bool
+	}{
+		{
+			name: "no package.json",
+			want: "",
+		},
+		{
+			name:        "invalid package.json",
+			packageJSON: `invalid json`,
+			wantErr:     true,
+		},
+		{
+			name:        "no build script",
+			packageJSON: `{"scripts": {"start": "node index.js"}}`,
+			want:        "",
+		},
+		{
+			name:        "with build script",
+			packageJSON: `{"scripts": {"build": "tsc"}}`,
+			want:        "build",
+		},
+		{
+			name:        "empty build script",
+			packageJSON: `{"scripts": {"build": ""}}`,
+			want:        "",
+		},
+		{
+			name:        "env override",
+			packageJSON: `{"scripts": {"build": "tsc", "compile": "webpack"}}`,
+			buildScript: "compile",
+			want:        "compile",
+		},
+		{
+			name:        "env override script absent",
+			packageJSON: `{"scripts": {"build": "tsc"}}`,
+			buildScript: "compile",
+			want:        "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tc.packageJSON != "" {
+				path := filepath.Join(dir, "package.json")
+				if err := ioutil.WriteFile(path, []byte(tc.packageJSON), 0744); err != nil {
+					t.Fatalf("writing %s: %v", path, err)
+				}
+			}
+			t.Setenv(EnvBuildScript, tc.buildScript)
+
+			got, err := BuildScript(gcp.NewContext(gcp.WithApplicationRoot(dir)))
+			if tc.wantErr == (err == nil) {
+				t.Errorf("BuildScript() got error: %v, want err? %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("BuildScript() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
 func TestRequestedNodejsVersion(t *testing.T) {
 	testCases := []struct {
 		name        string
 		nodeEnv     string
 		runtimeEnv  string
+		additional  string
 		packageJSON string
 		want        string
 		wantErr     bool
@@ -212,6 +283,20 @@ func TestRequestedNodejsVersion(t *testing.T) {
 			runtimeEnv:  "3.3.3",
 			want:        "3.3.3",
 		},
+		{
+			name:        "GOOGLE_RUNTIME_VERSION of another language",
+			packageJSON: `{"engines": {"node": "2.2.2"}}`,
+			runtimeEnv:  "1.22",
+			additional:  "nodejs",
+			want:        "2.2.2",
+		},
+		{
+			name:       "GOOGLE_NODEJS_VERSION with another language",
+			nodeEnv:    "1.2.3",
+			runtimeEnv: "1.22",
+			additional: "nodejs",
+			want:       "1.2.3",
+		},
 		{
 			name:        "invalid package.json",
 			packageJSON: `invalid json`,
@@ -235,6 +320,9 @@ func TestRequestedNodejsVersion(t *testing.T) {
 			if tc.runtimeEnv != "" {
 				t.Setenv("GOOGLE_RUNTIME_VERSION", tc.runtimeEnv)
 			}
+			if tc.additional != "" {
+				t.Setenv("GOOGLE_ADDITIONAL_BUILDPACKS", tc.additional)
+			}
 
 			ctx := gcp.NewContext()
 			got, err := RequestedNodejsVersion(ctx, dir)
@@ -248,6 +336,59 @@ func TestRequestedNodejsVersion(t *testing.T) {
 	}
 }
 
+func TestKeepDevDependencies(t *testing.T) {
+	testCases := []struct {
+		name       string
+		includeDev string
+		nodeEnv    string
+		want       bool
+		wantReason string
+		wantErr    bool
+	}{
+		{
+			name: "production by default",
+		},
+		{
+			name:    "NODE_ENV is production",
+			nodeEnv: "production",
+		},
+		{
+			name:       "NODE_ENV is development",
+			nodeEnv:    "development",
+			want:       true,
+			wantReason: `$NODE_ENV="development"`,
+		},
+		{
+			name:       "GOOGLE_NODEJS_INCLUDE_DEV is true",
+			includeDev: "true",
+			nodeEnv:    "production",
+			want:       true,
+			wantReason: "GOOGLE_NODEJS_INCLUDE_DEV is set",
+		},
+		{
+			name:       "GOOGLE_NODEJS_INCLUDE_DEV is invalid",
+			includeDev: "maybe",
+			wantErr:    true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.includeDev != "" {
+				t.Setenv(EnvIncludeDev, tc.includeDev)
+			}
+			t.Setenv("NODE_ENV", tc.nodeEnv)
+
+			got, reason, err := KeepDevDependencies()
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("KeepDevDependencies() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if got != tc.want || reason != tc.wantReason {
+				t.Errorf("KeepDevDependencies() = %t, %q, want %t, %q", got, reason, tc.want, tc.wantReason)
+			}
+		})
+	}
+}
+
 func TestIsNodeJS8Runtime(t *testing.T) {
 	testCases := []struct {
 		name           string
@@ -293,3 +434,56 @@ func setGoogleRuntime(t *testing.T, value string) {
 		t.Errorf("Error setting environment variable %q: %v", googleRuntimeEnv, err)
 	}
 }
+
+func TestPackageJSONOptOut(t *testing.T) {
+	testCases := []struct {
+		name        string
+		files       []string
+		wantOptOut  bool
+		wantMessage string
+	}{
+		{
+			name:  "package.json",
+			files: []string{"package.json", "index.js"},
+		},
+		{
+			name:        "static site",
+			files:       []string{"index.html", "app.js"},
+			wantOptOut:  true,
+			wantMessage: "package.json not found",
+		},
+		{
+			name:        "empty",
+			wantOptOut:  true,
+			wantMessage: "package.json not found",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, f := range tc.files {
+				if err := ioutil.WriteFile(filepath.Join(dir, f), []byte("{}"), 0644); err != nil {
+					t.Fatalf("writing %s: %v", f, err)
+				}
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+
+			result, optOut, err := PackageJSONOptOut(ctx)
+			if err != nil {
+				t.Fatalf("PackageJSONOptOut() got error: %v", err)
+			}
+			if optOut != tc.wantOptOut {
+				t.Fatalf("PackageJSONOptOut() opted out: %t, want %t", optOut, tc.wantOptOut)
+			}
+			if !optOut {
+				return
+			}
+			if result.Result().Pass {
+				t.Errorf("PackageJSONOptOut() passed, want opt out")
+			}
+			if !strings.Contains(result.Reason(), tc.wantMessage) {
+				t.Errorf("PackageJSONOptOut() reason = %q, want it to contain %q", result.Reason(), tc.wantMessage)
+			}
+		})
+	}
+}
@@ -15,8 +15,12 @@
 package nodejs
 
 import (
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/Masterminds/semver"
 )
@@ -33,15 +37,32 @@ var (
 	minPruneVersion = semver.MustParse("5.7.0")
 	// minNpmCIVersion is the first npm version that suports the ci command.
 	minNpmCIVersion = semver.MustParse("6.14.0")
+	// minOmitVersion is the first npm version that supports the --omit and --include flags.
+	minOmitVersion = semver.MustParse("7.0.0")
+	// minWorkspacesVersion is the first npm version that supports workspaces.
+	minWorkspacesVersion = semver.MustParse("7.0.0")
 )
 
 // RequestedNPMVersion returns any customer provided NPM version constraint configured in the
-// "engines" section of the package.json file in the given application dir.
+// "engines" section of the package.json file in the given application dir. An npm version pinned
+// by the "packageManager" field takes precedence over "engines.npm".
 func RequestedNPMVersion(dir string) (string, error) {
 	pjs, err := ReadPackageJSONIfExists(dir)
-	if err != nil || pjs == nil || pjs.Engines.NPM == "" {
+	if err != nil || pjs == nil {
 		return "", err
 	}
+	if pjs.PackageManager != "" {
+		pm, err := ParsePackageManager(pjs.PackageManager)
+		if err != nil {
+			return "", err
+		}
+		if pm.Name == "npm" {
+			return pm.Version, nil
+		}
+	}
+	if pjs.Engines.NPM == "" {
+		return "", nil
+	}
 	version, err := resolvePackageVersion("npm", pjs.Engines.NPM)
 	if err != nil {
 		gcp.InternalErrorf("fetching npm metadata: %v", err)
@@ -86,6 +107,64 @@ func NPMInstallCommand(ctx *gcp.Context) (string, error) {
 	return "ci", nil
 }
 
+// NPMWorkspace describes the install of the dependencies of a member of an npm workspaces monorepo.
+type NPMWorkspace struct {
+	// Member is the workspace whose dependencies are installed.
+	Member Workspace
+	// Flags select the member in the npm commands run from the workspace root, e.g. `npm ci`.
+	Flags []string
+	// CacheFiles are the files that determine the installed dependencies, relative to the root.
+	CacheFiles []string
+}
+
+// NPMWorkspaceInstall returns how to install the dependencies of the given member of the npm
+// workspaces monorepo at the application root. npm resolves the dependencies of all workspaces in
+// the root lockfile, so the install runs from the root and is cached on the root lockfile, and
+// `npm ci -w <member>` installs only the dependencies of the member.
+func NPMWorkspaceInstall(ctx *gcp.Context, member *Workspace) (*NPMWorkspace, error) {
+	version, err := semver.NewVersion(npmVersion(ctx))
+	if err != nil {
+		return nil, gcp.InternalErrorf("parsing npm version: %v", err)
+	}
+	if version.LessThan(minWorkspacesVersion) {
+		return nil, gcp.UserErrorf("%s is set to %q but npm %s does not support workspaces, use npm %s or later", EnvWorkspace, member.Name, version, minWorkspacesVersion)
+	}
+	lockfile := ""
+	// npm prefers npm-shrinkwrap.json, see https://docs.npmjs.com/cli/shrinkwrap.
+	for _, name := range []string{NPMShrinkwrap, PackageLock} {
+		exists, err := ctx.FileExists(ctx.ApplicationRoot(), name)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			lockfile = name
+			break
+		}
+	}
+	if lockfile == "" {
+		return nil, gcp.UserErrorf("installing workspace %s requires a %s at the workspace root", member.Name, PackageLock)
+	}
+	for _, name := range []string{NPMShrinkwrap, PackageLock} {
+		exists, err := ctx.FileExists(ctx.ApplicationRoot(), member.Dir, name)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			ctx.Warnf("Ignoring %s of workspace %s, dependencies are installed from the root %s.", filepath.Join(member.Dir, name), member.Name, lockfile)
+		}
+	}
+	return &NPMWorkspace{
+		Member:     *member,
+		Flags:      []string{"-w", member.Name},
+		CacheFiles: []string{"package.json", lockfile, filepath.Join(member.Dir, "package.json")},
+	}, nil
+}
+
+// NPMVersion returns the version of npm installed in the system.
+func NPMVersion(ctx *gcp.Context) string {
+	return npmVersion(ctx)
+}
+
 // npmVersion returns the version of NPM installed in the system.
 var npmVersion = func(ctx *gcp.Context) string {
 	return strings.TrimSpace(ctx.Exec([]string{"npm", "--version"}).Stdout)
@@ -100,3 +179,60 @@ func SupportsNPMPrune(ctx *gcp.Context) (bool, error) {
 	}
 	return !version.LessThan(minPruneVersion), nil
 }
+
+// NPMDevDependencyFlags returns the flags of `npm ci` and `npm install` that select whether
+// devDependencies are installed. Outside of dev mode devDependencies are omitted, unless they are
+// kept in the application image, see KeepDevDependencies. In dev mode, no flags are returned and npm
+// decides based on NODE_ENV.
+func NPMDevDependencyFlags(ctx *gcp.Context) ([]string, error) {
+	devMode, err := env.IsDevMode()
+	if err != nil {
+		return nil, gcp.UserErrorf("%v", err)
+	}
+	includeDev, _, err := KeepDevDependencies()
+	if err != nil {
+		return nil, err
+	}
+	if devMode {
+		return nil, nil
+	}
+	version, err := semver.NewVersion(npmVersion(ctx))
+	if err != nil {
+		return nil, gcp.InternalErrorf("parsing npm version: %v", err)
+	}
+	return devDependencyFlags(version, includeDev), nil
+}
+
+// devDependencyFlags returns the flags that include or omit devDependencies for the npm version.
+func devDependencyFlags(version *semver.Version, includeDev bool) []string {
+	omitSupported := !version.LessThan(minOmitVersion)
+	switch {
+	case includeDev && omitSupported:
+		return []string{"--include=dev"}
+	case includeDev:
+		return []string{"--production=false"}
+	case omitSupported:
+		return []string{"--omit=dev"}
+	default:
+		return []string{"--production"}
+	}
+}
+
+// StrictSSLFlag returns the flags that disable the validation of the SSL certificate of the npm
+// registry if GOOGLE_NPM_STRICT_SSL is false, or nil by default. The flags are only added to the
+// npm commands of the build, never to the configuration of the application image.
+func StrictSSLFlag(ctx *gcp.Context) ([]string, error) {
+	v, ok := os.LookupEnv(EnvNPMStrictSSL)
+	if !ok || v == "" {
+		return nil, nil
+	}
+	strict, err := strconv.ParseBool(v)
+	if err != nil {
+		return nil, gcp.UserErrorf("invalid value %q for %s, must be true or false: %v", v, EnvNPMStrictSSL, err)
+	}
+	if strict {
+		return nil, nil
+	}
+	ctx.Warnf("%s=false disables the validation of the SSL certificate of the npm registry, which is insecure: packages could be tampered with in transit. Only use it behind a trusted proxy.", EnvNPMStrictSSL)
+	return []string{"--strict-ssl=false"}, nil
+}
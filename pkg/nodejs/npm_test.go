@@ -15,11 +15,18 @@
 package nodejs
 
 import (
+	"bytes"
 	"io/ioutil"
+	"log"
+	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestRequestedNPMVersion(t *testing.T) {
@@ -39,6 +46,26 @@ func TestRequestedNPMVersion(t *testing.T) {
 			packageJSON: `{"engines": {"npm": "2.2.2"}}`,
 			want:        "2.2.2",
 		},
+		{
+			name:        "packageManager takes precedence over engines.npm",
+			packageJSON: `{"packageManager": "npm@9.8.1", "engines": {"npm": "2.2.2"}}`,
+			want:        "9.8.1",
+		},
+		{
+			name:        "packageManager is not npm",
+			packageJSON: `{"packageManager": "yarn@3.6.0", "engines": {"npm": "2.2.2"}}`,
+			want:        "2.2.2",
+		},
+		{
+			name:        "packageManager is pnpm",
+			packageJSON: `{"packageManager": "pnpm@8.6.0", "engines": {"npm": "2.2.2"}}`,
+			want:        "2.2.2",
+		},
+		{
+			name:        "invalid packageManager",
+			packageJSON: `{"packageManager": "npm@latest"}`,
+			wantErr:     true,
+		},
 		{
 			name:        "invalid package.json",
 			packageJSON: `invalid json`,
@@ -133,3 +160,242 @@ func TestSupportsNPMPrune(t *testing.T) {
 		})
 	}
 }
+
+func TestNPMDevDependencyFlags(t *testing.T) {
+	testCases := []struct {
+		name       string
+		version    string
+		devMode    bool
+		includeDev bool
+		nodeEnv    string
+		want       []string
+	}{
+		{
+			name:    "npm 8 omits devDependencies",
+			version: "8.3.1",
+			want:    []string{"--omit=dev"},
+		},
+		{
+			name:    "npm 7 omits devDependencies",
+			version: "7.0.0",
+			want:    []string{"--omit=dev"},
+		},
+		{
+			name:    "npm 6 uses --production",
+			version: "6.14.15",
+			want:    []string{"--production"},
+		},
+		{
+			name:       "npm 8 includes devDependencies",
+			version:    "8.3.1",
+			includeDev: true,
+			want:       []string{"--include=dev"},
+		},
+		{
+			name:       "npm 6 includes devDependencies",
+			version:    "6.14.15",
+			includeDev: true,
+			want:       []string{"--production=false"},
+		},
+		{
+			name:    "npm 8 includes devDependencies outside of production",
+			version: "8.3.1",
+			nodeEnv: "development",
+			want:    []string{"--include=dev"},
+		},
+		{
+			name:    "dev mode",
+			version: "8.3.1",
+			devMode: true,
+		},
+		{
+			name:       "dev mode with include dev",
+			version:    "6.14.15",
+			devMode:    true,
+			includeDev: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func(fn func(*gcpbuildpack.Context) string) { npmVersion = fn }(npmVersion)
+			npmVersion = func(*gcpbuildpack.Context) string { return tc.version }
+			t.Setenv(env.DevMode, strconv.FormatBool(tc.devMode))
+			t.Setenv(EnvIncludeDev, strconv.FormatBool(tc.includeDev))
+			t.Setenv("NODE_ENV", tc.nodeEnv)
+
+			got, err := NPMDevDependencyFlags(nil)
+			if err != nil {
+				t.Fatalf("npm %v: NPMDevDependencyFlags(nil) got error: %v", tc.version, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("npm %v: NPMDevDependencyFlags(nil) mismatch (-want +got):\n%s", tc.version, diff)
+			}
+		})
+	}
+}
+
+func TestNPMWorkspaceInstall(t *testing.T) {
+	member := &Workspace{Name: "@acme/api", Dir: "packages/api"}
+	testCases := []struct {
+		name    string
+		version string
+		files   map[string]string
+		want    *NPMWorkspace
+		wantErr bool
+	}{
+		{
+			name:    "root package-lock.json",
+			version: "8.3.1",
+			files: map[string]string{
+				"package.json":              `{"workspaces": ["packages/*"]}`,
+				"package-lock.json":         "{}",
+				"packages/api/package.json": `{"name": "@acme/api"}`,
+			},
+			want: &NPMWorkspace{
+				Member:     *member,
+				Flags:      []string{"-w", "@acme/api"},
+				CacheFiles: []string{"package.json", "package-lock.json", "packages/api/package.json"},
+			},
+		},
+		{
+			name:    "root npm-shrinkwrap.json",
+			version: "8.3.1",
+			files: map[string]string{
+				"package.json":              `{"workspaces": ["packages/*"]}`,
+				"package-lock.json":         "{}",
+				"npm-shrinkwrap.json":       "{}",
+				"packages/api/package.json": `{"name": "@acme/api"}`,
+			},
+			want: &NPMWorkspace{
+				Member:     *member,
+				Flags:      []string{"-w", "@acme/api"},
+				CacheFiles: []string{"package.json", "npm-shrinkwrap.json", "packages/api/package.json"},
+			},
+		},
+		{
+			name:    "member lockfile is ignored",
+			version: "8.3.1",
+			files: map[string]string{
+				"package.json":                   `{"workspaces": ["packages/*"]}`,
+				"package-lock.json":              "{}",
+				"packages/api/package.json":      `{"name": "@acme/api"}`,
+				"packages/api/package-lock.json": "{}",
+			},
+			want: &NPMWorkspace{
+				Member:     *member,
+				Flags:      []string{"-w", "@acme/api"},
+				CacheFiles: []string{"package.json", "package-lock.json", "packages/api/package.json"},
+			},
+		},
+		{
+			name:    "no root lockfile",
+			version: "8.3.1",
+			files: map[string]string{
+				"package.json":                   `{"workspaces": ["packages/*"]}`,
+				"packages/api/package.json":      `{"name": "@acme/api"}`,
+				"packages/api/package-lock.json": "{}",
+			},
+			wantErr: true,
+		},
+		{
+			name:    "npm 6 does not support workspaces",
+			version: "6.14.15",
+			files: map[string]string{
+				"package.json":              `{"workspaces": ["packages/*"]}`,
+				"package-lock.json":         "{}",
+				"packages/api/package.json": `{"name": "@acme/api"}`,
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func(fn func(*gcpbuildpack.Context) string) { npmVersion = fn }(npmVersion)
+			npmVersion = func(*gcpbuildpack.Context) string { return tc.version }
+			dir := t.TempDir()
+			writeFiles(t, dir, tc.files)
+
+			got, err := NPMWorkspaceInstall(gcpbuildpack.NewContext(gcpbuildpack.WithApplicationRoot(dir)), member)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("NPMWorkspaceInstall() got %+v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NPMWorkspaceInstall() got error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("NPMWorkspaceInstall() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestStrictSSLFlag(t *testing.T) {
+	testCases := []struct {
+		name     string
+		value    *string
+		want     []string
+		wantWarn bool
+		wantErr  bool
+	}{
+		{
+			name: "strict by default",
+		},
+		{
+			name:  "empty",
+			value: stringPtr(""),
+		},
+		{
+			name:  "strict",
+			value: stringPtr("true"),
+		},
+		{
+			name:     "disabled",
+			value:    stringPtr("false"),
+			want:     []string{"--strict-ssl=false"},
+			wantWarn: true,
+		},
+		{
+			name:     "disabled with 0",
+			value:    stringPtr("0"),
+			want:     []string{"--strict-ssl=false"},
+			wantWarn: true,
+		},
+		{
+			name:    "invalid",
+			value:   stringPtr("sometimes"),
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(EnvNPMStrictSSL, "")
+			if tc.value == nil {
+				os.Unsetenv(EnvNPMStrictSSL)
+			} else {
+				t.Setenv(EnvNPMStrictSSL, *tc.value)
+			}
+			var buf bytes.Buffer
+			ctx := gcpbuildpack.NewContext(gcpbuildpack.WithLogger(log.New(&buf, "", 0)))
+
+			got, err := StrictSSLFlag(ctx)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("StrictSSLFlag() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("StrictSSLFlag() mismatch (-want +got):\n%s", diff)
+			}
+			if gotWarn := strings.Contains(buf.String(), "WARNING") && strings.Contains(buf.String(), "insecure"); gotWarn != tc.wantWarn {
+				t.Errorf("StrictSSLFlag() logged %q, want insecure warning: %t", buf.String(), tc.wantWarn)
+			}
+		})
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
@@ -0,0 +1,59 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+const npmCacheLayer = "npm_cache"
+
+// npmCacheMaxBytes is the size that the npm cache layer is pruned to after each install.
+var npmCacheMaxBytes int64 = 1 << 30
+
+// NPMCache is the npm cache directory, which holds the downloaded package tarballs. It is kept
+// across builds in a cache layer, independently of the node_modules layer, so that an install
+// after a change to the lockfile only downloads the packages that changed.
+type NPMCache struct {
+	layer *libcnb.Layer
+}
+
+// NewNPMCache returns the npm cache in its cache layer.
+func NewNPMCache(ctx *gcp.Context) (*NPMCache, error) {
+	l, err := ctx.Layer(npmCacheLayer, gcp.CacheLayer)
+	if err != nil {
+		return nil, fmt.Errorf("creating %v layer: %w", npmCacheLayer, err)
+	}
+	return &NPMCache{layer: l}, nil
+}
+
+// Path returns the path of the npm cache directory.
+func (c *NPMCache) Path() string {
+	return c.layer.Path
+}
+
+// ExecOptions returns the options that make npm commands use the cache.
+func (c *NPMCache) ExecOptions() []gcp.ExecOption {
+	return []gcp.ExecOption{gcp.WithEnv("npm_config_cache=" + c.layer.Path)}
+}
+
+// Prune evicts the least recently used files of the cache once it exceeds its maximum size.
+func (c *NPMCache) Prune(ctx *gcp.Context) error {
+	return cache.PruneBySize(ctx, c.layer.Path, npmCacheMaxBytes)
+}
@@ -0,0 +1,91 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestNPMCacheEnv(t *testing.T) {
+	layersDir := t.TempDir()
+	ctx := gcp.NewContext(gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: layersDir}}))
+
+	c, err := NewNPMCache(ctx)
+	if err != nil {
+		t.Fatalf("NewNPMCache() got error: %v", err)
+	}
+
+	want := filepath.Join(layersDir, npmCacheLayer)
+	if c.Path() != want {
+		t.Errorf("NewNPMCache() path = %q, want %q", c.Path(), want)
+	}
+	result, cerr := ctx.ExecWithErr([]string{"sh", "-c", `echo "$npm_config_cache"`}, c.ExecOptions()...)
+	if cerr != nil {
+		t.Fatalf("running command got error: %v", cerr)
+	}
+	if got := strings.TrimSpace(result.Stdout); got != want {
+		t.Errorf("npm_config_cache = %q, want %q", got, want)
+	}
+}
+
+func TestNPMCachePrune(t *testing.T) {
+	defer func(max int64) { npmCacheMaxBytes = max }(npmCacheMaxBytes)
+	npmCacheMaxBytes = 250
+	ctx := gcp.NewContext(gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: t.TempDir()}}))
+	c, err := NewNPMCache(ctx)
+	if err != nil {
+		t.Fatalf("NewNPMCache() got error: %v", err)
+	}
+	// The tarballs are 100 bytes each, from the least to the most recently used.
+	files := []string{"_cacache/content-v2/sha512/aa/old", "_cacache/content-v2/sha512/bb/older", "_cacache/content-v2/sha512/cc/recent", "_cacache/content-v2/sha512/dd/newest"}
+	for i, f := range files {
+		path := filepath.Join(c.Path(), f)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("creating directory of %s: %v", f, err)
+		}
+		if err := os.WriteFile(path, make([]byte, 100), 0644); err != nil {
+			t.Fatalf("writing %s: %v", f, err)
+		}
+		used := time.Now().Add(time.Duration(i-len(files)) * time.Hour)
+		if err := os.Chtimes(path, used, used); err != nil {
+			t.Fatalf("setting the times of %s: %v", f, err)
+		}
+	}
+
+	if err := c.Prune(ctx); err != nil {
+		t.Fatalf("Prune() got error: %v", err)
+	}
+
+	var total int64
+	for i, f := range files {
+		info, err := os.Stat(filepath.Join(c.Path(), f))
+		if wantKept := i >= 2; (err == nil) != wantKept {
+			t.Errorf("Prune() kept %s: %t, want %t", f, err == nil, wantKept)
+		}
+		if err == nil {
+			total += info.Size()
+		}
+	}
+	if total > npmCacheMaxBytes {
+		t.Errorf("Prune() left %d bytes, want at most %d", total, npmCacheMaxBytes)
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// missingPackageMessages are the messages with which npm and Yarn fail when a package that is not in
+// the offline mirror would have to be fetched from the network.
+var missingPackageMessages = []string{
+	// npm
+	"ENOTCACHED",
+	"cache mode is 'only-if-cached'",
+	// Yarn 1
+	"Can't make a request in offline mode",
+	"Couldn't find any versions for",
+	// Yarn 2+
+	"has been blocked because of your configuration settings",
+	"enableNetwork",
+}
+
+// OfflineConfig configures a package manager to install dependencies from an offline mirror only.
+type OfflineConfig struct {
+	// Mirror is the absolute path of the offline mirror.
+	Mirror string
+	// Flags are added to the install command.
+	Flags []string
+	// Env is added to the environment of the install command.
+	Env []string
+}
+
+// ExecOptions returns the options to run the install command with, which fail with a clear message
+// when a package is missing from the mirror.
+func (c *OfflineConfig) ExecOptions() []gcp.ExecOption {
+	if c.Mirror == "" {
+		return nil
+	}
+	return []gcp.ExecOption{gcp.WithEnv(c.Env...), gcp.WithMessageProducer(c.missingPackageMessage)}
+}
+
+func (c *OfflineConfig) missingPackageMessage(result *gcp.ExecResult) string {
+	for _, line := range strings.Split(result.Combined, "\n") {
+		for _, m := range missingPackageMessages {
+			if strings.Contains(line, m) {
+				return "a required package is missing from the offline mirror " + c.Mirror + ": " + strings.TrimSpace(line)
+			}
+		}
+	}
+	return gcp.KeepCombinedTail(result)
+}
+
+// OfflineMirror returns the absolute path of the offline mirror configured with
+// GOOGLE_NODE_OFFLINE_MIRROR, or an empty string if it is not set. Relative paths are resolved
+// against the application root.
+func OfflineMirror(ctx *gcp.Context) string {
+	mirror := os.Getenv(EnvOfflineMirror)
+	if mirror == "" || filepath.IsAbs(mirror) {
+		return mirror
+	}
+	return filepath.Join(ctx.ApplicationRoot(), mirror)
+}
+
+// ConfiguredOfflineInstall returns the OfflineInstall configuration for the mirror configured with
+// GOOGLE_NODE_OFFLINE_MIRROR, or an empty configuration that installs from the network if it is not
+// set.
+func ConfiguredOfflineInstall(ctx *gcp.Context) (*OfflineConfig, error) {
+	mirror := OfflineMirror(ctx)
+	if mirror == "" {
+		return &OfflineConfig{}, nil
+	}
+	return OfflineInstall(ctx, mirror)
+}
+
+// OfflineInstall returns the configuration that makes the package manager of the application install
+// dependencies from the given mirror without network access: a pre-populated npm cache for npm, an
+// offline mirror of package tarballs for Yarn 1 and a cache folder for Yarn 2+.
+func OfflineInstall(ctx *gcp.Context, mirror string) (*OfflineConfig, error) {
+	info, err := os.Stat(mirror)
+	if err != nil || !info.IsDir() {
+		return nil, gcp.UserErrorf("%s=%q is not a directory", EnvOfflineMirror, mirror)
+	}
+	c := &OfflineConfig{Mirror: mirror}
+	yarnLockExists, err := ctx.FileExists(ctx.ApplicationRoot(), YarnLock)
+	if err != nil {
+		return nil, err
+	}
+	if !yarnLockExists {
+		c.Flags = []string{"--offline", "--cache=" + mirror}
+	} else if yarn2, err := IsYarn2(ctx.ApplicationRoot()); err != nil {
+		return nil, err
+	} else if yarn2 {
+		c.Env = []string{"YARN_ENABLE_NETWORK=0", "YARN_ENABLE_GLOBAL_CACHE=0", "YARN_CACHE_FOLDER=" + mirror}
+	} else {
+		c.Flags = []string{"--offline"}
+		c.Env = []string{"YARN_YARN_OFFLINE_MIRROR=" + mirror}
+	}
+	ctx.Logf("Installing dependencies from the offline mirror %s.", mirror)
+	return c, nil
+}
@@ -0,0 +1,156 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestOfflineInstall(t *testing.T) {
+	testCases := []struct {
+		name      string
+		files     map[string]string
+		wantFlags []string
+		wantEnv   []string
+	}{
+		{
+			name:      "npm",
+			files:     map[string]string{"package.json": "{}", "package-lock.json": "{}"},
+			wantFlags: []string{"--offline", "--cache=$MIRROR"},
+		},
+		{
+			name:      "yarn 1",
+			files:     map[string]string{"package.json": "{}", "yarn.lock": "# yarn lockfile v1\n"},
+			wantFlags: []string{"--offline"},
+			wantEnv:   []string{"YARN_YARN_OFFLINE_MIRROR=$MIRROR"},
+		},
+		{
+			name:    "yarn 2",
+			files:   map[string]string{"package.json": "{}", "yarn.lock": "__metadata:\n  version: 4\n"},
+			wantEnv: []string{"YARN_ENABLE_NETWORK=0", "YARN_ENABLE_GLOBAL_CACHE=0", "YARN_CACHE_FOLDER=$MIRROR"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tc.files)
+			mirror := filepath.Join(dir, "mirror")
+			writeFiles(t, mirror, map[string]string{"left-pad-1.3.0.tgz": ""})
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+
+			got, err := OfflineInstall(ctx, mirror)
+			if err != nil {
+				t.Fatalf("OfflineInstall() got error: %v", err)
+			}
+			replace := func(s []string) []string {
+				var r []string
+				for _, v := range s {
+					r = append(r, strings.ReplaceAll(v, "$MIRROR", mirror))
+				}
+				return r
+			}
+			if diff := cmp.Diff(replace(tc.wantFlags), got.Flags); diff != "" {
+				t.Errorf("OfflineInstall() flags mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(replace(tc.wantEnv), got.Env); diff != "" {
+				t.Errorf("OfflineInstall() env mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestOfflineInstallMissingMirror(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"package.json": "{}"})
+	ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+
+	if _, err := OfflineInstall(ctx, filepath.Join(dir, "missing")); err == nil {
+		t.Error("OfflineInstall() with a missing mirror got nil error, want error")
+	}
+}
+
+func TestConfiguredOfflineInstall(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"package.json": "{}", "mirror/.keep": ""})
+	ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+
+	t.Setenv(EnvOfflineMirror, "")
+	got, err := ConfiguredOfflineInstall(ctx)
+	if err != nil {
+		t.Fatalf("ConfiguredOfflineInstall() got error: %v", err)
+	}
+	if len(got.Flags) != 0 || len(got.ExecOptions()) != 0 {
+		t.Errorf("ConfiguredOfflineInstall() without a mirror got flags %v and %d exec options, want none", got.Flags, len(got.ExecOptions()))
+	}
+
+	t.Setenv(EnvOfflineMirror, "mirror")
+	got, err = ConfiguredOfflineInstall(ctx)
+	if err != nil {
+		t.Fatalf("ConfiguredOfflineInstall() got error: %v", err)
+	}
+	if want := filepath.Join(dir, "mirror"); got.Mirror != want {
+		t.Errorf("ConfiguredOfflineInstall() mirror=%q, want %q", got.Mirror, want)
+	}
+}
+
+func TestOfflineMissingPackageMessage(t *testing.T) {
+	c := &OfflineConfig{Mirror: "/mirror"}
+	testCases := []struct {
+		name     string
+		combined string
+		want     string
+	}{
+		{
+			name:     "npm",
+			combined: "npm ERR! code ENOTCACHED\nnpm ERR! request to https://registry.npmjs.org/left-pad failed: cache mode is 'only-if-cached' but no cached response is available.",
+			want:     "a required package is missing from the offline mirror /mirror: npm ERR! code ENOTCACHED",
+		},
+		{
+			name:     "yarn 1",
+			combined: "yarn install v1.22.19\nerror Couldn't find any versions for \"left-pad\" that matches \"^1.3.0\" in our cache",
+			want:     "a required package is missing from the offline mirror /mirror: error Couldn't find any versions for \"left-pad\" that matches \"^1.3.0\" in our cache",
+		},
+		{
+			name:     "other failure",
+			combined: "error An unexpected error occurred",
+			want:     "error An unexpected error occurred",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := c.missingPackageMessage(&gcp.ExecResult{Combined: tc.combined}); !strings.Contains(got, tc.want) {
+				t.Errorf("missingPackageMessage() got %q, want it to contain %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestOfflineInstallFailsOnMissingPackage(t *testing.T) {
+	c := &OfflineConfig{Mirror: "/mirror"}
+	ctx := gcp.NewContext()
+
+	_, err := ctx.ExecWithErr([]string{"bash", "-c", "echo 'npm ERR! code ENOTCACHED' >&2; exit 1"}, append(c.ExecOptions(), gcp.WithUserAttribution)...)
+	if err == nil {
+		t.Fatal("ExecWithErr() got nil error, want error")
+	}
+	if want := "missing from the offline mirror /mirror"; !strings.Contains(err.Error(), want) {
+		t.Errorf("ExecWithErr() got error %q, want it to contain %q", err, want)
+	}
+}
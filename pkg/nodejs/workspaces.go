@@ -0,0 +1,172 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// packageWorkspacesJSON holds the glob patterns of the "workspaces" field of package.json. The
+// field is either an array of patterns or an object with a "packages" array of patterns.
+type packageWorkspacesJSON []string
+
+// UnmarshalJSON implements json.Unmarshaler for both forms of the "workspaces" field.
+func (w *packageWorkspacesJSON) UnmarshalJSON(data []byte) error {
+	var patterns []string
+	if err := json.Unmarshal(data, &patterns); err == nil {
+		*w = patterns
+		return nil
+	}
+	var obj struct {
+		Packages []string `json:"packages"`
+	}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return fmt.Errorf("workspaces must be an array of patterns or an object with a packages array: %v", err)
+	}
+	*w = obj.Packages
+	return nil
+}
+
+// Workspace is a package of a workspaces monorepo.
+type Workspace struct {
+	// Name is the name of the package from its package.json.
+	Name string
+	// Dir is the directory of the package relative to the workspace root.
+	Dir string
+}
+
+// Workspaces returns the packages matched by the "workspaces" patterns of the package.json in the
+// given dir, sorted by directory. It returns nil if the package.json does not declare workspaces.
+func Workspaces(dir string) ([]Workspace, error) {
+	pjs, err := ReadPackageJSONIfExists(dir)
+	if err != nil || pjs == nil || len(pjs.Workspaces) == 0 {
+		return nil, err
+	}
+
+	var workspaces []Workspace
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if d.Name() == "node_modules" || strings.HasPrefix(d.Name(), ".") && p != dir {
+			return filepath.SkipDir
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil || rel == "." || !matchWorkspace(pjs.Workspaces, filepath.ToSlash(rel)) {
+			return err
+		}
+		wpjs, err := ReadPackageJSONIfExists(p)
+		if err != nil || wpjs == nil {
+			return err
+		}
+		workspaces = append(workspaces, Workspace{Name: wpjs.Name, Dir: rel})
+		return nil
+	})
+	if err != nil {
+		return nil, gcp.InternalErrorf("finding workspaces in %q: %v", dir, err)
+	}
+	sort.Slice(workspaces, func(i, j int) bool { return workspaces[i].Dir < workspaces[j].Dir })
+	return workspaces, nil
+}
+
+// WorkspacePackageJSONs returns the paths of the package.json files of all workspaces of the
+// monorepo rooted at dir, relative to dir.
+func WorkspacePackageJSONs(dir string) ([]string, error) {
+	workspaces, err := Workspaces(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, w := range workspaces {
+		files = append(files, filepath.Join(w.Dir, "package.json"))
+	}
+	return files, nil
+}
+
+// FindWorkspace returns the workspace of the monorepo rooted at dir that matches the given package
+// name or directory. It is used to select the workspace configured with GOOGLE_NODEJS_WORKSPACE.
+func FindWorkspace(dir, nameOrDir string) (*Workspace, error) {
+	workspaces, err := Workspaces(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(workspaces) == 0 {
+		return nil, gcp.UserErrorf("%s is set to %q but package.json does not declare any workspaces", EnvWorkspace, nameOrDir)
+	}
+	var available []string
+	for _, w := range workspaces {
+		if w.Name == nameOrDir || w.Dir == filepath.Clean(nameOrDir) {
+			return &w, nil
+		}
+		available = append(available, w.Name)
+	}
+	return nil, gcp.UserErrorf("%s is set to %q which does not match any workspace, available workspaces: %s", EnvWorkspace, nameOrDir, strings.Join(available, ", "))
+}
+
+// matchWorkspace reports whether the slash-separated relative dir matches the workspace patterns.
+// Patterns prefixed with "!" exclude directories matched by earlier patterns.
+func matchWorkspace(patterns []string, dir string) bool {
+	matched := false
+	for _, p := range patterns {
+		if strings.HasPrefix(p, "!") {
+			if matchGlob(strings.TrimPrefix(p, "!"), dir) {
+				matched = false
+			}
+			continue
+		}
+		if matchGlob(p, dir) {
+			matched = true
+		}
+	}
+	return matched
+}
+
+// matchGlob matches a slash-separated path against a glob pattern in which "**" matches any number
+// of path segments.
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(path.Clean(strings.TrimPrefix(pattern, "./")), "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(name); i++ {
+			if matchSegments(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(name) == 0 {
+		return false
+	}
+	if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], name[1:])
+}
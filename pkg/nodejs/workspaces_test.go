@@ -0,0 +1,178 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReadPackageJSONWorkspaces(t *testing.T) {
+	testCases := []struct {
+		name        string
+		packageJSON string
+		want        []string
+		wantErr     bool
+	}{
+		{
+			name:        "no workspaces",
+			packageJSON: `{"name": "app"}`,
+		},
+		{
+			name:        "array of patterns",
+			packageJSON: `{"workspaces": ["packages/*", "apps/**", "!packages/legacy"]}`,
+			want:        []string{"packages/*", "apps/**", "!packages/legacy"},
+		},
+		{
+			name:        "object with packages",
+			packageJSON: `{"workspaces": {"packages": ["packages/*"], "nohoist": ["**/react-native"]}}`,
+			want:        []string{"packages/*"},
+		},
+		{
+			name:        "invalid workspaces",
+			packageJSON: `{"workspaces": "packages/*"}`,
+			wantErr:     true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{"package.json": tc.packageJSON})
+
+			got, err := ReadPackageJSONIfExists(dir)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("ReadPackageJSONIfExists() got err=%t, want err=%t. err: %v", gotErr, tc.wantErr, err)
+			}
+			if tc.wantErr {
+				return
+			}
+			if diff := cmp.Diff(tc.want, []string(got.Workspaces)); diff != "" {
+				t.Errorf("ReadPackageJSONIfExists() workspaces mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMatchWorkspace(t *testing.T) {
+	testCases := []struct {
+		patterns []string
+		dir      string
+		want     bool
+	}{
+		{patterns: []string{"packages/*"}, dir: "packages/api", want: true},
+		{patterns: []string{"./packages/*"}, dir: "packages/api", want: true},
+		{patterns: []string{"packages/*"}, dir: "packages/api/src", want: false},
+		{patterns: []string{"packages/*"}, dir: "apps/web", want: false},
+		{patterns: []string{"apps/**"}, dir: "apps/web/frontend", want: true},
+		{patterns: []string{"**/server"}, dir: "apps/server", want: true},
+		{patterns: []string{"api"}, dir: "api", want: true},
+		{patterns: []string{"packages/*", "!packages/legacy"}, dir: "packages/legacy", want: false},
+		{patterns: []string{"packages/*", "!packages/legacy"}, dir: "packages/api", want: true},
+	}
+	for _, tc := range testCases {
+		if got := matchWorkspace(tc.patterns, tc.dir); got != tc.want {
+			t.Errorf("matchWorkspace(%q, %q)=%t, want %t", tc.patterns, tc.dir, got, tc.want)
+		}
+	}
+}
+
+func TestWorkspaces(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"package.json":                           `{"name": "root", "workspaces": ["packages/*", "!packages/legacy"]}`,
+		"packages/api/package.json":              `{"name": "@acme/api"}`,
+		"packages/web/package.json":              `{"name": "@acme/web"}`,
+		"packages/legacy/package.json":           `{"name": "@acme/legacy"}`,
+		"packages/docs/README.md":                "",
+		"packages/api/node_modules/package.json": `{"name": "dep"}`,
+	})
+
+	got, err := Workspaces(dir)
+	if err != nil {
+		t.Fatalf("Workspaces() got error: %v", err)
+	}
+	want := []Workspace{
+		{Name: "@acme/api", Dir: "packages/api"},
+		{Name: "@acme/web", Dir: "packages/web"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Workspaces() mismatch (-want +got):\n%s", diff)
+	}
+
+	files, err := WorkspacePackageJSONs(dir)
+	if err != nil {
+		t.Fatalf("WorkspacePackageJSONs() got error: %v", err)
+	}
+	wantFiles := []string{"packages/api/package.json", "packages/web/package.json"}
+	if diff := cmp.Diff(wantFiles, files); diff != "" {
+		t.Errorf("WorkspacePackageJSONs() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestFindWorkspace(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"package.json":              `{"workspaces": ["packages/*"]}`,
+		"packages/api/package.json": `{"name": "@acme/api"}`,
+		"packages/web/package.json": `{"name": "@acme/web"}`,
+	})
+
+	testCases := []struct {
+		nameOrDir string
+		want      string
+		wantErr   bool
+	}{
+		{nameOrDir: "@acme/web", want: "packages/web"},
+		{nameOrDir: "packages/api", want: "packages/api"},
+		{nameOrDir: "packages/api/", want: "packages/api"},
+		{nameOrDir: "@acme/missing", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.nameOrDir, func(t *testing.T) {
+			got, err := FindWorkspace(dir, tc.nameOrDir)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("FindWorkspace(%q) got err=%t, want err=%t. err: %v", tc.nameOrDir, gotErr, tc.wantErr, err)
+			}
+			if !tc.wantErr && got.Dir != tc.want {
+				t.Errorf("FindWorkspace(%q).Dir=%q, want %q", tc.nameOrDir, got.Dir, tc.want)
+			}
+		})
+	}
+}
+
+func TestFindWorkspaceWithoutWorkspaces(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"package.json": `{"name": "app"}`})
+
+	if _, err := FindWorkspace(dir, "app"); err == nil {
+		t.Error("FindWorkspace() got nil error, want error")
+	}
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("creating dir for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("writing %s: %v", name, err)
+		}
+	}
+}
@@ -21,6 +21,9 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/appengine"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
@@ -42,6 +45,43 @@ const (
 
 	composerVersionKey = "php"
 
+	// symfonyFrameworkBundle is the Composer package that identifies a Symfony application.
+	symfonyFrameworkBundle = "symfony/framework-bundle"
+	// SymfonyConsole is the path to the Symfony console script.
+	SymfonyConsole = "bin/console"
+	// SymfonyDocroot is the directory containing the Symfony front controller, public/index.php.
+	SymfonyDocroot = "public"
+	// SymfonyCacheWarmupEnv is an env var used to control whether the Symfony cache is cleared
+	// and warmed up during the build. The cache warmup is enabled by default.
+	// Example: `false`, `False`, `0` will skip the cache warmup.
+	SymfonyCacheWarmupEnv = "GOOGLE_PHP_SYMFONY_CACHE_WARMUP"
+	// ComposerOptimizeEnv is an env var used to control whether Composer optimizes the autoloader
+	// by generating a classmap. The optimization is enabled by default, except in dev mode.
+	// Example: `false`, `False`, `0` will skip the optimization.
+	ComposerOptimizeEnv = "GOOGLE_COMPOSER_OPTIMIZE"
+	// ComposerAuthoritativeEnv is an env var used to control whether the optimized classmap is
+	// authoritative, i.e. classes that are not in the classmap are not loaded. It defaults to the
+	// value of GOOGLE_COMPOSER_OPTIMIZE.
+	// Example: `false`, `False`, `0` will allow classes that are not in the classmap to be loaded.
+	ComposerAuthoritativeEnv = "GOOGLE_COMPOSER_AUTHORITATIVE"
+	// ComposerPlatformCheckEnv is an env var used to control whether the runtime platform check that
+	// Composer generates in vendor/composer/platform_check.php is kept. The check is kept by default.
+	// Example: `false`, `False`, `0` will disable the check, e.g. when the application is built and
+	// run with intentionally different PHP versions or extensions.
+	ComposerPlatformCheckEnv = "GOOGLE_COMPOSER_PLATFORM_CHECK"
+	// DocumentRootEnv is the env var used by the web server to locate the document root.
+	DocumentRootEnv = "DOCUMENT_ROOT"
+	// MemoryLimitEnv is an env var used to set the PHP memory_limit, in bytes with an optional K, M
+	// or G suffix. There is no limit by default.
+	// Example: `256M`, `1G`, `-1` for no limit.
+	MemoryLimitEnv = "GOOGLE_PHP_MEMORY_LIMIT"
+	// OpcacheEnv is an env var used to control whether the opcache is enabled. It is enabled by
+	// default. Outside of dev mode, the opcache does not check whether scripts changed.
+	// Example: `false`, `False`, `0` will disable the opcache.
+	OpcacheEnv = "GOOGLE_PHP_OPCACHE"
+	// defaultMemoryLimit is the memory_limit used if GOOGLE_PHP_MEMORY_LIMIT is not set.
+	defaultMemoryLimit = "-1"
+
 	// PHPIni is the content of the php.ini config file
 	PHPIni = `
 ; Copyright 2022 Google Inc.
@@ -59,7 +99,6 @@ const (
 ; limitations under the License.
 
 expose_php = Off
-memory_limit = -1
 max_execution_time = 0
 
 ;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;
@@ -115,6 +154,37 @@ func ReadComposerJSON(dir string) (*ComposerJSON, error) {
 	return &cjs, nil
 }
 
+// IsSymfony returns true if the composer.json in the given dir requires the Symfony framework bundle.
+func IsSymfony(dir string) (bool, error) {
+	if _, err := os.Stat(filepath.Join(dir, composerJSON)); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, gcp.InternalErrorf("stat %s: %v", composerJSON, err)
+	}
+	cjs, err := ReadComposerJSON(dir)
+	if err != nil {
+		return false, err
+	}
+	_, ok := cjs.Require[symfonyFrameworkBundle]
+	return ok, nil
+}
+
+// SetSymfonyDocroot sets the document root of the Symfony application in appDir at launch to the
+// directory of its front controller, unless it is already set.
+func SetSymfonyDocroot(l *libcnb.Layer, appDir string) {
+	l.LaunchEnvironment.Default(DocumentRootEnv, filepath.Join(appDir, SymfonyDocroot))
+}
+
+// SymfonyCacheWarmup clears and warms up the Symfony cache for the prod environment so that the
+// application does not compile its container and routes on the first request.
+func SymfonyCacheWarmup(ctx *gcp.Context) {
+	ctx.Logf("Warming up the Symfony cache.")
+	opts := []gcp.ExecOption{gcp.WithEnv("APP_ENV=prod", "APP_DEBUG=0"), gcp.WithUserAttribution}
+	ctx.Exec([]string{"php", SymfonyConsole, "cache:clear", "--no-warmup", "--no-interaction"}, opts...)
+	ctx.Exec([]string{"php", SymfonyConsole, "cache:warmup", "--no-interaction"}, opts...)
+}
+
 // version returns the installed version of PHP.
 func version(ctx *gcp.Context) string {
 	result := ctx.Exec([]string{"php", "-r", "echo PHP_VERSION;"})
@@ -157,6 +227,121 @@ func composerInstall(ctx *gcp.Context, flags []string) {
 	ctx.Exec(cmd, gcp.WithUserAttribution)
 }
 
+// AutoloaderFlags returns the `composer install` flags that optimize the autoloader, based on the
+// GOOGLE_COMPOSER_OPTIMIZE and GOOGLE_COMPOSER_AUTHORITATIVE env vars read with lookupEnv, e.g.
+// os.LookupEnv. The autoloader is optimized with an authoritative classmap by default, except in
+// dev mode.
+func AutoloaderFlags(lookupEnv func(string) (string, bool)) ([]string, error) {
+	devMode, err := boolEnv(lookupEnv, env.DevMode, false)
+	if err != nil {
+		return nil, err
+	}
+	optimize, err := boolEnv(lookupEnv, ComposerOptimizeEnv, !devMode)
+	if err != nil {
+		return nil, err
+	}
+	authoritative, err := boolEnv(lookupEnv, ComposerAuthoritativeEnv, optimize)
+	if err != nil {
+		return nil, err
+	}
+	var flags []string
+	if optimize {
+		flags = append(flags, "--optimize-autoloader")
+	}
+	if authoritative {
+		// --classmap-authoritative implies --optimize-autoloader.
+		flags = append(flags, "--classmap-authoritative")
+	}
+	return flags, nil
+}
+
+// PlatformCheckFlag returns whether the Composer platform check is kept, based on the
+// GOOGLE_COMPOSER_PLATFORM_CHECK env var read with lookupEnv, e.g. os.LookupEnv. It defaults to true.
+func PlatformCheckFlag(lookupEnv func(string) (string, bool)) (bool, error) {
+	return boolEnv(lookupEnv, ComposerPlatformCheckEnv, true)
+}
+
+// DisablePlatformCheck configures Composer not to generate the platform check in the vendor
+// directory, which would otherwise fail at runtime if the PHP version or extensions differ from the
+// build. It sets platform-check in the global Composer configuration, leaving composer.json as is.
+func DisablePlatformCheck(ctx *gcp.Context) error {
+	if _, err := ctx.ExecWithErr([]string{"composer", "config", "--global", "platform-check", "false"}, gcp.WithUserAttribution); err != nil {
+		return gcp.InternalErrorf("disabling the Composer platform check: %v", err)
+	}
+	return nil
+}
+
+// memoryLimitRegexp matches a valid memory_limit: a number of bytes with an optional K, M or G
+// suffix, or -1 for no limit.
+var memoryLimitRegexp = regexp.MustCompile(`^(-1|[0-9]+[KMGkmg]?)$`)
+
+// IniConfig is the production configuration of PHP that is written into a php.ini fragment.
+type IniConfig struct {
+	// MemoryLimit is the value of memory_limit.
+	MemoryLimit string
+	// Opcache enables the opcache.
+	Opcache bool
+	// ValidateTimestamps makes the opcache check whether scripts changed, e.g. in dev mode.
+	ValidateTimestamps bool
+}
+
+// IniConfigFromEnv returns the php.ini configuration based on the GOOGLE_PHP_MEMORY_LIMIT and
+// GOOGLE_PHP_OPCACHE env vars read with lookupEnv, e.g. os.LookupEnv. By default there is no memory
+// limit and the opcache is enabled, and only checks whether scripts changed in dev mode.
+func IniConfigFromEnv(lookupEnv func(string) (string, bool)) (IniConfig, error) {
+	devMode, err := boolEnv(lookupEnv, env.DevMode, false)
+	if err != nil {
+		return IniConfig{}, err
+	}
+	opcache, err := boolEnv(lookupEnv, OpcacheEnv, true)
+	if err != nil {
+		return IniConfig{}, err
+	}
+	limit := defaultMemoryLimit
+	if v, ok := lookupEnv(MemoryLimitEnv); ok && v != "" {
+		if !memoryLimitRegexp.MatchString(v) {
+			return IniConfig{}, gcp.UserErrorf("invalid %s=%q: must be a number of bytes with an optional K, M or G suffix, or -1", MemoryLimitEnv, v)
+		}
+		limit = v
+	}
+	return IniConfig{MemoryLimit: limit, Opcache: opcache, ValidateTimestamps: devMode}, nil
+}
+
+// GenerateIniFragment returns the content of a php.ini fragment that applies cfg.
+func GenerateIniFragment(cfg IniConfig) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "memory_limit = %s\n", cfg.MemoryLimit)
+	if !cfg.Opcache {
+		sb.WriteString("opcache.enable = 0\n")
+		return sb.String()
+	}
+	sb.WriteString("zend_extension = opcache.so\n")
+	sb.WriteString("opcache.enable = 1\n")
+	sb.WriteString("opcache.memory_consumption = 128\n")
+	sb.WriteString("opcache.interned_strings_buffer = 16\n")
+	sb.WriteString("opcache.max_accelerated_files = 10000\n")
+	if cfg.ValidateTimestamps {
+		sb.WriteString("opcache.validate_timestamps = 1\n")
+		sb.WriteString("opcache.revalidate_freq = 0\n")
+	} else {
+		sb.WriteString("opcache.validate_timestamps = 0\n")
+	}
+	return sb.String()
+}
+
+// boolEnv returns the boolean value of the env var read with lookupEnv, or def if it is not set.
+func boolEnv(lookupEnv func(string) (string, bool), name string, def bool) (bool, error) {
+	v, ok := lookupEnv(name)
+	if !ok || v == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, gcp.UserErrorf("parsing %s=%q: %v", name, v, err)
+	}
+	return b, nil
+}
+
 // ComposerInstall runs `composer install`, using the cache iff a lock file is present.
 // It creates a layer, so it returns the layer so that the caller may further modify it
 // if they desire.
@@ -166,7 +351,30 @@ func ComposerInstall(ctx *gcp.Context, cacheTag string) (*libcnb.Layer, error) {
 	//   https://github.com/GoogleCloudPlatform/php-docs-samples/issues/736
 	//   https://github.com/GoogleCloudPlatform/runtimes-common/pull/763
 	//   https://github.com/GoogleCloudPlatform/runtimes-common/commit/6c4970f609d80f9436ac58ae272cfcc6bcd57143
-	flags := []string{"--no-dev", "--no-progress", "--no-interaction", "--optimize-autoloader"}
+	flags := []string{"--no-dev", "--no-progress", "--no-interaction"}
+	autoloaderFlags, err := AutoloaderFlags(os.LookupEnv)
+	if err != nil {
+		return nil, err
+	}
+	flags = append(flags, autoloaderFlags...)
+	platformCheck, err := PlatformCheckFlag(os.LookupEnv)
+	if err != nil {
+		return nil, err
+	}
+	cacheStrings := autoloaderFlags
+	if !platformCheck {
+		ctx.Logf("Disabling the Composer platform check because %s is false.", ComposerPlatformCheckEnv)
+		cacheStrings = append(append([]string{}, autoloaderFlags...), "platform-check=false")
+	}
+	install := func() error {
+		if !platformCheck {
+			if err := DisablePlatformCheck(ctx); err != nil {
+				return err
+			}
+		}
+		composerInstall(ctx, flags)
+		return nil
+	}
 
 	if err := ctx.RemoveAll(Vendor); err != nil {
 		return nil, err
@@ -186,11 +394,13 @@ func ComposerInstall(ctx *gcp.Context, cacheTag string) (*libcnb.Layer, error) {
 	// to newer versions in the future.
 	if !composerLockExists {
 		ctx.Logf("*** Improve build performance by generating and committing %s.", composerLock)
-		composerInstall(ctx, flags)
+		if err := install(); err != nil {
+			return nil, err
+		}
 		return l, nil
 	}
 
-	cached, err := checkCache(ctx, l, cache.WithFiles(composerJSON, composerLock))
+	cached, err := checkCache(ctx, l, cache.WithFiles(composerJSON, composerLock), cache.WithStrings(cacheStrings...))
 	if err != nil {
 		return l, fmt.Errorf("checking cache: %w", err)
 	}
@@ -205,7 +415,9 @@ func ComposerInstall(ctx *gcp.Context, cacheTag string) (*libcnb.Layer, error) {
 		if err := ctx.ClearLayer(l); err != nil {
 			return nil, fmt.Errorf("clearing layer %q: %w", l.Name, err)
 		}
-		composerInstall(ctx, flags)
+		if err := install(); err != nil {
+			return nil, err
+		}
 
 		// Ensure vendor exists even if no dependencies were installed.
 		if err := ctx.MkdirAll(Vendor, 0755); err != nil {
@@ -17,6 +17,7 @@ package php
 import (
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"reflect"
 	"strings"
@@ -24,6 +25,7 @@ import (
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
 )
 
 func TestReadComposerJSON(t *testing.T) {
@@ -187,3 +189,342 @@ func TestExtractVersion(t *testing.T) {
 	}
 
 }
+
+func TestSetSymfonyDocroot(t *testing.T) {
+	l := &libcnb.Layer{LaunchEnvironment: libcnb.Environment{}}
+
+	SetSymfonyDocroot(l, "/workspace")
+
+	if got, want := l.LaunchEnvironment["DOCUMENT_ROOT.default"], "/workspace/public"; got != want {
+		t.Errorf("SetSymfonyDocroot() set DOCUMENT_ROOT=%q, want %q", got, want)
+	}
+}
+
+func TestIsSymfony(t *testing.T) {
+	testCases := []struct {
+		name         string
+		composerJSON string
+		want         bool
+		wantErr      bool
+	}{
+		{
+			name: "no composer.json",
+		},
+		{
+			name: "symfony framework bundle",
+			composerJSON: `{
+  "require": {
+    "php": ">=8.0",
+    "symfony/framework-bundle": "6.1.*"
+  }
+}`,
+			want: true,
+		},
+		{
+			name: "other symfony components",
+			composerJSON: `{
+  "require": {
+    "symfony/console": "6.1.*"
+  }
+}`,
+		},
+		{
+			name:         "invalid composer.json",
+			composerJSON: `{`,
+			wantErr:      true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tc.composerJSON != "" {
+				if err := ioutil.WriteFile(filepath.Join(dir, composerJSON), []byte(tc.composerJSON), 0644); err != nil {
+					t.Fatalf("Failed to write composer.json: %v", err)
+				}
+			}
+
+			got, err := IsSymfony(dir)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("IsSymfony() got err=%t, want err=%t. err: %v", gotErr, tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Errorf("IsSymfony()=%t, want=%t", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAutoloaderFlags(t *testing.T) {
+	testCases := []struct {
+		name    string
+		env     map[string]string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "default",
+			want: []string{"--optimize-autoloader", "--classmap-authoritative"},
+		},
+		{
+			name: "dev mode",
+			env:  map[string]string{env.DevMode: "true"},
+		},
+		{
+			name: "optimize in dev mode",
+			env:  map[string]string{env.DevMode: "true", ComposerOptimizeEnv: "1"},
+			want: []string{"--optimize-autoloader", "--classmap-authoritative"},
+		},
+		{
+			name: "optimize disabled",
+			env:  map[string]string{ComposerOptimizeEnv: "0"},
+		},
+		{
+			name: "authoritative disabled",
+			env:  map[string]string{ComposerAuthoritativeEnv: "false"},
+			want: []string{"--optimize-autoloader"},
+		},
+		{
+			name: "optimize disabled, authoritative enabled",
+			env:  map[string]string{ComposerOptimizeEnv: "false", ComposerAuthoritativeEnv: "true"},
+			want: []string{"--classmap-authoritative"},
+		},
+		{
+			name: "both enabled",
+			env:  map[string]string{ComposerOptimizeEnv: "TRUE", ComposerAuthoritativeEnv: "True"},
+			want: []string{"--optimize-autoloader", "--classmap-authoritative"},
+		},
+		{
+			name:    "invalid optimize",
+			env:     map[string]string{ComposerOptimizeEnv: "sometimes"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid authoritative",
+			env:     map[string]string{ComposerAuthoritativeEnv: "sometimes"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			lookupEnv := func(name string) (string, bool) {
+				v, ok := tc.env[name]
+				return v, ok
+			}
+
+			got, err := AutoloaderFlags(lookupEnv)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("AutoloaderFlags() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("AutoloaderFlags() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPlatformCheckFlag(t *testing.T) {
+	testCases := []struct {
+		name    string
+		env     map[string]string
+		want    bool
+		wantErr bool
+	}{
+		{
+			name: "default keeps the check",
+			want: true,
+		},
+		{
+			name: "empty keeps the check",
+			env:  map[string]string{ComposerPlatformCheckEnv: ""},
+			want: true,
+		},
+		{
+			name: "disabled",
+			env:  map[string]string{ComposerPlatformCheckEnv: "false"},
+		},
+		{
+			name: "enabled",
+			env:  map[string]string{ComposerPlatformCheckEnv: "1"},
+			want: true,
+		},
+		{
+			name:    "invalid",
+			env:     map[string]string{ComposerPlatformCheckEnv: "never"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			lookupEnv := func(name string) (string, bool) {
+				v, ok := tc.env[name]
+				return v, ok
+			}
+
+			got, err := PlatformCheckFlag(lookupEnv)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("PlatformCheckFlag() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("PlatformCheckFlag() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDisablePlatformCheck(t *testing.T) {
+	var got []string
+	execCmd := func(name string, args ...string) *exec.Cmd {
+		got = append(got, strings.Join(append([]string{filepath.Base(name)}, args...), " "))
+		return exec.Command("true")
+	}
+	ctx := gcp.NewContext(gcp.WithApplicationRoot(t.TempDir()), gcp.WithExecCmd(execCmd))
+
+	if err := DisablePlatformCheck(ctx); err != nil {
+		t.Fatalf("DisablePlatformCheck() got error: %v", err)
+	}
+
+	want := []string{"composer config --global platform-check false"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DisablePlatformCheck() ran %q, want %q", got, want)
+	}
+}
+
+func TestIniConfigFromEnv(t *testing.T) {
+	testCases := []struct {
+		name    string
+		env     map[string]string
+		want    IniConfig
+		wantErr bool
+	}{
+		{
+			name: "default",
+			want: IniConfig{MemoryLimit: "-1", Opcache: true},
+		},
+		{
+			name: "dev mode",
+			env:  map[string]string{env.DevMode: "true"},
+			want: IniConfig{MemoryLimit: "-1", Opcache: true, ValidateTimestamps: true},
+		},
+		{
+			name: "memory limit",
+			env:  map[string]string{MemoryLimitEnv: "256M"},
+			want: IniConfig{MemoryLimit: "256M", Opcache: true},
+		},
+		{
+			name: "memory limit in bytes",
+			env:  map[string]string{MemoryLimitEnv: "134217728"},
+			want: IniConfig{MemoryLimit: "134217728", Opcache: true},
+		},
+		{
+			name: "opcache enabled",
+			env:  map[string]string{OpcacheEnv: "1"},
+			want: IniConfig{MemoryLimit: "-1", Opcache: true},
+		},
+		{
+			name: "opcache disabled",
+			env:  map[string]string{OpcacheEnv: "0"},
+			want: IniConfig{MemoryLimit: "-1"},
+		},
+		{
+			name:    "invalid memory limit",
+			env:     map[string]string{MemoryLimitEnv: "256MB"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid opcache",
+			env:     map[string]string{OpcacheEnv: "sometimes"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			lookupEnv := func(name string) (string, bool) {
+				v, ok := tc.env[name]
+				return v, ok
+			}
+
+			got, err := IniConfigFromEnv(lookupEnv)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("IniConfigFromEnv() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("IniConfigFromEnv() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestGenerateIniFragment(t *testing.T) {
+	testCases := []struct {
+		name        string
+		cfg         IniConfig
+		wantLines   []string
+		unwantLines []string
+	}{
+		{
+			name: "production",
+			cfg:  IniConfig{MemoryLimit: "-1", Opcache: true},
+			wantLines: []string{
+				"memory_limit = -1",
+				"zend_extension = opcache.so",
+				"opcache.enable = 1",
+				"opcache.validate_timestamps = 0",
+			},
+			unwantLines: []string{"opcache.enable = 0"},
+		},
+		{
+			name: "memory limit",
+			cfg:  IniConfig{MemoryLimit: "512M", Opcache: true},
+			wantLines: []string{
+				"memory_limit = 512M",
+				"opcache.enable = 1",
+			},
+			unwantLines: []string{"memory_limit = -1"},
+		},
+		{
+			name: "validate timestamps",
+			cfg:  IniConfig{MemoryLimit: "-1", Opcache: true, ValidateTimestamps: true},
+			wantLines: []string{
+				"opcache.enable = 1",
+				"opcache.validate_timestamps = 1",
+				"opcache.revalidate_freq = 0",
+			},
+			unwantLines: []string{"opcache.validate_timestamps = 0"},
+		},
+		{
+			name: "opcache disabled",
+			cfg:  IniConfig{MemoryLimit: "128M"},
+			wantLines: []string{
+				"memory_limit = 128M",
+				"opcache.enable = 0",
+			},
+			unwantLines: []string{"zend_extension = opcache.so", "opcache.enable = 1"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := GenerateIniFragment(tc.cfg)
+
+			lines := strings.Split(got, "\n")
+			contains := func(want string) bool {
+				for _, l := range lines {
+					if l == want {
+						return true
+					}
+				}
+				return false
+			}
+			for _, want := range tc.wantLines {
+				if !contains(want) {
+					t.Errorf("GenerateIniFragment(%+v) = %q, want line %q", tc.cfg, got, want)
+				}
+			}
+			for _, unwant := range tc.unwantLines {
+				if contains(unwant) {
+					t.Errorf("GenerateIniFragment(%+v) = %q, want no line %q", tc.cfg, got, unwant)
+				}
+			}
+		})
+	}
+}
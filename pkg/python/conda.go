@@ -0,0 +1,204 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	goruntime "runtime"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// EnvironmentYML is the file that declares the conda environment of the application.
+	EnvironmentYML = "environment.yml"
+
+	micromambaVersion = "1.5.8"
+	// micromambaURL is the micromamba release for a conda platform, e.g. linux-64, whose binary is
+	// bin/micromamba in the archive.
+	micromambaURL    = "https://micro.mamba.pm/api/micromamba/%s/%s"
+	micromambaLayer  = "micromamba"
+	condaEnvDir      = "env"
+	condaEnvHashKey  = "environment_hash"
+	condaPipDepsName = "pip"
+)
+
+// condaPythonDepRegexp matches a python dependency with or without a version constraint, but not
+// other packages whose names start with python, e.g. python-dateutil.
+var condaPythonDepRegexp = regexp.MustCompile(`^python(?:$|[\s=<>!~])`)
+
+// condaPlatforms are the conda platforms of the architectures that micromamba is released for.
+var condaPlatforms = map[string]string{
+	"amd64":   "linux-64",
+	"arm64":   "linux-aarch64",
+	"ppc64le": "linux-ppc64le",
+}
+
+// CondaEnvironment is the conda environment declared by an environment.yml file.
+type CondaEnvironment struct {
+	// Name is the name of the environment.
+	Name string
+	// Channels are the channels the conda packages are installed from.
+	Channels []string
+	// Dependencies are the conda packages, e.g. `python=3.11` or `numpy`.
+	Dependencies []string
+	// PipDependencies are the packages installed with pip, listed under `pip` in the dependencies.
+	PipDependencies []string
+}
+
+// environmentYML is the format of environment.yml, whose dependencies are either conda packages or
+// a `pip` list of packages.
+type environmentYML struct {
+	Name         string        `yaml:"name"`
+	Channels     []string      `yaml:"channels"`
+	Dependencies []interface{} `yaml:"dependencies"`
+}
+
+// ReadCondaEnvironment returns the conda environment declared by the environment.yml of dir, or nil
+// if there is none.
+func ReadCondaEnvironment(dir string) (*CondaEnvironment, error) {
+	path := filepath.Join(dir, EnvironmentYML)
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, gcp.InternalErrorf("reading %s: %v", path, err)
+	}
+	var f environmentYML
+	if err := yaml.Unmarshal(content, &f); err != nil {
+		return nil, gcp.UserErrorf("parsing %s: %v", EnvironmentYML, err)
+	}
+	e := &CondaEnvironment{Name: f.Name, Channels: f.Channels}
+	for _, d := range f.Dependencies {
+		switch v := d.(type) {
+		case string:
+			e.Dependencies = append(e.Dependencies, strings.TrimSpace(v))
+		case map[interface{}]interface{}:
+			pip, ok := v[condaPipDepsName].([]interface{})
+			if len(v) != 1 || !ok {
+				return nil, gcp.UserErrorf("invalid dependency %v in %s, expected a package or a list of pip packages", v, EnvironmentYML)
+			}
+			for _, p := range pip {
+				s, ok := p.(string)
+				if !ok {
+					return nil, gcp.UserErrorf("invalid pip dependency %v in %s", p, EnvironmentYML)
+				}
+				e.PipDependencies = append(e.PipDependencies, strings.TrimSpace(s))
+			}
+		default:
+			return nil, gcp.UserErrorf("invalid dependency %v in %s", d, EnvironmentYML)
+		}
+	}
+	return e, nil
+}
+
+// HasPython returns whether the environment depends on python, with or without a version.
+func (e *CondaEnvironment) HasPython() bool {
+	for _, d := range e.Dependencies {
+		if condaPythonDepRegexp.MatchString(stripCondaChannel(d)) {
+			return true
+		}
+	}
+	return false
+}
+
+// stripCondaChannel strips the channel of a package, e.g. conda-forge::python=3.11.
+func stripCondaChannel(d string) string {
+	if i := strings.Index(d, "::"); i >= 0 {
+		return d[i+len("::"):]
+	}
+	return d
+}
+
+// InstallCondaEnvironment creates the conda environment e declared by environment.yml in the layer
+// with micromamba, unless the environment of a previous build with the same environment.yml is
+// cached, and activates it for the rest of the build and at launch. Python is added to the
+// environment if e does not depend on it, so that pip and the application use the environment.
+func InstallCondaEnvironment(ctx *gcp.Context, l *libcnb.Layer, e *CondaEnvironment) error {
+	var specs []string
+	if !e.HasPython() {
+		ctx.Logf("Adding python to the conda environment, since %s does not depend on it.", EnvironmentYML)
+		specs = append(specs, "python")
+	}
+	prefix := filepath.Join(l.Path, condaEnvDir)
+	hash, err := cache.Hash(ctx, cache.WithFiles(filepath.Join(ctx.ApplicationRoot(), EnvironmentYML)), cache.WithStrings(append([]string{micromambaVersion}, specs...)...))
+	if err != nil {
+		return fmt.Errorf("computing the hash of %s: %w", EnvironmentYML, err)
+	}
+	ctx.Debugf("Current environment hash: %q", hash)
+	ctx.Debugf("  Cache environment hash: %q", ctx.GetMetadata(l, condaEnvHashKey))
+	if hash == ctx.GetMetadata(l, condaEnvHashKey) {
+		ctx.Logf("Conda environment cache hit, skipping installation.")
+	} else {
+		if err := ctx.ClearLayer(l); err != nil {
+			return fmt.Errorf("clearing layer %q: %w", l.Name, err)
+		}
+		if err := createCondaEnvironment(ctx, prefix, specs); err != nil {
+			return err
+		}
+		ctx.SetMetadata(l, condaEnvHashKey, hash)
+	}
+
+	bin := filepath.Join(prefix, "bin")
+	l.SharedEnvironment.Override("CONDA_PREFIX", prefix)
+	l.SharedEnvironment.Prepend("PATH", string(os.PathListSeparator), bin)
+	// Use the conda environment python3 for all subsequent commands in this buildpack.
+	if err := ctx.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH")); err != nil {
+		return err
+	}
+	return ctx.Setenv("CONDA_PREFIX", prefix)
+}
+
+// createCondaEnvironment downloads micromamba into a scratch layer and creates the environment in
+// prefix, with the packages of specs in addition to those of environment.yml.
+func createCondaEnvironment(ctx *gcp.Context, prefix string, specs []string) error {
+	platform, err := condaPlatform(goruntime.GOARCH)
+	if err != nil {
+		return err
+	}
+	ml, err := ctx.Layer(micromambaLayer, gcp.ScratchLayer)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", micromambaLayer, err)
+	}
+	ctx.Logf("Installing micromamba v%s.", micromambaVersion)
+	url := fmt.Sprintf(micromambaURL, platform, micromambaVersion)
+	if _, err := ctx.ExecWithErr([]string{"bash", "-c", fmt.Sprintf("curl --fail --show-error --silent --location --retry 3 %s | tar -xj -C %s bin/micromamba", url, ml.Path)}); err != nil {
+		return err
+	}
+	ctx.Logf("Creating the conda environment from %s.", EnvironmentYML)
+	micromamba := filepath.Join(ml.Path, "bin", "micromamba")
+	cmd := append([]string{micromamba, "create", "--yes", "--prefix", prefix, "--file", EnvironmentYML}, specs...)
+	if _, err := ctx.ExecWithErr(cmd, gcp.WithEnv("MAMBA_ROOT_PREFIX="+filepath.Join(ml.Path, "root")), gcp.WithUserAttribution); err != nil {
+		return err
+	}
+	return nil
+}
+
+// condaPlatform returns the conda platform of the Go architecture arch, e.g. linux-64 for amd64.
+func condaPlatform(arch string) (string, error) {
+	platform, ok := condaPlatforms[arch]
+	if !ok {
+		return "", gcp.UserErrorf("conda environments are not supported on the %s architecture", arch)
+	}
+	return platform, nil
+}
@@ -0,0 +1,204 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReadCondaEnvironment(t *testing.T) {
+	testCases := []struct {
+		name       string
+		yml        string
+		want       *CondaEnvironment
+		wantPython bool
+		wantErr    bool
+	}{
+		{
+			name: "no environment.yml",
+		},
+		{
+			name: "conda and pip dependencies",
+			yml: `name: science
+channels:
+  - conda-forge
+  - defaults
+dependencies:
+  - python=3.11
+  - numpy>=1.26
+  - pip
+  - pip:
+    - flask==3.0.0
+    - gunicorn
+`,
+			want: &CondaEnvironment{
+				Name:            "science",
+				Channels:        []string{"conda-forge", "defaults"},
+				Dependencies:    []string{"python=3.11", "numpy>=1.26", "pip"},
+				PipDependencies: []string{"flask==3.0.0", "gunicorn"},
+			},
+			wantPython: true,
+		},
+		{
+			name: "python with channel and exact version",
+			yml:  "dependencies:\n  - conda-forge::python==3.10.13\n",
+			want: &CondaEnvironment{
+				Dependencies: []string{"conda-forge::python==3.10.13"},
+			},
+			wantPython: true,
+		},
+		{
+			name: "python with wildcard and build string",
+			yml:  "dependencies:\n  - python 3.12.*\n  - python-dateutil=2.8\n",
+			want: &CondaEnvironment{
+				Dependencies: []string{"python 3.12.*", "python-dateutil=2.8"},
+			},
+			wantPython: true,
+		},
+		{
+			name: "python without version",
+			yml:  "name: app\ndependencies:\n  - python\n  - pandas\n",
+			want: &CondaEnvironment{
+				Name:         "app",
+				Dependencies: []string{"python", "pandas"},
+			},
+			wantPython: true,
+		},
+		{
+			name: "no python",
+			yml:  "dependencies:\n  - python-dateutil=2.8\n  - numpy\n",
+			want: &CondaEnvironment{
+				Dependencies: []string{"python-dateutil=2.8", "numpy"},
+			},
+		},
+		{
+			name:    "invalid dependency",
+			yml:     "dependencies:\n  - conda:\n    - numpy\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid yaml",
+			yml:     "dependencies: [python=3.11\n",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tc.yml != "" {
+				if err := os.WriteFile(filepath.Join(dir, EnvironmentYML), []byte(tc.yml), 0644); err != nil {
+					t.Fatalf("writing %s: %v", EnvironmentYML, err)
+				}
+			}
+
+			got, err := ReadCondaEnvironment(dir)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("ReadCondaEnvironment() got err=%t, want err=%t. err: %v", gotErr, tc.wantErr, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("ReadCondaEnvironment() mismatch (-want +got):\n%s", diff)
+			}
+			if got == nil {
+				return
+			}
+			if has := got.HasPython(); has != tc.wantPython {
+				t.Errorf("HasPython()=%t, want %t", has, tc.wantPython)
+			}
+		})
+	}
+}
+
+func TestInstallCondaEnvironmentAddsPython(t *testing.T) {
+	testCases := []struct {
+		name string
+		yml  string
+		want string
+	}{
+		{
+			name: "python listed",
+			yml:  "dependencies:\n  - python=3.11\n  - numpy\n",
+			want: "create --yes --prefix PREFIX --file environment.yml",
+		},
+		{
+			name: "python added",
+			yml:  "dependencies:\n  - numpy\n  - pip:\n    - flask\n",
+			want: "create --yes --prefix PREFIX --file environment.yml python",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, EnvironmentYML), []byte(tc.yml), 0644); err != nil {
+				t.Fatalf("writing %s: %v", EnvironmentYML, err)
+			}
+			e, err := ReadCondaEnvironment(dir)
+			if err != nil {
+				t.Fatalf("ReadCondaEnvironment() got error: %v", err)
+			}
+			var got string
+			execCmd := func(name string, args ...string) *exec.Cmd {
+				if filepath.Base(name) == "micromamba" {
+					got = strings.Join(args, " ")
+				}
+				return exec.Command("true")
+			}
+			t.Setenv("PATH", os.Getenv("PATH"))
+			t.Setenv("CONDA_PREFIX", "")
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir), gcp.WithExecCmd(execCmd), gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: t.TempDir()}}))
+			l, err := ctx.Layer("conda", gcp.BuildLayer, gcp.LaunchLayer)
+			if err != nil {
+				t.Fatalf("creating layer: %v", err)
+			}
+
+			if err := InstallCondaEnvironment(ctx, l, e); err != nil {
+				t.Fatalf("InstallCondaEnvironment() got error: %v", err)
+			}
+			if want := strings.Replace(tc.want, "PREFIX", filepath.Join(l.Path, condaEnvDir), 1); got != want {
+				t.Errorf("micromamba %s, want micromamba %s", got, want)
+			}
+		})
+	}
+}
+
+func TestCondaPlatform(t *testing.T) {
+	testCases := []struct {
+		arch    string
+		want    string
+		wantErr bool
+	}{
+		{arch: "amd64", want: "linux-64"},
+		{arch: "arm64", want: "linux-aarch64"},
+		{arch: "s390x", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.arch, func(t *testing.T) {
+			got, err := condaPlatform(tc.arch)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("condaPlatform(%q) got error: %v, want error: %t", tc.arch, err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("condaPlatform(%q) = %q, want %q", tc.arch, got, tc.want)
+			}
+		})
+	}
+}
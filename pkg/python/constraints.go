@@ -0,0 +1,81 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"os"
+	"path/filepath"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// ConstraintsEnv is an environment variable with the path of a pip constraints file, relative to
+	// the application root or absolute.
+	ConstraintsEnv = "GOOGLE_PIP_CONSTRAINTS"
+
+	constraintsFile = "constraints.txt"
+)
+
+// ConstraintsFile returns the path of the pip constraints file passed to `pip install --constraint`:
+// the file set by GOOGLE_PIP_CONSTRAINTS, or constraints.txt in the application root if it exists.
+// It returns an empty string if there is no constraints file.
+func ConstraintsFile(ctx *gcp.Context) (string, error) {
+	path, ok := os.LookupEnv(ConstraintsEnv)
+	if !ok || path == "" {
+		path = filepath.Join(ctx.ApplicationRoot(), constraintsFile)
+		exists, err := ctx.FileExists(path)
+		if err != nil || !exists {
+			return "", err
+		}
+		ctx.Logf("Using the pip constraints file %s.", constraintsFile)
+		return path, nil
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(ctx.ApplicationRoot(), path)
+	}
+	exists, err := ctx.FileExists(path)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "", gcp.UserErrorf("constraints file %s set by %s does not exist", path, ConstraintsEnv)
+	}
+	ctx.Logf("Using the pip constraints file %s.", path)
+	return path, nil
+}
+
+// dependencyFiles returns the files that the dependency cache key of InstallRequirements depends on:
+// the requirements files and constraints file, with the files they include.
+func dependencyFiles(constraints string, reqs ...string) ([]string, error) {
+	if constraints != "" {
+		reqs = append(append([]string{}, reqs...), constraints)
+	}
+	var files []string
+	seen := map[string]bool{}
+	for _, req := range reqs {
+		includes, err := RequirementsIncludes(req)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range includes {
+			if !seen[f] {
+				seen[f] = true
+				files = append(files, f)
+			}
+		}
+	}
+	return files, nil
+}
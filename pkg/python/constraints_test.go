@@ -0,0 +1,149 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestConstraintsFile(t *testing.T) {
+	testCases := []struct {
+		name    string
+		files   []string
+		env     string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "no constraints",
+			files: []string{"requirements.txt"},
+		},
+		{
+			name:  "constraints.txt",
+			files: []string{"requirements.txt", "constraints.txt"},
+			want:  "constraints.txt",
+		},
+		{
+			name:  "env relative to the application root",
+			files: []string{"requirements.txt", "constraints.txt", "pins/prod.txt"},
+			env:   "pins/prod.txt",
+			want:  "pins/prod.txt",
+		},
+		{
+			name:    "env file missing",
+			files:   []string{"requirements.txt", "constraints.txt"},
+			env:     "pins/prod.txt",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			root := t.TempDir()
+			for _, f := range tc.files {
+				writeAppFile(t, root, f, "")
+			}
+			if tc.env != "" {
+				t.Setenv(ConstraintsEnv, tc.env)
+			}
+
+			got, err := ConstraintsFile(gcp.NewContext(gcp.WithApplicationRoot(root)))
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("ConstraintsFile() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			want := ""
+			if tc.want != "" {
+				want = filepath.Join(root, tc.want)
+			}
+			if got != want {
+				t.Errorf("ConstraintsFile() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestConstraintsFileAbsolutePath(t *testing.T) {
+	path := writeAppFile(t, t.TempDir(), "constraints.txt", "")
+	t.Setenv(ConstraintsEnv, path)
+
+	got, err := ConstraintsFile(gcp.NewContext(gcp.WithApplicationRoot(t.TempDir())))
+	if err != nil {
+		t.Fatalf("ConstraintsFile() got error: %v", err)
+	}
+	if got != path {
+		t.Errorf("ConstraintsFile() = %q, want %q", got, path)
+	}
+}
+
+func TestDependencyFiles(t *testing.T) {
+	root := t.TempDir()
+	reqs := writeAppFile(t, root, "requirements.txt", "flask\n")
+	constraints := writeAppFile(t, root, "constraints.txt", "werkzeug==2.0.1\n")
+	ctx := gcp.NewContext()
+	hash := func() string {
+		t.Helper()
+		files, err := dependencyFiles(constraints, reqs)
+		if err != nil {
+			t.Fatalf("dependencyFiles() got error: %v", err)
+		}
+		h, err := cache.Hash(ctx, cache.WithFiles(files...))
+		if err != nil {
+			t.Fatalf("cache.Hash() got error: %v", err)
+		}
+		return h
+	}
+
+	if got, err := dependencyFiles("", reqs); err != nil {
+		t.Errorf("dependencyFiles() without constraints got error: %v", err)
+	} else if diff := cmp.Diff([]string{reqs}, got); diff != "" {
+		t.Errorf("dependencyFiles() without constraints mismatch (-want +got):\n%s", diff)
+	}
+	if got, err := dependencyFiles(constraints, reqs); err != nil {
+		t.Errorf("dependencyFiles() with constraints got error: %v", err)
+	} else if diff := cmp.Diff([]string{reqs, constraints}, got); diff != "" {
+		t.Errorf("dependencyFiles() with constraints mismatch (-want +got):\n%s", diff)
+	}
+
+	before := hash()
+	writeAppFile(t, root, "constraints.txt", "werkzeug==2.0.2\n")
+	if after := hash(); after == before {
+		t.Errorf("dependency hash %q did not change after changing the constraints file", after)
+	}
+
+	writeAppFile(t, root, "requirements.txt", "-r requirements/base.txt\nflask\n")
+	writeAppFile(t, root, "requirements/base.txt", "requests==2.27.0\n")
+	before = hash()
+	writeAppFile(t, root, "requirements/base.txt", "requests==2.28.0\n")
+	if after := hash(); after == before {
+		t.Errorf("dependency hash %q did not change after changing an included requirements file", after)
+	}
+}
+
+func writeAppFile(t *testing.T, root, name, content string) string {
+	t.Helper()
+	path := filepath.Join(root, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("creating directory of %s: %v", name, err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("writing %s: %v", name, err)
+	}
+	return path
+}
@@ -0,0 +1,152 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// DjangoCollectStaticEnv is the environment variable that runs `manage.py collectstatic` in
+	// the build of Django applications.
+	DjangoCollectStaticEnv = "GOOGLE_DJANGO_COLLECTSTATIC"
+	djangoManage           = "manage.py"
+	// staticRootScript prints the STATIC_ROOT setting of a Django project when run by `manage.py shell`.
+	staticRootScript = "from django.conf import settings; print(settings.STATIC_ROOT or '')"
+)
+
+var (
+	// settingsModuleRegexp matches the default settings module set by manage.py, e.g.
+	// os.environ.setdefault("DJANGO_SETTINGS_MODULE", "mysite.settings").
+	settingsModuleRegexp = regexp.MustCompile(`["']DJANGO_SETTINGS_MODULE["']\s*,\s*["']([\w.]+)["']`)
+)
+
+// IsDjango returns whether the application in dir is a Django project: it has a manage.py and
+// depends on django in requirements.txt or pyproject.toml.
+func IsDjango(dir string) (bool, error) {
+	if _, err := os.Stat(filepath.Join(dir, djangoManage)); os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, gcp.InternalErrorf("stating %s: %v", djangoManage, err)
+	}
+	required, err := requiredFrameworks(filepath.Join(dir, "requirements.txt"))
+	if err != nil {
+		return false, err
+	}
+	for _, f := range required {
+		if f == "django" {
+			return true, nil
+		}
+	}
+	return pyProjectDependsOn(dir, "django")
+}
+
+// DjangoWSGIModule returns the WSGI module of the Django project in dir, e.g. mysite.wsgi, or ""
+// if it cannot be found. The module is looked for in the package of the settings module that
+// manage.py uses, or else is the only wsgi.py next to a settings.py.
+func DjangoWSGIModule(dir string) (string, error) {
+	return djangoModule(dir, "wsgi")
+}
+
+// djangoModule returns the module with the given name in the project package of the Django
+// project in dir, or "" if it cannot be found.
+func djangoModule(dir, name string) (string, error) {
+	content, err := os.ReadFile(filepath.Join(dir, djangoManage))
+	if err != nil && !os.IsNotExist(err) {
+		return "", gcp.InternalErrorf("reading %s: %v", djangoManage, err)
+	}
+	if m := settingsModuleRegexp.FindSubmatch(content); m != nil {
+		// The settings may be a package of their own, e.g. config.settings.production, so the
+		// module is looked for in each enclosing package, innermost first.
+		parts := strings.Split(string(m[1]), ".")
+		for i := len(parts) - 1; i > 0; i-- {
+			pkg := parts[:i]
+			path := filepath.Join(append([]string{dir}, pkg...)...)
+			if _, err := os.Stat(filepath.Join(path, name+".py")); err == nil {
+				return strings.Join(append(pkg, name), "."), nil
+			}
+		}
+	}
+	settings, err := filepath.Glob(filepath.Join(dir, "*", "settings.py"))
+	if err != nil {
+		return "", gcp.InternalErrorf("finding settings.py: %v", err)
+	}
+	var modules []string
+	for _, s := range settings {
+		project := filepath.Dir(s)
+		if _, err := os.Stat(filepath.Join(project, name+".py")); err == nil {
+			modules = append(modules, filepath.Base(project)+"."+name)
+		}
+	}
+	if len(modules) != 1 {
+		return "", nil
+	}
+	return modules[0], nil
+}
+
+// pyProjectDependsOn returns whether the pyproject.toml in dir lists the named project in its
+// PEP 621 or Poetry dependencies.
+func pyProjectDependsOn(dir, name string) (bool, error) {
+	path := filepath.Join(dir, PyProjectTOML)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return false, nil
+	}
+	var p pyProject
+	if _, err := toml.DecodeFile(path, &p); err != nil {
+		return false, gcp.UserErrorf("parsing %s: %v", PyProjectTOML, err)
+	}
+	for _, d := range p.Project.Dependencies {
+		if strings.EqualFold(requirementNameRegexp.FindString(strings.TrimSpace(d)), name) {
+			return true, nil
+		}
+	}
+	for d := range p.Tool.Poetry.Dependencies {
+		if strings.EqualFold(d, name) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// CollectStatic runs `manage.py collectstatic` for the Django project in the application root.
+// STATIC_ROOT is cleared first, so that files deleted from the application are not served, and must
+// be within the application, since files outside of it are not part of the application image.
+func CollectStatic(ctx *gcp.Context) error {
+	result, cerr := ctx.ExecWithErr([]string{"python3", djangoManage, "shell", "-c", staticRootScript}, gcp.WithUserAttribution)
+	if cerr != nil {
+		return gcp.UserErrorf("reading STATIC_ROOT from the Django settings: %s", cerr.Message)
+	}
+	lines := strings.Split(strings.TrimSpace(result.Stdout), "\n")
+	staticRoot := strings.TrimSpace(lines[len(lines)-1])
+	if staticRoot == "" {
+		return gcp.UserErrorf("STATIC_ROOT must be set in the Django settings to run collectstatic with %s", DjangoCollectStaticEnv)
+	}
+	if !filepath.IsAbs(staticRoot) {
+		staticRoot = filepath.Join(ctx.ApplicationRoot(), staticRoot)
+	}
+	if rel, err := filepath.Rel(ctx.ApplicationRoot(), staticRoot); err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return gcp.UserErrorf("STATIC_ROOT %s must be a directory within the application root %s to run collectstatic with %s", staticRoot, ctx.ApplicationRoot(), DjangoCollectStaticEnv)
+	}
+
+	ctx.Logf("Collecting static files into %s.", staticRoot)
+	ctx.Exec([]string{"python3", djangoManage, "collectstatic", "--noinput", "--clear"}, gcp.WithUserAttribution)
+	return nil
+}
@@ -0,0 +1,236 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+const manageScript = `import os
+import sys
+
+if __name__ == "__main__":
+    os.environ.setdefault("DJANGO_SETTINGS_MODULE", "%s")
+    from django.core.management import execute_from_command_line
+    execute_from_command_line(sys.argv)
+`
+
+func TestIsDjango(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  bool
+	}{
+		{
+			name: "requirements.txt",
+			files: map[string]string{
+				"manage.py":        "",
+				"requirements.txt": "Django==4.1.2\ngunicorn\n",
+			},
+			want: true,
+		},
+		{
+			name: "pyproject.toml dependencies",
+			files: map[string]string{
+				"manage.py":      "",
+				"pyproject.toml": "[project]\nname = \"site\"\ndependencies = [\"django>=4.1\", \"psycopg2\"]\n",
+			},
+			want: true,
+		},
+		{
+			name: "poetry dependencies",
+			files: map[string]string{
+				"manage.py":      "",
+				"pyproject.toml": "[tool.poetry.dependencies]\npython = \"^3.10\"\nDjango = \"^4.1\"\n",
+			},
+			want: true,
+		},
+		{
+			name: "no manage.py",
+			files: map[string]string{
+				"requirements.txt": "django\n",
+			},
+		},
+		{
+			name: "no django dependency",
+			files: map[string]string{
+				"manage.py":        "",
+				"requirements.txt": "flask\ndjango-environ\n",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTestFiles(t, dir, tc.files)
+
+			got, err := IsDjango(dir)
+			if err != nil {
+				t.Fatalf("IsDjango(%q) got error: %v", dir, err)
+			}
+			if got != tc.want {
+				t.Errorf("IsDjango(%q) = %t, want %t", dir, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDjangoWSGIModule(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name: "settings module of manage.py",
+			files: map[string]string{
+				"manage.py":          fmt.Sprintf(manageScript, "mysite.settings"),
+				"mysite/settings.py": "",
+				"mysite/wsgi.py":     "",
+			},
+			want: "mysite.wsgi",
+		},
+		{
+			name: "settings package",
+			files: map[string]string{
+				"manage.py":                     fmt.Sprintf(manageScript, "config.settings.production"),
+				"config/settings/__init__.py":   "",
+				"config/settings/production.py": "",
+				"config/wsgi.py":                "",
+			},
+			want: "config.wsgi",
+		},
+		{
+			name: "settings.py next to wsgi.py",
+			files: map[string]string{
+				"manage.py":        "",
+				"shop/settings.py": "",
+				"shop/wsgi.py":     "",
+				"shop/urls.py":     "",
+				"orders/models.py": "",
+			},
+			want: "shop.wsgi",
+		},
+		{
+			name: "several projects",
+			files: map[string]string{
+				"manage.py":        "",
+				"shop/settings.py": "",
+				"shop/wsgi.py":     "",
+				"blog/settings.py": "",
+				"blog/wsgi.py":     "",
+			},
+		},
+		{
+			name: "no wsgi.py",
+			files: map[string]string{
+				"manage.py":          fmt.Sprintf(manageScript, "mysite.settings"),
+				"mysite/settings.py": "",
+				"mysite/asgi.py":     "",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTestFiles(t, dir, tc.files)
+
+			got, err := DjangoWSGIModule(dir)
+			if err != nil {
+				t.Fatalf("DjangoWSGIModule(%q) got error: %v", dir, err)
+			}
+			if got != tc.want {
+				t.Errorf("DjangoWSGIModule(%q) = %q, want %q", dir, got, tc.want)
+			}
+		})
+	}
+}
+
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("creating directory for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("writing %s: %v", name, err)
+		}
+	}
+}
+
+func TestCollectStatic(t *testing.T) {
+	appDir := t.TempDir()
+	testCases := []struct {
+		name       string
+		staticRoot string
+		want       [][]string
+		wantErr    bool
+	}{
+		{
+			name:       "relative STATIC_ROOT",
+			staticRoot: "staticfiles",
+			want:       [][]string{{"python3", "manage.py", "collectstatic", "--noinput", "--clear"}},
+		},
+		{
+			name:       "absolute STATIC_ROOT within the application",
+			staticRoot: filepath.Join(appDir, "static"),
+			want:       [][]string{{"python3", "manage.py", "collectstatic", "--noinput", "--clear"}},
+		},
+		{
+			name:       "STATIC_ROOT outside of the application",
+			staticRoot: "/var/www/static",
+			wantErr:    true,
+		},
+		{
+			name:       "STATIC_ROOT is the application root",
+			staticRoot: appDir,
+			wantErr:    true,
+		},
+		{
+			name:    "STATIC_ROOT not set",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got [][]string
+			execCmd := func(name string, args ...string) *exec.Cmd {
+				if strings.Join(args, " ") == "manage.py shell -c "+staticRootScript {
+					return exec.Command("echo", tc.staticRoot)
+				}
+				got = append(got, append([]string{name}, args...))
+				return exec.Command("true")
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(appDir), gcp.WithExecCmd(execCmd))
+
+			err := CollectStatic(ctx)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("CollectStatic() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("CollectStatic() commands mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// RequireHashesEnv is an environment variable that forces `pip install --require-hashes` for all
+// requirements files, instead of only for the ones with hash annotations.
+const RequireHashesEnv = "GOOGLE_PIP_REQUIRE_HASHES"
+
+var (
+	// hashOptionRegexp matches the --hash option of a requirement.
+	hashOptionRegexp = regexp.MustCompile(`(^|\s)--hash[=\s]`)
+	// requirementNameRegexp matches the project name at the start of a requirement.
+	requirementNameRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*`)
+)
+
+// RequirementHashes is the result of scanning a requirements file for hash annotations.
+type RequirementHashes struct {
+	// Hashed are the requirements with at least one --hash option.
+	Hashed []string
+	// Unhashed are the requirements without a --hash option, including editable requirements which
+	// cannot be hashed.
+	Unhashed []string
+}
+
+// ScanRequirementHashes scans the requirements file at path for requirements with and without
+// --hash options. Options such as --index-url and nested requirements files are ignored.
+func ScanRequirementHashes(path string) (*RequirementHashes, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, gcp.InternalErrorf("reading %s: %v", path, err)
+	}
+	r := &RequirementHashes{}
+	for _, line := range requirementLines(string(content)) {
+		name := line
+		if f := strings.Fields(line); len(f) > 0 && (f[0] == "-e" || f[0] == "--editable") {
+			r.Unhashed = append(r.Unhashed, line)
+			continue
+		}
+		if strings.HasPrefix(line, "-") {
+			continue
+		}
+		// VCS and URL requirements are named by their location rather than their project.
+		if _, value := classifyRequirement(line); value != "" {
+			name = value
+		}
+		if hashOptionRegexp.MatchString(line) {
+			r.Hashed = append(r.Hashed, name)
+		} else {
+			r.Unhashed = append(r.Unhashed, name)
+		}
+	}
+	return r, nil
+}
+
+// requirementLines returns the logical lines of a requirements file, with line continuations
+// joined and comments and blank lines removed.
+func requirementLines(content string) []string {
+	content = strings.ReplaceAll(content, "\\\r\n", " ")
+	content = strings.ReplaceAll(content, "\\\n", " ")
+	var lines []string
+	for _, line := range strings.Split(content, "\n") {
+		// A comment starts at a # at the start of the line or after whitespace, other #s are part of
+		// URL fragments such as #egg=name.
+		for i := strings.Index(line, "#"); i >= 0; i = nextIndex(line, "#", i) {
+			if i == 0 || line[i-1] == ' ' || line[i-1] == '\t' {
+				line = line[:i]
+				break
+			}
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// nextIndex returns the index of the next instance of substr in s after index i, or -1.
+func nextIndex(s, substr string, i int) int {
+	j := strings.Index(s[i+1:], substr)
+	if j < 0 {
+		return -1
+	}
+	return i + 1 + j
+}
+
+// RequireHashes returns true if the requirements file at path must be installed with
+// `pip install --require-hashes`: if it has hash annotations or if GOOGLE_PIP_REQUIRE_HASHES is true.
+// When hashes are required, every requirement in the file must have a hash, except VCS and
+// editable requirements if GOOGLE_PIP_ALLOW_UNHASHED_VCS is true.
+func RequireHashes(ctx *gcp.Context, path string) (bool, error) {
+	forced, err := env.IsPresentAndTrue(RequireHashesEnv)
+	if err != nil {
+		return false, gcp.UserErrorf("%v", err)
+	}
+	hashes, err := ScanRequirementHashes(path)
+	if err != nil {
+		return false, err
+	}
+	if !forced && len(hashes.Hashed) == 0 {
+		return false, nil
+	}
+	allowVCS, err := allowUnhashedVCS()
+	if err != nil {
+		return false, err
+	}
+	var unhashed, unhashable []string
+	for _, req := range hashes.Unhashed {
+		if isUnhashable(req) {
+			unhashable = append(unhashable, req)
+			if allowVCS {
+				continue
+			}
+		}
+		unhashed = append(unhashed, req)
+	}
+	if len(unhashed) > 0 {
+		reason := "it contains hash annotations"
+		if forced {
+			reason = RequireHashesEnv + " is set"
+		}
+		msg := fmt.Sprintf("hashes are required for all requirements in %s because %s, but these requirements do not have a --hash: %s", path, reason, strings.Join(unhashed, ", "))
+		if len(unhashable) > 0 {
+			msg += fmt.Sprintf(". VCS and editable requirements cannot be hashed, set %s=true to install them without hash checking", AllowUnhashedVCSEnv)
+		}
+		return false, gcp.UserErrorf("%s", msg)
+	}
+	ctx.Logf("Verifying the hashes of the requirements in %s.", path)
+	return true, nil
+}
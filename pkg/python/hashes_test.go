@@ -0,0 +1,197 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestScanRequirementHashes(t *testing.T) {
+	testCases := []struct {
+		name         string
+		requirements string
+		want         RequirementHashes
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:         "no hashes",
+			requirements: "flask==2.0.1\n# a comment\nrequests>=2.0 ; python_version >= \"3.7\"\n",
+			want:         RequirementHashes{Unhashed: []string{"flask", "requests"}},
+		},
+		{
+			name: "hashes on continuation lines",
+			requirements: "flask==2.0.1 \\\n    --hash=sha256:aaaa \\\n    --hash=sha256:bbbb\n" +
+				"click==8.0.1 --hash=sha256:cccc  # inline comment\n",
+			want: RequirementHashes{Hashed: []string{"flask", "click"}},
+		},
+		{
+			name:         "partial hashes",
+			requirements: "flask==2.0.1 --hash=sha256:aaaa\ngunicorn==20.1.0\n",
+			want:         RequirementHashes{Hashed: []string{"flask"}, Unhashed: []string{"gunicorn"}},
+		},
+		{
+			name:         "options are ignored",
+			requirements: "--index-url https://example.com/simple\n-r other.txt\n--require-hashes\nflask==2.0.1 --hash=sha256:aaaa\n",
+			want:         RequirementHashes{Hashed: []string{"flask"}},
+		},
+		{
+			name:         "editable requirements cannot be hashed",
+			requirements: "-e ./local --hash=sha256:aaaa\n",
+			want:         RequirementHashes{Unhashed: []string{"-e ./local --hash=sha256:aaaa"}},
+		},
+		{
+			name:         "vcs requirements are named by their location",
+			requirements: "git+https://github.com/org/repo#egg=repo\n",
+			want:         RequirementHashes{Unhashed: []string{"git+https://github.com/org/repo#egg=repo"}},
+		},
+		{
+			name:         "extras",
+			requirements: "uvicorn[standard]==0.15.0 --hash=sha256:aaaa\n",
+			want:         RequirementHashes{Hashed: []string{"uvicorn"}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := writeRequirements(t, tc.requirements)
+
+			got, err := ScanRequirementHashes(path)
+			if err != nil {
+				t.Fatalf("ScanRequirementHashes() got error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, *got); diff != "" {
+				t.Errorf("ScanRequirementHashes() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRequireHashes(t *testing.T) {
+	testCases := []struct {
+		name         string
+		requirements string
+		env          string
+		allowVCS     string
+		want         bool
+		wantErr      bool
+	}{
+		{
+			name:         "no hashes",
+			requirements: "flask==2.0.1\n",
+		},
+		{
+			name:         "all hashed",
+			requirements: "flask==2.0.1 --hash=sha256:aaaa\nclick==8.0.1 --hash=sha256:bbbb\n",
+			want:         true,
+		},
+		{
+			name:         "partially hashed",
+			requirements: "flask==2.0.1 --hash=sha256:aaaa\nclick==8.0.1\n",
+			wantErr:      true,
+		},
+		{
+			name:         "forced without hashes",
+			requirements: "flask==2.0.1\n",
+			env:          "true",
+			wantErr:      true,
+		},
+		{
+			name:         "forced with hashes",
+			requirements: "flask==2.0.1 --hash=sha256:aaaa\n",
+			env:          "TRUE",
+			want:         true,
+		},
+		{
+			name:         "forced with only options",
+			requirements: "-r other.txt\n",
+			env:          "true",
+			want:         true,
+		},
+		{
+			name:         "false does not disable detection",
+			requirements: "flask==2.0.1 --hash=sha256:aaaa\n",
+			env:          "false",
+			want:         true,
+		},
+		{
+			name:         "invalid env",
+			requirements: "flask==2.0.1\n",
+			env:          "maybe",
+			wantErr:      true,
+		},
+		{
+			name:         "vcs without hashes",
+			requirements: "flask==2.0.1\ngit+https://github.com/org/repo#egg=repo\n",
+		},
+		{
+			name:         "hashed with vcs",
+			requirements: "flask==2.0.1 --hash=sha256:aaaa\ngit+https://github.com/org/repo#egg=repo\n",
+			wantErr:      true,
+		},
+		{
+			name:         "hashed with vcs and editable allowed",
+			requirements: "flask==2.0.1 --hash=sha256:aaaa\ngit+https://github.com/org/repo#egg=repo\n-e ./local\n",
+			allowVCS:     "true",
+			want:         true,
+		},
+		{
+			name:         "allowed vcs does not allow unhashed pypi requirements",
+			requirements: "flask==2.0.1 --hash=sha256:aaaa\nclick==8.0.1\ngit+https://github.com/org/repo#egg=repo\n",
+			allowVCS:     "true",
+			wantErr:      true,
+		},
+		{
+			name:         "forced with allowed vcs",
+			requirements: "git+https://github.com/org/repo#egg=repo\n",
+			env:          "true",
+			allowVCS:     "true",
+			want:         true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.env != "" {
+				t.Setenv(RequireHashesEnv, tc.env)
+			}
+			if tc.allowVCS != "" {
+				t.Setenv(AllowUnhashedVCSEnv, tc.allowVCS)
+			}
+			path := writeRequirements(t, tc.requirements)
+
+			got, err := RequireHashes(gcp.NewContext(), path)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("RequireHashes() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("RequireHashes() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
+
+func writeRequirements(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "requirements.txt")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("writing requirements.txt: %v", err)
+	}
+	return path
+}
@@ -0,0 +1,100 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// includeOptions are the options of a requirements file that include another file.
+var includeOptions = []string{"-r", "--requirement", "-c", "--constraint"}
+
+// RequirementsIncludes returns the requirements file at path followed by the files that it
+// includes with -r/--requirement or -c/--constraint, transitively, each listed once. Relative
+// include paths are resolved against the directory of the including file, as pip does, and
+// includes of URLs are ignored. Circular includes are an error.
+func RequirementsIncludes(path string) ([]string, error) {
+	var files []string
+	seen := map[string]bool{}
+	var visit func(path string, stack []string) error
+	visit = func(path string, stack []string) error {
+		for i, p := range stack {
+			if p == path {
+				return gcp.UserErrorf("circular requirements file includes: %s", strings.Join(append(stack[i:], path), " -> "))
+			}
+		}
+		if seen[path] {
+			return nil
+		}
+		seen[path] = true
+		files = append(files, path)
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) && len(stack) > 0 {
+				return gcp.UserErrorf("requirements file %s included by %s does not exist", path, stack[len(stack)-1])
+			}
+			return gcp.InternalErrorf("reading %s: %v", path, err)
+		}
+		for _, line := range requirementLines(string(content)) {
+			include := includedFile(line)
+			if include == "" {
+				continue
+			}
+			if !filepath.IsAbs(include) {
+				include = filepath.Join(filepath.Dir(path), include)
+			}
+			if err := visit(filepath.Clean(include), append(stack, path)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := visit(filepath.Clean(path), nil); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// includedFile returns the file that a requirements file line includes, or "" if the line is not
+// an include of a local file.
+func includedFile(line string) string {
+	for _, opt := range includeOptions {
+		var file string
+		switch {
+		case strings.HasPrefix(line, opt+"="):
+			file = line[len(opt)+1:]
+		case strings.HasPrefix(line, opt+" "), strings.HasPrefix(line, opt+"\t"):
+			file = line[len(opt):]
+		case opt == "-r" || opt == "-c":
+			// Short options also accept the value without a separator, e.g. -rbase.txt.
+			if strings.HasPrefix(line, opt) && !strings.HasPrefix(line, "--") {
+				file = line[len(opt):]
+			}
+		}
+		if file = strings.TrimSpace(file); file == "" {
+			continue
+		}
+		if strings.Contains(file, "://") {
+			return ""
+		}
+		return file
+	}
+	return ""
+}
@@ -0,0 +1,111 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRequirementsIncludes(t *testing.T) {
+	testCases := []struct {
+		name    string
+		files   map[string]string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "no includes",
+			files: map[string]string{"requirements.txt": "flask\n"},
+			want:  []string{"requirements.txt"},
+		},
+		{
+			name: "nested includes",
+			files: map[string]string{
+				"requirements.txt": "-r prod.txt\nflask\n",
+				"prod.txt":         "--requirement=base.txt\ngunicorn\n",
+				"base.txt":         "-c constraints.txt\nrequests\n",
+				"constraints.txt":  "urllib3<2\n",
+			},
+			want: []string{"requirements.txt", "prod.txt", "base.txt", "constraints.txt"},
+		},
+		{
+			name: "relative to the including file",
+			files: map[string]string{
+				"requirements.txt":      "-r requirements/prod.txt\n",
+				"requirements/prod.txt": "-r base.txt\n-r ../common.txt\n",
+				"requirements/base.txt": "flask\n",
+				"common.txt":            "requests\n",
+			},
+			want: []string{"requirements.txt", "requirements/prod.txt", "requirements/base.txt", "common.txt"},
+		},
+		{
+			name: "file included twice",
+			files: map[string]string{
+				"requirements.txt": "-r a.txt\n-r b.txt\n",
+				"a.txt":            "-r base.txt\n",
+				"b.txt":            "-rbase.txt\n",
+				"base.txt":         "flask\n",
+			},
+			want: []string{"requirements.txt", "a.txt", "base.txt", "b.txt"},
+		},
+		{
+			name: "url and comments are ignored",
+			files: map[string]string{
+				"requirements.txt": "# -r missing.txt\n-r https://example.com/requirements.txt\n-e .\nflask\n",
+			},
+			want: []string{"requirements.txt"},
+		},
+		{
+			name: "cycle",
+			files: map[string]string{
+				"requirements.txt": "-r a.txt\n",
+				"a.txt":            "-r b.txt\n",
+				"b.txt":            "-r ./a.txt\n",
+			},
+			wantErr: true,
+		},
+		{
+			name:    "self include",
+			files:   map[string]string{"requirements.txt": "-r requirements.txt\n"},
+			wantErr: true,
+		},
+		{
+			name:    "missing include",
+			files:   map[string]string{"requirements.txt": "-r base.txt\n"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTestFiles(t, dir, tc.files)
+
+			got, err := RequirementsIncludes(filepath.Join(dir, "requirements.txt"))
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("RequirementsIncludes() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			var want []string
+			for _, f := range tc.want {
+				want = append(want, filepath.Join(dir, f))
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("RequirementsIncludes() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// PoetryScriptEnv is the environment variable that selects which of the scripts declared under
+// `[tool.poetry.scripts]` is the entrypoint of the application.
+const PoetryScriptEnv = "GOOGLE_POETRY_SCRIPT"
+
+// scriptReferenceRegexp matches a console script reference, e.g. app.cli:main.
+var scriptReferenceRegexp = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*(?:\.[A-Za-z_][A-Za-z0-9_]*)*):([A-Za-z_][A-Za-z0-9_]*(?:\.[A-Za-z_][A-Za-z0-9_]*)*)$`)
+
+// PoetryScripts returns the console scripts declared under `[tool.poetry.scripts]` in the
+// pyproject.toml of dir, keyed by name, e.g. {"serve": "app.cli:main"}. Scripts declared as a
+// table use their `callable` or `reference`. It returns nil if there is no pyproject.toml or it
+// declares no scripts.
+func PoetryScripts(dir string) (map[string]string, error) {
+	path := filepath.Join(dir, PyProjectTOML)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, nil
+	}
+	var p pyProject
+	if _, err := toml.DecodeFile(path, &p); err != nil {
+		return nil, gcp.UserErrorf("parsing %s: %v", PyProjectTOML, err)
+	}
+	if len(p.Tool.Poetry.Scripts) == 0 {
+		return nil, nil
+	}
+	scripts := make(map[string]string, len(p.Tool.Poetry.Scripts))
+	for name, s := range p.Tool.Poetry.Scripts {
+		switch v := s.(type) {
+		case string:
+			scripts[name] = v
+		case map[string]interface{}:
+			for _, key := range []string{"callable", "reference"} {
+				if ref, ok := v[key].(string); ok {
+					scripts[name] = ref
+					break
+				}
+			}
+		}
+		if _, ok := scripts[name]; !ok {
+			return nil, gcp.UserErrorf("invalid script %q in [tool.poetry.scripts] of %s", name, PyProjectTOML)
+		}
+	}
+	return scripts, nil
+}
+
+// PoetryEntrypoint returns the shell command that runs the Poetry script of the application in
+// dir, or "" if it declares no scripts. A single script is used as is, while several scripts
+// require PoetryScriptEnv to select one. The script is run the same way as its installed console
+// script, by calling the referenced function and exiting with its return value.
+func PoetryEntrypoint(dir string) (string, error) {
+	scripts, err := PoetryScripts(dir)
+	if err != nil || len(scripts) == 0 {
+		return "", err
+	}
+	var names []string
+	for name := range scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	name := strings.TrimSpace(os.Getenv(PoetryScriptEnv))
+	switch {
+	case name != "":
+		if _, ok := scripts[name]; !ok {
+			return "", gcp.UserErrorf("%s=%q is not one of the scripts declared in %s: %s", PoetryScriptEnv, name, PyProjectTOML, strings.Join(names, ", "))
+		}
+	case len(names) == 1:
+		name = names[0]
+	default:
+		return "", gcp.UserErrorf("%s declares several scripts (%s), set %s to choose the entrypoint", PyProjectTOML, strings.Join(names, ", "), PoetryScriptEnv)
+	}
+	return scriptCommand(name, scripts[name])
+}
+
+// scriptCommand returns the shell command that calls the function referenced by a console script.
+func scriptCommand(name, ref string) (string, error) {
+	m := scriptReferenceRegexp.FindStringSubmatch(strings.TrimSpace(ref))
+	if m == nil {
+		return "", gcp.UserErrorf("invalid reference %q of script %q in %s, expected module:function", ref, name, PyProjectTOML)
+	}
+	module, attr := m[1], m[2]
+	obj := strings.SplitN(attr, ".", 2)[0]
+	return fmt.Sprintf("exec python3 -c 'import sys; from %s import %s; sys.exit(%s())'", module, obj, attr), nil
+}
@@ -0,0 +1,133 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPoetryScripts(t *testing.T) {
+	testCases := []struct {
+		name      string
+		pyproject string
+		want      map[string]string
+		wantErr   bool
+	}{
+		{
+			name: "no pyproject.toml",
+		},
+		{
+			name:      "no scripts",
+			pyproject: "[tool.poetry]\nname = \"app\"\n",
+		},
+		{
+			name:      "scripts",
+			pyproject: "[tool.poetry.scripts]\nserve = \"app.cli:serve\"\nmigrate = \"app.db:migrate\"\n",
+			want:      map[string]string{"serve": "app.cli:serve", "migrate": "app.db:migrate"},
+		},
+		{
+			name:      "script table",
+			pyproject: "[tool.poetry.scripts]\nserve = { callable = \"app.cli:serve\", extras = [\"web\"] }\n",
+			want:      map[string]string{"serve": "app.cli:serve"},
+		},
+		{
+			name:      "invalid script",
+			pyproject: "[tool.poetry.scripts]\nserve = 1\n",
+			wantErr:   true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tc.pyproject != "" {
+				writeAppFile(t, dir, PyProjectTOML, tc.pyproject)
+			}
+
+			got, err := PoetryScripts(dir)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("PoetryScripts() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("PoetryScripts() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPoetryEntrypoint(t *testing.T) {
+	testCases := []struct {
+		name      string
+		pyproject string
+		env       string
+		want      string
+		wantErr   bool
+	}{
+		{
+			name:      "no scripts",
+			pyproject: "[tool.poetry]\nname = \"app\"\n",
+		},
+		{
+			name:      "single script is selected",
+			pyproject: "[tool.poetry.scripts]\nserve = \"app.cli:main\"\n",
+			want:      "exec python3 -c 'import sys; from app.cli import main; sys.exit(main())'",
+		},
+		{
+			name:      "attribute reference",
+			pyproject: "[tool.poetry.scripts]\nserve = \"app:cli.main\"\n",
+			want:      "exec python3 -c 'import sys; from app import cli; sys.exit(cli.main())'",
+		},
+		{
+			name:      "multiple scripts require selection",
+			pyproject: "[tool.poetry.scripts]\nserve = \"app.cli:serve\"\nmigrate = \"app.db:migrate\"\n",
+			wantErr:   true,
+		},
+		{
+			name:      "multiple scripts with selection",
+			pyproject: "[tool.poetry.scripts]\nserve = \"app.cli:serve\"\nmigrate = \"app.db:migrate\"\n",
+			env:       "serve",
+			want:      "exec python3 -c 'import sys; from app.cli import serve; sys.exit(serve())'",
+		},
+		{
+			name:      "unknown script selected",
+			pyproject: "[tool.poetry.scripts]\nserve = \"app.cli:serve\"\n",
+			env:       "worker",
+			wantErr:   true,
+		},
+		{
+			name:      "invalid reference",
+			pyproject: "[tool.poetry.scripts]\nserve = \"app.cli:main; rm -rf /\"\n",
+			wantErr:   true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeAppFile(t, dir, PyProjectTOML, tc.pyproject)
+			if tc.env != "" {
+				t.Setenv(PoetryScriptEnv, tc.env)
+			}
+
+			got, err := PoetryEntrypoint(dir)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("PoetryEntrypoint() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("PoetryEntrypoint() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtime"
+)
+
+// PythonVersionFile is the file in which pyenv pins the Python version of the application.
+const PythonVersionFile = ".python-version"
+
+// pyenvVersionRegexp matches the CPython versions that pyenv accepts, e.g. 3, 3.11 or 3.11.4.
+var pyenvVersionRegexp = regexp.MustCompile(`^[0-9]+(?:\.[0-9]+){0,2}$`)
+
+// PyenvVersion returns the Python version pinned by the .python-version file of dir, or "" if
+// there is none or it is empty. Like pyenv, it uses the first of the versions listed in the file,
+// one per line or separated by spaces, and skips comments. A partial version such as 3.11 is
+// returned as the 3.11.x constraint, which resolves to the newest 3.11 patch version.
+func PyenvVersion(ctx *gcp.Context, dir string) (string, error) {
+	content, err := runtime.ReadVersionFile(ctx, filepath.Join(dir, PythonVersionFile))
+	if err != nil || content == "" {
+		return "", err
+	}
+	var v string
+	for _, line := range strings.Split(content, "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 && !strings.HasPrefix(fields[0], "#") {
+			v = fields[0]
+			break
+		}
+	}
+	if v == "" {
+		ctx.Warnf("Ignoring %s because it only contains comments, it should contain a version.", PythonVersionFile)
+		return "", nil
+	}
+	if !pyenvVersionRegexp.MatchString(v) {
+		return "", gcp.UserErrorf("invalid Python version %q in %s, it must be a version such as 3.11 or 3.11.4", v, PythonVersionFile)
+	}
+	for i := strings.Count(v, "."); i < 2; i++ {
+		v += ".x"
+	}
+	return v, nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+func TestPyenvVersion(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content *string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "no .python-version",
+		},
+		{
+			name:    "single line",
+			content: stringPtr("3.11.4\n"),
+			want:    "3.11.4",
+		},
+		{
+			name:    "multi-line uses the first entry",
+			content: stringPtr("3.12.1\n3.11.7\n3.10.13\n"),
+			want:    "3.12.1",
+		},
+		{
+			name:    "space-separated uses the first entry",
+			content: stringPtr("3.12.1 3.11.7"),
+			want:    "3.12.1",
+		},
+		{
+			name:    "whitespace-padded",
+			content: stringPtr("\n  \t3.10.13  \r\n\n"),
+			want:    "3.10.13",
+		},
+		{
+			name:    "comments are skipped",
+			content: stringPtr("# pinned for production\n3.11.4\n"),
+			want:    "3.11.4",
+		},
+		{
+			name:    "partial version",
+			content: stringPtr("3.11\n"),
+			want:    "3.11.x",
+		},
+		{
+			name:    "major version",
+			content: stringPtr("3"),
+			want:    "3.x.x",
+		},
+		{
+			name:    "empty",
+			content: stringPtr(""),
+		},
+		{
+			name:    "only comments",
+			content: stringPtr("# no version\n"),
+		},
+		{
+			name:    "unparseable",
+			content: stringPtr("pypy3.9-7.3.11\n"),
+			wantErr: true,
+		},
+		{
+			name:    "virtualenv name",
+			content: stringPtr("my-env\n3.11.4\n"),
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tc.content != nil {
+				if err := os.WriteFile(filepath.Join(dir, PythonVersionFile), []byte(*tc.content), 0644); err != nil {
+					t.Fatalf("writing %s: %v", PythonVersionFile, err)
+				}
+			}
+
+			got, err := PyenvVersion(gcp.NewContext(), dir)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("PyenvVersion() got err=%t, want err=%t. err: %v", gotErr, tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Errorf("PyenvVersion()=%q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
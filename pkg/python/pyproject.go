@@ -0,0 +1,152 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// PyProjectTOML is the name of the file with the project metadata.
+const PyProjectTOML = "pyproject.toml"
+
+// pyProject holds the parts of pyproject.toml that declare the supported Python versions, the
+// dependencies and the Poetry scripts.
+type pyProject struct {
+	Project struct {
+		RequiresPython string   `toml:"requires-python"`
+		Dependencies   []string `toml:"dependencies"`
+	} `toml:"project"`
+	Tool struct {
+		Poetry struct {
+			Dependencies map[string]interface{} `toml:"dependencies"`
+			Scripts      map[string]interface{} `toml:"scripts"`
+		} `toml:"poetry"`
+	} `toml:"tool"`
+}
+
+// RequestedPythonVersion returns the Python version constraint declared in the pyproject.toml of
+// dir, converted to a semver constraint. The PEP 621 `[project].requires-python` takes precedence
+// over the Poetry `[tool.poetry.dependencies].python`. It returns an empty string if there is no
+// pyproject.toml or it does not declare a constraint.
+func RequestedPythonVersion(dir string) (string, error) {
+	path := filepath.Join(dir, PyProjectTOML)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return "", nil
+	}
+	var p pyProject
+	if _, err := toml.DecodeFile(path, &p); err != nil {
+		return "", gcp.UserErrorf("parsing %s: %v", PyProjectTOML, err)
+	}
+	if v := strings.TrimSpace(p.Project.RequiresPython); v != "" {
+		c, err := semverConstraint(v)
+		if err != nil {
+			return "", gcp.UserErrorf("invalid requires-python %q in %s: %v", v, PyProjectTOML, err)
+		}
+		return c, nil
+	}
+	if v, ok := p.Tool.Poetry.Dependencies["python"].(string); ok && strings.TrimSpace(v) != "" {
+		c, err := semverConstraint(strings.TrimSpace(v))
+		if err != nil {
+			return "", gcp.UserErrorf("invalid python dependency %q in %s: %v", v, PyProjectTOML, err)
+		}
+		return c, nil
+	}
+	return "", nil
+}
+
+// semverConstraint converts a PEP 440 version specifier, or a Poetry constraint, to the semver
+// constraint syntax used to resolve the runtime version. Operators with the same meaning in both
+// syntaxes are kept as is.
+func semverConstraint(spec string) (string, error) {
+	var alternatives []string
+	for _, alt := range strings.Split(spec, "||") {
+		var clauses []string
+		for _, clause := range strings.Split(alt, ",") {
+			clause = strings.TrimSpace(clause)
+			if clause == "" {
+				continue
+			}
+			c, err := semverClause(clause)
+			if err != nil {
+				return "", err
+			}
+			clauses = append(clauses, c)
+		}
+		if len(clauses) == 0 {
+			return "", fmt.Errorf("empty version constraint")
+		}
+		alternatives = append(alternatives, strings.Join(clauses, ", "))
+	}
+	return strings.Join(alternatives, " || "), nil
+}
+
+func semverClause(clause string) (string, error) {
+	switch {
+	case strings.HasPrefix(clause, "~="):
+		// The compatible release operator: ~=3.10 is >=3.10, <4 and ~=3.10.2 is >=3.10.2, <3.11.
+		v := strings.TrimSpace(clause[2:])
+		parts := strings.Split(v, ".")
+		if len(parts) < 2 {
+			return "", fmt.Errorf("%q requires at least a major and a minor version", clause)
+		}
+		upper := parts[:len(parts)-1]
+		n, err := strconv.Atoi(upper[len(upper)-1])
+		if err != nil {
+			return "", fmt.Errorf("invalid version in %q", clause)
+		}
+		upper = append(append([]string{}, upper[:len(upper)-1]...), strconv.Itoa(n+1))
+		return fmt.Sprintf(">=%s, <%s", padVersion(v), padVersion(strings.Join(upper, "."))), nil
+	case strings.HasPrefix(clause, "==="):
+		return "=" + padVersion(strings.TrimSpace(clause[3:])), nil
+	case strings.HasPrefix(clause, "=="):
+		v := strings.TrimSpace(clause[2:])
+		if strings.HasSuffix(v, ".*") {
+			return strings.TrimSuffix(v, ".*") + ".x", nil
+		}
+		return "=" + padVersion(v), nil
+	}
+	for _, op := range []string{">=", "<=", "!=", ">", "<", "="} {
+		if strings.HasPrefix(clause, op) {
+			return op + padVersion(strings.TrimSpace(clause[len(op):])), nil
+		}
+	}
+	return clause, nil
+}
+
+// padVersion pads a version with missing minor or patch components with zeros, e.g. 3.10 to 3.10.0.
+// A semver constraint treats missing components as wildcards, so that <3.13 would match 3.13.0
+// while PEP 440 compares the missing components as zeros.
+func padVersion(v string) string {
+	parts := strings.Split(v, ".")
+	if len(parts) >= 3 {
+		return v
+	}
+	for _, p := range parts {
+		if _, err := strconv.Atoi(p); err != nil {
+			return v
+		}
+	}
+	for len(parts) < 3 {
+		parts = append(parts, "0")
+	}
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,136 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Masterminds/semver"
+)
+
+func TestRequestedPythonVersion(t *testing.T) {
+	testCases := []struct {
+		name      string
+		pyproject string
+		want      string
+		wantErr   bool
+	}{
+		{
+			name: "no pyproject.toml",
+		},
+		{
+			name:      "PEP 621 requires-python",
+			pyproject: "[project]\nname = \"app\"\nrequires-python = \">=3.10\"\n",
+			want:      ">=3.10.0",
+		},
+		{
+			name:      "Poetry python dependency",
+			pyproject: "[tool.poetry]\nname = \"app\"\n\n[tool.poetry.dependencies]\npython = \"^3.9\"\nflask = \"^2.0\"\n",
+			want:      "^3.9",
+		},
+		{
+			name:      "PEP 621 takes precedence over Poetry",
+			pyproject: "[project]\nrequires-python = \">=3.11,<3.13\"\n\n[tool.poetry.dependencies]\npython = \"^3.9\"\n",
+			want:      ">=3.11.0, <3.13.0",
+		},
+		{
+			name:      "Poetry without python dependency",
+			pyproject: "[tool.poetry.dependencies]\nflask = \"^2.0\"\n",
+		},
+		{
+			name:      "no version metadata",
+			pyproject: "[build-system]\nrequires = [\"setuptools\"]\n",
+		},
+		{
+			name:      "compatible release",
+			pyproject: "[project]\nrequires-python = \"~=3.10\"\n",
+			want:      ">=3.10.0, <4.0.0",
+		},
+		{
+			name:      "invalid compatible release",
+			pyproject: "[project]\nrequires-python = \"~=3\"\n",
+			wantErr:   true,
+		},
+		{
+			name:      "invalid toml",
+			pyproject: "[project\n",
+			wantErr:   true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tc.pyproject != "" {
+				if err := os.WriteFile(filepath.Join(dir, PyProjectTOML), []byte(tc.pyproject), 0644); err != nil {
+					t.Fatalf("writing %s: %v", PyProjectTOML, err)
+				}
+			}
+
+			got, err := RequestedPythonVersion(dir)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("RequestedPythonVersion() got err=%t, want err=%t. err: %v", gotErr, tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Errorf("RequestedPythonVersion()=%q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSemverConstraint(t *testing.T) {
+	testCases := []struct {
+		spec  string
+		want  string
+		match []string
+		miss  []string
+	}{
+		{spec: ">=3.10", want: ">=3.10.0", match: []string{"3.10.0", "3.12.1"}, miss: []string{"3.9.18"}},
+		{spec: ">=3.8, <3.11", want: ">=3.8.0, <3.11.0", match: []string{"3.10.13"}, miss: []string{"3.11.0"}},
+		{spec: "~=3.10", want: ">=3.10.0, <4.0.0", match: []string{"3.12.1"}, miss: []string{"3.9.18", "4.0.0"}},
+		{spec: "~=3.10.2", want: ">=3.10.2, <3.11.0", match: []string{"3.10.13"}, miss: []string{"3.10.1", "3.11.0"}},
+		{spec: "==3.11.*", want: "3.11.x", match: []string{"3.11.7"}, miss: []string{"3.12.0"}},
+		{spec: "==3.11.4", want: "=3.11.4", match: []string{"3.11.4"}, miss: []string{"3.11.5"}},
+		{spec: "!=3.11.0", want: "!=3.11.0", match: []string{"3.11.1"}, miss: []string{"3.11.0"}},
+		{spec: "<3.13", want: "<3.13.0", match: []string{"3.12.9"}, miss: []string{"3.13.0"}},
+		{spec: "^3.9 || ^4.0", want: "^3.9 || ^4.0", match: []string{"3.12.0"}, miss: []string{"2.7.18"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.spec, func(t *testing.T) {
+			got, err := semverConstraint(tc.spec)
+			if err != nil {
+				t.Fatalf("semverConstraint(%q) got error: %v", tc.spec, err)
+			}
+			if got != tc.want {
+				t.Errorf("semverConstraint(%q)=%q, want %q", tc.spec, got, tc.want)
+			}
+			c, err := semver.NewConstraint(got)
+			if err != nil {
+				t.Fatalf("semver.NewConstraint(%q) got error: %v", got, err)
+			}
+			for _, v := range tc.match {
+				if !c.Check(semver.MustParse(v)) {
+					t.Errorf("constraint %q does not match %s", got, v)
+				}
+			}
+			for _, v := range tc.miss {
+				if c.Check(semver.MustParse(v)) {
+					t.Errorf("constraint %q matches %s", got, v)
+				}
+			}
+		})
+	}
+}
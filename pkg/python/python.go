@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
 
@@ -39,10 +40,16 @@ const (
 	expiryTimestampKey = "expiry_timestamp"
 
 	cacheName = "pipcache"
+	// pipCacheMaxBytes is the size that the pip cache layer is pruned to after each install.
+	pipCacheMaxBytes = 1 << 30
 
 	// RequirementsFilesEnv is an environment variable containg os-path-separator-separated list of paths to pip requirements files.
 	// The requirements files are processed from left to right, with requirements from the next overriding any conflicts from the previous.
 	RequirementsFilesEnv = "GOOGLE_INTERNAL_REQUIREMENTS_FILES"
+
+	// VenvEnv is an environment variable that installs the dependencies into a virtual environment in
+	// the dependency layer instead of the user site-packages directory.
+	VenvEnv = "GOOGLE_PYTHON_VENV"
 )
 
 var (
@@ -54,6 +61,9 @@ var (
 	RequirementsProvidesPlan = libcnb.BuildPlan{Provides: RequirementsProvides}
 	// RequirementsProvidesRequiresPlan is a build plan returned by buildpacks that consume requirements.txt.
 	RequirementsProvidesRequiresPlan = libcnb.BuildPlan{Provides: RequirementsProvides, Requires: RequirementsRequires}
+
+	// outdatedPip matches the warning that pip prints when a newer version of pip is available.
+	outdatedPip = regexp.MustCompile(`You are using pip version (\S+?);? however, version (\S+?)\.? is available`)
 )
 
 // Version returns the installed version of Python.
@@ -62,6 +72,17 @@ func Version(ctx *gcp.Context) string {
 	return strings.TrimSpace(result.Stdout)
 }
 
+// PipVersion returns the version of pip of the installed Python, e.g. 23.0.1, or "" if it cannot be
+// determined.
+func PipVersion(ctx *gcp.Context) string {
+	// The output looks like "pip 23.0.1 from /usr/lib/python3/dist-packages/pip (python 3.11)".
+	fields := strings.Fields(ctx.Exec([]string{"python3", "-m", "pip", "--version"}).Stdout)
+	if len(fields) < 2 || fields[0] != "pip" {
+		return ""
+	}
+	return fields[1]
+}
+
 // InstallRequirements installs dependencies from the given requirements files in a virtual env.
 // It will install the files in order in which they are specified, so that dependencies specified
 // in later requirements files can override later ones.
@@ -72,8 +93,21 @@ func Version(ctx *gcp.Context) string {
 // accidentally override some builtin stdlib modules, e.g. typing, enum, etc., and cause both
 // build-time and run-time failures.
 func InstallRequirements(ctx *gcp.Context, l *libcnb.Layer, reqs ...string) error {
+	return install(ctx, l, false, reqs...)
+}
+
+// InstallPackage installs the given requirements files like InstallRequirements, then the
+// setuptools package in the application root with `pip install --editable .`, which also installs
+// the dependencies that the package declares. The package is installed in editable mode so that it
+// always runs the application source, and only needs to be reinstalled when its setup.py,
+// setup.cfg or pyproject.toml changes.
+func InstallPackage(ctx *gcp.Context, l *libcnb.Layer, reqs ...string) error {
+	return install(ctx, l, true, reqs...)
+}
+
+func install(ctx *gcp.Context, l *libcnb.Layer, pkg bool, reqs ...string) error {
 	// Defensive check, this should not happen in practice.
-	if len(reqs) == 0 {
+	if len(reqs) == 0 && !pkg {
 		ctx.Debugf("No requirements.txt to install, clearing layer.")
 		if err := ctx.ClearLayer(l); err != nil {
 			return fmt.Errorf("clearing layer %q: %w", l.Name, err)
@@ -81,8 +115,33 @@ func InstallRequirements(ctx *gcp.Context, l *libcnb.Layer, reqs ...string) erro
 		return nil
 	}
 
+	constraints, err := ConstraintsFile(ctx)
+	if err != nil {
+		return err
+	}
+	venv, err := env.IsPresentAndTrue(VenvEnv)
+	if err != nil {
+		return gcp.UserErrorf("%v", err)
+	}
+
 	// Check if we can use the cached-layer as is without reinstalling dependencies.
-	cached, err := checkCache(ctx, l, cache.WithFiles(reqs...))
+	files, err := dependencyFiles(constraints, reqs...)
+	if err != nil {
+		return err
+	}
+	if pkg {
+		pf, err := packageFiles(ctx.ApplicationRoot())
+		if err != nil {
+			return err
+		}
+		files = append(files, pf...)
+	}
+	cacheOpts := []cache.Option{cache.WithFiles(files...)}
+	if venv {
+		// The layout of the layer differs with a virtual environment.
+		cacheOpts = append(cacheOpts, cache.WithStrings(VenvEnv))
+	}
+	cached, err := checkCache(ctx, l, cacheOpts...)
 	if err != nil {
 		return fmt.Errorf("checking cache: %w", err)
 	}
@@ -118,20 +177,17 @@ func InstallRequirements(ctx *gcp.Context, l *libcnb.Layer, reqs ...string) erro
 	// the functions-framework-pinned package).
 
 	// HACK: For backwards compatibility with Python 3.7 and 3.8 on App Engine and Cloud Functions.
-	virtualEnv := requiresVirtualEnv()
-	if virtualEnv {
+	legacyVirtualEnv := requiresVirtualEnv()
+	virtualEnv := venv || legacyVirtualEnv
+	if legacyVirtualEnv {
 		// --without-pip and --system-site-packages allow us to use `pip` and other packages from the
 		// build image and avoid reinstalling them, saving about 10MB.
 		// TODO(b/140775593): Use virtualenv pip after FTL is no longer used and remove from build image.
-		ctx.Exec([]string{"python3", "-m", "venv", "--without-pip", "--system-site-packages", l.Path})
-		// The VIRTUAL_ENV variable is usually set by the virtual environment's activate script.
-		l.SharedEnvironment.Override("VIRTUAL_ENV", l.Path)
-		// Use the virtual environment python3 for all subsequent commands in this buildpack, for
-		// subsequent buildpacks, l.Path/bin will be added by lifecycle.
-		if err := ctx.Setenv("PATH", filepath.Join(l.Path, "bin")+string(os.PathListSeparator)+os.Getenv("PATH")); err != nil {
+		if err := createVenv(ctx, l, "--without-pip", "--system-site-packages"); err != nil {
 			return err
 		}
-		if err := ctx.Setenv("VIRTUAL_ENV", l.Path); err != nil {
+	} else if venv {
+		if err := CreateVenv(ctx, l); err != nil {
 			return err
 		}
 	} else {
@@ -142,22 +198,18 @@ func InstallRequirements(ctx *gcp.Context, l *libcnb.Layer, reqs ...string) erro
 	}
 
 	for _, req := range reqs {
-		cmd := []string{
-			"python3", "-m", "pip", "install",
-			"--requirement", req,
-			"--upgrade",
-			"--upgrade-strategy", "only-if-needed",
-			"--no-warn-script-location", // bin is added at run time by lifecycle.
-			"--no-warn-conflicts",       // Needed for python37 which allowed users to override dependencies. For newer versions, we do a separate `pip check`.
-			"--force-reinstall",         // Some dependencies may be in the build image but not run image. Later requirements.txt should override earlier.
-			"--no-compile",              // Prevent default timestamp-based bytecode compilation. Deterministic pycs are generated in a second step below.
+		if err := installRequirementsFile(ctx, cl, virtualEnv, constraints, req); err != nil {
+			return err
 		}
-		if !virtualEnv {
-			cmd = append(cmd, "--user") // Install into user site-packages directory.
+	}
+	if pkg {
+		ctx.Logf("Installing the package in %s.", ctx.ApplicationRoot())
+		if err := pipInstall(ctx, cl, virtualEnv, constraints, "--editable", ctx.ApplicationRoot()); err != nil {
+			return err
 		}
-		ctx.Exec(cmd,
-			gcp.WithEnv("PIP_CACHE_DIR="+cl.Path, "PIP_DISABLE_PIP_VERSION_CHECK=1"),
-			gcp.WithUserAttribution)
+	}
+	if err := cache.PruneBySize(ctx, cl.Path, pipCacheMaxBytes); err != nil {
+		return fmt.Errorf("pruning the pip cache: %w", err)
 	}
 
 	// Generate deterministic hash-based pycs (https://www.python.org/dev/peps/pep-0552/).
@@ -183,6 +235,83 @@ func InstallRequirements(ctx *gcp.Context, l *libcnb.Layer, reqs ...string) erro
 	return nil
 }
 
+// installRequirementsFile installs the requirements file req, with hash checking if it is required.
+// VCS and editable requirements, which cannot be hashed, are then only allowed with
+// GOOGLE_PIP_ALLOW_UNHASHED_VCS, and are installed after the other requirements without hash
+// checking and without their dependencies, which must be hashed in req.
+func installRequirementsFile(ctx *gcp.Context, cl *libcnb.Layer, virtualEnv bool, constraints, req string) error {
+	r, err := ClassifyRequirements(req)
+	if err != nil {
+		return err
+	}
+	if err := checkGit(r, req); err != nil {
+		return err
+	}
+	requireHashes, err := RequireHashes(ctx, req)
+	if err != nil {
+		return err
+	}
+	unhashable := r.unhashableArgs()
+	if !requireHashes || len(unhashable) == 0 {
+		args := []string{"--requirement", req}
+		if requireHashes {
+			args = append(args, "--require-hashes")
+		}
+		return pipInstall(ctx, cl, virtualEnv, constraints, args...)
+	}
+	hashed, err := writeHashedRequirements(req)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(hashed)
+	if err := pipInstall(ctx, cl, virtualEnv, constraints, "--requirement", hashed, "--require-hashes"); err != nil {
+		return err
+	}
+	ctx.Warnf("Installing the VCS and editable requirements of %s without hash checking because %s is set: %s", req, AllowUnhashedVCSEnv, strings.Join(append(append([]string{}, r.VCS...), r.Editable...), ", "))
+	return pipInstall(ctx, cl, virtualEnv, constraints, append(unhashable, "--no-deps")...)
+}
+
+// pipInstall runs `pip install` with the given arguments, using the cache layer cl as the pip cache.
+// pip only checks whether it is outdated if GOOGLE_STRICT_DEPS is true, in which case the build
+// fails if it is.
+func pipInstall(ctx *gcp.Context, cl *libcnb.Layer, virtualEnv bool, constraints string, args ...string) error {
+	cmd := append([]string{"python3", "-m", "pip", "install"}, args...)
+	cmd = append(cmd,
+		"--upgrade",
+		"--upgrade-strategy", "only-if-needed",
+		"--no-warn-script-location", // bin is added at run time by lifecycle.
+		"--no-warn-conflicts",       // Needed for python37 which allowed users to override dependencies. For newer versions, we do a separate `pip check`.
+		"--force-reinstall",         // Some dependencies may be in the build image but not run image. Later requirements.txt should override earlier.
+		"--no-compile",              // Prevent default timestamp-based bytecode compilation. Deterministic pycs are generated in a second step below.
+	)
+	if !virtualEnv {
+		cmd = append(cmd, "--user") // Install into user site-packages directory.
+	}
+	if constraints != "" {
+		cmd = append(cmd, "--constraint", constraints)
+	}
+	strict, err := env.IsPresentAndTrue(env.StrictDeps)
+	if err != nil {
+		return gcp.UserErrorf("%v", err)
+	}
+	envs := []string{"PIP_CACHE_DIR=" + cl.Path}
+	if !strict {
+		envs = append(envs, "PIP_DISABLE_PIP_VERSION_CHECK=1")
+	}
+	result := ctx.Exec(cmd, gcp.WithEnv(envs...), gcp.WithUserAttribution)
+	return CheckPipVersion(ctx, result.Combined)
+}
+
+// CheckPipVersion warns if the output of a pip command reports that pip is outdated, or fails if
+// GOOGLE_STRICT_DEPS is true.
+func CheckPipVersion(ctx *gcp.Context, output string) error {
+	m := outdatedPip.FindStringSubmatch(output)
+	if m == nil {
+		return nil
+	}
+	return ctx.StrictWarnf("pip %s is outdated, version %s is available.", m[1], m[2])
+}
+
 // checkCache checks whether cached dependencies exist, match, and have not expired.
 func checkCache(ctx *gcp.Context, l *libcnb.Layer, opts ...cache.Option) (bool, error) {
 	currentPythonVersion := Version(ctx)
@@ -235,6 +364,31 @@ func cacheExpired(ctx *gcp.Context, l *libcnb.Layer) bool {
 	return !t.After(time.Now())
 }
 
+// CreateVenv creates a self-contained virtual environment in the layer, with its own pip and
+// without access to the system site-packages, and activates it for the rest of the build. The bin
+// directory of the virtual environment is added to PATH at launch.
+func CreateVenv(ctx *gcp.Context, l *libcnb.Layer) error {
+	ctx.Logf("Creating a virtual environment in %s.", l.Path)
+	return createVenv(ctx, l)
+}
+
+func createVenv(ctx *gcp.Context, l *libcnb.Layer, args ...string) error {
+	cmd := append([]string{"python3", "-m", "venv"}, args...)
+	if _, err := ctx.ExecWithErr(append(cmd, l.Path)); err != nil {
+		return err
+	}
+	bin := filepath.Join(l.Path, "bin")
+	// The VIRTUAL_ENV variable is usually set by the virtual environment's activate script.
+	l.SharedEnvironment.Override("VIRTUAL_ENV", l.Path)
+	l.LaunchEnvironment.Prepend("PATH", string(os.PathListSeparator), bin)
+	// Use the virtual environment python3 for all subsequent commands in this buildpack, for
+	// subsequent buildpacks, l.Path/bin will be added by lifecycle.
+	if err := ctx.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH")); err != nil {
+		return err
+	}
+	return ctx.Setenv("VIRTUAL_ENV", l.Path)
+}
+
 // requiresVirtualEnv returns true for runtimes that require a virtual environment to be created before pip install.
 // We cannot use Python per-user site-packages (https://www.python.org/dev/peps/pep-0370/),
 // because Python 3.7 and 3.8 on App Engine and Cloud Functions have a virtualenv set up
@@ -0,0 +1,203 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCreateVenv(t *testing.T) {
+	// CreateVenv activates the virtual environment for the rest of the build.
+	t.Setenv("PATH", os.Getenv("PATH"))
+	t.Setenv("VIRTUAL_ENV", "")
+	var got [][]string
+	execCmd := func(name string, args ...string) *exec.Cmd {
+		got = append(got, append([]string{name}, args...))
+		return exec.Command("true")
+	}
+	ctx := gcp.NewContext(gcp.WithExecCmd(execCmd))
+	l := &libcnb.Layer{
+		Name:              "pip",
+		Path:              t.TempDir(),
+		Metadata:          map[string]interface{}{},
+		SharedEnvironment: libcnb.Environment{},
+		LaunchEnvironment: libcnb.Environment{},
+	}
+
+	if err := CreateVenv(ctx, l); err != nil {
+		t.Fatalf("CreateVenv() got error: %v", err)
+	}
+
+	if diff := cmp.Diff([][]string{{"python3", "-m", "venv", l.Path}}, got); diff != "" {
+		t.Errorf("CreateVenv() commands mismatch (-want +got):\n%s", diff)
+	}
+	if got, want := l.SharedEnvironment["VIRTUAL_ENV.override"], l.Path; got != want {
+		t.Errorf("VIRTUAL_ENV = %q, want %q", got, want)
+	}
+	bin := filepath.Join(l.Path, "bin")
+	if got := l.LaunchEnvironment["PATH.prepend"]; got != bin {
+		t.Errorf("launch PATH prepends %q, want %q", got, bin)
+	}
+	if got := filepath.SplitList(os.Getenv("PATH"))[0]; got != bin {
+		t.Errorf("build PATH starts with %q, want %q", got, bin)
+	}
+	if got := os.Getenv("VIRTUAL_ENV"); got != l.Path {
+		t.Errorf("build VIRTUAL_ENV = %q, want %q", got, l.Path)
+	}
+}
+
+func TestInstallRequirementsPipCacheDir(t *testing.T) {
+	// The install sets PYTHONUSERBASE for the rest of the build.
+	t.Setenv("PYTHONUSERBASE", "")
+	t.Setenv("GOOGLE_RUNTIME", "")
+	t.Setenv(VenvEnv, "false")
+	t.Setenv(ConstraintsEnv, "")
+	// An existing .netrc skips the lookup of Artifact Registry credentials.
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.WriteFile(filepath.Join(home, ".netrc"), nil, 0600); err != nil {
+		t.Fatalf("writing .netrc: %v", err)
+	}
+	appDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(appDir, "requirements.txt"), []byte("flask\n"), 0644); err != nil {
+		t.Fatalf("writing requirements.txt: %v", err)
+	}
+	// Each pip install records the pip cache directory it is run with.
+	envFile := filepath.Join(t.TempDir(), "pip-cache-dir")
+	execCmd := func(name string, args ...string) *exec.Cmd {
+		switch {
+		case len(args) > 0 && args[0] == "--version":
+			return exec.Command("echo", "Python 3.11.4")
+		case len(args) > 2 && args[1] == "pip":
+			return exec.Command("sh", "-c", `echo "$PIP_CACHE_DIR" >> `+envFile)
+		}
+		return exec.Command("true")
+	}
+	layersDir := t.TempDir()
+	ctx := gcp.NewContext(gcp.WithApplicationRoot(appDir), gcp.WithExecCmd(execCmd), gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: layersDir}}))
+	l, err := ctx.Layer("pip", gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayer)
+	if err != nil {
+		t.Fatalf("creating layer: %v", err)
+	}
+
+	if err := InstallRequirements(ctx, l, filepath.Join(appDir, "requirements.txt")); err != nil {
+		t.Fatalf("InstallRequirements() got error: %v", err)
+	}
+
+	got, err := os.ReadFile(envFile)
+	if err != nil {
+		t.Fatalf("reading the recorded PIP_CACHE_DIR: %v", err)
+	}
+	// The cache layer is separate from the layer of the installed packages.
+	if want := filepath.Join(layersDir, cacheName) + "\n"; string(got) != want {
+		t.Errorf("pip install PIP_CACHE_DIR = %q, want %q", got, want)
+	}
+}
+
+func TestPipVersion(t *testing.T) {
+	testCases := []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{
+			name:   "pip",
+			output: "pip 23.0.1 from /layers/google.python.runtime/python/lib/python3.11/site-packages/pip (python 3.11)",
+			want:   "23.0.1",
+		},
+		{
+			name:   "unexpected output",
+			output: "No module named pip",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			execCmd := func(name string, args ...string) *exec.Cmd {
+				return exec.Command("echo", tc.output)
+			}
+			ctx := gcp.NewContext(gcp.WithExecCmd(execCmd))
+
+			if got := PipVersion(ctx); got != tc.want {
+				t.Errorf("PipVersion() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCheckPipVersion(t *testing.T) {
+	const outdated = "Successfully installed flask-2.2.2\nWARNING: You are using pip version 21.0.1; however, version 22.3.1 is available.\n"
+	testCases := []struct {
+		name        string
+		output      string
+		strictDeps  string
+		wantErr     bool
+		wantWarning bool
+	}{
+		{
+			name:   "up to date",
+			output: "Successfully installed flask-2.2.2\n",
+		},
+		{
+			name:       "up to date in strict mode",
+			output:     "Successfully installed flask-2.2.2\n",
+			strictDeps: "true",
+		},
+		{
+			name:        "outdated",
+			output:      outdated,
+			wantWarning: true,
+		},
+		{
+			name:       "outdated in strict mode",
+			output:     outdated,
+			strictDeps: "true",
+			wantErr:    true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(env.StrictDeps, tc.strictDeps)
+			if tc.strictDeps == "" {
+				os.Unsetenv(env.StrictDeps)
+			}
+			var buf bytes.Buffer
+			ctx := gcp.NewContext(gcp.WithLogger(log.New(&buf, "", 0)))
+
+			err := CheckPipVersion(ctx, tc.output)
+
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("CheckPipVersion(%q) got error: %v, want error: %t", tc.output, err, tc.wantErr)
+			}
+			want := "pip 21.0.1 is outdated, version 22.3.1 is available."
+			if tc.wantErr && !strings.Contains(err.Error(), want) {
+				t.Errorf("CheckPipVersion(%q) got error %q, want it to contain %q", tc.output, err, want)
+			}
+			if gotWarning := strings.Contains(buf.String(), "WARNING: "+want); gotWarning != tc.wantWarning {
+				t.Errorf("CheckPipVersion(%q) logged warning: %t, want %t, output:\n%s", tc.output, gotWarning, tc.wantWarning, buf.String())
+			}
+		})
+	}
+}
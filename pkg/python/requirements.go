@@ -0,0 +1,211 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// AllowUnhashedVCSEnv is an environment variable that allows VCS and editable requirements, which
+// cannot be hashed, in requirements files that are installed with `pip install --require-hashes`.
+// They are then installed separately, without hash checking.
+const AllowUnhashedVCSEnv = "GOOGLE_PIP_ALLOW_UNHASHED_VCS"
+
+const (
+	requirementPyPI     = "pypi"
+	requirementVCS      = "vcs"
+	requirementURL      = "url"
+	requirementEditable = "editable"
+	requirementOption   = "option"
+)
+
+var (
+	// vcsPrefixes are the schemes of the version control systems supported by pip.
+	vcsPrefixes = []string{"git+", "hg+", "svn+", "bzr+"}
+	// directReferenceRegexp matches a PEP 508 direct reference, e.g. `pkg @ https://...`.
+	directReferenceRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*(\[[^\]]*\])?\s*@\s*(\S+)`)
+	// archiveSuffixes are the extensions of the distribution archives that pip installs from a path.
+	archiveSuffixes = []string{".whl", ".tar.gz", ".tgz", ".zip"}
+
+	// lookPath finds a binary on the PATH. It can be overridden for testing.
+	lookPath = exec.LookPath
+)
+
+// Requirements are the entries of a requirements file by where they are installed from.
+type Requirements struct {
+	// PyPI are the names of the projects installed from a package index.
+	PyPI []string
+	// VCS are the requirements installed from a version control system, e.g.
+	// `git+https://github.com/org/repo@v1.0#egg=repo` or `repo @ git+https://...`.
+	VCS []string
+	// URL are the requirements installed from an archive by URL or local path.
+	URL []string
+	// Editable are the paths or VCS URLs of the requirements installed with -e or --editable.
+	Editable []string
+}
+
+// ClassifyRequirements separates the entries of the requirements file at path into PyPI, VCS, URL
+// and editable requirements. Options such as --index-url and nested requirements files are ignored.
+func ClassifyRequirements(path string) (*Requirements, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, gcp.InternalErrorf("reading %s: %v", path, err)
+	}
+	r := &Requirements{}
+	for _, line := range requirementLines(string(content)) {
+		kind, value := classifyRequirement(line)
+		switch kind {
+		case requirementPyPI:
+			r.PyPI = append(r.PyPI, value)
+		case requirementVCS:
+			r.VCS = append(r.VCS, value)
+		case requirementURL:
+			r.URL = append(r.URL, value)
+		case requirementEditable:
+			r.Editable = append(r.Editable, value)
+		}
+	}
+	return r, nil
+}
+
+// NeedsGit returns true if any of the requirements is installed from a git repository.
+func (r *Requirements) NeedsGit() bool {
+	for _, req := range append(append([]string{}, r.VCS...), r.Editable...) {
+		if strings.Contains(req, "git+") {
+			return true
+		}
+	}
+	return false
+}
+
+// unhashableArgs returns the `pip install` arguments of the VCS and editable requirements, for
+// which pip cannot verify hashes.
+func (r *Requirements) unhashableArgs() []string {
+	args := append([]string{}, r.VCS...)
+	for _, e := range r.Editable {
+		args = append(args, "--editable", e)
+	}
+	return args
+}
+
+// classifyRequirement returns the kind of a logical line of a requirements file and its value:
+// the project name of PyPI requirements, the requirement without its options for VCS and URL
+// requirements, and the path or URL of editable requirements.
+func classifyRequirement(line string) (string, string) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return requirementOption, ""
+	}
+	switch {
+	case fields[0] == "-e" || fields[0] == "--editable":
+		if len(fields) < 2 {
+			return requirementOption, ""
+		}
+		return requirementEditable, fields[1]
+	case strings.HasPrefix(fields[0], "--editable="):
+		return requirementEditable, strings.TrimPrefix(fields[0], "--editable=")
+	case strings.HasPrefix(line, "-"):
+		return requirementOption, ""
+	}
+	// The requirement ends at its first option, e.g. --hash.
+	var spec []string
+	for _, f := range fields {
+		if strings.HasPrefix(f, "-") {
+			break
+		}
+		spec = append(spec, f)
+	}
+	req := strings.Join(spec, " ")
+	location := fields[0]
+	if m := directReferenceRegexp.FindStringSubmatch(req); m != nil {
+		location = m[2]
+	}
+	for _, p := range vcsPrefixes {
+		if strings.HasPrefix(location, p) {
+			return requirementVCS, req
+		}
+	}
+	if strings.Contains(location, "://") || strings.HasPrefix(location, ".") || strings.HasPrefix(location, "/") {
+		return requirementURL, req
+	}
+	for _, s := range archiveSuffixes {
+		if strings.HasSuffix(location, s) {
+			return requirementURL, req
+		}
+	}
+	if m := requirementNameRegexp.FindString(line); m != "" {
+		return requirementPyPI, m
+	}
+	return requirementPyPI, req
+}
+
+// isUnhashable returns true if pip cannot verify the hash of the requirement, as returned by
+// ScanRequirementHashes.
+func isUnhashable(req string) bool {
+	kind, _ := classifyRequirement(req)
+	return kind == requirementVCS || kind == requirementEditable
+}
+
+// checkGit returns a user error if the requirements need git and it is not installed.
+func checkGit(r *Requirements, path string) error {
+	if !r.NeedsGit() {
+		return nil
+	}
+	if _, err := lookPath("git"); err != nil {
+		return gcp.UserErrorf("%s has requirements that are installed from git repositories, but git is not installed: %s", path, strings.Join(append(append([]string{}, r.VCS...), r.Editable...), ", "))
+	}
+	return nil
+}
+
+// allowUnhashedVCS returns true if GOOGLE_PIP_ALLOW_UNHASHED_VCS is true.
+func allowUnhashedVCS() (bool, error) {
+	allowed, err := env.IsPresentAndTrue(AllowUnhashedVCSEnv)
+	if err != nil {
+		return false, gcp.UserErrorf("%v", err)
+	}
+	return allowed, nil
+}
+
+// writeHashedRequirements writes a copy of the requirements file at path without its VCS and
+// editable requirements, next to it so that relative paths in it are resolved the same way, and
+// returns its path. The caller removes the copy.
+func writeHashedRequirements(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", gcp.InternalErrorf("reading %s: %v", path, err)
+	}
+	var lines []string
+	for _, line := range requirementLines(string(content)) {
+		if !isUnhashable(line) {
+			lines = append(lines, line)
+		}
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), ".hashed-requirements-*.txt")
+	if err != nil {
+		return "", gcp.InternalErrorf("creating a copy of %s: %v", path, err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(strings.Join(lines, "\n") + "\n"); err != nil {
+		return "", gcp.InternalErrorf("writing a copy of %s: %v", path, err)
+	}
+	return f.Name(), nil
+}
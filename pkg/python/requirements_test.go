@@ -0,0 +1,163 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestClassifyRequirements(t *testing.T) {
+	testCases := []struct {
+		name         string
+		requirements string
+		want         Requirements
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:         "pypi",
+			requirements: "flask==2.0.1\nuvicorn[standard]>=0.15 ; python_version >= \"3.7\"\n",
+			want:         Requirements{PyPI: []string{"flask", "uvicorn"}},
+		},
+		{
+			name: "vcs",
+			requirements: "git+https://github.com/org/repo@v1.0#egg=repo\n" +
+				"other @ git+ssh://git@github.com/org/other.git\n" +
+				"hg+https://hg.example.com/project#egg=project\n",
+			want: Requirements{VCS: []string{
+				"git+https://github.com/org/repo@v1.0#egg=repo",
+				"other @ git+ssh://git@github.com/org/other.git",
+				"hg+https://hg.example.com/project#egg=project",
+			}},
+		},
+		{
+			name: "url",
+			requirements: "https://example.com/pkg-1.0-py3-none-any.whl --hash=sha256:aaaa\n" +
+				"pkg @ https://example.com/pkg-1.0.tar.gz\n" +
+				"./wheels/local-1.0-py3-none-any.whl\n" +
+				"dist/archive.tar.gz\n",
+			want: Requirements{URL: []string{
+				"https://example.com/pkg-1.0-py3-none-any.whl",
+				"pkg @ https://example.com/pkg-1.0.tar.gz",
+				"./wheels/local-1.0-py3-none-any.whl",
+				"dist/archive.tar.gz",
+			}},
+		},
+		{
+			name:         "editable",
+			requirements: "-e ./local\n--editable git+https://github.com/org/repo#egg=repo\n--editable=../sibling\n",
+			want:         Requirements{Editable: []string{"./local", "git+https://github.com/org/repo#egg=repo", "../sibling"}},
+		},
+		{
+			name: "mixed with options and comments",
+			requirements: "--index-url https://example.com/simple\n-r other.txt\n# a comment\n" +
+				"flask==2.0.1 --hash=sha256:aaaa\ngit+https://github.com/org/repo#egg=repo  # pinned\n-e .\n",
+			want: Requirements{
+				PyPI:     []string{"flask"},
+				VCS:      []string{"git+https://github.com/org/repo#egg=repo"},
+				Editable: []string{"."},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := writeRequirements(t, tc.requirements)
+
+			got, err := ClassifyRequirements(path)
+			if err != nil {
+				t.Fatalf("ClassifyRequirements() got error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, *got); diff != "" {
+				t.Errorf("ClassifyRequirements() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCheckGit(t *testing.T) {
+	testCases := []struct {
+		name         string
+		requirements Requirements
+		gitInstalled bool
+		wantErr      bool
+	}{
+		{
+			name:         "no vcs",
+			requirements: Requirements{PyPI: []string{"flask"}, URL: []string{"https://example.com/pkg.whl"}},
+		},
+		{
+			name:         "git with git installed",
+			requirements: Requirements{VCS: []string{"git+https://github.com/org/repo#egg=repo"}},
+			gitInstalled: true,
+		},
+		{
+			name:         "git without git installed",
+			requirements: Requirements{VCS: []string{"git+https://github.com/org/repo#egg=repo"}},
+			wantErr:      true,
+		},
+		{
+			name:         "editable git without git installed",
+			requirements: Requirements{Editable: []string{"git+https://github.com/org/repo#egg=repo"}},
+			wantErr:      true,
+		},
+		{
+			name:         "mercurial without git installed",
+			requirements: Requirements{VCS: []string{"hg+https://hg.example.com/project#egg=project"}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			origLookPath := lookPath
+			lookPath = func(file string) (string, error) {
+				if tc.gitInstalled {
+					return "/usr/bin/" + file, nil
+				}
+				return "", errors.New("not found")
+			}
+			t.Cleanup(func() { lookPath = origLookPath })
+
+			err := checkGit(&tc.requirements, "requirements.txt")
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("checkGit() got error: %v, want error: %t", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestWriteHashedRequirements(t *testing.T) {
+	path := writeRequirements(t, "-r other.txt\nflask==2.0.1 \\\n    --hash=sha256:aaaa\ngit+https://github.com/org/repo#egg=repo\n-e ./local\n")
+
+	hashed, err := writeHashedRequirements(path)
+	if err != nil {
+		t.Fatalf("writeHashedRequirements() got error: %v", err)
+	}
+	if got, want := filepath.Dir(hashed), filepath.Dir(path); got != want {
+		t.Errorf("writeHashedRequirements() wrote to directory %q, want %q", got, want)
+	}
+	content, err := os.ReadFile(hashed)
+	if err != nil {
+		t.Fatalf("reading %s: %v", hashed, err)
+	}
+	want := "-r other.txt\nflask==2.0.1      --hash=sha256:aaaa\n"
+	if diff := cmp.Diff(want, string(content)); diff != "" {
+		t.Errorf("writeHashedRequirements() content mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// WSGI is the interface of synchronous web frameworks such as Flask and Django, served by gunicorn.
+	WSGI = "wsgi"
+	// ASGI is the interface of asynchronous web frameworks such as FastAPI, served by gunicorn with
+	// uvicorn workers.
+	ASGI = "asgi"
+)
+
+var (
+	// serverFrameworks maps the import names of web frameworks to the interface they implement.
+	serverFrameworks = map[string]string{
+		"bottle":    WSGI,
+		"django":    WSGI,
+		"flask":     WSGI,
+		"pyramid":   WSGI,
+		"fastapi":   ASGI,
+		"litestar":  ASGI,
+		"quart":     ASGI,
+		"starlette": ASGI,
+	}
+
+	importRegexp = regexp.MustCompile(`(?m)^\s*(?:from|import)\s+([A-Za-z_][A-Za-z0-9_]*)`)
+)
+
+// ServerInterface classifies the application in dir as WSGI or ASGI by the web frameworks that
+// its top-level Python files import, or, if they import none, that requirements.txt lists. It
+// returns "" if no known framework is used, or if frameworks of both interfaces are used, which
+// requires an explicit entrypoint. The detected frameworks are returned as well, sorted.
+func ServerInterface(dir string) (string, []string, error) {
+	frameworks, err := importedFrameworks(dir)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(frameworks) == 0 {
+		if frameworks, err = requiredFrameworks(filepath.Join(dir, "requirements.txt")); err != nil {
+			return "", nil, err
+		}
+	}
+	interfaces := map[string]bool{}
+	for _, f := range frameworks {
+		interfaces[serverFrameworks[f]] = true
+	}
+	if len(interfaces) != 1 {
+		return "", frameworks, nil
+	}
+	return serverFrameworks[frameworks[0]], frameworks, nil
+}
+
+// DefaultEntrypoint returns the shell command that serves the application in dir with the given
+// interface, or "" if the application module cannot be determined. Django projects are served by
+// the wsgi.py or asgi.py module of their project package, other applications are expected to
+// define `app` in main.py.
+func DefaultEntrypoint(dir, iface string) (string, error) {
+	var worker string
+	switch iface {
+	case WSGI:
+	case ASGI:
+		worker = " -k uvicorn.workers.UvicornWorker"
+	default:
+		return "", nil
+	}
+	app, err := appModule(dir, iface)
+	if err != nil || app == "" {
+		return "", err
+	}
+	return fmt.Sprintf("exec gunicorn -b :$PORT%s %s", worker, app), nil
+}
+
+// appModule returns the gunicorn application path of the application in dir, e.g. main:app.
+func appModule(dir, iface string) (string, error) {
+	django, err := IsDjango(dir)
+	if err != nil {
+		return "", err
+	}
+	if !django {
+		if _, err := os.Stat(filepath.Join(dir, "main.py")); err == nil {
+			return "main:app", nil
+		} else if !os.IsNotExist(err) {
+			return "", gcp.InternalErrorf("stating main.py: %v", err)
+		}
+	}
+	// Also serve Django projects whose dependency on django is not declared.
+	module, err := djangoModule(dir, iface)
+	if err != nil || module == "" {
+		return "", err
+	}
+	return module + ":application", nil
+}
+
+// importedFrameworks returns the web frameworks imported by the top-level Python files of dir.
+func importedFrameworks(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.py"))
+	if err != nil {
+		return nil, gcp.InternalErrorf("finding Python files: %v", err)
+	}
+	found := map[string]bool{}
+	for _, f := range files {
+		content, err := os.ReadFile(f)
+		if err != nil {
+			return nil, gcp.InternalErrorf("reading %s: %v", f, err)
+		}
+		for _, m := range importRegexp.FindAllStringSubmatch(string(content), -1) {
+			if _, ok := serverFrameworks[m[1]]; ok {
+				found[m[1]] = true
+			}
+		}
+	}
+	return sortedKeys(found), nil
+}
+
+// requiredFrameworks returns the web frameworks listed in the requirements file at path.
+func requiredFrameworks(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, gcp.InternalErrorf("opening %s: %v", path, err)
+	}
+	defer f.Close()
+	found := map[string]bool{}
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		name := strings.ToLower(requirementNameRegexp.FindString(strings.TrimSpace(s.Text())))
+		if _, ok := serverFrameworks[name]; ok {
+			found[name] = true
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, gcp.InternalErrorf("reading %s: %v", path, err)
+	}
+	return sortedKeys(found), nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,124 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestServerInterface(t *testing.T) {
+	testCases := []struct {
+		name           string
+		files          map[string]string
+		want           string
+		wantFrameworks []string
+		wantEntrypoint string
+	}{
+		{
+			name:           "flask",
+			files:          map[string]string{"main.py": "from flask import Flask\napp = Flask(__name__)\n"},
+			want:           WSGI,
+			wantFrameworks: []string{"flask"},
+			wantEntrypoint: "exec gunicorn -b :$PORT main:app",
+		},
+		{
+			name: "django",
+			files: map[string]string{
+				"manage.py":          "import os\nfrom django.core.management import execute_from_command_line\n",
+				"mysite/settings.py": "DEBUG = False\n",
+				"mysite/wsgi.py":     "from django.core.wsgi import get_wsgi_application\napplication = get_wsgi_application()\n",
+			},
+			want:           WSGI,
+			wantFrameworks: []string{"django"},
+			wantEntrypoint: "exec gunicorn -b :$PORT mysite.wsgi:application",
+		},
+		{
+			name:           "fastapi",
+			files:          map[string]string{"main.py": "from fastapi import FastAPI\napp = FastAPI()\n"},
+			want:           ASGI,
+			wantFrameworks: []string{"fastapi"},
+			wantEntrypoint: "exec gunicorn -b :$PORT -k uvicorn.workers.UvicornWorker main:app",
+		},
+		{
+			name:           "starlette",
+			files:          map[string]string{"main.py": "import starlette.applications\n"},
+			want:           ASGI,
+			wantFrameworks: []string{"starlette"},
+			wantEntrypoint: "exec gunicorn -b :$PORT -k uvicorn.workers.UvicornWorker main:app",
+		},
+		{
+			name: "requirements only",
+			files: map[string]string{
+				"main.py":          "import app\n",
+				"requirements.txt": "FastAPI==0.85.0\nrequests\n",
+			},
+			want:           ASGI,
+			wantFrameworks: []string{"fastapi"},
+			wantEntrypoint: "exec gunicorn -b :$PORT -k uvicorn.workers.UvicornWorker main:app",
+		},
+		{
+			name: "imports take precedence over requirements",
+			files: map[string]string{
+				"main.py":          "from flask import Flask\n",
+				"requirements.txt": "flask\nstarlette\n",
+			},
+			want:           WSGI,
+			wantFrameworks: []string{"flask"},
+			wantEntrypoint: "exec gunicorn -b :$PORT main:app",
+		},
+		{
+			name:           "ambiguous",
+			files:          map[string]string{"main.py": "from flask import Flask\nfrom fastapi import FastAPI\n"},
+			wantFrameworks: []string{"fastapi", "flask"},
+		},
+		{
+			name:  "no framework",
+			files: map[string]string{"main.py": "import os\n"},
+		},
+		{
+			name:           "no application module",
+			files:          map[string]string{"server.py": "from flask import Flask\n"},
+			want:           WSGI,
+			wantFrameworks: []string{"flask"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTestFiles(t, dir, tc.files)
+
+			got, frameworks, err := ServerInterface(dir)
+			if err != nil {
+				t.Fatalf("ServerInterface(%q) got error: %v", dir, err)
+			}
+			if got != tc.want {
+				t.Errorf("ServerInterface(%q) = %q, want %q", dir, got, tc.want)
+			}
+			if diff := cmp.Diff(tc.wantFrameworks, frameworks); diff != "" {
+				t.Errorf("ServerInterface(%q) frameworks mismatch (-want +got):\n%s", dir, diff)
+			}
+
+			entrypoint, err := DefaultEntrypoint(dir, got)
+			if err != nil {
+				t.Fatalf("DefaultEntrypoint(%q, %q) got error: %v", dir, got, err)
+			}
+			if entrypoint != tc.wantEntrypoint {
+				t.Errorf("DefaultEntrypoint(%q, %q) = %q, want %q", dir, got, entrypoint, tc.wantEntrypoint)
+			}
+		})
+	}
+}
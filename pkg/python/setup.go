@@ -0,0 +1,140 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// SetupPy is the name of the setuptools build script of a package.
+	SetupPy = "setup.py"
+	// SetupCfg is the name of the declarative setuptools configuration of a package.
+	SetupCfg = "setup.cfg"
+)
+
+var setupPyPythonRequiresRegexp = regexp.MustCompile(`\bpython_requires\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+
+// IsPackage returns whether the application in dir is a setuptools package, i.e. has a setup.py or
+// a setup.cfg.
+func IsPackage(dir string) (bool, error) {
+	for _, f := range []string{SetupPy, SetupCfg} {
+		if _, err := os.Stat(filepath.Join(dir, f)); err == nil {
+			return true, nil
+		} else if !os.IsNotExist(err) {
+			return false, gcp.InternalErrorf("stating %s: %v", f, err)
+		}
+	}
+	return false, nil
+}
+
+// packageFiles returns the paths of the files in dir that declare the metadata and dependencies of
+// a package: setup.py, setup.cfg and pyproject.toml, if they exist.
+func packageFiles(dir string) ([]string, error) {
+	var files []string
+	for _, f := range []string{SetupPy, SetupCfg, PyProjectTOML} {
+		path := filepath.Join(dir, f)
+		if _, err := os.Stat(path); err == nil {
+			files = append(files, path)
+		} else if !os.IsNotExist(err) {
+			return nil, gcp.InternalErrorf("stating %s: %v", f, err)
+		}
+	}
+	return files, nil
+}
+
+// RequestedSetupPythonVersion returns the `python_requires` of the package in dir, converted to a
+// semver constraint, and the file that declares it. The `[options]` of setup.cfg take precedence
+// over the setup() call of setup.py. It returns empty strings if neither declares a constraint.
+func RequestedSetupPythonVersion(dir string) (string, string, error) {
+	spec, err := setupCfgPythonRequires(filepath.Join(dir, SetupCfg))
+	if err != nil {
+		return "", "", err
+	}
+	file := SetupCfg
+	if spec == "" {
+		if spec, err = setupPyPythonRequires(filepath.Join(dir, SetupPy)); err != nil {
+			return "", "", err
+		}
+		file = SetupPy
+	}
+	if spec == "" {
+		return "", "", nil
+	}
+	c, err := semverConstraint(spec)
+	if err != nil {
+		return "", "", gcp.UserErrorf("invalid python_requires %q in %s: %v", spec, file, err)
+	}
+	return c, file, nil
+}
+
+// setupCfgPythonRequires returns the python_requires option in the [options] section of setup.cfg.
+func setupCfgPythonRequires(path string) (string, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", gcp.InternalErrorf("opening %s: %v", SetupCfg, err)
+	}
+	defer f.Close()
+
+	var section string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		if section != "options" {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) == 2 && strings.TrimSpace(parts[0]) == "python_requires" {
+			return strings.TrimSpace(parts[1]), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", gcp.InternalErrorf("reading %s: %v", SetupCfg, err)
+	}
+	return "", nil
+}
+
+// setupPyPythonRequires returns the python_requires keyword argument passed to setup() in setup.py,
+// if it is a string literal.
+func setupPyPythonRequires(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", gcp.InternalErrorf("reading %s: %v", SetupPy, err)
+	}
+	m := setupPyPythonRequiresRegexp.FindStringSubmatch(string(content))
+	if m == nil {
+		return "", nil
+	}
+	return strings.TrimSpace(m[1] + m[2]), nil
+}
@@ -0,0 +1,122 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"testing"
+)
+
+func TestIsPackage(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  bool
+	}{
+		{
+			name:  "setup.py only",
+			files: map[string]string{"setup.py": "from setuptools import setup\nsetup()\n"},
+			want:  true,
+		},
+		{
+			name:  "setup.cfg only",
+			files: map[string]string{"setup.cfg": "[metadata]\nname = app\n"},
+			want:  true,
+		},
+		{
+			name:  "requirements.txt",
+			files: map[string]string{"requirements.txt": "flask\n"},
+		},
+		{
+			name:  "pyproject.toml only",
+			files: map[string]string{"pyproject.toml": "[project]\nname = \"app\"\n"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTestFiles(t, dir, tc.files)
+
+			got, err := IsPackage(dir)
+			if err != nil {
+				t.Fatalf("IsPackage() got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("IsPackage() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRequestedSetupPythonVersion(t *testing.T) {
+	testCases := []struct {
+		name     string
+		files    map[string]string
+		want     string
+		wantFile string
+		wantErr  bool
+	}{
+		{
+			name: "no setup files",
+		},
+		{
+			name:     "setup.cfg",
+			files:    map[string]string{"setup.cfg": "[metadata]\nname = app\n\n[options]\npackages = find:\npython_requires = >=3.8, <4\n"},
+			want:     ">=3.8.0, <4.0.0",
+			wantFile: "setup.cfg",
+		},
+		{
+			name:  "setup.cfg python_requires outside of options",
+			files: map[string]string{"setup.cfg": "[metadata]\npython_requires = >=3.8\n"},
+		},
+		{
+			name:     "setup.py",
+			files:    map[string]string{"setup.py": "from setuptools import setup\n\nsetup(\n    name=\"app\",\n    python_requires=\"~=3.9\",\n)\n"},
+			want:     ">=3.9.0, <4.0.0",
+			wantFile: "setup.py",
+		},
+		{
+			name: "setup.cfg takes precedence over setup.py",
+			files: map[string]string{
+				"setup.cfg": "[options]\npython_requires = >=3.10\n",
+				"setup.py":  "setup(python_requires='>=3.8')\n",
+			},
+			want:     ">=3.10.0",
+			wantFile: "setup.cfg",
+		},
+		{
+			name:  "setup.py without python_requires",
+			files: map[string]string{"setup.py": "setup(name='app')\n"},
+		},
+		{
+			name:    "invalid constraint",
+			files:   map[string]string{"setup.cfg": "[options]\npython_requires = ~=3\n"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTestFiles(t, dir, tc.files)
+
+			got, file, err := RequestedSetupPythonVersion(dir)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("RequestedSetupPythonVersion() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if got != tc.want || file != tc.wantFile {
+				t.Errorf("RequestedSetupPythonVersion() = (%q, %q), want (%q, %q)", got, file, tc.want, tc.wantFile)
+			}
+		})
+	}
+}
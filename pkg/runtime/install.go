@@ -15,14 +15,23 @@
 package runtime
 
 import (
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
+	"regexp"
+	goruntime "runtime"
+	"strconv"
+	"strings"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/fetch"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/version"
+	"github.com/Masterminds/semver"
 	"github.com/buildpacks/libcnb"
 )
 
@@ -30,6 +39,16 @@ var (
 	dartSdkURL         = "https://storage.googleapis.com/dart-archive/channels/stable/release/%s/sdk/dartsdk-linux-x64-release.zip"
 	googleTarballURL   = "https://dl.google.com/runtimes/%[1]s/%[1]s-%s.tar.gz"
 	runtimeVersionsURL = "https://dl.google.com/runtimes/%s/version.json"
+
+	// urlTemplatePlaceholder matches the placeholders of GOOGLE_RUNTIME_URL_TEMPLATE.
+	urlTemplatePlaceholder = regexp.MustCompile(`\{[^{}]*\}`)
+	// singleVersion matches a version constraint that is a single, possibly partial, version such as
+	// "18", "18.2", "v18.2.1" or "18.x", capturing its major and minor components.
+	singleVersion = regexp.MustCompile(`^v?(\d+)(?:\.(\d+|[xX*]))?(?:\.(?:\d+|[xX*]))?(?:[-+].*)?$`)
+	// operatorSpace matches a comparison operator followed by whitespace, e.g. the ">= " in ">= 18".
+	operatorSpace = regexp.MustCompile(`([<>=!~^]+)\s+`)
+	// partialUpperBound matches an exclusive upper bound with a partial version, e.g. "<21" or "<21.2".
+	partialUpperBound = regexp.MustCompile(`^<(v?\d+(?:\.\d+)?)(?:\.[xX*])*$`)
 )
 
 // InstallableRuntime is used to hold runtimes information
@@ -49,13 +68,14 @@ const (
 
 // User friendly display name of all runtime (e.g. for use in error message).
 var runtimeNames = map[InstallableRuntime]string{
-	Nodejs:    "Node.js",
-	PHP:       "PHP Runtime",
-	Python:    "Python",
-	Ruby:      "Ruby Runtime",
-	Nginx:     "Nginx Web Server",
-	Pid1:      "Pid1",
-	DotnetSDK: ".NET SDK",
+	Nodejs:     "Node.js",
+	PHP:        "PHP Runtime",
+	Python:     "Python",
+	Ruby:       "Ruby Runtime",
+	Nginx:      "Nginx Web Server",
+	Pid1:       "Pid1",
+	DotnetSDK:  ".NET SDK",
+	AspNetCore: "ASP.NET Core Runtime",
 }
 
 const (
@@ -118,16 +138,21 @@ func InstallTarballIfNotCached(ctx *gcp.Context, runtime InstallableRuntime, ver
 	runtimeName := runtimeNames[runtime]
 	runtimeID := string(runtime)
 
-	version, err := resolveVersion(runtime, versionConstraint)
+	if err := validateURLTemplate(); err != nil {
+		return false, err
+	}
+	version, err := ResolveVersion(runtime, versionConstraint)
 	if err != nil {
 		return false, err
 	}
+	LogResolution(ctx, versionConstraint, version)
 	ctx.AddBOMEntry(libcnb.BOMEntry{
 		Name:     runtimeID,
 		Metadata: map[string]interface{}{"version": version},
 		Launch:   true,
 		Build:    true,
 	})
+	ctx.RecordVersion(gcp.VersionCategoryRuntime, runtimeID, version)
 
 	if IsCached(ctx, layer, version) {
 		ctx.CacheHit(runtimeID)
@@ -139,12 +164,25 @@ func InstallTarballIfNotCached(ctx *gcp.Context, runtime InstallableRuntime, ver
 		return false, gcp.InternalErrorf("clearing layer %q: %w", layer.Name, err)
 	}
 	ctx.Logf("Installing %s v%s.", runtimeName, version)
+	defer ctx.Timer(fmt.Sprintf("Install %s v%s", runtimeName, version))()
 
-	ctx.SetMetadata(layer, versionKey, version)
-	runtimeURL := fmt.Sprintf(googleTarballURL, runtime, version)
-
-	if err := fetch.Tarball(runtimeURL, layer.Path, 0); err != nil {
-		ctx.Warnf("Failed to download %s version %s. You can specify the verison by setting the GOOGLE_RUNTIME_VERSION environment variable", runtimeName, version)
+	tarball, local, err := localTarball(runtime, version)
+	if err != nil {
+		return false, err
+	}
+	if local {
+		ctx.Logf("Using %s from %s.", tarball, env.RuntimeLocalDir)
+	} else {
+		dir, err := ctx.TempDir(runtimeID)
+		if err != nil {
+			return false, err
+		}
+		tarball = filepath.Join(dir, fmt.Sprintf("%s-%s.tar.gz", runtimeID, version))
+		if err := downloadTarball(ctx, runtime, version, tarball); err != nil {
+			return false, err
+		}
+	}
+	if err := fetch.UntarFile(tarball, layer.Path, 0); err != nil {
 		return false, err
 	}
 
@@ -153,23 +191,178 @@ func InstallTarballIfNotCached(ctx *gcp.Context, runtime InstallableRuntime, ver
 	return false, nil
 }
 
-// resolveVersion returns the newest available version of a runtime that satisfies the provided
-// version constraint.
-func resolveVersion(runtime InstallableRuntime, verConstraint string) (string, error) {
+// downloadTarball downloads the tarball of a runtime version from dl.google.com to the given path.
+func downloadTarball(ctx *gcp.Context, runtime InstallableRuntime, version, tarball string) error {
+	runtimeURL := tarballURL(runtime, version)
+	if err := ctx.HTTPGet(runtimeURL, tarball); err != nil {
+		ctx.Warnf("Failed to download %s version %s. You can specify the verison by setting the GOOGLE_RUNTIME_VERSION environment variable", runtimeNames[runtime], version)
+		var clientErr *gcp.HTTPClientError
+		if errors.As(err, &clientErr) {
+			return gcp.UserErrorf("%v", err)
+		}
+		var serverErr *gcp.HTTPServerError
+		if errors.As(err, &serverErr) {
+			return gcp.InternalErrorf("%v", err)
+		}
+		return err
+	}
+	return nil
+}
+
+// tarballURL returns the URL of the tarball of a runtime version, from GOOGLE_RUNTIME_URL_TEMPLATE
+// if set, or dl.google.com otherwise.
+func tarballURL(runtime InstallableRuntime, version string) string {
+	tmpl := os.Getenv(env.RuntimeURLTemplate)
+	if tmpl == "" {
+		return fmt.Sprintf(googleTarballURL, runtime, version)
+	}
+	return strings.NewReplacer("{name}", string(runtime), "{version}", version, "{arch}", goruntime.GOARCH).Replace(tmpl)
+}
+
+// validateURLTemplate returns an error if GOOGLE_RUNTIME_URL_TEMPLATE has placeholders other than
+// {name}, {version} and {arch}.
+func validateURLTemplate() error {
+	tmpl := os.Getenv(env.RuntimeURLTemplate)
+	if tmpl == "" {
+		return nil
+	}
+	for _, p := range urlTemplatePlaceholder.FindAllString(tmpl, -1) {
+		if p != "{name}" && p != "{version}" && p != "{arch}" {
+			return gcp.UserErrorf("invalid %s %q: unknown placeholder %s, supported placeholders are {name}, {version} and {arch}", env.RuntimeURLTemplate, tmpl, p)
+		}
+	}
+	if strings.ContainsAny(urlTemplatePlaceholder.ReplaceAllString(tmpl, ""), "{}") {
+		return gcp.UserErrorf("invalid %s %q: unbalanced braces", env.RuntimeURLTemplate, tmpl)
+	}
+	return nil
+}
+
+// VersionedInstall installs a runtime tarball into a cache and launch layer whose name is derived
+// only from the runtime and its resolved version, see VersionedLayerName. Like any layer, it is
+// cached per application: it is only reused by builds that restore the same cache, e.g. builds of
+// different applications with the same cache image. Returns the layer and true if a cached layer
+// is used.
+func VersionedInstall(ctx *gcp.Context, runtime InstallableRuntime, versionConstraint string) (*libcnb.Layer, bool, error) {
+	if err := validateURLTemplate(); err != nil {
+		return nil, false, err
+	}
+	version, err := ResolveVersion(runtime, versionConstraint)
+	if err != nil {
+		return nil, false, err
+	}
+	LogResolution(ctx, versionConstraint, version)
+	name := VersionedLayerName(runtime, version)
+	l, err := ctx.Layer(name, gcp.CacheLayer, gcp.LaunchLayer)
+	if err != nil {
+		return nil, false, fmt.Errorf("creating %v layer: %w", name, err)
+	}
+	cached, err := InstallTarballIfNotCached(ctx, runtime, version, l)
+	if err != nil {
+		return nil, false, err
+	}
+	return l, cached, nil
+}
+
+// VersionedLayerName returns the name of the layer that VersionedInstall installs the given version
+// of a runtime into. The name is content-addressed: it only depends on the runtime and the version.
+func VersionedLayerName(runtime InstallableRuntime, version string) string {
+	sum := sha256.Sum256([]byte(string(runtime) + "@" + version))
+	return fmt.Sprintf("%s-%x", runtime, sum[:8])
+}
+
+// ResolveVersion returns the newest available version of a runtime that satisfies the provided
+// version constraint. If GOOGLE_RUNTIME_LOCAL_DIR is set, only the versions with a tarball in that
+// directory are considered. Space-separated npm-style ranges such as ">=18 <21" are supported.
+func ResolveVersion(runtime InstallableRuntime, verConstraint string) (string, error) {
 	if version.IsExactSemver(verConstraint) {
 		return verConstraint, nil
 	}
+	constraint := normalizeRange(verConstraint)
+	if constraint != "" {
+		if _, err := semver.NewConstraint(constraint); err != nil {
+			return "", gcp.UserErrorf("invalid %s version specified: %v", runtimeNames[runtime], err)
+		}
+	}
 
-	url := fmt.Sprintf(runtimeVersionsURL, runtime)
+	versions, err := Versions(runtime)
+	if err != nil {
+		return "", err
+	}
 
-	var versions []string
-	if err := fetch.JSON(url, &versions); err != nil {
-		return "", gcp.InternalErrorf("fetching %s versions: %v", runtimeNames[runtime], err)
+	v, err := version.ResolveVersion(constraint, versions)
+	if err == nil {
+		return v, nil
+	}
+	newest, nerr := version.ResolveVersion("*", versions)
+	if nerr != nil {
+		return "", gcp.UserErrorf("no %s version satisfies %q: %v", runtimeNames[runtime], verConstraint, err)
 	}
+	return "", gcp.UserErrorf("no %s version satisfies %q, the newest available version is %s", runtimeNames[runtime], verConstraint, newest)
+}
 
-	v, err := version.ResolveVersion(verConstraint, versions)
+// normalizeRange rewrites the whitespace-separated comparators of an npm-style range, e.g.
+// ">=18 <21", into the comma-separated form of a semver constraint, e.g. ">=18,<21.0.0". Hyphen
+// ranges such as "18 - 20" are left untouched.
+func normalizeRange(constraint string) string {
+	var ors []string
+	for _, c := range strings.Split(strings.TrimSpace(constraint), "||") {
+		c = strings.TrimSpace(c)
+		if !strings.Contains(c, " - ") {
+			c = operatorSpace.ReplaceAllString(c, "$1")
+			comparators := strings.Fields(strings.ReplaceAll(c, ",", " "))
+			for i, cmp := range comparators {
+				// A partial upper bound such as "<21" would otherwise also match 21.x.
+				if m := partialUpperBound.FindStringSubmatch(cmp); m != nil {
+					comparators[i] = "<" + m[1] + strings.Repeat(".0", 2-strings.Count(m[1], "."))
+				}
+			}
+			c = strings.Join(comparators, ",")
+		}
+		ors = append(ors, c)
+	}
+	return strings.Join(ors, " || ")
+}
+
+// LogResolution logs the version that the requested version constraint resolved to. It warns if
+// the resolved version has a different major version, or a different minor version if the request
+// specifies one, e.g. because it was rolled forward. A different patch version is expected, and
+// version ranges such as "^18.2" are only logged.
+func LogResolution(ctx *gcp.Context, requested, resolved string) {
+	requested = strings.TrimSpace(requested)
+	if requested == "" || requested == resolved {
+		return
+	}
+	ctx.Logf("Resolved the requested version %q to %s.", requested, resolved)
+	m := singleVersion.FindStringSubmatch(requested)
+	if m == nil {
+		return
+	}
+	v, err := semver.NewVersion(resolved)
+	if err != nil {
+		ctx.Debugf("Parsing resolved version %q: %v", resolved, err)
+		return
+	}
+	if major, err := strconv.ParseUint(m[1], 10, 64); err == nil && major != v.Major() {
+		ctx.Warnf("The requested version %q resolved to %s, which has a different major version. Request an exact version if this is unexpected.", requested, resolved)
+		return
+	}
+	if minor, err := strconv.ParseUint(m[2], 10, 64); err == nil && minor != v.Minor() {
+		ctx.Warnf("The requested version %q resolved to %s, which has a different minor version. Request an exact version if this is unexpected.", requested, resolved)
+	}
+}
+
+// Versions returns the versions of the runtime that can be installed, from the tarballs in
+// GOOGLE_RUNTIME_LOCAL_DIR if it is set and from dl.google.com otherwise.
+func Versions(runtime InstallableRuntime) ([]string, error) {
+	versions, local, err := localVersions(runtime)
 	if err != nil {
-		return "", gcp.UserErrorf("invalid %s version specified: %v", runtimeNames[runtime], err)
+		return nil, err
+	}
+	if !local {
+		url := fmt.Sprintf(runtimeVersionsURL, runtime)
+		if err := fetch.JSON(url, &versions); err != nil {
+			return nil, gcp.InternalErrorf("fetching %s versions: %v", runtimeNames[runtime], err)
+		}
 	}
-	return v, nil
+	return versions, nil
 }
@@ -15,12 +15,18 @@
 package runtime
 
 import (
+	"bytes"
+	"log"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	goruntime "runtime"
+	"strings"
 	"testing"
 
 	"github.com/GoogleCloudPlatform/buildpacks/internal/testserver"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/testdata"
 	"github.com/buildpacks/libcnb"
@@ -53,7 +59,7 @@ func TestInstallDartSDK(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			ctx := gcp.NewContext()
+			ctx := gcp.NewContext(gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: t.TempDir()}}))
 			l := &libcnb.Layer{
 				Path:     t.TempDir(),
 				Metadata: map[string]interface{}{},
@@ -159,7 +165,7 @@ func TestInstallRuby(t *testing.T) {
 				Path:     t.TempDir(),
 				Metadata: map[string]interface{}{},
 			}
-			ctx := gcp.NewContext()
+			ctx := gcp.NewContext(gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: t.TempDir()}}))
 			if tc.wantCached {
 				ctx.SetMetadata(layer, "version", "2.2.2")
 			}
@@ -183,3 +189,369 @@ func TestInstallRuby(t *testing.T) {
 		})
 	}
 }
+
+func TestVersionedInstall(t *testing.T) {
+	testserver.New(
+		t,
+		testserver.WithStatus(http.StatusOK),
+		testserver.WithFile(testdata.MustGetPath("testdata/dummy-ruby-runtime.tar.gz")),
+		testserver.WithMockURL(&googleTarballURL))
+	testserver.New(
+		t,
+		testserver.WithStatus(http.StatusOK),
+		testserver.WithJSON(`["1.1.1","3.3.3","2.2.2"]`),
+		testserver.WithMockURL(&runtimeVersionsURL),
+	)
+	layersDir := t.TempDir()
+
+	// The first application installs the runtime.
+	ctx := gcp.NewContext(gcp.WithApplicationRoot(t.TempDir()), gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: layersDir}}))
+	l, cached, err := VersionedInstall(ctx, AspNetCore, "2.x.x")
+	if err != nil {
+		t.Fatalf("VersionedInstall(ctx, %q, %q) got error: %v", AspNetCore, "2.x.x", err)
+	}
+	if cached {
+		t.Errorf("VersionedInstall(ctx, %q, %q) got cached: true on the first install, want false", AspNetCore, "2.x.x")
+	}
+	if want := VersionedLayerName(AspNetCore, "2.2.2"); l.Name != want {
+		t.Errorf("VersionedInstall(ctx, %q, %q) got layer %q, want %q", AspNetCore, "2.x.x", l.Name, want)
+	}
+	if fp := filepath.Join(l.Path, "lib/foo.txt"); !fileExists(fp) {
+		t.Errorf("Failed to extract. Missing file: %s", fp)
+	}
+	// Persist the layer metadata, as the lifecycle does when restoring the layer from the cache.
+	if err := os.WriteFile(filepath.Join(layersDir, l.Name+".toml"), []byte("cache = true\nlaunch = true\n\n[metadata]\n  version = \"2.2.2\"\n"), 0644); err != nil {
+		t.Fatalf("writing layer metadata: %v", err)
+	}
+
+	// A second application requesting the same version reuses the layer.
+	ctx = gcp.NewContext(gcp.WithApplicationRoot(t.TempDir()), gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: layersDir}}))
+	l2, cached, err := VersionedInstall(ctx, AspNetCore, "2.2.2")
+	if err != nil {
+		t.Fatalf("VersionedInstall(ctx, %q, %q) got error: %v", AspNetCore, "2.2.2", err)
+	}
+	if !cached {
+		t.Errorf("VersionedInstall(ctx, %q, %q) got cached: false on the second install, want true", AspNetCore, "2.2.2")
+	}
+	if l2.Name != l.Name {
+		t.Errorf("VersionedInstall(ctx, %q, %q) got layer %q, want %q", AspNetCore, "2.2.2", l2.Name, l.Name)
+	}
+}
+
+func TestLogResolution(t *testing.T) {
+	testCases := []struct {
+		name      string
+		requested string
+		resolved  string
+		wantLog   bool
+		wantWarn  string
+	}{
+		{
+			name:      "patch bump of a major version",
+			requested: "18",
+			resolved:  "18.19.1",
+			wantLog:   true,
+		},
+		{
+			name:      "patch bump of a minor version",
+			requested: "6.0",
+			resolved:  "6.0.25",
+			wantLog:   true,
+		},
+		{
+			name:      "patch bump",
+			requested: "6.0.5",
+			resolved:  "6.0.25",
+			wantLog:   true,
+		},
+		{
+			name:      "minor bump",
+			requested: "6.0.5",
+			resolved:  "6.1.0",
+			wantLog:   true,
+			wantWarn:  "different minor version",
+		},
+		{
+			name:      "minor bump of a wildcard version",
+			requested: "18.2.x",
+			resolved:  "18.3.0",
+			wantLog:   true,
+			wantWarn:  "different minor version",
+		},
+		{
+			name:      "major bump",
+			requested: "6.0.5",
+			resolved:  "7.0.0",
+			wantLog:   true,
+			wantWarn:  "different major version",
+		},
+		{
+			name:      "major bump of a major version",
+			requested: "v16",
+			resolved:  "18.0.0",
+			wantLog:   true,
+			wantWarn:  "different major version",
+		},
+		{
+			name:      "range",
+			requested: "^18.2",
+			resolved:  "18.5.0",
+			wantLog:   true,
+		},
+		{
+			name:      "exact version",
+			requested: "18.19.1",
+			resolved:  "18.19.1",
+		},
+		{
+			name:     "no request",
+			resolved: "18.19.1",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			ctx := gcp.NewContext(gcp.WithLogger(log.New(&buf, "", 0)))
+
+			LogResolution(ctx, tc.requested, tc.resolved)
+
+			got := buf.String()
+			if logged := strings.Contains(got, "Resolved the requested version"); logged != tc.wantLog {
+				t.Errorf("LogResolution(ctx, %q, %q) logged resolution=%t, want %t, output:\n%s", tc.requested, tc.resolved, logged, tc.wantLog, got)
+			}
+			warned := strings.Contains(got, "WARNING:")
+			if tc.wantWarn == "" && warned {
+				t.Errorf("LogResolution(ctx, %q, %q) warned, want no warning, output:\n%s", tc.requested, tc.resolved, got)
+			}
+			if tc.wantWarn != "" && (!warned || !strings.Contains(got, tc.wantWarn)) {
+				t.Errorf("LogResolution(ctx, %q, %q) got output:\n%s\nwant a warning containing %q", tc.requested, tc.resolved, got, tc.wantWarn)
+			}
+		})
+	}
+}
+
+func TestResolveVersion(t *testing.T) {
+	testCases := []struct {
+		name       string
+		constraint string
+		want       string
+		wantErr    string
+	}{
+		{
+			name:       "space separated range",
+			constraint: ">=18 <21",
+			want:       "20.11.1",
+		},
+		{
+			name:       "space separated range with spaces after operators",
+			constraint: ">= 16 < 20",
+			want:       "18.19.1",
+		},
+		{
+			name:       "comma separated range",
+			constraint: ">=16, <18",
+			want:       "16.20.2",
+		},
+		{
+			name:       "partial upper bound",
+			constraint: ">=16 <18.x",
+			want:       "16.20.2",
+		},
+		{
+			name:       "caret range",
+			constraint: "^18",
+			want:       "18.19.1",
+		},
+		{
+			name:       "wildcard",
+			constraint: "16.x",
+			want:       "16.20.2",
+		},
+		{
+			name:       "or range",
+			constraint: "14 || >=16 <18",
+			want:       "16.20.2",
+		},
+		{
+			name:       "hyphen range",
+			constraint: "16 - 18",
+			want:       "18.19.1",
+		},
+		{
+			name: "no constraint",
+			want: "21.6.2",
+		},
+		{
+			name:       "exact version",
+			constraint: "18.0.0",
+			want:       "18.0.0",
+		},
+		{
+			name:       "unsatisfiable range",
+			constraint: ">=22 <23",
+			wantErr:    `no Node.js version satisfies ">=22 <23", the newest available version is 21.6.2`,
+		},
+		{
+			name:       "invalid range",
+			constraint: "latest",
+			wantErr:    "invalid Node.js version specified",
+		},
+	}
+	testserver.New(
+		t,
+		testserver.WithStatus(http.StatusOK),
+		testserver.WithJSON(`["16.20.2","18.19.1","21.6.2","20.11.1","18.0.0"]`),
+		testserver.WithMockURL(&runtimeVersionsURL),
+	)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ResolveVersion(Nodejs, tc.constraint)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("ResolveVersion(%q, %q) got error: %v, want error containing %q", Nodejs, tc.constraint, err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ResolveVersion(%q, %q) got error: %v", Nodejs, tc.constraint, err)
+			}
+			if got != tc.want {
+				t.Errorf("ResolveVersion(%q, %q) = %q, want %q", Nodejs, tc.constraint, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestVersionedLayerName(t *testing.T) {
+	if got, want := VersionedLayerName(AspNetCore, "6.0.1"), VersionedLayerName(AspNetCore, "6.0.1"); got != want {
+		t.Errorf("VersionedLayerName() is not deterministic: %q != %q", got, want)
+	}
+	if a, b := VersionedLayerName(AspNetCore, "6.0.1"), VersionedLayerName(AspNetCore, "6.0.2"); a == b {
+		t.Errorf("VersionedLayerName() = %q for different versions", a)
+	}
+	if a, b := VersionedLayerName(AspNetCore, "6.0.1"), VersionedLayerName(DotnetSDK, "6.0.1"); a == b {
+		t.Errorf("VersionedLayerName() = %q for different runtimes", a)
+	}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestTarballURL(t *testing.T) {
+	testCases := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{
+			name: "default",
+			want: "https://dl.google.com/runtimes/nodejs/nodejs-16.17.1.tar.gz",
+		},
+		{
+			name:     "all placeholders",
+			template: "https://mirror.example.com/{name}/{version}/{arch}.tar.gz",
+			want:     "https://mirror.example.com/nodejs/16.17.1/" + goruntime.GOARCH + ".tar.gz",
+		},
+		{
+			name:     "repeated placeholders",
+			template: "https://mirror.example.com/{name}/{name}-{version}.tar.gz",
+			want:     "https://mirror.example.com/nodejs/nodejs-16.17.1.tar.gz",
+		},
+		{
+			name:     "no placeholders",
+			template: "https://mirror.example.com/node.tar.gz",
+			want:     "https://mirror.example.com/node.tar.gz",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(env.RuntimeURLTemplate, tc.template)
+
+			if got := tarballURL(Nodejs, "16.17.1"); got != tc.want {
+				t.Errorf("tarballURL() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidateURLTemplate(t *testing.T) {
+	testCases := []struct {
+		name     string
+		template string
+		wantErr  bool
+	}{
+		{
+			name: "unset",
+		},
+		{
+			name:     "valid",
+			template: "https://mirror.example.com/{name}/{version}/{arch}.tar.gz",
+		},
+		{
+			name:     "unknown placeholder",
+			template: "https://mirror.example.com/{runtime}/{version}.tar.gz",
+			wantErr:  true,
+		},
+		{
+			name:     "empty placeholder",
+			template: "https://mirror.example.com/{}/{version}.tar.gz",
+			wantErr:  true,
+		},
+		{
+			name:     "unbalanced braces",
+			template: "https://mirror.example.com/{name/{version}.tar.gz",
+			wantErr:  true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(env.RuntimeURLTemplate, tc.template)
+
+			err := validateURLTemplate()
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("validateURLTemplate() got error: %v, want error: %t", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestInstallTarballWithURLTemplate(t *testing.T) {
+	var gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		http.ServeFile(w, r, testdata.MustGetPath("testdata/dummy-ruby-runtime.tar.gz"))
+	}))
+	defer server.Close()
+	t.Setenv(env.RuntimeURLTemplate, server.URL+"/{name}/{version}/{arch}.tar.gz")
+	layer := &libcnb.Layer{
+		Path:     t.TempDir(),
+		Metadata: map[string]interface{}{},
+	}
+
+	ctx := gcp.NewContext(gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: t.TempDir()}}))
+
+	if _, err := InstallTarballIfNotCached(ctx, Ruby, "2.2.2", layer); err != nil {
+		t.Fatalf("InstallTarballIfNotCached() got error: %v", err)
+	}
+	if want := "/ruby/2.2.2/" + goruntime.GOARCH + ".tar.gz"; gotPath != want {
+		t.Errorf("InstallTarballIfNotCached() downloaded %q, want %q", gotPath, want)
+	}
+	if fp := filepath.Join(layer.Path, "lib/foo.txt"); !fileExists(fp) {
+		t.Errorf("Failed to extract. Missing file: %s", fp)
+	}
+}
+
+func TestInstallTarballWithInvalidURLTemplate(t *testing.T) {
+	t.Setenv(env.RuntimeURLTemplate, "https://mirror.example.com/{runtime}.tar.gz")
+	layer := &libcnb.Layer{
+		Path:     t.TempDir(),
+		Metadata: map[string]interface{}{"version": "2.2.2"},
+	}
+
+	// The template is validated even if the runtime is cached.
+	if _, err := InstallTarballIfNotCached(gcp.NewContext(), Ruby, "2.2.2", layer); err == nil {
+		t.Error("InstallTarballIfNotCached() got no error, want error")
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// checksumSuffix is the suffix of the file next to a local tarball that holds its SHA-256 checksum,
+// in the format of sha256sum, e.g. nodejs-16.17.1-amd64.tar.gz.sha256.
+const checksumSuffix = ".sha256"
+
+// localTarballName returns the name of the tarball of the given runtime version in the directory
+// set by GOOGLE_RUNTIME_LOCAL_DIR.
+func localTarballName(runtime InstallableRuntime, version string) string {
+	return fmt.Sprintf("%s-%s-%s.tar.gz", runtime, version, goruntime.GOARCH)
+}
+
+// localTarball returns the path of the tarball of the given runtime version in the directory set by
+// GOOGLE_RUNTIME_LOCAL_DIR, after verifying its checksum. It returns false if the env var is unset.
+func localTarball(runtime InstallableRuntime, version string) (string, bool, error) {
+	dir := os.Getenv(env.RuntimeLocalDir)
+	if dir == "" {
+		return "", false, nil
+	}
+	path := filepath.Join(dir, localTarballName(runtime, version))
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return "", false, gcp.UserErrorf("%s v%s not found in %s=%s, expected %s", runtimeNames[runtime], version, env.RuntimeLocalDir, dir, path)
+	} else if err != nil {
+		return "", false, gcp.InternalErrorf("stat %q: %v", path, err)
+	}
+	if err := verifyChecksum(path); err != nil {
+		return "", false, err
+	}
+	return path, true, nil
+}
+
+// verifyChecksum compares the SHA-256 checksum of the file with the one in the checksum file next
+// to it, if there is one.
+func verifyChecksum(path string) error {
+	data, err := os.ReadFile(path + checksumSuffix)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return gcp.InternalErrorf("reading %s: %v", path+checksumSuffix, err)
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return gcp.UserErrorf("checksum file %s is empty", path+checksumSuffix)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return gcp.InternalErrorf("opening %s: %v", path, err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return gcp.InternalErrorf("reading %s: %v", path, err)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(got, fields[0]) {
+		return gcp.UserErrorf("checksum of %s is %s, want %s from %s", path, got, fields[0], path+checksumSuffix)
+	}
+	return nil
+}
+
+// localVersions returns the versions of the runtime with a tarball in the directory set by
+// GOOGLE_RUNTIME_LOCAL_DIR. It returns false if the env var is unset.
+func localVersions(runtime InstallableRuntime) ([]string, bool, error) {
+	dir := os.Getenv(env.RuntimeLocalDir)
+	if dir == "" {
+		return nil, false, nil
+	}
+	prefix := string(runtime) + "-"
+	suffix := "-" + goruntime.GOARCH + ".tar.gz"
+	matches, err := filepath.Glob(filepath.Join(dir, prefix+"*"+suffix))
+	if err != nil {
+		return nil, false, gcp.InternalErrorf("listing %s tarballs in %s: %v", runtimeNames[runtime], dir, err)
+	}
+	var versions []string
+	for _, m := range matches {
+		versions = append(versions, strings.TrimSuffix(strings.TrimPrefix(filepath.Base(m), prefix), suffix))
+	}
+	return versions, true, nil
+}
@@ -0,0 +1,149 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/internal/testserver"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/testdata"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestInstallTarballFromLocalDir(t *testing.T) {
+	tarball, err := os.ReadFile(testdata.MustGetPath("testdata/dummy-ruby-runtime.tar.gz"))
+	if err != nil {
+		t.Fatalf("reading tarball: %v", err)
+	}
+	sum := sha256.Sum256(tarball)
+	checksum := hex.EncodeToString(sum[:])
+
+	testCases := []struct {
+		name        string
+		version     string
+		files       map[string][]byte
+		wantVersion string
+		wantErr     string
+	}{
+		{
+			name:        "local hit",
+			version:     "2.2.2",
+			files:       map[string][]byte{"ruby-2.2.2-" + goruntime.GOARCH + ".tar.gz": tarball},
+			wantVersion: "2.2.2",
+		},
+		{
+			name:    "version resolved from local tarballs",
+			version: "2.x.x",
+			files: map[string][]byte{
+				"ruby-2.1.0-" + goruntime.GOARCH + ".tar.gz": tarball,
+				"ruby-2.2.2-" + goruntime.GOARCH + ".tar.gz": tarball,
+				"ruby-3.3.3-" + goruntime.GOARCH + ".tar.gz": tarball,
+				"ruby-2.9.9-other.tar.gz":                    tarball,
+			},
+			wantVersion: "2.2.2",
+		},
+		{
+			name:    "matching checksum",
+			version: "2.2.2",
+			files: map[string][]byte{
+				"ruby-2.2.2-" + goruntime.GOARCH + ".tar.gz":        tarball,
+				"ruby-2.2.2-" + goruntime.GOARCH + ".tar.gz.sha256": []byte(checksum + "  ruby-2.2.2.tar.gz\n"),
+			},
+			wantVersion: "2.2.2",
+		},
+		{
+			name:    "mismatched checksum",
+			version: "2.2.2",
+			files: map[string][]byte{
+				"ruby-2.2.2-" + goruntime.GOARCH + ".tar.gz":        tarball,
+				"ruby-2.2.2-" + goruntime.GOARCH + ".tar.gz.sha256": []byte(strings.Repeat("0", 64)),
+			},
+			wantErr: "checksum of",
+		},
+		{
+			name:    "local miss",
+			version: "2.2.2",
+			files:   map[string][]byte{"ruby-1.1.1-" + goruntime.GOARCH + ".tar.gz": tarball},
+			wantErr: "ruby-2.2.2-" + goruntime.GOARCH + ".tar.gz",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Fail any network access, the runtime must be installed from the local directory.
+			testserver.New(t, testserver.WithStatus(http.StatusInternalServerError), testserver.WithMockURL(&googleTarballURL))
+			testserver.New(t, testserver.WithStatus(http.StatusInternalServerError), testserver.WithMockURL(&runtimeVersionsURL))
+			dir := t.TempDir()
+			for name, data := range tc.files {
+				if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+					t.Fatalf("writing %s: %v", name, err)
+				}
+			}
+			t.Setenv(env.RuntimeLocalDir, dir)
+			layer := &libcnb.Layer{
+				Path:     t.TempDir(),
+				Metadata: map[string]interface{}{},
+			}
+
+			ctx := gcp.NewContext(gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: t.TempDir()}}))
+			_, err := InstallTarballIfNotCached(ctx, Ruby, tc.version, layer)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("InstallTarballIfNotCached() got error: %v, want error containing %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("InstallTarballIfNotCached() got error: %v", err)
+			}
+			if fp := filepath.Join(layer.Path, "lib/foo.txt"); !fileExists(fp) {
+				t.Errorf("Failed to extract. Missing file: %s", fp)
+			}
+			if layer.Metadata["version"] != tc.wantVersion {
+				t.Errorf("Layer Metadata.version = %q, want %q", layer.Metadata["version"], tc.wantVersion)
+			}
+		})
+	}
+}
+
+func TestInstallTarballLocalDirUnset(t *testing.T) {
+	testserver.New(
+		t,
+		testserver.WithStatus(http.StatusOK),
+		testserver.WithFile(testdata.MustGetPath("testdata/dummy-ruby-runtime.tar.gz")),
+		testserver.WithMockURL(&googleTarballURL))
+	t.Setenv(env.RuntimeLocalDir, "")
+	layer := &libcnb.Layer{
+		Path:     t.TempDir(),
+		Metadata: map[string]interface{}{},
+	}
+
+	ctx := gcp.NewContext(gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: t.TempDir()}}))
+
+	if _, err := InstallTarballIfNotCached(ctx, Ruby, "2.2.2", layer); err != nil {
+		t.Fatalf("InstallTarballIfNotCached() got error: %v", err)
+	}
+	if fp := filepath.Join(layer.Path, "lib/foo.txt"); !fileExists(fp) {
+		t.Errorf("Failed to extract. Missing file: %s", fp)
+	}
+}
@@ -37,6 +37,9 @@ import (
 //   value this returns an OptIn reult. Indicates a gae or gcf build where the environment value
 //   is set to a Google runtime name such ase 'python37' which is supported by the buildpack
 //   performing detection.
+// o If the GOOGLE_RUNTIME environment variable is set to another value but GOOGLE_ADDITIONAL_BUILDPACKS
+//   lists the wantRuntime value this returns an OptIn result. Indicates a build where the buildpack
+//   builds part of an application whose main language is another runtime.
 // o If the GOOGLE_RUNTIME environment variable is set to another value this returns an OptOut result..
 //   Indicates a gae or gcf build and the runtime needed for the build is not supported by the
 //   buildpack performing detection.
@@ -49,6 +52,9 @@ func CheckOverride(wantRuntime string) gcp.DetectResult {
 	if strings.HasPrefix(envRuntime, wantRuntime) {
 		return gcp.OptIn(fmt.Sprintf("%s  matches %q", env.Runtime, wantRuntime))
 	}
+	if env.IsAdditionalBuildpack(wantRuntime) {
+		return gcp.OptIn(fmt.Sprintf("%s lists %q", env.AdditionalBuildpacks, wantRuntime))
+	}
 	return gcp.OptOut(fmt.Sprintf("%s does not match to %q", env.Runtime, wantRuntime))
 }
 
@@ -24,6 +24,7 @@ func TestCheckOverride(t *testing.T) {
 	testCases := []struct {
 		name       string
 		envRuntime string
+		additional string
 		wantIn     bool
 		wantOut    bool
 	}{
@@ -46,12 +47,25 @@ func TestCheckOverride(t *testing.T) {
 			envRuntime: "php55",
 			wantOut:    true,
 		},
+		{
+			name:       "with runtime mismatch listed in additional buildpacks opts in",
+			envRuntime: "go",
+			additional: "nodejs, Python",
+			wantIn:     true,
+		},
+		{
+			name:       "with runtime mismatch not listed in additional buildpacks opts out",
+			envRuntime: "go",
+			additional: "nodejs",
+			wantOut:    true,
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			if tc.envRuntime != "" {
 				t.Setenv(env.Runtime, tc.envRuntime)
 			}
+			t.Setenv(env.AdditionalBuildpacks, tc.additional)
 			got := CheckOverride("python")
 			if got == nil {
 				if tc.wantIn || tc.wantOut {
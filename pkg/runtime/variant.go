@@ -0,0 +1,53 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/version"
+)
+
+// optimizedSuffix is appended to a version of a runtime to name its optimized build in the
+// manifest, e.g. 3.11.4-optimized for a Python interpreter built with --enable-optimizations. As a
+// pre-release suffix, it keeps version constraints from resolving to the optimized build.
+const optimizedSuffix = "-optimized"
+
+// SelectOptimizedVariant resolves the version constraint of a runtime and returns the version of
+// its optimized build if the manifest offers one, or the resolved version otherwise.
+func SelectOptimizedVariant(runtime InstallableRuntime, versionConstraint string) (string, error) {
+	versions, err := Versions(runtime)
+	if err != nil {
+		return "", err
+	}
+	resolved := versionConstraint
+	if !version.IsExactSemver(versionConstraint) {
+		if resolved, err = version.ResolveVersion(versionConstraint, versions); err != nil {
+			return "", gcp.UserErrorf("invalid %s version specified: %v", runtimeNames[runtime], err)
+		}
+	}
+	for _, v := range versions {
+		if v == resolved+optimizedSuffix {
+			return v, nil
+		}
+	}
+	return resolved, nil
+}
+
+// IsOptimizedVariant returns true if the version is the optimized build of a runtime version.
+func IsOptimizedVariant(version string) bool {
+	return strings.HasSuffix(version, optimizedSuffix)
+}
@@ -0,0 +1,83 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/internal/testserver"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+func TestSelectOptimizedVariant(t *testing.T) {
+	testCases := []struct {
+		name     string
+		versions string
+		version  string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "optimized build available",
+			versions: `["3.11.4","3.11.4-optimized","3.12.1"]`,
+			version:  "3.11.4",
+			want:     "3.11.4-optimized",
+		},
+		{
+			name:     "constraint resolved before selecting the optimized build",
+			versions: `["3.11.3","3.11.3-optimized","3.11.4","3.11.4-optimized","3.12.1"]`,
+			version:  "3.11.x",
+			want:     "3.11.4-optimized",
+		},
+		{
+			name:     "falls back without optimized build",
+			versions: `["3.11.3","3.11.3-optimized","3.11.4"]`,
+			version:  "3.11.x",
+			want:     "3.11.4",
+		},
+		{
+			name:     "falls back without any optimized builds",
+			versions: `["3.11.4","3.12.1"]`,
+			version:  "3.12.1",
+			want:     "3.12.1",
+		},
+		{
+			name:     "unsatisfiable constraint",
+			versions: `["3.11.4","3.11.4-optimized"]`,
+			version:  "3.10.x",
+			wantErr:  true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(env.RuntimeLocalDir, "")
+			testserver.New(
+				t,
+				testserver.WithStatus(http.StatusOK),
+				testserver.WithJSON(tc.versions),
+				testserver.WithMockURL(&runtimeVersionsURL),
+			)
+
+			got, err := SelectOptimizedVariant(Python, tc.version)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("SelectOptimizedVariant(%q, %q) got error: %v, want error: %t", Python, tc.version, err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("SelectOptimizedVariant(%q, %q) = %q, want %q", Python, tc.version, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// ReadVersionFile returns the version in a file that only contains a version, such as .nvmrc or
+// .python-version, with surrounding whitespace removed. It returns "" if the file does not exist,
+// and also if it is empty or only contains whitespace, in which case it logs a warning, so that
+// callers fall through to the next source of the version.
+func ReadVersionFile(ctx *gcp.Context, path string) (string, error) {
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", gcp.InternalErrorf("reading %s: %v", path, err)
+	}
+	v := strings.TrimSpace(string(raw))
+	if v == "" {
+		ctx.Warnf("Ignoring %s because it is empty, it should contain a version.", filepath.Base(path))
+	}
+	return v, nil
+}
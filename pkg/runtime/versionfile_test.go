@@ -0,0 +1,86 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+func TestReadVersionFile(t *testing.T) {
+	testCases := []struct {
+		name     string
+		content  *string
+		want     string
+		wantWarn bool
+	}{
+		{
+			name: "missing",
+		},
+		{
+			name:     "empty",
+			content:  stringPtr(""),
+			wantWarn: true,
+		},
+		{
+			name:     "whitespace only",
+			content:  stringPtr("  \n\t\n"),
+			wantWarn: true,
+		},
+		{
+			name:    "valid",
+			content: stringPtr("18.17.1"),
+			want:    "18.17.1",
+		},
+		{
+			name:    "valid with surrounding whitespace",
+			content: stringPtr("\n 3.11\n"),
+			want:    "3.11",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), ".nvmrc")
+			if tc.content != nil {
+				if err := os.WriteFile(path, []byte(*tc.content), 0644); err != nil {
+					t.Fatalf("writing %s: %v", path, err)
+				}
+			}
+			var buf bytes.Buffer
+			ctx := gcp.NewContext(gcp.WithLogger(log.New(&buf, "", 0)))
+
+			got, err := ReadVersionFile(ctx, path)
+			if err != nil {
+				t.Fatalf("ReadVersionFile(ctx, %q) got error: %v", path, err)
+			}
+			if got != tc.want {
+				t.Errorf("ReadVersionFile(ctx, %q)=%q, want %q", path, got, tc.want)
+			}
+			if warned := strings.Contains(buf.String(), "WARNING: Ignoring .nvmrc because it is empty"); warned != tc.wantWarn {
+				t.Errorf("ReadVersionFile(ctx, %q) warned=%t, want %t, output:\n%s", path, warned, tc.wantWarn, buf.String())
+			}
+		})
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
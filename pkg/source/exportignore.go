@@ -0,0 +1,215 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package source contains tools to select the application files that are shipped in the image.
+package source
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	gitAttributesFile = ".gitattributes"
+	exportIgnoreAttr  = "export-ignore"
+)
+
+// attributeRule is a pattern of .gitattributes that sets or unsets export-ignore.
+type attributeRule struct {
+	pattern *regexp.Regexp
+	// basename is true if the pattern has no slash and matches the file name at any depth.
+	basename bool
+	ignore   bool
+}
+
+// ExportIgnore returns the paths, relative to dir, of the files and directories that are marked
+// export-ignore in dir/.gitattributes, i.e. that git excludes from archives. When a directory is
+// ignored, its contents are not listed separately. As in git, the last matching line wins, e.g.
+// "-export-ignore" unsets an earlier "export-ignore". The .git directory and .gitattributes files
+// in subdirectories are ignored.
+func ExportIgnore(dir string) ([]string, error) {
+	rules, err := readExportIgnoreRules(filepath.Join(dir, gitAttributesFile))
+	if err != nil || len(rules) == 0 {
+		return nil, err
+	}
+	var ignored []string
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if !exportIgnored(rules, filepath.ToSlash(rel)) {
+			return nil
+		}
+		ignored = append(ignored, rel)
+		if d.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walking %s: %w", dir, err)
+	}
+	return ignored, nil
+}
+
+// RemoveExportIgnored removes the files and directories marked export-ignore in the .gitattributes
+// of the application, so that they are not shipped in the image. Files are kept in dev mode.
+func RemoveExportIgnored(ctx *gcp.Context) error {
+	if devMode, err := env.IsDevMode(); err == nil && devMode {
+		ctx.Debugf("Keeping export-ignore files in dev mode.")
+		return nil
+	}
+	paths, err := ExportIgnore(ctx.ApplicationRoot())
+	if err != nil {
+		return gcp.InternalErrorf("finding export-ignore files: %v", err)
+	}
+	for _, p := range paths {
+		if err := ctx.RemoveAll(filepath.Join(ctx.ApplicationRoot(), p)); err != nil {
+			return err
+		}
+	}
+	if len(paths) > 0 {
+		ctx.Logf("Removed %d files and directories marked export-ignore in %s.", len(paths), gitAttributesFile)
+		ctx.Debugf("Removed export-ignore files: %v", paths)
+	}
+	return nil
+}
+
+// exportIgnored returns true if the last rule matching the slash-separated relative path sets
+// export-ignore.
+func exportIgnored(rules []attributeRule, rel string) bool {
+	name := rel[strings.LastIndex(rel, "/")+1:]
+	ignored := false
+	for _, r := range rules {
+		target := rel
+		if r.basename {
+			target = name
+		}
+		if r.pattern.MatchString(target) {
+			ignored = r.ignore
+		}
+	}
+	return ignored
+}
+
+// readExportIgnoreRules parses the lines of a .gitattributes file that set or unset export-ignore.
+// It returns no rules if the file does not exist.
+func readExportIgnoreRules(path string) ([]attributeRule, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var rules []attributeRule
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		// Skip blank lines, comments and macro definitions.
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], "[attr]") {
+			continue
+		}
+		for _, attr := range fields[1:] {
+			var ignore bool
+			switch attr {
+			case exportIgnoreAttr:
+				ignore = true
+			case "-" + exportIgnoreAttr, "!" + exportIgnoreAttr:
+				ignore = false
+			default:
+				continue
+			}
+			rule, err := newAttributeRule(fields[0], ignore)
+			if err != nil {
+				return nil, fmt.Errorf("parsing pattern %q in %s: %w", fields[0], path, err)
+			}
+			rules = append(rules, rule)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// newAttributeRule compiles a .gitattributes pattern. As in .gitignore, a pattern without a slash
+// matches the file name at any depth, other patterns are relative to the .gitattributes directory
+// and "**" matches any number of directories.
+func newAttributeRule(pattern string, ignore bool) (attributeRule, error) {
+	pattern = strings.TrimSuffix(pattern, "/")
+	basename := !strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+	var re strings.Builder
+	re.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			re.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "/**") && i+3 == len(pattern):
+			re.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			re.WriteString(".*")
+			i++
+		case c == '*':
+			re.WriteString("[^/]*")
+		case c == '?':
+			re.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				re.WriteString(`\[`)
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			re.WriteString("[" + class + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			re.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	re.WriteString("$")
+	compiled, err := regexp.Compile(re.String())
+	if err != nil {
+		return attributeRule{}, err
+	}
+	return attributeRule{pattern: compiled, basename: basename, ignore: ignore}, nil
+}
@@ -0,0 +1,151 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package source
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+var appFiles = []string{
+	".gitattributes",
+	".github/workflows/ci.yml",
+	"README.md",
+	"docs/index.md",
+	"docs/api/usage.md",
+	"main.go",
+	"main_test.go",
+	"pkg/util.go",
+	"pkg/util_test.go",
+	"pkg/testdata/input.txt",
+	"tests/e2e/run.sh",
+}
+
+func TestExportIgnore(t *testing.T) {
+	testCases := []struct {
+		name          string
+		gitAttributes string
+		want          []string
+	}{
+		{
+			name: "no .gitattributes",
+		},
+		{
+			name:          "no export-ignore",
+			gitAttributes: "*.go text eol=lf\n*.png binary\n",
+		},
+		{
+			name:          "directory",
+			gitAttributes: "/docs export-ignore\n",
+			want:          []string{"docs"},
+		},
+		{
+			name:          "basename pattern at any depth",
+			gitAttributes: "*_test.go export-ignore\ntestdata export-ignore\n",
+			want:          []string{"main_test.go", "pkg/testdata", "pkg/util_test.go"},
+		},
+		{
+			name:          "anchored pattern",
+			gitAttributes: "/main_test.go export-ignore\n",
+			want:          []string{"main_test.go"},
+		},
+		{
+			name:          "double star",
+			gitAttributes: "docs/** export-ignore\n**/e2e export-ignore\n",
+			want:          []string{"docs/api", "docs/index.md", "tests/e2e"},
+		},
+		{
+			name:          "multiple attributes and comments",
+			gitAttributes: "# Excluded from archives.\n.gitattributes export-ignore\n.github/ text export-ignore\n",
+			want:          []string{".gitattributes", ".github"},
+		},
+		{
+			name:          "later unset wins",
+			gitAttributes: "*.md export-ignore\nREADME.md -export-ignore\n",
+			want:          []string{"docs/api/usage.md", "docs/index.md"},
+		},
+		{
+			name:          "export-ignore with a value is not set",
+			gitAttributes: "*.md export-ignore=false\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, appFiles)
+			if tc.gitAttributes != "" {
+				if err := os.WriteFile(filepath.Join(dir, ".gitattributes"), []byte(tc.gitAttributes), 0644); err != nil {
+					t.Fatalf("writing .gitattributes: %v", err)
+				}
+			} else if err := os.Remove(filepath.Join(dir, ".gitattributes")); err != nil {
+				t.Fatalf("removing .gitattributes: %v", err)
+			}
+
+			got, err := ExportIgnore(dir)
+			if err != nil {
+				t.Fatalf("ExportIgnore() got error: %v", err)
+			}
+			sort.Strings(got)
+			var want []string
+			for _, w := range tc.want {
+				want = append(want, filepath.FromSlash(w))
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("ExportIgnore() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRemoveExportIgnored(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, appFiles)
+	if err := os.WriteFile(filepath.Join(dir, ".gitattributes"), []byte("/docs export-ignore\n*_test.go export-ignore\n"), 0644); err != nil {
+		t.Fatalf("writing .gitattributes: %v", err)
+	}
+
+	if err := RemoveExportIgnored(gcp.NewContext(gcp.WithApplicationRoot(dir))); err != nil {
+		t.Fatalf("RemoveExportIgnored() got error: %v", err)
+	}
+
+	for _, f := range []string{"docs", "main_test.go", "pkg/util_test.go"} {
+		if _, err := os.Stat(filepath.Join(dir, f)); !os.IsNotExist(err) {
+			t.Errorf("%s was not removed", f)
+		}
+	}
+	for _, f := range []string{".gitattributes", "README.md", "main.go", "pkg/util.go", "tests/e2e/run.sh"} {
+		if _, err := os.Stat(filepath.Join(dir, f)); err != nil {
+			t.Errorf("%s was removed: %v", f, err)
+		}
+	}
+}
+
+func writeFiles(t *testing.T, dir string, files []string) {
+	t.Helper()
+	for _, f := range files {
+		path := filepath.Join(dir, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("creating directory of %s: %v", f, err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatalf("writing %s: %v", f, err)
+		}
+	}
+}